@@ -0,0 +1,84 @@
+// mockadserver はサーバーサイド広告挿入の動作確認用にVASTを返すローカルの広告判定サーバーです
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	addr := flag.String("addr", ":8090", "待ち受けるアドレス")
+	baseURL := flag.String("base-url", "http://localhost:8090", "VASTに記載する素材とビーコンのURLの基準")
+	mediaPath := flag.String("media", "", "広告素材として配信するmp4ファイル")
+	creativeDuration := flag.Int("duration", 15, "広告素材1本の長さ（秒）")
+	flag.Parse()
+
+	if *mediaPath == "" {
+		log.Fatal("-mediaで広告素材のmp4ファイルを指定してください")
+	}
+
+	mux := http.NewServeMux()
+
+	// 広告枠の長さ（duration）に収まる本数の広告をアドポッドとして返す
+	mux.HandleFunc("/vast", func(w http.ResponseWriter, r *http.Request) {
+		breakDuration, err := strconv.Atoi(r.URL.Query().Get("duration"))
+		if err != nil || breakDuration <= 0 {
+			breakDuration = *creativeDuration
+		}
+		breakID := r.URL.Query().Get("break")
+		count := breakDuration / *creativeDuration
+		log.Printf("広告リクエスト: 広告枠=%s, 長さ=%d秒, 広告数=%d", breakID, breakDuration, count)
+
+		w.Header().Set("Content-Type", "application/xml")
+		fmt.Fprint(w, buildVAST(*baseURL, breakID, count, *creativeDuration))
+	})
+
+	mux.HandleFunc("/media/creative.mp4", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, *mediaPath)
+	})
+
+	mux.HandleFunc("/impression", func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("インプレッション: 広告=%s, 広告枠=%s", r.URL.Query().Get("ad"), r.URL.Query().Get("break"))
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	log.Printf("モック広告サーバーを開始します: %s", *addr)
+	if err := http.ListenAndServe(*addr, mux); err != nil {
+		log.Fatalf("モック広告サーバーの起動に失敗: %v", err)
+	}
+}
+
+func buildVAST(baseURL, breakID string, count, creativeDuration int) string {
+	var builder strings.Builder
+	builder.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	builder.WriteString(`<VAST version="4.0">` + "\n")
+	for i := 1; i <= count; i++ {
+		adID := fmt.Sprintf("mock-ad-%d", i)
+		fmt.Fprintf(&builder, `  <Ad id="%s" sequence="%d">
+    <InLine>
+      <AdSystem>mockadserver</AdSystem>
+      <AdTitle>モック広告%d</AdTitle>
+      <Impression><![CDATA[%s/impression?ad=%s&break=%s]]></Impression>
+      <Creatives>
+        <Creative id="%s-creative">
+          <Linear>
+            <Duration>%02d:%02d:%02d</Duration>
+            <MediaFiles>
+              <MediaFile delivery="progressive" type="video/mp4" width="1280" height="720"><![CDATA[%s/media/creative.mp4]]></MediaFile>
+            </MediaFiles>
+          </Linear>
+        </Creative>
+      </Creatives>
+    </InLine>
+  </Ad>
+`, adID, i, i, baseURL, adID, url.QueryEscape(breakID), adID,
+			creativeDuration/3600, creativeDuration/60%60, creativeDuration%60, baseURL)
+	}
+	builder.WriteString("</VAST>\n")
+	return builder.String()
+}
@@ -3,7 +3,9 @@ package main
 import (
 	"context"
 	"log"
+	"os"
 	"time"
+	_ "time/tzdata"
 
 	"cloud.google.com/go/firestore"
 	"cloud.google.com/go/storage"
@@ -37,12 +39,52 @@ func main() {
 	defer gcsClient.Close()
 
 	scheduleRepo := repository.NewFirestoreScheduleRepository(firestoreClient)
+	assetRepo := repository.NewFirestoreAssetRepository(firestoreClient)
+	recurrenceRepo := repository.NewFirestoreRecurrenceRepository(firestoreClient)
+	templateRepo := repository.NewFirestoreTemplateRepository(firestoreClient)
 	gcsRepo := repository.NewGCSRepository(gcsClient)
 	ffmpegService := media.NewFFmpegService()
 
-	scheduleService := service.NewScheduleService(scheduleRepo)
-	streamingService := service.NewStreamingService(gcsRepo)
-	mediaService := service.NewMediaService(gcsRepo, ffmpegService)
+	clock := domain.SystemClock{}
+
+	scheduleService := service.NewScheduleService(scheduleRepo, assetRepo, recurrenceRepo, cfg.Location, clock)
+	fillerService := service.NewFillerService(assetRepo, cfg.FillerTags, cfg.Location, clock)
+	var adService *service.AdInsertionService
+	if cfg.AdDecisionURL != "" {
+		adService = service.NewAdInsertionService(repository.NewVASTClient(cfg.AdDecisionURL, 5*time.Second), gcsRepo, ffmpegService)
+	}
+	streamingService := service.NewStreamingService(gcsRepo, assetRepo, fillerService, cfg.Shortfall, cfg.Bumper, adService, cfg.Watermark, cfg.Location, clock)
+	assetReferenceService := service.NewAssetReferenceService(scheduleRepo, recurrenceRepo, templateRepo, cfg.Bumper, cfg.ReadinessLookahead, cfg.Location, clock)
+	mediaService := service.NewMediaService(gcsRepo, assetRepo, ffmpegService, cfg.Watermark, assetReferenceService)
+	readinessService := service.NewReadinessService(scheduleService, assetRepo, gcsRepo, cfg.Bumper, cfg.ReadinessLookahead, cfg.Location, clock)
+	templateService := service.NewTemplateService(templateRepo, scheduleService)
+	autoProgramService := service.NewAutoProgramService(scheduleService, assetRepo)
+	interstitialService := service.NewInterstitialService(gcsRepo, assetRepo, adService)
+
+	var sessionStore domain.SessionStore = repository.NewInMemorySessionStore()
+	if cfg.SessionStore == "firestore" {
+		sessionStore = repository.NewFirestoreSessionStore(firestoreClient)
+	}
+	// 視聴者の記録は流出元の特定に使用するため、セッションのストアによらずFirestoreに保持する
+	viewerStore := repository.NewFirestoreSessionViewerStore(firestoreClient)
+	sessionService := service.NewSessionService(sessionStore, viewerStore, cfg.SessionTTL, clock)
+	playbackService := service.NewPlaybackTokenService(cfg.PlaybackTokenSecret, cfg.ChannelID, cfg.PlaybackTokenTTL, clock)
+	authService, err := initAuth(cfg, clock)
+	if err != nil {
+		log.Fatalf("認証の初期化に失敗: %v", err)
+	}
+	if !authService.Enabled() {
+		log.Println("警告: AUTH_DISABLED=trueが指定されているため、管理APIを認証なしで公開します")
+	}
+
+	var auditRepo domain.AuditRepository = repository.NewFirestoreAuditRepository(firestoreClient)
+	if cfg.AuditStore == "memory" {
+		auditRepo = repository.NewInMemoryAuditRepository()
+	}
+	auditService := service.NewAuditService(auditRepo, clock)
+	if err := auditService.RecordConfig(ctx, cfg.AuditSnapshot()); err != nil {
+		log.Printf("設定の監査ログの記録に失敗: %v", err)
+	}
 
 	if err := scheduleService.RefreshFromRepository(ctx); err != nil {
 		log.Printf("初回番組表読み込みに失敗: %v", err)
@@ -50,12 +92,16 @@ func main() {
 	}
 
 	go scheduleService.StartPeriodicRefresh(ctx, 5*time.Minute)
+	go readinessService.StartPeriodicCheck(ctx, 15*time.Minute)
+	go fillerService.StartPeriodicRefresh(ctx, 5*time.Minute)
+	go sessionService.StartPeriodicCleanup(ctx, cfg.SessionTTL)
 
-	httpHandler := handler.NewHTTPHandler(scheduleService, streamingService, mediaService)
+	httpHandler := handler.NewHTTPHandler(scheduleService, streamingService, mediaService, readinessService, templateService, fillerService, autoProgramService, interstitialService, sessionService, playbackService, authService, auditService)
 
 	router := gin.Default()
 	httpHandler.SetupRoutes(router)
 
+	log.Printf("チャンネルのタイムゾーン: %s", cfg.Location)
 	log.Printf("サーバーを開始します: http://0.0.0.0:%s", cfg.Port)
 	if err := router.Run("0.0.0.0:" + cfg.Port); err != nil {
 		log.Fatalf("サーバーの起動に失敗: %v", err)
@@ -77,3 +123,28 @@ func initGCS(ctx context.Context) (*storage.Client, error) {
 	}
 	return client, nil
 }
+
+func initAuth(cfg *config.Config, clock domain.Clock) (*service.AuthService, error) {
+	authenticators := make([]domain.Authenticator, 0)
+	if len(cfg.AuthAPIKeys) > 0 {
+		authenticators = append(authenticators, service.NewAPIKeyAuthenticator(cfg.AuthAPIKeys))
+	}
+	if cfg.AuthJWKSFile != "" {
+		data, err := os.ReadFile(cfg.AuthJWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		jwks, err := domain.ParseJWKS(data)
+		if err != nil {
+			return nil, err
+		}
+		verifier := domain.JWTVerifier{
+			Keys:       jwks,
+			Issuer:     cfg.AuthJWTIssuer,
+			Audience:   cfg.AuthJWTAudience,
+			RolesClaim: cfg.AuthJWTRolesClaim,
+		}
+		authenticators = append(authenticators, service.NewJWTAuthenticator(verifier, clock))
+	}
+	return service.NewAuthService(authenticators...), nil
+}
@@ -0,0 +1,108 @@
+// watermarktrace は流出した録画から読み取ったA/Bの透かしの並びから、配信したセッションと視聴者を特定します
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/firestore"
+	"github.com/genki0524/hls_striming_go/internal/domain"
+	"github.com/genki0524/hls_striming_go/internal/repository"
+)
+
+func main() {
+	secret := flag.String("secret", os.Getenv("WATERMARK_SECRET"), "配信時と同じWATERMARK_SECRET")
+	sessionsPath := flag.String("sessions", "", "候補のセッションIDを1行に1つずつ記載したファイル（アクセスログのsidなど）")
+	observedList := flag.String("observed", "", "素材のセグメント番号と透かしのカンマ区切り（例: 0:a,1:b,2:b）")
+	top := flag.Int("top", 5, "表示する候補の数")
+	projectID := flag.String("project", os.Getenv("PROJECT_ID"), "セッションの視聴者の記録を参照するFirestoreのプロジェクトID（省略時は視聴者を表示しません）")
+	flag.Parse()
+
+	if *secret == "" || *sessionsPath == "" || *observedList == "" {
+		log.Fatal("-secret、-sessions、-observedを指定してください")
+	}
+
+	candidates, err := readSessions(*sessionsPath)
+	if err != nil {
+		log.Fatalf("セッションIDの読み込みに失敗: %v", err)
+	}
+	observed, err := parseObserved(*observedList)
+	if err != nil {
+		log.Fatalf("-observedが不正です: %v", err)
+	}
+
+	var viewers domain.SessionViewerStore
+	if *projectID != "" {
+		client, err := firestore.NewClient(context.Background(), *projectID)
+		if err != nil {
+			log.Fatalf("Firestoreクライアントの初期化に失敗: %v", err)
+		}
+		defer client.Close()
+		viewers = repository.NewFirestoreSessionViewerStore(client)
+	}
+
+	matches := domain.Watermark{Secret: *secret}.Match(candidates, observed)
+	for i, match := range matches {
+		if i >= *top {
+			break
+		}
+		fmt.Printf("%s\t%d/%d%s\n", match.SessionID, match.Matched, match.Compared, sessionViewers(viewers, match.SessionID))
+	}
+}
+
+// sessionViewers はセッションで再生を許可した視聴者のIDをタブ区切りの列として返します
+func sessionViewers(store domain.SessionViewerStore, sessionID string) string {
+	if store == nil {
+		return ""
+	}
+	records, err := store.ListSessionViewers(context.Background(), sessionID)
+	if err != nil {
+		log.Printf("セッション(%s)の視聴者の取得に失敗: %v", sessionID, err)
+		return "\t?"
+	}
+
+	ids := make([]string, 0, len(records))
+	for _, record := range records {
+		ids = append(ids, record.Viewer)
+	}
+	return "\t" + strings.Join(ids, ",")
+}
+
+func readSessions(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	sessions := make([]string, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			sessions = append(sessions, line)
+		}
+	}
+	return sessions, scanner.Err()
+}
+
+func parseObserved(list string) (map[int]string, error) {
+	observed := make(map[int]string)
+	for _, entry := range strings.Split(list, ",") {
+		index, variant, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || (variant != domain.WatermarkVariantA && variant != domain.WatermarkVariantB) {
+			return nil, fmt.Errorf("セグメント番号:aまたはbの形式で指定してください: %s", entry)
+		}
+		segmentIndex, err := strconv.Atoi(index)
+		if err != nil || segmentIndex < 0 {
+			return nil, fmt.Errorf("セグメント番号が不正です: %s", entry)
+		}
+		observed[segmentIndex] = variant
+	}
+	return observed, nil
+}
@@ -0,0 +1,404 @@
+package domain
+
+import (
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AdBreak は番組内の広告枠です。Atを指定した場合はその時刻に、
+// 指定しない場合は番組開始からOffset秒の位置に広告枠を設けます
+type AdBreak struct {
+	ID          string  `firestore:"id" json:"id,omitempty"`
+	Offset      float64 `firestore:"offset" json:"offset"`
+	At          string  `firestore:"at" json:"at,omitempty"`
+	DurationSec float64 `firestore:"duration_sec" json:"duration_sec"`
+	// Interstitial がtrueの場合は広告をセグメントに挿入せず、HLS Interstitialsとしてプレイヤーに再生させます
+	Interstitial bool `firestore:"interstitial" json:"interstitial,omitempty"`
+}
+
+type CueType string
+
+const (
+	CueOut          CueType = "out"
+	CueIn           CueType = "in"
+	CueInterstitial CueType = "interstitial"
+)
+
+// dateRangeTimeFormat はEXT-X-DATERANGEやEXT-X-PROGRAM-DATE-TIMEで使用する時刻の形式です
+const dateRangeTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// Cue はセグメントの直前に付ける広告枠の開始・終了の合図です
+type Cue struct {
+	Type CueType
+	ID   string
+	// StartDate は広告枠の開始時刻です（ゼロ値の場合はEXT-X-DATERANGEを出力しません）
+	StartDate time.Time
+	// Duration はCUE-OUTでは予定の長さ、CUE-INでは実際の長さです
+	Duration float64
+	// SCTE35 はSCTE-35のsplice_info_sectionの16進数表記（0x...）です
+	SCTE35 string
+
+	// AssetURI、AssetList、Restrict はインタースティシャルのX-ASSET-URI、X-ASSET-LIST、X-RESTRICTです
+	AssetURI  string
+	AssetList string
+	Restrict  string
+}
+
+// Lines はCueをEXT-X-DATERANGEとEXT-X-CUE-OUT/EXT-X-CUE-INの行に変換します
+func (c Cue) Lines() []string {
+	if c.Type == CueInterstitial {
+		return c.interstitialLines()
+	}
+
+	lines := make([]string, 0, 2)
+	if c.ID != "" && !c.StartDate.IsZero() {
+		attributes := []string{
+			fmt.Sprintf("ID=%q", c.ID),
+			fmt.Sprintf("START-DATE=%q", c.StartDate.Format(dateRangeTimeFormat)),
+		}
+		switch c.Type {
+		case CueOut:
+			if c.Duration > 0 {
+				attributes = append(attributes, fmt.Sprintf("PLANNED-DURATION=%.3f", c.Duration))
+			}
+			if c.SCTE35 != "" {
+				attributes = append(attributes, "SCTE35-OUT="+c.SCTE35)
+			}
+		case CueIn:
+			if c.Duration > 0 {
+				attributes = append(attributes, fmt.Sprintf("DURATION=%.3f", c.Duration))
+			}
+			if c.SCTE35 != "" {
+				attributes = append(attributes, "SCTE35-IN="+c.SCTE35)
+			}
+		}
+		lines = append(lines, "#EXT-X-DATERANGE:"+strings.Join(attributes, ","))
+	}
+
+	switch c.Type {
+	case CueOut:
+		if c.Duration > 0 {
+			lines = append(lines, fmt.Sprintf("#EXT-X-CUE-OUT:DURATION=%.3f", c.Duration))
+		} else {
+			lines = append(lines, "#EXT-X-CUE-OUT")
+		}
+	case CueIn:
+		lines = append(lines, "#EXT-X-CUE-IN")
+	}
+	return lines
+}
+
+// validate は広告枠の指定を検証します
+func (b AdBreak) validate(program *ProgramItem) error {
+	if b.DurationSec <= 0 {
+		return fmt.Errorf("長さは0秒より大きい値を指定してください")
+	}
+	return validateCuePosition(b.Offset, b.At, program)
+}
+
+// validateCuePosition は番組開始からの秒数（offset）または時刻（at）による位置の指定を検証します
+func validateCuePosition(offset float64, at string, program *ProgramItem) error {
+	if at != "" {
+		if offset != 0 {
+			return fmt.Errorf("offsetとatは同時に指定できません")
+		}
+		if _, err := time.Parse(time.RFC3339, at); err != nil {
+			return fmt.Errorf("atがRFC3339形式ではありません: %q", at)
+		}
+		// 番組の開始時刻の誤りは別に報告する
+		programStart, err := program.GetStartTime()
+		if err != nil {
+			return nil
+		}
+		offset, _ = cueOffset(0, at, programStart)
+	}
+
+	if offset < 0 || (program.DurationSec > 0 && offset >= float64(program.DurationSec)) {
+		return fmt.Errorf("開始位置が番組の放送時間の範囲外です: 番組開始から%.1f秒", offset)
+	}
+	return nil
+}
+
+// cueOffset は番組開始から指定した位置までの秒数を返します
+func cueOffset(offset float64, at string, programStart time.Time) (float64, error) {
+	if at == "" {
+		return offset, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, at)
+	if err != nil {
+		return 0, err
+	}
+	return parsed.Sub(programStart).Seconds(), nil
+}
+
+// cueID は広告枠などのIDを返します（指定されていない場合は番組IDと種類、順番から生成します）
+func cueID(id string, program *ProgramItem, kind string, index int) string {
+	if id != "" {
+		return id
+	}
+	return fmt.Sprintf("%s-%s%d", program.ID, kind, index)
+}
+
+// spliceEventID は広告枠のIDからSCTE-35のsplice_event_idを生成します
+func spliceEventID(id string) uint32 {
+	hash := fnv.New32a()
+	hash.Write([]byte(id))
+	return hash.Sum32()
+}
+
+// placeCues は番組の広告枠とインタースティシャルをセグメントの境目に合わせて配置します。
+// 広告枠の開始・終了位置以降で最初に始まるセグメントにCUE-OUT/CUE-INを付け、
+// 放送時間内に終了しない広告枠にはCUE-INを付けません。
+// インタースティシャルとして再生する広告枠にはCUE-OUT/CUE-INの代わりにEXT-X-DATERANGEを付けます
+func (p *M3U8Playlist) placeCues(program *ProgramItem, programStart time.Time) {
+	if len(program.AdBreaks) == 0 && len(program.Interstitials) == 0 {
+		return
+	}
+
+	starts := p.segmentStarts()
+	boundary := func(offset float64) int {
+		for i, start := range starts {
+			if start >= offset-timelineEpsilon {
+				return i
+			}
+		}
+		return len(starts)
+	}
+
+	startDateAt := func(index int) time.Time {
+		return programStart.Add(time.Duration(starts[index] * float64(time.Second)))
+	}
+
+	// 素材から引き継いだ合図に加えるため、元のプレイリストと共有しないようコピーする
+	p.cues = maps.Clone(p.cues)
+	if p.cues == nil {
+		p.cues = make(map[int][]Cue)
+	}
+	for i, interstitial := range program.Interstitials {
+		offset, err := cueOffset(interstitial.Offset, interstitial.At, programStart)
+		if err != nil {
+			continue
+		}
+		index := boundary(offset)
+		if index >= len(starts) {
+			continue
+		}
+		id := cueID(interstitial.ID, program, "interstitial", i)
+		p.cues[index] = append(p.cues[index], interstitial.cue(id, startDateAt(index)))
+	}
+
+	for i, adBreak := range program.AdBreaks {
+		offset, err := cueOffset(adBreak.Offset, adBreak.At, programStart)
+		if err != nil || adBreak.DurationSec <= 0 {
+			continue
+		}
+		outIndex := boundary(offset)
+		if outIndex >= len(starts) {
+			continue
+		}
+
+		id := cueID(adBreak.ID, program, "break", i)
+		startDate := startDateAt(outIndex)
+		if adBreak.Interstitial {
+			p.cues[outIndex] = append(p.cues[outIndex], Cue{
+				Type:      CueInterstitial,
+				ID:        id,
+				StartDate: startDate,
+				Duration:  adBreak.DurationSec,
+				AssetList: AdAssetListURI(AdRequest{BreakID: id, ProgramID: program.ID, DurationSec: adBreak.DurationSec, StartDate: startDate}),
+				Restrict:  "SKIP,JUMP",
+			})
+			continue
+		}
+
+		eventID := spliceEventID(id)
+		p.cues[outIndex] = append(p.cues[outIndex], Cue{
+			Type:      CueOut,
+			ID:        id,
+			StartDate: startDate,
+			Duration:  adBreak.DurationSec,
+			SCTE35:    SCTE35Hex(EncodeSpliceInsert(eventID, true, adBreak.DurationSec)),
+		})
+
+		inIndex := boundary(starts[outIndex] + adBreak.DurationSec)
+		if inIndex >= len(starts) {
+			continue
+		}
+		p.cues[inIndex] = append(p.cues[inIndex], Cue{
+			Type:      CueIn,
+			ID:        id,
+			StartDate: startDate,
+			Duration:  starts[inIndex] - starts[outIndex],
+			SCTE35:    SCTE35Hex(EncodeSpliceInsert(eventID, false, 0)),
+		})
+	}
+}
+
+// rebaseCarriedCues は素材のプレイリストから引き継いだ広告枠の開始時刻を放送時刻（番組開始＋セグメントの開始位置）に置き換えます。
+// 素材のSTART-DATEは素材を作成した時点の時刻のため、そのままでは放送回ごとに広告枠を区別できません。
+// ループする番組では周回ごとに異なる時刻になるよう、合図をセグメントのインデックスごとに付け直します
+func (p *M3U8Playlist) rebaseCarriedCues(programStart time.Time) {
+	if !hasSegmentCues(p.Segments) && !hasSegmentCues(p.tail) {
+		return
+	}
+
+	starts := p.segmentStarts()
+	cues := make(map[int][]Cue)
+	outStarts := make(map[string]time.Time)
+	for i, start := range starts {
+		for _, cue := range p.SegmentAt(i).Cues {
+			if cue.ID != "" {
+				airing := programStart.Add(time.Duration(start * float64(time.Second)))
+				if cue.Type == CueIn {
+					// CUE-INのEXT-X-DATERANGEは同じIDのCUE-OUTと同じ開始時刻にする
+					if outStart, ok := outStarts[cue.ID]; ok {
+						airing = outStart
+					} else {
+						airing = airing.Add(-time.Duration(cue.Duration * float64(time.Second)))
+					}
+				} else {
+					outStarts[cue.ID] = airing
+				}
+				cue.StartDate = airing
+			}
+			cues[i] = append(cues[i], cue)
+		}
+	}
+
+	p.Segments = withoutCues(p.Segments)
+	p.tail = withoutCues(p.tail)
+	p.cues = cues
+}
+
+func hasSegmentCues(segments []M3U8Segment) bool {
+	for _, segment := range segments {
+		if len(segment.Cues) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutCues は合図を外したセグメントのコピーを返します
+func withoutCues(segments []M3U8Segment) []M3U8Segment {
+	if segments == nil {
+		return nil
+	}
+	result := make([]M3U8Segment, len(segments))
+	for i, segment := range segments {
+		segment.Cues = nil
+		result[i] = segment
+	}
+	return result
+}
+
+// segmentStarts は番組開始から各セグメントの開始までの秒数を返します
+func (p *M3U8Playlist) segmentStarts() []float64 {
+	starts := make([]float64, p.Len())
+	position := -p.StartOffset
+	for i := range starts {
+		starts[i] = position
+		position += p.SegmentAt(i).Duration
+	}
+	return starts
+}
+
+// SegmentStart は番組開始からindex番目のセグメントの開始までの秒数を返します
+// （EXT-X-PROGRAM-DATE-TIMEに使用します）
+func (p *M3U8Playlist) SegmentStart(index int) float64 {
+	position := -p.StartOffset
+	for i := 0; i < index && i < p.Len(); i++ {
+		position += p.SegmentAt(i).Duration
+	}
+	return position
+}
+
+// ProgramDateTimeTag はEXT-X-PROGRAM-DATE-TIMEタグを返します
+func ProgramDateTimeTag(t time.Time) string {
+	return "#EXT-X-PROGRAM-DATE-TIME:" + t.Format(dateRangeTimeFormat)
+}
+
+// parseAttributeList はタグの属性リスト（KEY=VALUE,KEY="VALUE"）を解釈します
+func parseAttributeList(list string) map[string]string {
+	attributes := make(map[string]string)
+	for len(list) > 0 {
+		eq := strings.IndexByte(list, '=')
+		if eq < 0 {
+			break
+		}
+		key := strings.TrimSpace(list[:eq])
+		list = list[eq+1:]
+
+		var value string
+		if strings.HasPrefix(list, "\"") {
+			end := strings.IndexByte(list[1:], '"')
+			if end < 0 {
+				value, list = list[1:], ""
+			} else {
+				value, list = list[1:end+1], list[end+2:]
+			}
+		} else if comma := strings.IndexByte(list, ','); comma >= 0 {
+			value, list = list[:comma], list[comma:]
+		} else {
+			value, list = list, ""
+		}
+		attributes[key] = value
+		list = strings.TrimPrefix(list, ",")
+	}
+	return attributes
+}
+
+// parseCueTag は素材のプレイリストに含まれる広告枠のタグを解釈し、次のセグメントに付けるCueに反映します。
+// 広告枠のタグでない場合はfalseを返します
+func parseCueTag(line string, pending map[CueType]*Cue) bool {
+	cueFor := func(cueType CueType) *Cue {
+		if pending[cueType] == nil {
+			pending[cueType] = &Cue{Type: cueType}
+		}
+		return pending[cueType]
+	}
+
+	switch {
+	case strings.HasPrefix(line, "#EXT-X-CUE-OUT-CONT"):
+		// 広告枠の途中を示すタグは配信時の位置と一致しないため引き継がない
+	case strings.HasPrefix(line, "#EXT-X-CUE-OUT"):
+		cue := cueFor(CueOut)
+		value := strings.TrimPrefix(strings.TrimPrefix(line, "#EXT-X-CUE-OUT"), ":")
+		value = strings.TrimPrefix(value, "DURATION=")
+		if duration, err := strconv.ParseFloat(value, 64); err == nil {
+			cue.Duration = duration
+		}
+	case strings.HasPrefix(line, "#EXT-X-CUE-IN"):
+		cueFor(CueIn)
+	case strings.HasPrefix(line, "#EXT-X-DATERANGE:"):
+		attributes := parseAttributeList(strings.TrimPrefix(line, "#EXT-X-DATERANGE:"))
+		var cue *Cue
+		if value, ok := attributes["SCTE35-OUT"]; ok {
+			cue = cueFor(CueOut)
+			cue.SCTE35 = value
+			if duration, err := strconv.ParseFloat(attributes["PLANNED-DURATION"], 64); err == nil {
+				cue.Duration = duration
+			}
+		} else if value, ok := attributes["SCTE35-IN"]; ok {
+			cue = cueFor(CueIn)
+			cue.SCTE35 = value
+			if duration, err := strconv.ParseFloat(attributes["DURATION"], 64); err == nil {
+				cue.Duration = duration
+			}
+		} else {
+			// 広告枠以外のEXT-X-DATERANGEは引き継がない
+			return true
+		}
+		cue.ID = attributes["ID"]
+		if startDate, err := time.Parse(time.RFC3339Nano, attributes["START-DATE"]); err == nil {
+			cue.StartDate = startDate
+		}
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,117 @@
+package domain
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+var (
+	ErrAssetNotFound = errors.New("アセットが見つかりません")
+	ErrAssetInUse    = errors.New("アセットは番組表などから参照されています")
+)
+
+// アセットを参照しているものの種類です
+const (
+	AssetReferenceProgram      = "program"
+	AssetReferenceInterstitial = "interstitial"
+	AssetReferenceRecurrence   = "recurrence"
+	AssetReferenceTemplate     = "template"
+	AssetReferenceBumper       = "bumper"
+)
+
+// AssetReference はアセットを参照している番組・繰り返し番組・テンプレート・設定です
+type AssetReference struct {
+	Kind      string `json:"kind"`
+	ID        string `json:"id,omitempty"`
+	Date      string `json:"date,omitempty"`
+	StartTime string `json:"start_time,omitempty"`
+	Title     string `json:"title,omitempty"`
+}
+
+// AssetInUseError は参照されているアセットを削除しようとした場合のエラーです
+type AssetInUseError struct {
+	References []AssetReference
+}
+
+func (e *AssetInUseError) Error() string {
+	return fmt.Sprintf("%s（%d件）", ErrAssetInUse.Error(), len(e.References))
+}
+
+func (e *AssetInUseError) Unwrap() error {
+	return ErrAssetInUse
+}
+
+// Rendition はアセットに含まれる1つのHLSプレイリストを表します
+type Rendition struct {
+	Name       string `firestore:"name" json:"name"`
+	Playlist   string `firestore:"playlist" json:"playlist"`
+	Bandwidth  int    `firestore:"bandwidth" json:"bandwidth"`
+	Resolution string `firestore:"resolution" json:"resolution"`
+}
+
+// Asset は放送日から独立して管理される動画素材です
+type Asset struct {
+	ID            string      `firestore:"id" json:"id"`
+	Title         string      `firestore:"title" json:"title"`
+	DurationSec   float64     `firestore:"duration_sec" json:"duration_sec"`
+	Renditions    []Rendition `firestore:"renditions" json:"renditions"`
+	StoragePrefix string      `firestore:"storage_prefix" json:"storage_prefix"`
+	Tags          []string    `firestore:"tags" json:"tags"`
+	CreatedAt     time.Time   `firestore:"created_at" json:"created_at"`
+	// Watermarked はセグメントごとにA/Bの透かし入りの素材があることを表します
+	Watermarked bool `firestore:"watermarked" json:"watermarked,omitempty"`
+}
+
+type AssetRepository interface {
+	ListAssets(ctx context.Context) ([]Asset, error)
+	GetAsset(ctx context.Context, id string) (*Asset, error)
+	CreateAsset(ctx context.Context, asset Asset) error
+	UpdateAsset(ctx context.Context, asset Asset) error
+	DeleteAsset(ctx context.Context, id string) error
+}
+
+// AssetPatch はアセットの部分更新リクエストです
+type AssetPatch struct {
+	Title *string   `json:"title"`
+	Tags  *[]string `json:"tags"`
+}
+
+// NewID はアセットや番組に付与するランダムなIDを生成します
+func NewID() string {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// MainPlaylistPath はアセットの代表プレイリストのオブジェクトパスを返します
+func (a *Asset) MainPlaylistPath() string {
+	if len(a.Renditions) > 0 && a.Renditions[0].Playlist != "" {
+		return a.StoragePrefix + "/" + a.Renditions[0].Playlist
+	}
+	return a.StoragePrefix + "/video.m3u8"
+}
+
+// ProgramAssetReferences は番組（ブロック番組の各アセットとインタースティシャルを含む）がアセットを参照している場合に参照元を返します
+func ProgramAssetReferences(program *ProgramItem, date, assetID string) []AssetReference {
+	references := make([]AssetReference, 0)
+	reference := AssetReference{ID: program.ID, Date: date, StartTime: program.StartTime, Title: program.Title}
+	if slices.Contains(program.AssetIDs(), assetID) {
+		reference.Kind = AssetReferenceProgram
+		references = append(references, reference)
+	}
+	for _, interstitial := range program.Interstitials {
+		if interstitial.AssetID == assetID || slices.Contains(interstitial.Assets, assetID) {
+			reference.Kind = AssetReferenceInterstitial
+			references = append(references, reference)
+			break
+		}
+	}
+	return references
+}
@@ -0,0 +1,177 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// AuditAction は監査ログに記録する変更の種類です
+type AuditAction string
+
+const (
+	AuditProgramCreate    AuditAction = "program.create"
+	AuditProgramUpdate    AuditAction = "program.update"
+	AuditProgramDelete    AuditAction = "program.delete"
+	AuditScheduleRefresh  AuditAction = "schedule.refresh"
+	AuditAssetUpload      AuditAction = "asset.upload"
+	AuditAssetCreate      AuditAction = "asset.create"
+	AuditAssetUpdate      AuditAction = "asset.update"
+	AuditAssetDelete      AuditAction = "asset.delete"
+	AuditRecurrenceCreate AuditAction = "recurrence.create"
+	AuditRecurrenceUpdate AuditAction = "recurrence.update"
+	AuditRecurrenceDelete AuditAction = "recurrence.delete"
+	AuditTemplateCreate   AuditAction = "template.create"
+	AuditTemplateUpdate   AuditAction = "template.update"
+	AuditTemplateDelete   AuditAction = "template.delete"
+	AuditTemplateApply    AuditAction = "template.apply"
+	AuditAutoProgram      AuditAction = "auto_program.apply"
+	// AuditConfigUpdate は起動時の設定（環境変数）が前回の起動から変わったことを表します
+	AuditConfigUpdate AuditAction = "config.update"
+)
+
+// AuditSystemActor は利用者の操作によらない変更（起動時の設定など）の実行者です
+const AuditSystemActor = "system"
+
+// AuditActor は変更を行った利用者です
+type AuditActor struct {
+	// Subject はAPIキーの名前またはJWTのsubです（管理APIの認証が無効な場合は anonymous）
+	Subject string
+	// Method は認証方式です
+	Method   string
+	SourceIP string
+}
+
+// AuditEntry は監査ログの1件です。Before、After は変更前後の内容（JSON）です
+type AuditEntry struct {
+	ID         string          `json:"id"`
+	Time       time.Time       `json:"time"`
+	Actor      string          `json:"actor"`
+	AuthMethod string          `json:"auth_method,omitempty"`
+	SourceIP   string          `json:"source_ip,omitempty"`
+	Action     AuditAction     `json:"action"`
+	Target     string          `json:"target"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+}
+
+// ChangeRecorder は番組・繰り返し番組・テンプレート・アセットの変更を保存した直後（番組表のリフレッシュより前）に、
+// 変更前後の内容を受け取ります（作成の場合はbefore、削除の場合はafterがnilです）。エラーを返しても変更は取り消されません
+type ChangeRecorder[T any] func(before, after *T) error
+
+// Record は変更を渡します。recorderがnilの場合は何もせず、失敗した場合は*ChangeNotRecordedErrorを返します
+func (r ChangeRecorder[T]) Record(before, after *T) error {
+	if r == nil {
+		return nil
+	}
+	if err := r(before, after); err != nil {
+		return &ChangeNotRecordedError{Err: err}
+	}
+	return nil
+}
+
+// ChangeNotRecordedError は変更は保存されたものの、変更の記録（監査ログ）に失敗したことを表します
+type ChangeNotRecordedError struct {
+	Err error
+}
+
+func (e *ChangeNotRecordedError) Error() string {
+	return "変更は保存されましたが、" + e.Err.Error()
+}
+
+func (e *ChangeNotRecordedError) Unwrap() error {
+	return e.Err
+}
+
+// NewAuditEntry は監査ログの1件を作成します。before、afterがnilの場合は記録しません
+func NewAuditEntry(now time.Time, actor AuditActor, action AuditAction, target string, before, after any) (AuditEntry, error) {
+	entry := AuditEntry{
+		ID:         NewID(),
+		Time:       now,
+		Actor:      actor.Subject,
+		AuthMethod: actor.Method,
+		SourceIP:   actor.SourceIP,
+		Action:     action,
+		Target:     target,
+	}
+
+	var err error
+	if entry.Before, err = auditJSON(before); err != nil {
+		return AuditEntry{}, fmt.Errorf("変更前の内容の変換に失敗: %w", err)
+	}
+	if entry.After, err = auditJSON(after); err != nil {
+		return AuditEntry{}, fmt.Errorf("変更後の内容の変換に失敗: %w", err)
+	}
+	return entry, nil
+}
+
+func auditJSON(value any) (json.RawMessage, error) {
+	data, err := json.Marshal(value)
+	if err != nil || string(data) == "null" {
+		return nil, err
+	}
+	return data, nil
+}
+
+// ProgramChanges は番組表の再読み込みで追加・削除・変更された番組のIDです
+type ProgramChanges struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Changed []string `json:"changed"`
+}
+
+// DiffPrograms はbeforeからafterへの番組の変更を番組IDごとに返します
+func DiffPrograms(before, after []ProgramItem) ProgramChanges {
+	changes := ProgramChanges{Added: []string{}, Removed: []string{}, Changed: []string{}}
+	previous := make(map[string]ProgramItem, len(before))
+	for _, program := range before {
+		previous[program.ID] = program
+	}
+
+	current := make(map[string]bool, len(after))
+	for _, program := range after {
+		current[program.ID] = true
+		old, ok := previous[program.ID]
+		switch {
+		case !ok:
+			changes.Added = append(changes.Added, program.ID)
+		case !reflect.DeepEqual(old, program):
+			changes.Changed = append(changes.Changed, program.ID)
+		}
+	}
+	for _, program := range before {
+		if !current[program.ID] {
+			changes.Removed = append(changes.Removed, program.ID)
+		}
+	}
+	return changes
+}
+
+// AuditQuery は監査ログの検索条件です（空の条件は絞り込みません）
+type AuditQuery struct {
+	From   time.Time
+	To     time.Time
+	Actor  string
+	Action AuditAction
+	Target string
+	// Limit は返す件数の上限です（新しい順）
+	Limit int
+}
+
+// Matches は監査ログが検索条件に一致するかどうかを返します
+func (q AuditQuery) Matches(entry AuditEntry) bool {
+	return (q.From.IsZero() || !entry.Time.Before(q.From)) &&
+		(q.To.IsZero() || entry.Time.Before(q.To)) &&
+		(q.Actor == "" || entry.Actor == q.Actor) &&
+		(q.Action == "" || entry.Action == q.Action) &&
+		(q.Target == "" || entry.Target == q.Target)
+}
+
+// AuditRepository は監査ログの保存先です。記録した監査ログは変更・削除できません
+type AuditRepository interface {
+	AppendAuditEntry(ctx context.Context, entry AuditEntry) error
+	// QueryAuditEntries は条件に一致する監査ログを新しい順に返します
+	QueryAuditEntries(ctx context.Context, query AuditQuery) ([]AuditEntry, error)
+}
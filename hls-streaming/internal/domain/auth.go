@@ -0,0 +1,105 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var (
+	// ErrNoCredentials は認証方式に対応する認証情報がリクエストに含まれていないことを表します
+	ErrNoCredentials   = errors.New("認証情報がありません")
+	ErrUnauthenticated = errors.New("認証に失敗しました")
+	ErrForbidden       = errors.New("権限がありません")
+)
+
+// Role は管理APIの操作権限です
+type Role string
+
+const (
+	// RoleViewer は番組表や素材の参照、再生トークンの発行ができます
+	RoleViewer Role = "viewer"
+	// RoleScheduler は番組表・繰り返し番組・テンプレートを編集できます
+	RoleScheduler Role = "scheduler"
+	// RoleUploader は動画のアップロードと素材の編集ができます
+	RoleUploader Role = "uploader"
+	// RoleAdmin はすべての操作ができます
+	RoleAdmin Role = "admin"
+)
+
+func ParseRole(value string) (Role, error) {
+	switch role := Role(strings.TrimSpace(value)); role {
+	case RoleViewer, RoleScheduler, RoleUploader, RoleAdmin:
+		return role, nil
+	default:
+		return "", fmt.Errorf("不明なロールです: %s", value)
+	}
+}
+
+// Principal は認証されたリクエストの利用者です
+type Principal struct {
+	// Subject はAPIキーの名前またはJWTのsubです
+	Subject string `json:"subject"`
+	Roles   []Role `json:"roles"`
+	// Method は認証方式です（api_key または jwt）
+	Method string `json:"method"`
+}
+
+// HasRole はroleの操作を許可するかどうかを返します。
+// adminはすべての操作を、いずれかのロールを持つ利用者はviewerの操作を許可します
+func (p *Principal) HasRole(role Role) bool {
+	if slices.Contains(p.Roles, RoleAdmin) {
+		return true
+	}
+	if role == RoleViewer {
+		return len(p.Roles) > 0
+	}
+	return slices.Contains(p.Roles, role)
+}
+
+// Credentials はリクエストに含まれる認証情報です
+type Credentials struct {
+	// APIKey はX-API-Keyヘッダーの値です
+	APIKey string
+	// BearerToken はAuthorizationヘッダー（Bearer）のトークンです
+	BearerToken string
+}
+
+// Authenticator は認証方式です。認証情報が自身の方式のものでない場合はErrNoCredentialsを返します
+type Authenticator interface {
+	Authenticate(ctx context.Context, credentials Credentials) (*Principal, error)
+}
+
+// APIKey は管理APIのキーです
+type APIKey struct {
+	Name  string
+	Key   string
+	Roles []Role
+}
+
+// ParseAPIKeys は「名前:キー:ロール+ロール」をカンマ区切りで並べた設定を解析します
+func ParseAPIKeys(value string) ([]APIKey, error) {
+	keys := make([]APIKey, 0)
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("名前:キー:ロールの形式で指定してください: %s", parts[0])
+		}
+
+		key := APIKey{Name: parts[0], Key: parts[1]}
+		for _, value := range strings.Split(parts[2], "+") {
+			role, err := ParseRole(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key.Name, err)
+			}
+			key.Roles = append(key.Roles, role)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
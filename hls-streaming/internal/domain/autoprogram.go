@@ -0,0 +1,259 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+var ErrInvalidAutoProgramRules = errors.New("自動編成のルールが不正です")
+
+// DayPart は自動編成で使用する時間帯ごとの設定です。
+// Endが空またはStart以前の場合は翌日0時までを表します
+type DayPart struct {
+	Name           string   `json:"name"`
+	Start          string   `json:"start"`
+	End            string   `json:"end"`
+	Categories     []string `json:"categories"`
+	MinDurationSec float64  `json:"min_duration_sec"`
+	MaxDurationSec float64  `json:"max_duration_sec"`
+}
+
+// AutoProgramRules はアセットライブラリから番組表を自動で組み立てるためのルールです。
+// カテゴリはアセットのタグと対応します
+type AutoProgramRules struct {
+	// Categories は時間帯にカテゴリの指定がない場合に順番に編成するカテゴリです
+	Categories []string `json:"categories"`
+	// MinRepeatMinutes は同じアセットを再び編成するまでに空ける最小の時間（分）です
+	MinRepeatMinutes int `json:"min_repeat_minutes"`
+	// SlotAlignSec は番組の枠をこの秒数の倍数に切り上げます（0の場合は素材の長さのまま）
+	SlotAlignSec int       `json:"slot_align_sec"`
+	DayParts     []DayPart `json:"day_parts"`
+	// Seed は並び替えの乱数の種です。同じ日付・ルール・ライブラリからは常に同じ番組表が生成されます
+	Seed int64 `json:"seed"`
+}
+
+// AutoProgramDay は1日分の自動編成の結果です
+type AutoProgramDay struct {
+	Programs    []ProgramItem `json:"programs"`
+	FilledSec   float64       `json:"filled_sec"`
+	UnfilledSec float64       `json:"unfilled_sec"`
+}
+
+// Validate は自動編成のルールを検証します。不正な場合はErrInvalidAutoProgramRulesをラップしたエラーを返します
+func (r *AutoProgramRules) Validate() error {
+	if err := r.validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidAutoProgramRules, err)
+	}
+	return nil
+}
+
+func (r *AutoProgramRules) validate() error {
+	if r.MinRepeatMinutes < 0 || r.SlotAlignSec < 0 {
+		return fmt.Errorf("min_repeat_minutesとslot_align_secは0以上を指定してください")
+	}
+	for i, part := range r.effectiveDayParts() {
+		if _, err := parseClock(part.Start); err != nil {
+			return fmt.Errorf("時間帯%d: %v", i, err)
+		}
+		if part.End != "" {
+			if _, err := parseClock(part.End); err != nil {
+				return fmt.Errorf("時間帯%d: %v", i, err)
+			}
+		}
+		if len(part.Categories) == 0 && len(r.Categories) == 0 {
+			return fmt.Errorf("時間帯%d: categoriesを指定してください", i)
+		}
+		if part.MaxDurationSec > 0 && part.MaxDurationSec < part.MinDurationSec {
+			return fmt.Errorf("時間帯%d: max_duration_secはmin_duration_sec以上を指定してください", i)
+		}
+	}
+	return nil
+}
+
+// effectiveDayParts は時間帯の指定がない場合に1日全体を1つの時間帯として返します
+func (r *AutoProgramRules) effectiveDayParts() []DayPart {
+	if len(r.DayParts) == 0 {
+		return []DayPart{{Name: "all", Start: "00:00"}}
+	}
+	return r.DayParts
+}
+
+// BuildAutoSchedule はアセットライブラリからdateの番組表を組み立てます。
+// historyには直前の日の番組を渡し、日付をまたいだ再放送間隔の判定に使用します。
+// 収まる素材がない時間は空けたままにします（フィラーや静止画で埋められます）
+func BuildAutoSchedule(date string, loc *time.Location, assets []Asset, rules AutoProgramRules, history []ProgramItem) (AutoProgramDay, error) {
+	day := AutoProgramDay{Programs: make([]ProgramItem, 0)}
+
+	lastAired := make(map[string]time.Time)
+	for _, program := range history {
+		startTime, err := program.GetStartTime()
+		if err != nil {
+			continue
+		}
+		for _, id := range program.AssetIDs() {
+			lastAired[id] = startTime
+		}
+	}
+	minRepeat := time.Duration(rules.MinRepeatMinutes) * time.Minute
+
+	rng := rand.New(rand.NewSource(autoProgramSeed(date, rules.Seed)))
+	library := make([]Asset, len(assets))
+	copy(library, assets)
+	sort.Slice(library, func(i, j int) bool { return library[i].ID < library[j].ID })
+	rng.Shuffle(len(library), func(i, j int) { library[i], library[j] = library[j], library[i] })
+
+	rotation := 0
+	for _, part := range rules.effectiveDayParts() {
+		start, end, err := dayPartBounds(date, part, loc)
+		if err != nil {
+			return AutoProgramDay{}, err
+		}
+		categories := part.Categories
+		if len(categories) == 0 {
+			categories = rules.Categories
+		}
+
+		cursor := start
+		for cursor.Before(end) {
+			asset, slot := pickAutoProgramAsset(library, categories, &rotation, part, rules.SlotAlignSec, cursor, end, lastAired, minRepeat)
+			if asset == nil {
+				break
+			}
+
+			program := NewProgramItem(RequestProgramItem{
+				StartTime:   cursor.Format(time.RFC3339),
+				DurationSec: slot,
+				Type:        ProgramTypeVideo,
+				Title:       asset.Title,
+				AssetID:     asset.ID,
+			})
+			program.ScheduleDate = date
+			day.Programs = append(day.Programs, program)
+			day.FilledSec += float64(slot)
+
+			lastAired[asset.ID] = cursor
+			cursor = cursor.Add(time.Duration(slot) * time.Second)
+		}
+		day.UnfilledSec += end.Sub(cursor).Seconds()
+	}
+	return day, nil
+}
+
+// pickAutoProgramAsset はカテゴリを順番に巡回して、条件を満たす素材と枠の秒数を返します。
+// 同じ条件の素材の中では最も長く放送されていないものを選びます
+func pickAutoProgramAsset(library []Asset, categories []string, rotation *int, part DayPart, alignSec int, cursor, end time.Time, lastAired map[string]time.Time, minRepeat time.Duration) (*Asset, int32) {
+	remaining := end.Sub(cursor).Seconds()
+
+	for attempt := 0; attempt < len(categories); attempt++ {
+		category := categories[(*rotation+attempt)%len(categories)]
+
+		var best *Asset
+		var bestSlot int32
+		for i := range library {
+			asset := &library[i]
+			if !asset.HasAnyTag([]string{category}) || asset.DurationSec <= 0 {
+				continue
+			}
+			if part.MinDurationSec > 0 && asset.DurationSec < part.MinDurationSec {
+				continue
+			}
+			if part.MaxDurationSec > 0 && asset.DurationSec > part.MaxDurationSec {
+				continue
+			}
+			if aired, ok := lastAired[asset.ID]; ok && cursor.Sub(aired) < minRepeat {
+				continue
+			}
+
+			slot := autoProgramSlot(asset.DurationSec, alignSec)
+			if float64(slot) > remaining {
+				// 枠を揃えると収まらない場合は素材の長さで収まるか確認する
+				slot = int32(math.Ceil(asset.DurationSec))
+				if float64(slot) > remaining {
+					continue
+				}
+			}
+
+			if best == nil || lastAired[asset.ID].Before(lastAired[best.ID]) {
+				best = asset
+				bestSlot = slot
+			}
+		}
+
+		if best != nil {
+			*rotation += attempt + 1
+			return best, bestSlot
+		}
+	}
+	return nil, 0
+}
+
+// autoProgramSlot は素材の長さを枠の単位に切り上げた秒数を返します
+func autoProgramSlot(durationSec float64, alignSec int) int32 {
+	slot := int32(math.Ceil(durationSec))
+	if alignSec > 0 {
+		align := int32(alignSec)
+		slot = (slot + align - 1) / align * align
+	}
+	return slot
+}
+
+func dayPartBounds(date string, part DayPart, loc *time.Location) (time.Time, time.Time, error) {
+	start, err := LocalDateTime(date, part.Start, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	nextDay := time.Date(start.Year(), start.Month(), start.Day()+1, 0, 0, 0, 0, loc)
+	if part.End == "" {
+		return start, nextDay, nil
+	}
+	end, err := LocalDateTime(date, part.End, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if !end.After(start) {
+		end = nextDay
+	}
+	return start, end, nil
+}
+
+// autoProgramSeed は日付とルールの種から乱数の種を作ります
+func autoProgramSeed(date string, seed int64) int64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(date))
+	return int64(hash.Sum64()) ^ seed
+}
+
+// AutoProgramDayResult は自動編成を1日分反映した結果です
+type AutoProgramDayResult struct {
+	TemplateDayResult
+	Programs    []ProgramItem `json:"programs"`
+	FilledSec   float64       `json:"filled_sec"`
+	UnfilledSec float64       `json:"unfilled_sec"`
+}
+
+// AutoProgramResult は期間に対する自動編成の結果です
+type AutoProgramResult struct {
+	From          string                 `json:"from"`
+	To            string                 `json:"to"`
+	Mode          TemplateApplyMode      `json:"mode"`
+	DryRun        bool                   `json:"dry_run"`
+	Days          []AutoProgramDayResult `json:"days"`
+	AppliedCount  int                    `json:"applied_count"`
+	ConflictCount int                    `json:"conflict_count"`
+}
+
+func (r *AutoProgramResult) Add(day AutoProgramDayResult) {
+	r.Days = append(r.Days, day)
+	switch day.Status {
+	case TemplateDayApplied, TemplateDayReplaced, TemplateDayMerged:
+		r.AppliedCount++
+	case TemplateDayConflict:
+		r.ConflictCount++
+	}
+}
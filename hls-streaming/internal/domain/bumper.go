@@ -0,0 +1,60 @@
+package domain
+
+import "fmt"
+
+// BumperMode はチャンネルのバンパー（番組の境目に入れるアイキャッチ）の挿入方法です
+type BumperMode string
+
+const (
+	// BumperAll はすべての番組の終わりにバンパーを入れます
+	BumperAll BumperMode = "all"
+	// BumperMarked はbumperに"on"を指定した番組の終わりにだけバンパーを入れます
+	BumperMarked BumperMode = "marked"
+)
+
+// 番組ごとのバンパーの指定（空の場合はチャンネルの設定に従います）
+const (
+	ProgramBumperOn  = "on"
+	ProgramBumperOff = "off"
+)
+
+// BumperConfig はチャンネルのバンパーの設定です
+type BumperConfig struct {
+	// AssetID はバンパーに使用するアセットのIDです（空の場合はバンパーを入れません）
+	AssetID string
+	Mode    BumperMode
+}
+
+// ParseBumperMode はバンパーの挿入方法を解釈します（空の場合はall）
+func ParseBumperMode(mode string) (BumperMode, error) {
+	switch BumperMode(mode) {
+	case "", BumperAll:
+		return BumperAll, nil
+	case BumperMarked:
+		return BumperMarked, nil
+	}
+	return "", fmt.Errorf("不明なバンパーの挿入方法です: %q", mode)
+}
+
+// validateProgramBumper は番組ごとのバンパーの指定を検証します
+func validateProgramBumper(bumper string) error {
+	switch bumper {
+	case "", ProgramBumperOn, ProgramBumperOff:
+		return nil
+	}
+	return fmt.Errorf("bumperには%qまたは%qを指定してください: %q", ProgramBumperOn, ProgramBumperOff, bumper)
+}
+
+// AppliesTo は番組の終わり（次の番組との境目）にバンパーを入れるかどうかを返します
+func (c BumperConfig) AppliesTo(program *ProgramItem) bool {
+	if c.AssetID == "" {
+		return false
+	}
+	switch program.Bumper {
+	case ProgramBumperOn:
+		return true
+	case ProgramBumperOff:
+		return false
+	}
+	return c.Mode != BumperMarked
+}
@@ -0,0 +1,24 @@
+package domain
+
+import "time"
+
+// Clock は現在時刻の取得を抽象化します。テストやプレビューでは任意の時刻を返す実装に差し替えます
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock はシステムの現在時刻を返します
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// FixedClock は常に同じ時刻を返します
+type FixedClock struct {
+	Time time.Time
+}
+
+func (c FixedClock) Now() time.Time {
+	return c.Time
+}
@@ -0,0 +1,43 @@
+package domain
+
+import "time"
+
+// EPGEntry は番組表をチャンネルのタイムゾーンで表した1件分の番組情報です
+type EPGEntry struct {
+	ID           string `json:"id"`
+	Title        string `json:"title"`
+	Type         string `json:"type"`
+	AssetID      string `json:"asset_id,omitempty"`
+	ScheduleDate string `json:"schedule_date"`
+	Start        string `json:"start"`
+	End          string `json:"end"`
+	DurationSec  int32  `json:"duration_sec"`
+}
+
+// BuildEPG は番組の開始・終了時刻をチャンネルのタイムゾーンに変換したEPGを作成します。
+// 開始時刻を解析できない番組は含まれません
+func BuildEPG(programs []ProgramItem, loc *time.Location) []EPGEntry {
+	entries := make([]EPGEntry, 0, len(programs))
+	for _, program := range programs {
+		startTime, err := program.GetStartTime()
+		if err != nil {
+			continue
+		}
+		endTime, err := program.GetEndTime(loc)
+		if err != nil {
+			continue
+		}
+
+		entries = append(entries, EPGEntry{
+			ID:           program.ID,
+			Title:        program.Title,
+			Type:         program.Type,
+			AssetID:      program.AssetID,
+			ScheduleDate: program.ScheduleDate,
+			Start:        startTime.In(loc).Format(time.RFC3339),
+			End:          endTime.Format(time.RFC3339),
+			DurationSec:  program.DurationSec,
+		})
+	}
+	return entries
+}
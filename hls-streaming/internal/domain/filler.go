@@ -0,0 +1,241 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// ProgramTypeFiller は隙間埋めのために自動で生成された番組の種別です
+const ProgramTypeFiller = "filler"
+
+// Gap は番組が編成されていない時間帯です
+type Gap struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+func (g Gap) Duration() time.Duration {
+	return g.End.Sub(g.Start)
+}
+
+// FillResult は隙間埋めの結果です。Slateには素材で埋められず静止画を表示する時間帯が入ります
+type FillResult struct {
+	Fillers   []ProgramItem `json:"fillers"`
+	Slate     []Gap         `json:"slate"`
+	FilledSec float64       `json:"filled_sec"`
+	SlateSec  float64       `json:"slate_sec"`
+}
+
+// HasAnyTag はアセットが指定したタグのいずれかを持つかを返します
+func (a *Asset) HasAnyTag(tags []string) bool {
+	for _, tag := range a.Tags {
+		for _, candidate := range tags {
+			if tag == candidate {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// FindGaps はfrom〜toのうち番組が編成されていない時間帯を返します（1秒未満の隙間は無視します）
+func FindGaps(programs []ProgramItem, from, to time.Time) []Gap {
+	type span struct{ start, end time.Time }
+	spans := make([]span, 0, len(programs))
+	for _, program := range programs {
+		startTime, err := program.GetStartTime()
+		if err != nil {
+			continue
+		}
+		spans = append(spans, span{startTime, startTime.Add(time.Duration(program.DurationSec) * time.Second)})
+	}
+	sort.Slice(spans, func(i, j int) bool {
+		return spans[i].start.Before(spans[j].start)
+	})
+
+	gaps := make([]Gap, 0)
+	cursor := from
+	add := func(start, end time.Time) {
+		if end.After(to) {
+			end = to
+		}
+		if end.Sub(start) >= time.Second {
+			gaps = append(gaps, Gap{Start: start, End: end})
+		}
+	}
+	for _, s := range spans {
+		if !cursor.Before(to) {
+			break
+		}
+		if s.start.After(cursor) {
+			add(cursor, s.start)
+		}
+		if s.end.After(cursor) {
+			cursor = s.end
+		}
+	}
+	if cursor.Before(to) {
+		add(cursor, to)
+	}
+	return gaps
+}
+
+// FillGaps はfrom〜toの隙間をフィラー素材で埋めます。
+// 素材は順番に使い回し、隙間の残りが最長の素材より短くなったら収まる中で最も長い素材を選びます。
+// どの素材も収まらない残り時間は静止画（Slate）として返します。
+// 隙間は現地時刻の0時で区切り、ローテーションは日ごとに最初の素材から始めるため、
+// ある日の結果はfrom〜toにその日全体が含まれていれば範囲の取り方によらず同じになります
+func FillGaps(programs []ProgramItem, from, to time.Time, pool []Asset, loc *time.Location) FillResult {
+	result := FillResult{
+		Fillers: make([]ProgramItem, 0),
+		Slate:   make([]Gap, 0),
+	}
+
+	candidates := make([]Asset, 0, len(pool))
+	for _, asset := range pool {
+		if fillerDuration(asset) > 0 {
+			candidates = append(candidates, asset)
+		}
+	}
+	// 素材の順序に依存せず同じ結果になるよう並べる
+	sort.Slice(candidates, func(i, j int) bool {
+		if fillerDuration(candidates[i]) != fillerDuration(candidates[j]) {
+			return fillerDuration(candidates[i]) > fillerDuration(candidates[j])
+		}
+		return candidates[i].ID < candidates[j].ID
+	})
+
+	rotation := 0
+	rotationDate := ""
+	for _, gap := range splitGapsAtMidnight(FindGaps(programs, from, to), loc) {
+		if date := gap.Start.In(loc).Format("2006-01-02"); date != rotationDate {
+			rotation = 0
+			rotationDate = date
+		}
+		cursor := gap.Start
+		previous := ""
+		for len(candidates) > 0 {
+			remaining := int32(gap.End.Sub(cursor) / time.Second)
+			index := pickFiller(candidates, remaining, rotation, previous)
+			if index < 0 {
+				break
+			}
+			rotation = index + 1
+
+			asset := candidates[index]
+			duration := fillerDuration(asset)
+			result.Fillers = append(result.Fillers, ProgramItem{
+				ID:           fmt.Sprintf("filler-%d", cursor.Unix()),
+				StartTime:    cursor.In(loc).Format(time.RFC3339),
+				DurationSec:  duration,
+				Type:         ProgramTypeFiller,
+				Title:        asset.Title,
+				AssetID:      asset.ID,
+				ScheduleDate: cursor.In(loc).Format("2006-01-02"),
+			})
+			result.FilledSec += float64(duration)
+			cursor = cursor.Add(time.Duration(duration) * time.Second)
+			previous = asset.ID
+		}
+
+		if gap.End.After(cursor) {
+			slate := Gap{Start: cursor, End: gap.End}
+			result.Slate = append(result.Slate, slate)
+			result.SlateSec += slate.Duration().Seconds()
+		}
+	}
+	return result
+}
+
+// splitGapsAtMidnight は日付をまたぐ隙間を現地時刻の0時で分割します
+func splitGapsAtMidnight(gaps []Gap, loc *time.Location) []Gap {
+	result := make([]Gap, 0, len(gaps))
+	for _, gap := range gaps {
+		start := gap.Start
+		for {
+			local := start.In(loc)
+			midnight := time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, loc)
+			if !midnight.Before(gap.End) {
+				break
+			}
+			result = append(result, Gap{Start: start, End: midnight})
+			start = midnight
+		}
+		result = append(result, Gap{Start: start, End: gap.End})
+	}
+	return result
+}
+
+// OnAirFiller はfillersのうちatの時点で放送中のフィラーを返します。
+// programsの番組と重なる場合（新しく番組が編成された場合など）はnilを返します
+func OnAirFiller(fillers, programs []ProgramItem, at time.Time) *ProgramItem {
+	for _, filler := range fillers {
+		if filler.Type != ProgramTypeFiller {
+			continue
+		}
+		startTime, err := filler.GetStartTime()
+		if err != nil {
+			continue
+		}
+		endTime := startTime.Add(time.Duration(filler.DurationSec) * time.Second)
+		if at.Before(startTime) || !at.Before(endTime) {
+			continue
+		}
+
+		gaps := FindGaps(programs, startTime, endTime)
+		if len(gaps) != 1 || !gaps[0].Start.Equal(startTime) || !gaps[0].End.Equal(endTime) {
+			return nil
+		}
+		return &filler
+	}
+	return nil
+}
+
+// pickFiller は残り時間に収まる素材のインデックスを返します（見つからない場合は-1）。
+// 直前と同じ素材は他に候補がない場合のみ選びます
+func pickFiller(candidates []Asset, remaining int32, rotation int, previous string) int {
+	if remaining <= 0 {
+		return -1
+	}
+
+	// 最長の素材が収まる間はローテーションで選ぶ
+	if fillerDuration(candidates[0]) <= remaining {
+		for offset := 0; offset < len(candidates); offset++ {
+			index := (rotation + offset) % len(candidates)
+			if candidates[index].ID != previous || len(candidates) == 1 {
+				return index
+			}
+		}
+	}
+
+	// 残り時間が短い場合は収まる中で最も長い素材を選ぶ（候補は長い順に並んでいる）
+	fallback := -1
+	for index, candidate := range candidates {
+		if fillerDuration(candidate) > remaining {
+			continue
+		}
+		if candidate.ID != previous {
+			return index
+		}
+		if fallback < 0 {
+			fallback = index
+		}
+	}
+	return fallback
+}
+
+// fillerDuration はフィラーとして使用する秒数を返します（端数は切り捨て）
+func fillerDuration(asset Asset) int32 {
+	return int32(math.Floor(asset.DurationSec))
+}
+
+// MergeFillers は番組とフィラーを開始時刻順にまとめます
+func MergeFillers(programs, fillers []ProgramItem) []ProgramItem {
+	merged := make([]ProgramItem, 0, len(programs)+len(fillers))
+	merged = append(merged, programs...)
+	merged = append(merged, fillers...)
+	SortProgramsByStartTime(merged)
+	return merged
+}
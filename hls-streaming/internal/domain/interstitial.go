@@ -0,0 +1,161 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidAssetListQuery = errors.New("アセットリストの指定が不正です")
+
+// InterstitialClass はHLS InterstitialsのEXT-X-DATERANGEに指定するCLASSです
+const InterstitialClass = "com.apple.hls.interstitial"
+
+// interstitialAssetListPath はX-ASSET-LISTで参照するアセットリストのパスです
+const interstitialAssetListPath = "/api/interstitials/asset-list"
+
+// Interstitial は番組の途中にプレイヤー側で再生させる告知などの差し込みです。
+// 位置の指定はAdBreakと同じで、AssetIDを指定した場合はX-ASSET-URI、
+// Assetsを指定した場合はX-ASSET-LISTで再生するアセットを示します
+type Interstitial struct {
+	ID          string   `firestore:"id" json:"id,omitempty"`
+	Offset      float64  `firestore:"offset" json:"offset"`
+	At          string   `firestore:"at" json:"at,omitempty"`
+	AssetID     string   `firestore:"asset_id" json:"asset_id,omitempty"`
+	Assets      []string `firestore:"assets" json:"assets,omitempty"`
+	DurationSec float64  `firestore:"duration_sec" json:"duration_sec,omitempty"`
+}
+
+// InterstitialAsset はアセットリスト（X-ASSET-LIST）の1件です
+type InterstitialAsset struct {
+	URI      string  `json:"URI"`
+	Duration float64 `json:"DURATION"`
+}
+
+// InterstitialAssetList はX-ASSET-LISTで返すJSONです
+type InterstitialAssetList struct {
+	Assets []InterstitialAsset `json:"ASSETS"`
+}
+
+// AssetPlaylistURI はアセットを単体で再生するプレイリストのURIです
+func AssetPlaylistURI(assetID string) string {
+	return "/api/assets/" + url.PathEscape(assetID) + "/video.m3u8"
+}
+
+// AdCreativePlaylistURI は変換済みの広告素材を再生するプレイリストのURIです
+func AdCreativePlaylistURI(creativeKey string) string {
+	return "/api/ads/" + url.PathEscape(creativeKey) + "/video.m3u8"
+}
+
+// AssetListURI は複数のアセットを順に再生するアセットリストのURIです
+func AssetListURI(assetIDs []string) string {
+	return interstitialAssetListPath + "?assets=" + url.QueryEscape(strings.Join(assetIDs, ","))
+}
+
+// AdAssetListURI は広告枠の広告をアセットリストとして返すURIです
+func AdAssetListURI(request AdRequest) string {
+	query := url.Values{}
+	query.Set("break", request.BreakID)
+	query.Set("program", request.ProgramID)
+	query.Set("start", strconv.FormatInt(request.StartDate.Unix(), 10))
+	query.Set("duration", strconv.FormatFloat(request.DurationSec, 'f', -1, 64))
+	if request.SessionID != "" {
+		query.Set("sid", request.SessionID)
+	}
+	return interstitialAssetListPath + "?" + query.Encode()
+}
+
+// ParseAdAssetListQuery はAdAssetListURIのクエリから広告判定のリクエストを復元します
+func ParseAdAssetListQuery(query url.Values) (AdRequest, error) {
+	start, err := strconv.ParseInt(query.Get("start"), 10, 64)
+	if err != nil {
+		return AdRequest{}, fmt.Errorf("%w: startはUNIX時間で指定してください", ErrInvalidAssetListQuery)
+	}
+	duration, err := strconv.ParseFloat(query.Get("duration"), 64)
+	if err != nil || duration <= 0 {
+		return AdRequest{}, fmt.Errorf("%w: durationは0より大きい秒数で指定してください", ErrInvalidAssetListQuery)
+	}
+	if query.Get("break") == "" {
+		return AdRequest{}, fmt.Errorf("%w: breakを指定してください", ErrInvalidAssetListQuery)
+	}
+	return AdRequest{
+		BreakID:     query.Get("break"),
+		ProgramID:   query.Get("program"),
+		DurationSec: duration,
+		StartDate:   time.Unix(start, 0),
+		SessionID:   query.Get("sid"),
+	}, nil
+}
+
+// validate はインタースティシャルの指定を検証します
+func (i Interstitial) validate(program *ProgramItem, assetExists func(id string) bool) error {
+	if (i.AssetID == "") == (len(i.Assets) == 0) {
+		return fmt.Errorf("asset_idまたはassetsのどちらか一方を指定してください")
+	}
+	if i.DurationSec < 0 {
+		return fmt.Errorf("長さは0以上を指定してください")
+	}
+	for _, id := range append([]string{i.AssetID}, i.Assets...) {
+		if id != "" && assetExists != nil && !assetExists(id) {
+			return fmt.Errorf("アセットが存在しません: %s", id)
+		}
+	}
+	return validateCuePosition(i.Offset, i.At, program)
+}
+
+// cue はインタースティシャルを示すEXT-X-DATERANGEのCueを返します
+func (i Interstitial) cue(id string, startDate time.Time) Cue {
+	cue := Cue{Type: CueInterstitial, ID: id, StartDate: startDate, Duration: i.DurationSec}
+	if i.AssetID != "" {
+		cue.AssetURI = AssetPlaylistURI(i.AssetID)
+	} else {
+		cue.AssetList = AssetListURI(i.Assets)
+	}
+	return cue
+}
+
+// interstitialLines はインタースティシャルのEXT-X-DATERANGEの行を返します
+func (c Cue) interstitialLines() []string {
+	attributes := []string{
+		fmt.Sprintf("ID=%q", c.ID),
+		fmt.Sprintf("CLASS=%q", InterstitialClass),
+		fmt.Sprintf("START-DATE=%q", c.StartDate.Format(dateRangeTimeFormat)),
+	}
+	if c.Duration > 0 {
+		attributes = append(attributes, fmt.Sprintf("DURATION=%.3f", c.Duration))
+	}
+	if c.AssetURI != "" {
+		attributes = append(attributes, fmt.Sprintf("X-ASSET-URI=%q", c.AssetURI))
+	}
+	if c.AssetList != "" {
+		attributes = append(attributes, fmt.Sprintf("X-ASSET-LIST=%q", c.AssetList))
+	}
+	if c.Restrict != "" {
+		attributes = append(attributes, fmt.Sprintf("X-RESTRICT=%q", c.Restrict))
+	}
+	return []string{"#EXT-X-DATERANGE:" + strings.Join(attributes, ",")}
+}
+
+// VODContent はプレイリストをインタースティシャルとして単体で再生するVODのm3u8に変換します
+func (p *M3U8Playlist) VODContent() string {
+	lines := []string{
+		"#EXTM3U",
+		"#EXT-X-VERSION:3",
+		"#EXT-X-TARGETDURATION:" + strconv.Itoa(p.TargetDuration),
+		"#EXT-X-MEDIA-SEQUENCE:0",
+		"#EXT-X-PLAYLIST-TYPE:VOD",
+	}
+	for i := 0; i < p.Len(); i++ {
+		lines = append(lines, p.SegmentAt(i).Lines()...)
+	}
+	lines = append(lines, "#EXT-X-ENDLIST")
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// IsCreativeKey はCreativeKeyが返す形式の文字列かどうかを返します
+func IsCreativeKey(key string) bool {
+	return isHexID(key, 20)
+}
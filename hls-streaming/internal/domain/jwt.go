@@ -0,0 +1,262 @@
+package domain
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// jwtLeeway はJWTの有効期限の判定で許容する時計のずれです
+const jwtLeeway = time.Minute
+
+// JWKS はJWTの署名を検証する公開鍵の集合です（RSAとP-256の楕円曲線鍵に対応）
+type JWKS struct {
+	keys map[string]crypto.PublicKey
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// ParseJWKS はJWKS形式（{"keys": [...]}）の公開鍵を解析します。署名用でない鍵や未対応の鍵は無視します
+func ParseJWKS(data []byte) (*JWKS, error) {
+	var document struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("JWKSの解析に失敗: %w", err)
+	}
+
+	jwks := &JWKS{keys: make(map[string]crypto.PublicKey)}
+	for _, key := range document.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		publicKey, err := key.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("鍵(%s)の解析に失敗: %w", key.Kid, err)
+		}
+		if publicKey != nil {
+			jwks.keys[key.Kid] = publicKey
+		}
+	}
+	if len(jwks.keys) == 0 {
+		return nil, errors.New("JWKSに署名を検証できる鍵がありません")
+	}
+	return jwks, nil
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch {
+	case k.Kty == "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil || !e.IsInt64() {
+			return nil, errors.New("eが不正です")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case k.Kty == "EC" && k.Crv == "P-256":
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return nil, errors.New("楕円曲線上の点ではありません")
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	default:
+		return nil, nil
+	}
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(data) == 0 {
+		return nil, errors.New("鍵のパラメータが不正です")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// key はJWTのkidに対応する公開鍵を返します（kidがない場合は鍵が1つのときのみ）
+func (j *JWKS) key(kid string) crypto.PublicKey {
+	if kid == "" && len(j.keys) == 1 {
+		for _, key := range j.keys {
+			return key
+		}
+	}
+	return j.keys[kid]
+}
+
+// JWTVerifier はOIDCのIDプロバイダーが発行したJWT（RS256またはES256）を検証します
+type JWTVerifier struct {
+	Keys *JWKS
+	// Issuer、Audience はiss、audに必要な値です（どちらかが空の場合はすべてのトークンを拒否します）
+	Issuer   string
+	Audience string
+	// RolesClaim はロールを含むクレームです（「realm_access.roles」のように.区切りで入れ子のクレームを指定できます）
+	RolesClaim string
+}
+
+// Verify はトークンの署名・有効期限・発行者・対象を検証し、利用者を返します
+func (v JWTVerifier) Verify(token string, now time.Time) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: JWTの形式が不正です", ErrUnauthenticated)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: 署名の形式が不正です", ErrUnauthenticated)
+	}
+	if err := v.verifySignature(header.Alg, v.Keys.key(header.Kid), parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]any
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if err := v.validateClaims(claims, now); err != nil {
+		return nil, err
+	}
+
+	subject, _ := claims["sub"].(string)
+	return &Principal{Subject: subject, Roles: v.roles(claims), Method: "jwt"}, nil
+}
+
+func decodeJWTPart(part string, value any) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return fmt.Errorf("%w: JWTの形式が不正です", ErrUnauthenticated)
+	}
+	if err := json.Unmarshal(data, value); err != nil {
+		return fmt.Errorf("%w: JWTの形式が不正です", ErrUnauthenticated)
+	}
+	return nil
+}
+
+func (v JWTVerifier) verifySignature(alg string, key crypto.PublicKey, signingInput string, signature []byte) error {
+	if key == nil {
+		return fmt.Errorf("%w: 署名を検証する鍵がありません", ErrUnauthenticated)
+	}
+	digest := sha256.Sum256([]byte(signingInput))
+
+	// algと鍵の種類が一致しない場合は受け付けない（alg=noneや鍵の取り違えを防ぐ）
+	switch publicKey := key.(type) {
+	case *rsa.PublicKey:
+		if alg == "RS256" && rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], signature) == nil {
+			return nil
+		}
+	case *ecdsa.PublicKey:
+		if alg == "ES256" && len(signature) == 64 {
+			r := new(big.Int).SetBytes(signature[:32])
+			s := new(big.Int).SetBytes(signature[32:])
+			if ecdsa.Verify(publicKey, digest[:], r, s) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%w: 署名が一致しません", ErrUnauthenticated)
+}
+
+func (v JWTVerifier) validateClaims(claims map[string]any, now time.Time) error {
+	expiresAt, ok := claims["exp"].(float64)
+	if !ok {
+		return fmt.Errorf("%w: expがありません", ErrUnauthenticated)
+	}
+	if !now.Before(time.Unix(int64(expiresAt), 0).Add(jwtLeeway)) {
+		return fmt.Errorf("%w: トークンの有効期限が切れています", ErrUnauthenticated)
+	}
+	if notBefore, ok := claims["nbf"].(float64); ok && now.Add(jwtLeeway).Before(time.Unix(int64(notBefore), 0)) {
+		return fmt.Errorf("%w: トークンはまだ有効ではありません", ErrUnauthenticated)
+	}
+
+	// 検証する値が設定されていない場合は、どのトークンも受け付けない
+	if v.Issuer == "" || v.Audience == "" {
+		return fmt.Errorf("%w: JWTの発行者と対象が設定されていません", ErrUnauthenticated)
+	}
+	if issuer, _ := claims["iss"].(string); issuer != v.Issuer {
+		return fmt.Errorf("%w: issが一致しません", ErrUnauthenticated)
+	}
+	if !containsClaim(claims["aud"], v.Audience) {
+		return fmt.Errorf("%w: audが一致しません", ErrUnauthenticated)
+	}
+	return nil
+}
+
+// roles はRolesClaimのクレーム（文字列の配列または空白区切りの文字列）からロールを返します。不明なロールは無視します
+func (v JWTVerifier) roles(claims map[string]any) []Role {
+	var value any = claims
+	for _, name := range strings.Split(v.RolesClaim, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = object[name]
+	}
+
+	var names []string
+	switch value := value.(type) {
+	case string:
+		names = strings.Fields(value)
+	case []any:
+		for _, name := range value {
+			if name, ok := name.(string); ok {
+				names = append(names, name)
+			}
+		}
+	}
+
+	roles := make([]Role, 0, len(names))
+	for _, name := range names {
+		if role, err := ParseRole(name); err == nil {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// containsClaim は文字列または文字列の配列のクレームにexpectedが含まれるかどうかを返します
+func containsClaim(claim any, expected string) bool {
+	switch claim := claim.(type) {
+	case string:
+		return claim == expected
+	case []any:
+		for _, value := range claim {
+			if value == expected {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package domain
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidPlaybackToken = errors.New("再生トークンが不正です")
+	ErrPlaybackTokenExpired = errors.New("再生トークンの有効期限が切れています")
+)
+
+// PlaybackClaims は再生トークンに含める内容です
+type PlaybackClaims struct {
+	// Subject はアプリが指定した視聴者のIDです（流出した録画から視聴者を特定するためにセッションに記録します）
+	Subject   string `json:"sub"`
+	Channel   string `json:"ch"`
+	ExpiresAt int64  `json:"exp"`
+	// IP を指定した場合は、そのIPアドレスからの視聴のみを許可します
+	IP string `json:"ip,omitempty"`
+}
+
+// PlaybackTokenSigner は視聴者の再生トークンをHMAC-SHA256で署名・検証します。
+// トークンは base64url(JSON).base64url(署名) の形式です
+type PlaybackTokenSigner struct {
+	Secret []byte
+}
+
+func (s PlaybackTokenSigner) Sign(claims PlaybackClaims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(s.signature(encoded)), nil
+}
+
+// Verify はトークンの署名・有効期限・チャンネル・IPアドレスを検証します
+func (s PlaybackTokenSigner) Verify(token, channel, ip string, now time.Time) (*PlaybackClaims, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidPlaybackToken
+	}
+	decodedSignature, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(decodedSignature, s.signature(encoded)) {
+		return nil, ErrInvalidPlaybackToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidPlaybackToken
+	}
+	var claims PlaybackClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidPlaybackToken
+	}
+
+	if !now.Before(time.Unix(claims.ExpiresAt, 0)) {
+		return nil, ErrPlaybackTokenExpired
+	}
+	if claims.Channel != channel || (claims.IP != "" && claims.IP != ip) {
+		return nil, ErrInvalidPlaybackToken
+	}
+	return &claims, nil
+}
+
+func (s PlaybackTokenSigner) signature(encoded string) []byte {
+	mac := hmac.New(sha256.New, s.Secret)
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
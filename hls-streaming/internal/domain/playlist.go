@@ -2,6 +2,8 @@ package domain
 
 import (
 	"bufio"
+	"fmt"
+	"math"
 	"strconv"
 	"strings"
 )
@@ -9,6 +11,10 @@ import (
 type M3U8Segment struct {
 	Duration float64
 	Filename string
+	// Discontinuity はこのセグメントの前にEXT-X-DISCONTINUITYが必要なことを表します
+	Discontinuity bool
+	// Cues はこのセグメントの前に付ける広告枠の開始・終了の合図です
+	Cues []Cue
 }
 
 type M3U8Playlist struct {
@@ -18,6 +24,15 @@ type M3U8Playlist struct {
 	PlaylistType   string
 	AllowCache     string
 	Segments       []M3U8Segment
+	// StartOffset は最初のセグメントの先頭から番組の開始位置までの秒数です（トリミングした場合に設定されます）
+	StartOffset float64
+
+	// loopLength はループする場合の繰り返し分を含むセグメント数です（0の場合はループしません）
+	loopLength int
+	// tail はループの後に続くセグメント（枠の残りを埋める静止画）です
+	tail []M3U8Segment
+	// cues は番組の広告枠としてセグメントのインデックスごとに付ける合図です
+	cues map[int][]Cue
 }
 
 const (
@@ -36,6 +51,7 @@ func ParseM3U8Content(m3u8Data string) (*M3U8Playlist, error) {
 	scanner := bufio.NewScanner(strings.NewReader(m3u8Data))
 
 	var currentDuration float64
+	pendingCues := make(map[CueType]*Cue)
 
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
@@ -56,14 +72,26 @@ func ParseM3U8Content(m3u8Data string) (*M3U8Playlist, error) {
 		} else if strings.HasPrefix(line, "#EXTINF:") {
 			parts := strings.Split(strings.TrimPrefix(line, "#EXTINF:"), ",")
 			if len(parts) > 0 {
-				duration, _ := strconv.ParseFloat(parts[0], 64)
+				duration, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+				if err != nil || duration < 0 {
+					return nil, fmt.Errorf("EXTINFのセグメントの長さが不正です: %q", line)
+				}
 				currentDuration = duration
 			}
+		} else if parseCueTag(line, pendingCues) {
+			continue
 		} else if line != "" && !strings.HasPrefix(line, "#") {
 			segment := M3U8Segment{
 				Duration: currentDuration,
 				Filename: line,
 			}
+			// 前の広告枠の終了を先に置く
+			for _, cueType := range []CueType{CueIn, CueOut} {
+				if cue := pendingCues[cueType]; cue != nil {
+					segment.Cues = append(segment.Cues, *cue)
+					delete(pendingCues, cueType)
+				}
+			}
 			playlist.Segments = append(playlist.Segments, segment)
 			currentDuration = 0
 		}
@@ -72,8 +100,14 @@ func ParseM3U8Content(m3u8Data string) (*M3U8Playlist, error) {
 	return playlist, scanner.Err()
 }
 
+// GetCurrentSegmentIndex は番組開始からの経過時間に対応するセグメントのインデックスを返します。
+// トリミングされたプレイリストではStartOffsetの位置を番組の開始として数えます
 func (p *M3U8Playlist) GetCurrentSegmentIndex(timeIntoProgram float64) int {
-	var accumulatedTime float64 = 0
+	if p.loopLength > 0 {
+		return p.loopSegmentIndex(timeIntoProgram)
+	}
+
+	var accumulatedTime float64 = -p.StartOffset
 	var currentSegmentIndex int = 0
 
 	for i, segment := range p.Segments {
@@ -88,9 +122,87 @@ func (p *M3U8Playlist) GetCurrentSegmentIndex(timeIntoProgram float64) int {
 	return currentSegmentIndex
 }
 
+// loopSegmentIndex はループするプレイリストで経過時間に対応するセグメントのインデックスを返します。
+// 素材1周分の長さで割った余りから周回内の位置を求めます
+func (p *M3U8Playlist) loopSegmentIndex(timeIntoProgram float64) int {
+	position := timeIntoProgram + p.StartOffset
+	count := len(p.Segments)
+	cycleDuration := p.TotalDuration()
+
+	cycles := int(math.Floor(position / cycleDuration))
+	index := cycles * count
+	remaining := position - float64(cycles)*cycleDuration
+	for _, segment := range p.Segments {
+		if segment.Duration > remaining {
+			break
+		}
+		remaining -= segment.Duration
+		index++
+	}
+
+	if index < p.loopLength {
+		return index
+	}
+
+	// ループの終わりを越えた場合は続く静止画の中の位置を求める
+	var loopDuration float64
+	for i := 0; i < p.loopLength; i++ {
+		loopDuration += p.Segments[i%count].Duration
+	}
+	remaining = position - loopDuration
+	index = p.loopLength
+	for _, segment := range p.tail {
+		if segment.Duration > remaining {
+			break
+		}
+		remaining -= segment.Duration
+		index++
+	}
+	return index
+}
+
+// Trim はinPoint〜outPoint（秒）と重なるセグメントだけを残したプレイリストを返します。
+// セグメントの途中で切ることはできないため、終了位置を含むセグメントまでを放送し、
+// 開始位置のずれはStartOffsetに保持します。outPointが0以下の場合は最後まで残します
+func (p *M3U8Playlist) Trim(inPoint, outPoint float64) *M3U8Playlist {
+	trimmed := *p
+	trimmed.Segments = make([]M3U8Segment, 0, len(p.Segments))
+	trimmed.StartOffset = 0
+	trimmed.loopLength = 0
+	trimmed.tail = nil
+	trimmed.cues = nil
+
+	var segmentStart float64
+	for _, segment := range p.Segments {
+		segmentEnd := segmentStart + segment.Duration
+		if segmentEnd > inPoint && (outPoint <= 0 || segmentStart < outPoint) {
+			if len(trimmed.Segments) == 0 {
+				trimmed.StartOffset = math.Max(0, inPoint-segmentStart)
+			}
+			trimmed.Segments = append(trimmed.Segments, segment)
+		}
+		segmentStart = segmentEnd
+	}
+	return &trimmed
+}
+
+// PlayableDuration は番組の開始位置から最後のセグメントの終わりまでの秒数を返します
+func (p *M3U8Playlist) PlayableDuration() float64 {
+	return p.TotalDuration() - p.StartOffset
+}
+
+// TotalDuration はプレイリスト全体の再生時間（秒）を返します
+func (p *M3U8Playlist) TotalDuration() float64 {
+	var total float64
+	for _, segment := range p.Segments {
+		total += segment.Duration
+	}
+	return total
+}
+
 func (p *M3U8Playlist) GetSegmentRange(currentSegmentIndex int) (int, int) {
 	startIndex := max(0, currentSegmentIndex-PlaylistLength+1)
-	endIndex := min(currentSegmentIndex, len(p.Segments)-1)
+	endIndex := min(currentSegmentIndex, p.Len()-1)
 	return startIndex, endIndex
 }
 
@@ -0,0 +1,81 @@
+package domain
+
+import "time"
+
+type ReadinessStatus string
+
+const (
+	ReadinessReady           ReadinessStatus = "ready"
+	ReadinessMissingPlaylist ReadinessStatus = "missing_playlist"
+	ReadinessMissingSegments ReadinessStatus = "missing_segments"
+	ReadinessShortMedia      ReadinessStatus = "short_media"
+	ReadinessError           ReadinessStatus = "error"
+)
+
+// ReadinessResult は1番組分の放送前チェックの結果です
+type ReadinessResult struct {
+	ProgramID           string          `json:"program_id"`
+	Title               string          `json:"title"`
+	ScheduleDate        string          `json:"schedule_date"`
+	StartTime           string          `json:"start_time"`
+	PlaylistPath        string          `json:"playlist_path"`
+	PlaylistPaths       []string        `json:"playlist_paths,omitempty"`
+	Status              ReadinessStatus `json:"status"`
+	MissingSegments     []string        `json:"missing_segments,omitempty"`
+	MediaDurationSec    float64         `json:"media_duration_sec"`
+	RequiredDurationSec int32           `json:"required_duration_sec"`
+	Message             string          `json:"message,omitempty"`
+	// Bumper はチャンネルのバンパー（BUMPER_ASSET_ID）の素材を確認した結果かどうかです
+	Bumper bool `json:"bumper,omitempty"`
+}
+
+func (r ReadinessResult) Ready() bool {
+	return r.Status == ReadinessReady
+}
+
+// ReadinessReport は先読み期間内の番組に対する放送前チェックの結果一覧です
+type ReadinessReport struct {
+	CheckedAt     time.Time         `json:"checked_at"`
+	From          time.Time         `json:"from"`
+	To            time.Time         `json:"to"`
+	Results       []ReadinessResult `json:"results"`
+	ReadyCount    int               `json:"ready_count"`
+	NotReadyCount int               `json:"not_ready_count"`
+}
+
+func (r *ReadinessReport) Add(result ReadinessResult) {
+	r.Results = append(r.Results, result)
+	if result.Ready() {
+		r.ReadyCount++
+	} else {
+		r.NotReadyCount++
+	}
+}
+
+// EvaluateMediaReadiness は存在しないセグメントと素材の長さから番組の準備状況を判定します
+func EvaluateMediaReadiness(playlist *M3U8Playlist, missingSegments []string, durationSec int32) (ReadinessStatus, float64) {
+	mediaDuration := playlist.PlayableDuration()
+	if len(missingSegments) > 0 {
+		return ReadinessMissingSegments, mediaDuration
+	}
+	if mediaDuration < float64(durationSec) {
+		return ReadinessShortMedia, mediaDuration
+	}
+	return ReadinessReady, mediaDuration
+}
+
+// ProgramsInWindow は指定した期間に放送される（一部でも重なる）番組を返します
+func ProgramsInWindow(schedule []ProgramItem, from, to time.Time) []ProgramItem {
+	result := make([]ProgramItem, 0)
+	for _, program := range schedule {
+		startTime, err := program.GetStartTime()
+		if err != nil {
+			continue
+		}
+		endTime := startTime.Add(time.Duration(program.DurationSec) * time.Second)
+		if endTime.After(from) && startTime.Before(to) {
+			result = append(result, program)
+		}
+	}
+	return result
+}
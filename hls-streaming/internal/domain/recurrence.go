@@ -0,0 +1,411 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrRecurrenceNotFound = errors.New("繰り返し番組が見つかりません")
+	ErrInvalidRecurrence  = errors.New("繰り返し番組の設定が不正です")
+	ErrOccurrenceReadOnly = errors.New("繰り返し番組の放送回は番組として操作できません")
+)
+
+// OccurrenceError は繰り返し番組から展開された放送回を番組として操作しようとした場合のエラーです。
+// 放送回は元の繰り返し番組（exdates、overrides）で変更します
+type OccurrenceError struct {
+	RecurrenceID string
+}
+
+func (e *OccurrenceError) Error() string {
+	return fmt.Sprintf("%s（繰り返し番組 %s を変更してください）", ErrOccurrenceReadOnly.Error(), e.RecurrenceID)
+}
+
+func (e *OccurrenceError) Unwrap() error {
+	return ErrOccurrenceReadOnly
+}
+
+// CheckOccurrenceID は番組IDが繰り返し番組の放送回のID（{繰り返し番組ID}@{YYYY-MM-DD}）の場合に*OccurrenceErrorを返します。
+// "@"を含んでいても放送回のIDの形式でなければ通常の番組IDとして扱います
+func CheckOccurrenceID(programID string) error {
+	recurrenceID, date, found := strings.Cut(programID, "@")
+	if !found || recurrenceID == "" {
+		return nil
+	}
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		return nil
+	}
+	return &OccurrenceError{RecurrenceID: recurrenceID}
+}
+
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+)
+
+// maxRecurrenceDays は展開時に走査する最大日数です（不正なルールによる無限ループを防ぐ）
+const maxRecurrenceDays = 366 * 10
+
+var rruleWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// RecurrenceRule はiCalendar RRULEのうち番組編成で使用するサブセットです
+type RecurrenceRule struct {
+	Freq       string
+	Interval   int
+	ByDay      []time.Weekday
+	ByMonthDay []int
+	Count      int
+	Until      string // YYYY-MM-DD（この日を含む）
+}
+
+// OccurrenceOverride は特定の日の放送回だけ内容を変更します
+type OccurrenceOverride struct {
+	Date        string `firestore:"date" json:"date"`
+	StartTime   string `firestore:"start_time" json:"start_time,omitempty"`
+	DurationSec int32  `firestore:"duration_sec" json:"duration_sec,omitempty"`
+	Title       string `firestore:"title" json:"title,omitempty"`
+	AssetID     string `firestore:"asset_id" json:"asset_id,omitempty"`
+	Cancelled   bool   `firestore:"cancelled" json:"cancelled,omitempty"`
+}
+
+// RecurringProgram は「平日19:00のニュース」のような繰り返し放送される番組枠です。
+// StartTimeはチャンネルのタイムゾーンでの時刻（HH:MMまたはHH:MM:SS）です
+type RecurringProgram struct {
+	ID           string               `firestore:"id" json:"id"`
+	Title        string               `firestore:"title" json:"title"`
+	Type         string               `firestore:"type" json:"type"`
+	PathTemplate string               `firestore:"path_template" json:"path_template"`
+	AssetID      string               `firestore:"asset_id" json:"asset_id"`
+	DurationSec  int32                `firestore:"duration_sec" json:"duration_sec"`
+	StartDate    string               `firestore:"start_date" json:"start_date"`
+	StartTime    string               `firestore:"start_time" json:"start_time"`
+	RRule        string               `firestore:"rrule" json:"rrule"`
+	ExDates      []string             `firestore:"exdates" json:"exdates"`
+	Overrides    []OccurrenceOverride `firestore:"overrides" json:"overrides"`
+}
+
+// ReferencesAsset は繰り返し番組（特定の日の変更を含む）がアセットを参照しているかどうかを返します
+func (r *RecurringProgram) ReferencesAsset(assetID string) bool {
+	if r.AssetID == assetID {
+		return true
+	}
+	for _, override := range r.Overrides {
+		if override.AssetID == assetID {
+			return true
+		}
+	}
+	return false
+}
+
+type RecurrenceRepository interface {
+	ListRecurrences(ctx context.Context) ([]RecurringProgram, error)
+	GetRecurrence(ctx context.Context, id string) (*RecurringProgram, error)
+	SaveRecurrence(ctx context.Context, recurrence RecurringProgram) error
+	DeleteRecurrence(ctx context.Context, id string) error
+}
+
+// ParseRRule はRRULE文字列（例: FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR）を解析します
+func ParseRRule(rrule string) (*RecurrenceRule, error) {
+	rule := &RecurrenceRule{Interval: 1}
+
+	for _, part := range strings.Split(strings.TrimPrefix(strings.TrimSpace(rrule), "RRULE:"), ";") {
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("RRULEの形式が不正です: %s", part)
+		}
+
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			rule.Freq = strings.ToUpper(value)
+		case "INTERVAL":
+			interval, err := strconv.Atoi(value)
+			if err != nil || interval <= 0 {
+				return nil, fmt.Errorf("INTERVALが不正です: %s", value)
+			}
+			rule.Interval = interval
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				weekday, ok := rruleWeekdays[strings.ToUpper(day)]
+				if !ok {
+					return nil, fmt.Errorf("BYDAYが不正です: %s", day)
+				}
+				rule.ByDay = append(rule.ByDay, weekday)
+			}
+		case "BYMONTHDAY":
+			for _, day := range strings.Split(value, ",") {
+				monthDay, err := strconv.Atoi(day)
+				if err != nil || monthDay < 1 || monthDay > 31 {
+					return nil, fmt.Errorf("BYMONTHDAYが不正です: %s", day)
+				}
+				rule.ByMonthDay = append(rule.ByMonthDay, monthDay)
+			}
+		case "COUNT":
+			count, err := strconv.Atoi(value)
+			if err != nil || count <= 0 {
+				return nil, fmt.Errorf("COUNTが不正です: %s", value)
+			}
+			rule.Count = count
+		case "UNTIL":
+			if len(value) < 8 {
+				return nil, fmt.Errorf("UNTILが不正です: %s", value)
+			}
+			until, err := time.Parse("20060102", value[:8])
+			if err != nil {
+				return nil, fmt.Errorf("UNTILが不正です: %s", value)
+			}
+			rule.Until = until.Format("2006-01-02")
+		default:
+			return nil, fmt.Errorf("未対応のRRULE属性です: %s", key)
+		}
+	}
+
+	switch rule.Freq {
+	case FreqDaily, FreqWeekly, FreqMonthly:
+	default:
+		return nil, fmt.Errorf("FREQはDAILY、WEEKLY、MONTHLYのいずれかを指定してください: %q", rule.Freq)
+	}
+	if rule.Count > 0 && rule.Until != "" {
+		return nil, fmt.Errorf("COUNTとUNTILは同時に指定できません")
+	}
+
+	return rule, nil
+}
+
+// OccurrenceDates はstartDateから始まる繰り返しのうち、from〜to（両端を含む）の日付を返します
+func (r *RecurrenceRule) OccurrenceDates(startDate, from, to string) ([]string, error) {
+	start, err := time.Parse("2006-01-02", startDate)
+	if err != nil {
+		return nil, fmt.Errorf("start_dateが不正です: %s", startDate)
+	}
+
+	dates := make([]string, 0)
+	count := 0
+	for offset := 0; offset < maxRecurrenceDays; offset++ {
+		day := start.AddDate(0, 0, offset)
+		date := day.Format("2006-01-02")
+		if date > to || (r.Until != "" && date > r.Until) {
+			break
+		}
+		if !r.matches(start, day) {
+			continue
+		}
+
+		count++
+		if r.Count > 0 && count > r.Count {
+			break
+		}
+		if date >= from {
+			dates = append(dates, date)
+		}
+	}
+	return dates, nil
+}
+
+func (r *RecurrenceRule) matches(start, day time.Time) bool {
+	switch r.Freq {
+	case FreqDaily:
+		days := int(day.Sub(start).Hours() / 24)
+		if days%r.Interval != 0 {
+			return false
+		}
+		return len(r.ByDay) == 0 || containsWeekday(r.ByDay, day.Weekday())
+	case FreqWeekly:
+		// 週の区切りはRFC 5545の既定値（月曜始まり）
+		weeks := int(weekStart(day).Sub(weekStart(start)).Hours() / 24 / 7)
+		if weeks%r.Interval != 0 {
+			return false
+		}
+		if len(r.ByDay) == 0 {
+			return day.Weekday() == start.Weekday()
+		}
+		return containsWeekday(r.ByDay, day.Weekday())
+	case FreqMonthly:
+		months := (day.Year()-start.Year())*12 + int(day.Month()) - int(start.Month())
+		if months%r.Interval != 0 {
+			return false
+		}
+		// BYDAYとBYMONTHDAYを両方指定した場合は両方に一致する日のみ（RFC 5545）
+		if len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 {
+			return day.Day() == start.Day()
+		}
+		if len(r.ByDay) > 0 && !containsWeekday(r.ByDay, day.Weekday()) {
+			return false
+		}
+		return len(r.ByMonthDay) == 0 || slices.Contains(r.ByMonthDay, day.Day())
+	}
+	return false
+}
+
+func weekStart(day time.Time) time.Time {
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+func containsWeekday(weekdays []time.Weekday, weekday time.Weekday) bool {
+	for _, candidate := range weekdays {
+		if candidate == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate は繰り返し番組の設定を検証します。不正な場合はErrInvalidRecurrenceをラップしたエラーを返します
+func (r *RecurringProgram) Validate() error {
+	if err := r.validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRecurrence, err)
+	}
+	return nil
+}
+
+func (r *RecurringProgram) validate() error {
+	if _, err := ParseRRule(r.RRule); err != nil {
+		return err
+	}
+	if _, err := time.Parse("2006-01-02", r.StartDate); err != nil {
+		return fmt.Errorf("start_dateはYYYY-MM-DD形式で指定してください: %s", r.StartDate)
+	}
+	if _, err := parseClock(r.StartTime); err != nil {
+		return err
+	}
+	if r.DurationSec <= 0 {
+		return fmt.Errorf("duration_secは1以上を指定してください")
+	}
+	if !KnownProgramTypes[r.Type] {
+		return fmt.Errorf("不明な番組種別です: %q", r.Type)
+	}
+	if r.AssetID == "" && r.Title == "" {
+		return fmt.Errorf("asset_idまたはtitleで素材を指定する必要があります")
+	}
+	for _, override := range r.Overrides {
+		if _, err := time.Parse("2006-01-02", override.Date); err != nil {
+			return fmt.Errorf("overridesのdateが不正です: %s", override.Date)
+		}
+		if override.StartTime != "" {
+			if _, err := parseClock(override.StartTime); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Expand は繰り返し番組をfrom〜to（両端を含む）の日付ごとの具体的な番組に展開します。
+// 除外日に該当する回や取り消された回は含まれません
+func (r *RecurringProgram) Expand(from, to string, loc *time.Location) ([]ProgramItem, error) {
+	rule, err := ParseRRule(r.RRule)
+	if err != nil {
+		return nil, err
+	}
+	dates, err := rule.OccurrenceDates(r.StartDate, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	excluded := make(map[string]bool)
+	for _, date := range r.ExDates {
+		excluded[date] = true
+	}
+	overrides := make(map[string]OccurrenceOverride)
+	for _, override := range r.Overrides {
+		overrides[override.Date] = override
+	}
+
+	programs := make([]ProgramItem, 0, len(dates))
+	for _, date := range dates {
+		if excluded[date] {
+			continue
+		}
+
+		startClock, durationSec, title, assetID := r.StartTime, r.DurationSec, r.Title, r.AssetID
+		if override, ok := overrides[date]; ok {
+			if override.Cancelled {
+				continue
+			}
+			if override.StartTime != "" {
+				startClock = override.StartTime
+			}
+			if override.DurationSec > 0 {
+				durationSec = override.DurationSec
+			}
+			if override.Title != "" {
+				title = override.Title
+			}
+			if override.AssetID != "" {
+				assetID = override.AssetID
+			}
+		}
+
+		startTime, err := LocalDateTime(date, startClock, loc)
+		if err != nil {
+			return nil, err
+		}
+
+		programs = append(programs, ProgramItem{
+			ID:           r.ID + "@" + date,
+			StartTime:    startTime.Format(time.RFC3339),
+			DurationSec:  durationSec,
+			Type:         r.Type,
+			PathTemplate: r.PathTemplate,
+			Title:        title,
+			AssetID:      assetID,
+			RecurrenceID: r.ID,
+			ScheduleDate: date,
+		})
+	}
+	return programs, nil
+}
+
+// LocalDateTime は日付（YYYY-MM-DD）と時刻（HH:MMまたはHH:MM:SS）からタイムゾーン上の時刻を作成します
+func LocalDateTime(date, clock string, loc *time.Location) (time.Time, error) {
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("日付が不正です: %s", date)
+	}
+	offset, err := parseClock(clock)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	hours := int(offset / time.Hour)
+	minutes := int(offset % time.Hour / time.Minute)
+	seconds := int(offset % time.Minute / time.Second)
+	return time.Date(day.Year(), day.Month(), day.Day(), hours, minutes, seconds, 0, loc), nil
+}
+
+// parseClock はHH:MMまたはHH:MM:SS形式の時刻を0時からの経過時間に変換します
+func parseClock(clock string) (time.Duration, error) {
+	parts := strings.Split(clock, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("時刻はHH:MMまたはHH:MM:SS形式で指定してください: %q", clock)
+	}
+
+	values := make([]int, 3)
+	limits := []int{24, 60, 60}
+	for i, part := range parts {
+		value, err := strconv.Atoi(part)
+		if err != nil || value < 0 || value >= limits[i] {
+			return 0, fmt.Errorf("時刻はHH:MMまたはHH:MM:SS形式で指定してください: %q", clock)
+		}
+		values[i] = value
+	}
+
+	return time.Duration(values[0])*time.Hour + time.Duration(values[1])*time.Minute + time.Duration(values[2])*time.Second, nil
+}
@@ -2,15 +2,44 @@ package domain
 
 import (
 	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sort"
 	"time"
 )
 
+var (
+	ErrScheduleNotFound = errors.New("番組表が見つかりません")
+	ErrProgramNotFound  = errors.New("番組が見つかりません")
+	ErrVersionConflict  = errors.New("番組表のバージョンが一致しません")
+)
+
+// AnyVersion は楽観的排他制御を行わずに番組表を更新する場合に指定します
+const AnyVersion int64 = -1
+
 type RequestProgramItem struct {
 	StartTime    string `json:"start_time"`
 	DurationSec  int32  `json:"duration_sec"`
 	Type         string `json:"type"`
 	PathTemplate string `json:"path_template"`
 	Title        string `json:"title"`
+	AssetID      string `json:"asset_id"`
+	// InPoint/OutPointは素材の一部だけを放送する場合の開始・終了位置（秒）です（OutPointが0の場合は最後まで）
+	InPoint  float64 `json:"in_point"`
+	OutPoint float64 `json:"out_point"`
+	// Shortfall は素材が放送時間より短い場合の埋め方です（slate、loop、filler。空の場合はチャンネルの既定値）
+	Shortfall string `json:"shortfall"`
+	// Loop は素材を放送時間いっぱいまで繰り返す場合にtrueを指定します
+	Loop bool `json:"loop"`
+	// Assets は1つの枠で順に放送する複数のアセットのIDです（ブロック番組）
+	Assets []string `json:"assets"`
+	// Bumper は番組の終わりにバンパーを入れるかどうかです（on、off。空の場合はチャンネルの設定に従います）
+	Bumper string `json:"bumper"`
+	// AdBreaks は番組内の広告枠です（SCTE-35の合図としてプレイリストに出力します）
+	AdBreaks []AdBreak `json:"ad_breaks"`
+	// Interstitials はHLS Interstitialsとしてプレイヤーに再生させる告知などです
+	Interstitials []Interstitial `json:"interstitials"`
 }
 
 type RequestSchedule struct {
@@ -18,71 +47,341 @@ type RequestSchedule struct {
 }
 
 type ProgramItem struct {
-	StartTime    string `firestore:"start_time"`
-	DurationSec  int32  `firestore:"duration_sec"`
-	Type         string `firestore:"type"`
-	PathTemplate string `firestore:"path_template"`
-	Title        string `firestore:"title"`
+	ID           string  `firestore:"id"`
+	StartTime    string  `firestore:"start_time"`
+	DurationSec  int32   `firestore:"duration_sec"`
+	Type         string  `firestore:"type"`
+	PathTemplate string  `firestore:"path_template"`
+	Title        string  `firestore:"title"`
+	AssetID      string  `firestore:"asset_id"`
+	InPoint      float64 `firestore:"in_point"`
+	OutPoint     float64 `firestore:"out_point"`
+	Shortfall    string  `firestore:"shortfall"`
+	Loop         bool    `firestore:"loop"`
+	// Assets はブロック番組で順に放送するアセットのIDです（指定した場合はAssetIDの代わりに使用します）
+	Assets        []string       `firestore:"assets"`
+	Bumper        string         `firestore:"bumper"`
+	AdBreaks      []AdBreak      `firestore:"ad_breaks"`
+	Interstitials []Interstitial `firestore:"interstitials"`
+	// RecurrenceID は繰り返し番組から展開された番組の場合に元の繰り返し番組のIDを保持します
+	RecurrenceID string `firestore:"recurrence_id"`
+	// ScheduleDate は番組が登録されている番組表の日付です（読み込み時に設定されます）
+	ScheduleDate string `firestore:"-"`
 }
 
 type Schedule struct {
 	Programs []ProgramItem `firestore:"programs"`
+	Version  int64         `firestore:"version"`
+}
+
+// ProgramPatch は番組の部分更新で指定されたフィールドのみを保持します
+type ProgramPatch struct {
+	StartTime     *string         `json:"start_time"`
+	DurationSec   *int32          `json:"duration_sec"`
+	Type          *string         `json:"type"`
+	PathTemplate  *string         `json:"path_template"`
+	Title         *string         `json:"title"`
+	AssetID       *string         `json:"asset_id"`
+	InPoint       *float64        `json:"in_point"`
+	OutPoint      *float64        `json:"out_point"`
+	Shortfall     *string         `json:"shortfall"`
+	Loop          *bool           `json:"loop"`
+	Assets        *[]string       `json:"assets"`
+	Bumper        *string         `json:"bumper"`
+	AdBreaks      *[]AdBreak      `json:"ad_breaks"`
+	Interstitials *[]Interstitial `json:"interstitials"`
 }
 
 type ScheduleRepository interface {
 	GetScheduleByDate(ctx context.Context, date string) (*Schedule, error)
-	PostSchedule(ctx context.Context, request RequestProgramItem, date string) error
+	// UpdateScheduleByDate は番組表をトランザクション内で読み込み、mutateで変更した結果を保存します。
+	// ドキュメントが存在しない場合は空の番組表（Version 0）がmutateに渡されます。
+	// expectedVersionがAnyVersion以外で現在のバージョンと異なる場合はErrVersionConflictを返します
+	UpdateScheduleByDate(ctx context.Context, date string, expectedVersion int64, mutate func(schedule *Schedule) error) (*Schedule, error)
+}
+
+// NewProgramItem はリクエストから新しいIDを持つ番組を生成します
+func NewProgramItem(request RequestProgramItem) ProgramItem {
+	program := request.ToProgramItem()
+	program.ID = NewID()
+	return program
+}
+
+func (r RequestProgramItem) ToProgramItem() ProgramItem {
+	return ProgramItem{
+		StartTime:     r.StartTime,
+		DurationSec:   r.DurationSec,
+		Type:          r.Type,
+		PathTemplate:  r.PathTemplate,
+		Title:         r.Title,
+		AssetID:       r.AssetID,
+		InPoint:       r.InPoint,
+		OutPoint:      r.OutPoint,
+		Shortfall:     r.Shortfall,
+		Loop:          r.Loop,
+		Assets:        r.Assets,
+		Bumper:        r.Bumper,
+		AdBreaks:      r.AdBreaks,
+		Interstitials: r.Interstitials,
+	}
+}
+
+// ApplyPatch は指定されたフィールドのみを番組に反映します
+func (p *ProgramItem) ApplyPatch(patch ProgramPatch) {
+	if patch.StartTime != nil {
+		p.StartTime = *patch.StartTime
+	}
+	if patch.DurationSec != nil {
+		p.DurationSec = *patch.DurationSec
+	}
+	if patch.Type != nil {
+		p.Type = *patch.Type
+	}
+	if patch.PathTemplate != nil {
+		p.PathTemplate = *patch.PathTemplate
+	}
+	if patch.Title != nil {
+		p.Title = *patch.Title
+	}
+	if patch.AssetID != nil {
+		p.AssetID = *patch.AssetID
+	}
+	if patch.InPoint != nil {
+		p.InPoint = *patch.InPoint
+	}
+	if patch.OutPoint != nil {
+		p.OutPoint = *patch.OutPoint
+	}
+	if patch.Shortfall != nil {
+		p.Shortfall = *patch.Shortfall
+	}
+	if patch.Loop != nil {
+		p.Loop = *patch.Loop
+	}
+	if patch.Assets != nil {
+		p.Assets = *patch.Assets
+	}
+	if patch.Bumper != nil {
+		p.Bumper = *patch.Bumper
+	}
+	if patch.AdBreaks != nil {
+		p.AdBreaks = *patch.AdBreaks
+	}
+	if patch.Interstitials != nil {
+		p.Interstitials = *patch.Interstitials
+	}
+}
+
+// IsBlock は番組が複数のアセットを順に放送するブロック番組かどうかを返します
+func (p *ProgramItem) IsBlock() bool {
+	return len(p.Assets) > 0
+}
+
+// AssetIDs は番組が参照するアセットのIDを放送順に返します
+func (p *ProgramItem) AssetIDs() []string {
+	if p.IsBlock() {
+		return p.Assets
+	}
+	if p.AssetID != "" {
+		return []string{p.AssetID}
+	}
+	return nil
+}
+
+// FindProgramByID はIDに一致する番組とそのインデックスを返します
+func (s *Schedule) FindProgramByID(id string) (*ProgramItem, int) {
+	for index := range s.Programs {
+		if s.Programs[index].ID == id {
+			return &s.Programs[index], index
+		}
+	}
+	return nil, -1
+}
+
+// CheckVersion は期待するバージョンと現在のバージョンを比較します
+func (s *Schedule) CheckVersion(expectedVersion int64) error {
+	if expectedVersion != AnyVersion && expectedVersion != s.Version {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// ReplaceProgram はIDが一致する番組を置き換えます
+func (s *Schedule) ReplaceProgram(program ProgramItem) error {
+	_, index := s.FindProgramByID(program.ID)
+	if index < 0 {
+		return ErrProgramNotFound
+	}
+	s.Programs[index] = program
+	return nil
+}
+
+// RemoveProgram はIDが一致する番組を削除します
+func (s *Schedule) RemoveProgram(id string) error {
+	_, index := s.FindProgramByID(id)
+	if index < 0 {
+		return ErrProgramNotFound
+	}
+	s.Programs = append(s.Programs[:index], s.Programs[index+1:]...)
+	return nil
+}
+
+// HasProgramsWithoutID はIDを持たない番組があるかどうかを返します
+func (s *Schedule) HasProgramsWithoutID() bool {
+	for _, program := range s.Programs {
+		if program.ID == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// EnsureProgramIDs はIDを持たない既存の番組にIDを割り当てます
+func (s *Schedule) EnsureProgramIDs() {
+	for index := range s.Programs {
+		if s.Programs[index].ID == "" {
+			s.Programs[index].ID = NewID()
+		}
+	}
 }
 
 func (p *ProgramItem) GetStartTime() (time.Time, error) {
 	return time.Parse(time.RFC3339, p.StartTime)
 }
 
-func (p *ProgramItem) GetEndTime(jst *time.Location) (time.Time, error) {
+func (p *ProgramItem) GetEndTime(loc *time.Location) (time.Time, error) {
 	startTime, err := p.GetStartTime()
 	if err != nil {
 		return time.Time{}, err
 	}
 
-	startTimeJST := startTime.In(jst)
-	return startTimeJST.Add(time.Duration(p.DurationSec) * time.Second), nil
+	startTimeLocal := startTime.In(loc)
+	return startTimeLocal.Add(time.Duration(p.DurationSec) * time.Second), nil
 }
 
-func (p *ProgramItem) IsCurrentlyAiring(currentTime time.Time, jst *time.Location) bool {
+func (p *ProgramItem) IsCurrentlyAiring(currentTime time.Time, loc *time.Location) bool {
 	startTime, err := p.GetStartTime()
 	if err != nil {
 		return false
 	}
 
-	startTimeJST := startTime.In(jst)
-	endTime, err := p.GetEndTime(jst)
+	startTimeLocal := startTime.In(loc)
+	endTime, err := p.GetEndTime(loc)
 	if err != nil {
 		return false
 	}
 
-	return currentTime.After(startTimeJST) && currentTime.Before(endTime)
+	return currentTime.After(startTimeLocal) && currentTime.Before(endTime)
 }
 
-func FindCurrentProgram(schedule []ProgramItem, currentTime time.Time, jst *time.Location) (*ProgramItem, int) {
+func FindCurrentProgram(schedule []ProgramItem, currentTime time.Time, loc *time.Location) (*ProgramItem, int) {
 	for index, program := range schedule {
-		if program.IsCurrentlyAiring(currentTime, jst) {
+		if program.IsCurrentlyAiring(currentTime, loc) {
 			return &program, index
 		}
 	}
 	return nil, -1
 }
 
-func FindNextProgram(schedule []ProgramItem, currentTime time.Time, jst *time.Location) *ProgramItem {
+func FindNextProgram(schedule []ProgramItem, currentTime time.Time, loc *time.Location) *ProgramItem {
 	for _, program := range schedule {
 		startTime, err := program.GetStartTime()
 		if err != nil {
 			continue
 		}
 
-		startTimeJST := startTime.In(jst)
-		if startTimeJST.After(currentTime) {
+		startTimeLocal := startTime.In(loc)
+		if startTimeLocal.After(currentTime) {
 			return &program
 		}
 	}
 	return nil
 }
+
+// WindowDates は基準時刻の前日・当日・翌日の日付を返します
+func WindowDates(currentTime time.Time, loc *time.Location) []string {
+	local := currentTime.In(loc)
+	dates := make([]string, 0, 3)
+	for offset := -1; offset <= 1; offset++ {
+		day := time.Date(local.Year(), local.Month(), local.Day()+offset, 12, 0, 0, 0, loc)
+		dates = append(dates, day.Format("2006-01-02"))
+	}
+	return dates
+}
+
+// DatesBetween はfromからtoまでに含まれる日付を順に返します（夏時間の切り替えがあっても日付を飛ばしません）
+func DatesBetween(from, to time.Time, loc *time.Location) []string {
+	start := from.In(loc)
+	lastDate := to.In(loc).Format("2006-01-02")
+
+	dates := make([]string, 0)
+	for offset := 0; ; offset++ {
+		date := time.Date(start.Year(), start.Month(), start.Day()+offset, 12, 0, 0, 0, loc).Format("2006-01-02")
+		if date > lastDate {
+			return dates
+		}
+		dates = append(dates, date)
+	}
+}
+
+// MergeSchedules は日付ごとの番組表を1つの番組リストにまとめ、開始時刻順に並べます。
+// 同じIDの番組が複数の日にある場合は、古い日付の番組を残します
+func MergeSchedules(schedules map[string]*Schedule) []ProgramItem {
+	merged := make([]ProgramItem, 0)
+	seen := make(map[string]bool)
+
+	// mapの順序に依存せず同じ結果になるよう、日付順に処理する
+	for _, date := range slices.Sorted(maps.Keys(schedules)) {
+		schedule := schedules[date]
+		if schedule == nil {
+			continue
+		}
+		for _, program := range schedule.Programs {
+			if program.ID != "" {
+				if seen[program.ID] {
+					continue
+				}
+				seen[program.ID] = true
+			}
+			program.ScheduleDate = date
+			merged = append(merged, program)
+		}
+	}
+
+	SortProgramsByStartTime(merged)
+	return merged
+}
+
+// SortProgramsByStartTime は番組を開始時刻順に並べます。開始時刻を解析できない番組は末尾に置かれます
+func SortProgramsByStartTime(programs []ProgramItem) {
+	// 比較のたびに開始時刻を解析しないよう、先にまとめて解析する
+	type keyedProgram struct {
+		program ProgramItem
+		start   time.Time
+		valid   bool
+	}
+	keyed := make([]keyedProgram, len(programs))
+	for index, program := range programs {
+		start, err := program.GetStartTime()
+		keyed[index] = keyedProgram{program: program, start: start, valid: err == nil}
+	}
+
+	sort.SliceStable(keyed, func(i, j int) bool {
+		switch {
+		case !keyed[i].valid && !keyed[j].valid:
+			return keyed[i].program.ScheduleDate < keyed[j].program.ScheduleDate
+		case !keyed[i].valid:
+			return false
+		case !keyed[j].valid:
+			return true
+		case keyed[i].start.Equal(keyed[j].start):
+			return keyed[i].program.ScheduleDate < keyed[j].program.ScheduleDate
+		default:
+			return keyed[i].start.Before(keyed[j].start)
+		}
+	})
+
+	for index := range keyed {
+		programs[index] = keyed[index].program
+	}
+}
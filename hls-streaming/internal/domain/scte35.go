@@ -0,0 +1,78 @@
+package domain
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"strings"
+)
+
+// scte35Timescale はSCTE-35の時刻を表す90kHzのクロックです
+const scte35Timescale = 90000
+
+// EncodeSpliceInsert はsplice_insertコマンドを含むSCTE-35のsplice_info_sectionを生成します。
+// outがtrueの場合は広告への切り替え（CUE-OUT）、falseの場合は番組への復帰（CUE-IN）を表し、
+// durationSecが0より大きい場合はbreak_durationを付けます
+func EncodeSpliceInsert(eventID uint32, out bool, durationSec float64) []byte {
+	command := make([]byte, 0, 20)
+	command = binary.BigEndian.AppendUint32(command, eventID)
+	// splice_event_cancel_indicator=0, reserved
+	command = append(command, 0x7F)
+
+	// program_splice_flag=1, splice_immediate_flag=1, event_id_compliance_flag=1, reserved
+	flags := byte(0x40 | 0x10 | 0x08 | 0x07)
+	if out {
+		flags |= 0x80
+	}
+	if durationSec > 0 {
+		flags |= 0x20
+	}
+	command = append(command, flags)
+
+	if durationSec > 0 {
+		// auto_return=1, reserved, duration(33bit)
+		ticks := uint64(math.Round(durationSec*scte35Timescale)) & 0x1FFFFFFFF
+		command = append(command, byte(0x80|0x7E|(ticks>>32)&0x01))
+		command = binary.BigEndian.AppendUint32(command, uint32(ticks))
+	}
+	// unique_program_id, avail_num, avails_expected
+	command = append(command, 0x00, 0x00, 0x00, 0x00)
+
+	// section_lengthの後からCRC_32までの長さ
+	sectionLength := 11 + len(command) + 2 + 4
+
+	section := make([]byte, 0, 3+sectionLength)
+	// table_id, section_syntax_indicator=0, private_indicator=0, sap_type=3, section_length
+	section = append(section, 0xFC, 0x30|byte(sectionLength>>8)&0x0F, byte(sectionLength))
+	// protocol_version, encrypted_packet=0, encryption_algorithm=0, pts_adjustment=0
+	section = append(section, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00)
+	// cw_index, tier=0xFFF, splice_command_length
+	section = append(section, 0x00, 0xFF, 0xF0|byte(len(command)>>8)&0x0F, byte(len(command)))
+	// splice_command_type=splice_insert
+	section = append(section, 0x05)
+	section = append(section, command...)
+	// descriptor_loop_length
+	section = append(section, 0x00, 0x00)
+	return binary.BigEndian.AppendUint32(section, MPEG2CRC32(section))
+}
+
+// SCTE35Hex はSCTE-35のバイナリをEXT-X-DATERANGEの属性値として使う16進数表記（0x...）に変換します
+func SCTE35Hex(section []byte) string {
+	return "0x" + strings.ToUpper(hex.EncodeToString(section))
+}
+
+// MPEG2CRC32 はMPEG-2のセクションで使用するCRC-32を計算します
+func MPEG2CRC32(data []byte) uint32 {
+	crc := uint32(0xFFFFFFFF)
+	for _, b := range data {
+		crc ^= uint32(b) << 24
+		for i := 0; i < 8; i++ {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04C11DB7
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,209 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var ErrSessionNotFound = errors.New("セッションが見つかりません")
+
+// Session はライブプレイリストを視聴者ごとに生成するためのセッションです。
+// 番組が切り替わってもメディアシーケンス番号が連続するよう、番組ごとの基準を保持します
+type Session struct {
+	ID        string    `firestore:"id" json:"id"`
+	CreatedAt time.Time `firestore:"created_at" json:"created_at"`
+	ExpiresAt time.Time `firestore:"expires_at" json:"expires_at"`
+
+	// ProgramKey は前回のプレイリストで放送中だった番組です
+	ProgramKey string `firestore:"program_key" json:"program_key"`
+	// SequenceBase、DiscontinuityBase は放送中の番組の先頭セグメントのシーケンス番号です
+	SequenceBase      int `firestore:"sequence_base" json:"sequence_base"`
+	DiscontinuityBase int `firestore:"discontinuity_base" json:"discontinuity_base"`
+	// NextProgramKey は次の番組で、Next*Baseはその番組に切り替わったときのシーケンス番号です
+	NextProgramKey        string `firestore:"next_program_key" json:"next_program_key"`
+	NextSequenceBase      int    `firestore:"next_sequence_base" json:"next_sequence_base"`
+	NextDiscontinuityBase int    `firestore:"next_discontinuity_base" json:"next_discontinuity_base"`
+
+	// PlaybackAuthorized は再生トークンで開始したセッションかどうかです（PlaybackIPはトークンで指定されたIPアドレス）
+	PlaybackAuthorized bool   `firestore:"playback_authorized" json:"playback_authorized"`
+	PlaybackIP         string `firestore:"playback_ip" json:"playback_ip,omitempty"`
+	// PlaybackChannel、PlaybackExpiresAt は再生を許可したトークンのチャンネルと有効期限です
+	PlaybackChannel   string    `firestore:"playback_channel" json:"playback_channel,omitempty"`
+	PlaybackExpiresAt time.Time `firestore:"playback_expires_at" json:"playback_expires_at"`
+	// Viewer は再生トークンで指定された視聴者のIDです
+	Viewer string `firestore:"viewer" json:"viewer,omitempty"`
+}
+
+// SessionStore はセッションを保存するストアです。
+// 複数のレプリカで配信する場合は共有のストアを使用します
+type SessionStore interface {
+	GetSession(ctx context.Context, id string) (*Session, error)
+	SaveSession(ctx context.Context, session Session) error
+	// DeleteExpiredSessions はnowの時点で期限切れのセッションを削除し、削除した件数を返します
+	DeleteExpiredSessions(ctx context.Context, now time.Time) (int, error)
+}
+
+// SessionViewer は再生トークンでセッションの再生を許可した視聴者の記録です。
+// 流出した録画から特定したセッションを視聴者までたどれるよう、セッションの削除後も保持します
+type SessionViewer struct {
+	SessionID    string    `firestore:"session_id" json:"session_id"`
+	Viewer       string    `firestore:"viewer" json:"viewer"`
+	Channel      string    `firestore:"channel" json:"channel"`
+	IP           string    `firestore:"ip" json:"ip,omitempty"`
+	AuthorizedAt time.Time `firestore:"authorized_at" json:"authorized_at"`
+}
+
+// SessionViewerStore はセッションと視聴者の対応を保持するストアです。
+// 期限切れのセッションを削除しても記録は削除しません
+type SessionViewerStore interface {
+	RecordSessionViewer(ctx context.Context, viewer SessionViewer) error
+	// ListSessionViewers はセッションで再生を許可した視聴者を許可した順に返します
+	ListSessionViewers(ctx context.Context, sessionID string) ([]SessionViewer, error)
+}
+
+// NewSession は新しいセッションを生成します
+func NewSession(now time.Time, ttl time.Duration) Session {
+	return Session{
+		ID:        NewID(),
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+}
+
+// IsSessionID はNewSessionが生成する形式のセッションIDかどうかを返します
+func IsSessionID(id string) bool {
+	return isHexID(id, 20)
+}
+
+// Expired はnowの時点でセッションが期限切れかどうかを返します
+func (s *Session) Expired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
+
+// AuthorizePlayback は再生トークンの内容でセッションの再生を許可します。
+// 許可はトークンの有効期限までで、セッションの有効期限もトークンの有効期限を超えないようにします
+func (s *Session) AuthorizePlayback(claims *PlaybackClaims) {
+	s.PlaybackAuthorized = true
+	s.PlaybackIP = claims.IP
+	s.PlaybackChannel = claims.Channel
+	s.PlaybackExpiresAt = time.Unix(claims.ExpiresAt, 0)
+	s.Viewer = claims.Subject
+	if s.ExpiresAt.After(s.PlaybackExpiresAt) {
+		s.ExpiresAt = s.PlaybackExpiresAt
+	}
+}
+
+// AuthorizedBy はセッションがclaimsの再生トークンで再生を許可されているかどうかを返します
+func (s *Session) AuthorizedBy(claims *PlaybackClaims) bool {
+	return s.PlaybackAuthorized &&
+		s.PlaybackIP == claims.IP &&
+		s.PlaybackChannel == claims.Channel &&
+		s.PlaybackExpiresAt.Equal(time.Unix(claims.ExpiresAt, 0)) &&
+		s.Viewer == claims.Subject
+}
+
+// Extend はセッションの有効期限をnowからttl後まで延長します。
+// 再生トークンで許可したセッションはトークンの有効期限より後には延長しません
+func (s *Session) Extend(now time.Time, ttl time.Duration) {
+	s.ExpiresAt = now.Add(ttl)
+	if s.PlaybackAuthorized && s.ExpiresAt.After(s.PlaybackExpiresAt) {
+		s.ExpiresAt = s.PlaybackExpiresAt
+	}
+}
+
+// PlaybackAllowed はchannelのipからのリクエストにセッションでの再生を許可するかどうかを返します。
+// 再生を許可したトークンの有効期限が切れた場合は、新しいトークンで許可し直すまで再生を許可しません
+func (s *Session) PlaybackAllowed(channel, ip string, now time.Time) bool {
+	return s.PlaybackAuthorized &&
+		!s.Expired(now) &&
+		now.Before(s.PlaybackExpiresAt) &&
+		s.PlaybackChannel == channel &&
+		(s.PlaybackIP == "" || s.PlaybackIP == ip)
+}
+
+// Advance は放送中の番組とウィンドウの先頭のインデックスから、セッションで連続する
+// EXT-X-MEDIA-SEQUENCEとEXT-X-DISCONTINUITY-SEQUENCEの値を返します。
+// nextは次の番組で、プレイリストの末尾に次の番組をつなげた場合と同じ番号で切り替わるよう基準を更新します
+func (s *Session) Advance(current, next *ProgramItem, playlist *M3U8Playlist, startIndex int) (int, int) {
+	key := sessionProgramKey(current)
+	switch {
+	case key == s.ProgramKey:
+	case key == s.NextProgramKey:
+		s.SequenceBase = s.NextSequenceBase
+		s.DiscontinuityBase = s.NextDiscontinuityBase
+	case s.ProgramKey != "":
+		// 予定外の番組に切り替わった場合は、つなげた次の番組の分（最大でPlaylistLength）より後の番号から続ける
+		s.SequenceBase = s.NextSequenceBase + PlaylistLength - startIndex
+		s.DiscontinuityBase = s.NextDiscontinuityBase + PlaylistLength - playlist.DiscontinuitySequence(startIndex)
+	}
+
+	s.ProgramKey = key
+	s.NextProgramKey = ""
+	if next != nil {
+		s.NextProgramKey = sessionProgramKey(next)
+	}
+	s.NextSequenceBase = s.SequenceBase + playlist.Len()
+	// 次の番組との境目にはEXT-X-DISCONTINUITYが1つ入る
+	s.NextDiscontinuityBase = s.DiscontinuityBase + playlist.DiscontinuitySequence(playlist.Len()) + 1
+
+	return s.SequenceBase + startIndex, s.DiscontinuityBase + playlist.DiscontinuitySequence(startIndex)
+}
+
+// sessionProgramKey は放送回を識別するキーです（同じIDの番組でも開始時刻が違えば別の放送回です）
+func sessionProgramKey(program *ProgramItem) string {
+	return program.ID + "@" + program.StartTime
+}
+
+// ForSession はインタースティシャルのURIにセッションID（sidクエリ）を付けた放送内容を返します。
+// 広告枠のアセットリストは視聴者ごとに広告を判定するため、再生トークンで保護する場合はセッションで認可するために使用します
+func (p *M3U8Playlist) ForSession(sessionID string) *M3U8Playlist {
+	if sessionID == "" || len(p.cues) == 0 {
+		return p
+	}
+
+	result := *p
+	result.cues = make(map[int][]Cue, len(p.cues))
+	for index, cues := range p.cues {
+		personalized := make([]Cue, 0, len(cues))
+		for _, cue := range cues {
+			if cue.Type == CueInterstitial {
+				cue.AssetURI = SessionURI(cue.AssetURI, sessionID)
+				cue.AssetList = SessionURI(cue.AssetList, sessionID)
+			}
+			personalized = append(personalized, cue)
+		}
+		result.cues[index] = personalized
+	}
+	return &result
+}
+
+// SessionURI はサーバーのURIにセッションID（sidクエリ）を付けます（uriが空の場合は空文字）
+func SessionURI(uri, sessionID string) string {
+	if uri == "" || sessionID == "" {
+		return uri
+	}
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	query := parsed.Query()
+	query.Set("sid", sessionID)
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+// isHexID はlength文字の16進数（小文字）の文字列かどうかを返します
+func isHexID(id string, length int) bool {
+	if len(id) != length {
+		return false
+	}
+	for _, r := range id {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,303 @@
+package domain
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidVAST = errors.New("VASTレスポンスが不正です")
+
+// AdCreative は広告判定サーバーが返した1本の広告素材です
+type AdCreative struct {
+	AdID        string
+	CreativeID  string
+	MediaURL    string
+	DurationSec float64
+	// Impressions は広告を配信した際に通知するインプレッションビーコンのURLです
+	Impressions []string
+}
+
+// AdRequest は広告枠に対する広告判定のリクエストです
+type AdRequest struct {
+	BreakID     string
+	ProgramID   string
+	DurationSec float64
+	StartDate   time.Time
+	// SessionID は視聴者ごとに広告を判定する場合のセッションIDです（空の場合は全視聴者で共通）
+	SessionID string
+}
+
+// AdDecisionClient は広告枠に挿入する広告を判定するサーバー（VASTなど）のクライアントです
+type AdDecisionClient interface {
+	RequestAds(ctx context.Context, request AdRequest) ([]AdCreative, error)
+}
+
+type vastDocument struct {
+	XMLName xml.Name `xml:"VAST"`
+	Ads     []vastAd `xml:"Ad"`
+}
+
+type vastAd struct {
+	ID       string      `xml:"id,attr"`
+	Sequence int         `xml:"sequence,attr"`
+	InLine   *vastInLine `xml:"InLine"`
+}
+
+type vastInLine struct {
+	Impressions []string       `xml:"Impression"`
+	Creatives   []vastCreative `xml:"Creatives>Creative"`
+}
+
+type vastCreative struct {
+	ID     string      `xml:"id,attr"`
+	Linear *vastLinear `xml:"Linear"`
+}
+
+type vastLinear struct {
+	Duration   string          `xml:"Duration"`
+	MediaFiles []vastMediaFile `xml:"MediaFiles>MediaFile"`
+}
+
+type vastMediaFile struct {
+	Type     string `xml:"type,attr"`
+	Delivery string `xml:"delivery,attr"`
+	URL      string `xml:",chardata"`
+}
+
+// ParseVAST はVASTレスポンスからリニア広告の素材を取り出します。
+// sequenceが指定された広告（アドポッド）はその順に並べ、InLineでない広告（Wrapper）は対象外です
+func ParseVAST(data []byte) ([]AdCreative, error) {
+	var document vastDocument
+	if err := xml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidVAST, err)
+	}
+
+	ads := document.Ads
+	sort.SliceStable(ads, func(i, j int) bool {
+		return ads[i].Sequence < ads[j].Sequence
+	})
+
+	creatives := make([]AdCreative, 0)
+	for _, ad := range ads {
+		if ad.InLine == nil {
+			continue
+		}
+		impressions := make([]string, 0, len(ad.InLine.Impressions))
+		for _, impression := range ad.InLine.Impressions {
+			if impression = strings.TrimSpace(impression); impression != "" {
+				impressions = append(impressions, impression)
+			}
+		}
+
+		for _, creative := range ad.InLine.Creatives {
+			if creative.Linear == nil {
+				continue
+			}
+			duration, err := parseVASTDuration(creative.Linear.Duration)
+			if err != nil {
+				return nil, fmt.Errorf("%w: 広告(%s)の長さ: %v", ErrInvalidVAST, ad.ID, err)
+			}
+			mediaURL := selectMediaFile(creative.Linear.MediaFiles)
+			if mediaURL == "" {
+				continue
+			}
+			creatives = append(creatives, AdCreative{
+				AdID:        ad.ID,
+				CreativeID:  creative.ID,
+				MediaURL:    mediaURL,
+				DurationSec: duration,
+				Impressions: impressions,
+			})
+		}
+	}
+	return creatives, nil
+}
+
+// selectMediaFile は変換元に使う素材のURLを選びます（progressiveのmp4を優先します）
+func selectMediaFile(files []vastMediaFile) string {
+	selected := ""
+	for _, file := range files {
+		mediaURL := strings.TrimSpace(file.URL)
+		if mediaURL == "" {
+			continue
+		}
+		if file.Type == "video/mp4" && file.Delivery != "streaming" {
+			return mediaURL
+		}
+		if selected == "" {
+			selected = mediaURL
+		}
+	}
+	return selected
+}
+
+// parseVASTDuration はVASTの長さ（HH:MM:SS または HH:MM:SS.mmm）を秒数に変換します
+func parseVASTDuration(duration string) (float64, error) {
+	parts := strings.Split(strings.TrimSpace(duration), ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("HH:MM:SS形式ではありません: %q", duration)
+	}
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, fmt.Errorf("HH:MM:SS形式ではありません: %q", duration)
+	}
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, fmt.Errorf("HH:MM:SS形式ではありません: %q", duration)
+	}
+	seconds, err := strconv.ParseFloat(parts[2], 64)
+	if err != nil {
+		return 0, fmt.Errorf("HH:MM:SS形式ではありません: %q", duration)
+	}
+	return float64(hours*3600+minutes*60) + seconds, nil
+}
+
+// ExpandAdURL は広告判定サーバーのURLのマクロ（[BREAK_ID]、[PROGRAM_ID]、[DURATION]、[TIMESTAMP]）を置き換えます
+func ExpandAdURL(template string, request AdRequest) string {
+	replacer := strings.NewReplacer(
+		"[BREAK_ID]", url.QueryEscape(request.BreakID),
+		"[PROGRAM_ID]", url.QueryEscape(request.ProgramID),
+		"[DURATION]", strconv.Itoa(int(request.DurationSec)),
+		"[TIMESTAMP]", strconv.FormatInt(request.StartDate.Unix(), 10),
+		"[SESSION_ID]", url.QueryEscape(request.SessionID),
+	)
+	return replacer.Replace(template)
+}
+
+// SelectAds は広告枠の長さに収まる広告を先頭から選びます
+func SelectAds(creatives []AdCreative, durationSec float64) []AdCreative {
+	selected := make([]AdCreative, 0, len(creatives))
+	var total float64
+	for _, creative := range creatives {
+		if creative.DurationSec <= 0 || total+creative.DurationSec > durationSec+timelineEpsilon {
+			continue
+		}
+		selected = append(selected, creative)
+		total += creative.DurationSec
+	}
+	return selected
+}
+
+// CreativeKey は広告素材の変換結果を保存するキーを素材のURLから生成します
+func CreativeKey(mediaURL string) string {
+	sum := sha1.Sum([]byte(mediaURL))
+	return hex.EncodeToString(sum[:10])
+}
+
+// CueOuts は番組の放送内容に含まれる広告枠の開始の合図を返します
+func (p *M3U8Playlist) CueOuts() []Cue {
+	cues := make([]Cue, 0)
+	for i := 0; i < p.Len(); i++ {
+		for _, cue := range p.SegmentAt(i).Cues {
+			if cue.Type == CueOut && cue.ID != "" {
+				cues = append(cues, cue)
+			}
+		}
+	}
+	return cues
+}
+
+// InterstitialAdRequests はインタースティシャルとして再生する広告枠の広告判定のリクエストを返します
+func (p *M3U8Playlist) InterstitialAdRequests() []AdRequest {
+	requests := make([]AdRequest, 0)
+	for i := 0; i < p.Len(); i++ {
+		for _, cue := range p.SegmentAt(i).Cues {
+			if cue.Type != CueInterstitial || cue.AssetList == "" {
+				continue
+			}
+			assetList, err := url.Parse(cue.AssetList)
+			if err != nil {
+				continue
+			}
+			if request, err := ParseAdAssetListQuery(assetList.Query()); err == nil {
+				requests = append(requests, request)
+			}
+		}
+	}
+	return requests
+}
+
+// InsertAds は広告枠（CUE-OUTからCUE-INまで）のセグメントを広告のプレイリストに置き換えます。
+// adsは広告枠のIDごとの広告です。広告は枠の長さで切り、足りない時間は静止画で埋めます
+func (p *M3U8Playlist) InsertAds(ads map[string]*M3U8Playlist) *M3U8Playlist {
+	segments := make([]M3U8Segment, 0, p.Len())
+	for i := 0; i < p.Len(); i++ {
+		segments = append(segments, p.SegmentAt(i))
+	}
+
+	result := *p
+	result.loopLength = 0
+	result.tail = nil
+	result.cues = nil
+	result.Segments = make([]M3U8Segment, 0, len(segments))
+
+	for i := 0; i < len(segments); {
+		segment := segments[i]
+		id := ""
+		for _, cue := range segment.Cues {
+			if cue.Type == CueOut && ads[cue.ID] != nil {
+				id = cue.ID
+			}
+		}
+		if id == "" {
+			result.Segments = append(result.Segments, segment)
+			i++
+			continue
+		}
+
+		// 広告枠の終わりはCUE-INを付けたセグメント（なければ番組の終わり）
+		end := i + 1
+		for end < len(segments) && !hasCue(segments[end], CueIn, id) {
+			end++
+		}
+
+		adSegments := fitSegments(ads[id].Segments, segmentsDuration(segments[i:end]), p.TargetDuration)
+		if len(adSegments) == 0 {
+			result.Segments = append(result.Segments, segments[i:end]...)
+			i = end
+			continue
+		}
+		adSegments[0].Cues = segment.Cues
+		adSegments[0].Discontinuity = true
+		result.Segments = append(result.Segments, adSegments...)
+		result.TargetDuration = maxTargetDuration(result.TargetDuration, adSegments)
+
+		if end < len(segments) {
+			segments[end].Discontinuity = true
+		}
+		i = end
+	}
+	return &result
+}
+
+// fitSegments はセグメントを指定した長さに収め、足りない時間を静止画で埋めます
+func fitSegments(segments []M3U8Segment, duration float64, targetDuration int) []M3U8Segment {
+	fitted := make([]M3U8Segment, 0, len(segments))
+	var position float64
+	for _, segment := range segments {
+		if position+segment.Duration > duration+timelineEpsilon {
+			break
+		}
+		fitted = append(fitted, segment)
+		position += segment.Duration
+	}
+	return append(fitted, slateSegments(targetDuration, duration-position)...)
+}
+
+func hasCue(segment M3U8Segment, cueType CueType, id string) bool {
+	for _, cue := range segment.Cues {
+		if cue.Type == cueType && cue.ID == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,270 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrTemplateNotFound = errors.New("テンプレートが見つかりません")
+	ErrInvalidTemplate  = errors.New("テンプレートの設定が不正です")
+)
+
+// TemplateApplyMode は番組が既に存在する日にテンプレートを適用するときの動作です
+type TemplateApplyMode string
+
+const (
+	// TemplateApplySkip は番組が存在する日を変更せず、競合として報告します
+	TemplateApplySkip TemplateApplyMode = "skip"
+	// TemplateApplyReplace は既存の番組をすべてテンプレートの番組で置き換えます
+	TemplateApplyReplace TemplateApplyMode = "replace"
+	// TemplateApplyMerge は既存の番組を残したままテンプレートの番組を追加します（重複する場合は競合）
+	TemplateApplyMerge TemplateApplyMode = "merge"
+)
+
+type TemplateDayStatus string
+
+const (
+	TemplateDayApplied  TemplateDayStatus = "applied"
+	TemplateDayReplaced TemplateDayStatus = "replaced"
+	TemplateDayMerged   TemplateDayStatus = "merged"
+	TemplateDayConflict TemplateDayStatus = "conflict"
+	TemplateDayEmpty    TemplateDayStatus = "empty"
+)
+
+// maxTemplateApplyDays は1回の適用で生成できる最大日数です
+const maxTemplateApplyDays = 366
+
+// TemplateSlot は週間テンプレート上の1枠です。StartTimeは曜日の0時からの現地時刻（HH:MM）です
+type TemplateSlot struct {
+	Weekday      string `firestore:"weekday" json:"weekday"`
+	StartTime    string `firestore:"start_time" json:"start_time"`
+	DurationSec  int32  `firestore:"duration_sec" json:"duration_sec"`
+	Type         string `firestore:"type" json:"type"`
+	PathTemplate string `firestore:"path_template" json:"path_template"`
+	Title        string `firestore:"title" json:"title"`
+	AssetID      string `firestore:"asset_id" json:"asset_id"`
+}
+
+// ScheduleTemplate は曜日ごとの番組枠をまとめた週間テンプレートです
+type ScheduleTemplate struct {
+	ID    string         `firestore:"id" json:"id"`
+	Name  string         `firestore:"name" json:"name"`
+	Slots []TemplateSlot `firestore:"slots" json:"slots"`
+}
+
+// ReferencesAsset はテンプレートのいずれかの枠がアセットを参照しているかどうかを返します
+func (t *ScheduleTemplate) ReferencesAsset(assetID string) bool {
+	for _, slot := range t.Slots {
+		if slot.AssetID == assetID {
+			return true
+		}
+	}
+	return false
+}
+
+type TemplateRepository interface {
+	ListTemplates(ctx context.Context) ([]ScheduleTemplate, error)
+	GetTemplate(ctx context.Context, id string) (*ScheduleTemplate, error)
+	SaveTemplate(ctx context.Context, template ScheduleTemplate) error
+	DeleteTemplate(ctx context.Context, id string) error
+}
+
+// GeneratedDayRecorder はテンプレートや自動編成で番組表に反映した日ごとに、反映した直後に変更前後の番組を受け取ります。
+// エラーを返した場合は以降の日を反映せずに中止します
+type GeneratedDayRecorder func(date string, before, after []ProgramItem) error
+
+// TemplateDayResult はテンプレートを1日分適用した結果です
+type TemplateDayResult struct {
+	Date          string            `json:"date"`
+	Status        TemplateDayStatus `json:"status"`
+	ProgramCount  int               `json:"program_count"`
+	ExistingCount int               `json:"existing_count"`
+	Violations    []Violation       `json:"violations,omitempty"`
+}
+
+// TemplateApplyResult はテンプレートを期間に適用した結果です
+type TemplateApplyResult struct {
+	TemplateID    string              `json:"template_id"`
+	From          string              `json:"from"`
+	To            string              `json:"to"`
+	Mode          TemplateApplyMode   `json:"mode"`
+	DryRun        bool                `json:"dry_run"`
+	Days          []TemplateDayResult `json:"days"`
+	AppliedCount  int                 `json:"applied_count"`
+	ConflictCount int                 `json:"conflict_count"`
+}
+
+func (r *TemplateApplyResult) Add(day TemplateDayResult) {
+	r.Days = append(r.Days, day)
+	switch day.Status {
+	case TemplateDayApplied, TemplateDayReplaced, TemplateDayMerged:
+		r.AppliedCount++
+	case TemplateDayConflict:
+		r.ConflictCount++
+	}
+}
+
+// ParseTemplateApplyMode は適用モードを解釈します（空の場合はskip）
+func ParseTemplateApplyMode(mode string) (TemplateApplyMode, error) {
+	switch TemplateApplyMode(mode) {
+	case "", TemplateApplySkip:
+		return TemplateApplySkip, nil
+	case TemplateApplyReplace, TemplateApplyMerge:
+		return TemplateApplyMode(mode), nil
+	}
+	return "", fmt.Errorf("modeはskip、replace、mergeのいずれかを指定してください: %q", mode)
+}
+
+// Validate はテンプレートの設定を検証します。不正な場合はErrInvalidTemplateをラップしたエラーを返します
+func (t *ScheduleTemplate) Validate() error {
+	if err := t.validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+	}
+	return nil
+}
+
+func (t *ScheduleTemplate) validate() error {
+	if t.Name == "" {
+		return fmt.Errorf("nameを指定してください")
+	}
+	for i, slot := range t.Slots {
+		if _, ok := rruleWeekdays[slot.Weekday]; !ok {
+			return fmt.Errorf("枠%d: weekdayはMO〜SUのいずれかを指定してください: %q", i, slot.Weekday)
+		}
+		if _, err := parseClock(slot.StartTime); err != nil {
+			return fmt.Errorf("枠%d: %v", i, err)
+		}
+	}
+
+	// 基準となる1週間（UTC）に翌週の月曜日を加えて展開し、枠の内容と曜日をまたぐ重複
+	// （日曜日の深夜から翌週の月曜日の最初の枠にかかる場合を含む）を検証する
+	week := make([]ProgramItem, 0, len(t.Slots))
+	for _, date := range []string{"2024-01-01", "2024-01-02", "2024-01-03", "2024-01-04", "2024-01-05", "2024-01-06", "2024-01-07", "2024-01-08"} {
+		programs, err := t.ProgramsForDate(date, time.UTC)
+		if err != nil {
+			return err
+		}
+		week = append(week, programs...)
+	}
+	report := ValidateSchedule(week, nil)
+	for _, violation := range report.Violations {
+		if violation.Severity == SeverityError {
+			return errors.New(violation.Message)
+		}
+	}
+	return nil
+}
+
+// ProgramsForDate は指定した日付の曜日に該当する枠から番組を生成します。番組には新しいIDが割り当てられます
+func (t *ScheduleTemplate) ProgramsForDate(date string, loc *time.Location) ([]ProgramItem, error) {
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return nil, fmt.Errorf("日付が不正です: %s", date)
+	}
+
+	programs := make([]ProgramItem, 0)
+	for _, slot := range t.Slots {
+		if rruleWeekdays[slot.Weekday] != day.Weekday() {
+			continue
+		}
+		startTime, err := LocalDateTime(date, slot.StartTime, loc)
+		if err != nil {
+			return nil, err
+		}
+		program := NewProgramItem(RequestProgramItem{
+			StartTime:    startTime.Format(time.RFC3339),
+			DurationSec:  slot.DurationSec,
+			Type:         slot.Type,
+			PathTemplate: slot.PathTemplate,
+			Title:        slot.Title,
+			AssetID:      slot.AssetID,
+		})
+		program.ScheduleDate = date
+		programs = append(programs, program)
+	}
+
+	SortProgramsByStartTime(programs)
+	return programs, nil
+}
+
+// DateRange はfrom〜to（両端を含む、YYYY-MM-DD）の日付一覧を返します
+func DateRange(from, to string) ([]string, error) {
+	start, err := time.Parse("2006-01-02", from)
+	if err != nil {
+		return nil, fmt.Errorf("fromはYYYY-MM-DD形式で指定してください: %s", from)
+	}
+	end, err := time.Parse("2006-01-02", to)
+	if err != nil {
+		return nil, fmt.Errorf("toはYYYY-MM-DD形式で指定してください: %s", to)
+	}
+	if end.Before(start) {
+		return nil, fmt.Errorf("toはfrom以降の日付を指定してください")
+	}
+
+	dates := make([]string, 0)
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		if len(dates) >= maxTemplateApplyDays {
+			return nil, fmt.Errorf("一度に指定できる期間は%d日までです", maxTemplateApplyDays)
+		}
+		dates = append(dates, day.Format("2006-01-02"))
+	}
+	return dates, nil
+}
+
+// ApplyTemplateDay はテンプレートから生成した1日分の番組をモードに従って番組表に反映します。
+// occurrencesにはその日と前後の日に展開される繰り返し番組を渡します。競合した場合、番組表は変更されません
+func ApplyTemplateDay(schedule *Schedule, programs, occurrences []ProgramItem, mode TemplateApplyMode, assetExists func(id string) bool) TemplateDayResult {
+	result := TemplateDayResult{
+		ProgramCount:  len(programs),
+		ExistingCount: len(schedule.Programs),
+	}
+
+	var next []ProgramItem
+	switch {
+	case len(programs) == 0:
+		result.Status = TemplateDayEmpty
+		return result
+	case len(schedule.Programs) == 0:
+		next = programs
+		result.Status = TemplateDayApplied
+	case mode == TemplateApplyReplace:
+		next = programs
+		result.Status = TemplateDayReplaced
+	case mode == TemplateApplyMerge:
+		next = make([]ProgramItem, 0, len(schedule.Programs)+len(programs))
+		next = append(next, schedule.Programs...)
+		next = append(next, programs...)
+		result.Status = TemplateDayMerged
+	default:
+		result.Status = TemplateDayConflict
+		return result
+	}
+
+	combined := make([]ProgramItem, 0, len(next)+len(occurrences))
+	combined = append(combined, next...)
+	combined = append(combined, occurrences...)
+	report := ValidateSchedule(combined, assetExists)
+
+	generated := make(map[string]bool)
+	for _, program := range programs {
+		generated[program.ID] = true
+	}
+	for _, violation := range report.Violations {
+		if violation.Severity != SeverityError {
+			continue
+		}
+		if generated[violation.ProgramID] || generated[violation.RelatedID] {
+			result.Violations = append(result.Violations, violation)
+		}
+	}
+	if len(result.Violations) > 0 {
+		result.Status = TemplateDayConflict
+		return result
+	}
+
+	schedule.Programs = next
+	return result
+}
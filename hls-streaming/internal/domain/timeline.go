@@ -0,0 +1,335 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// ShortfallPolicy は素材が番組の放送時間より短い場合に残りの時間を埋める方法です
+type ShortfallPolicy string
+
+const (
+	// ShortfallSlate は残りの時間に静止画を表示します
+	ShortfallSlate ShortfallPolicy = "slate"
+	// ShortfallLoop は素材を先頭から繰り返します
+	ShortfallLoop ShortfallPolicy = "loop"
+	// ShortfallFiller はフィラー素材で埋め、埋めきれない時間に静止画を表示します
+	ShortfallFiller ShortfallPolicy = "filler"
+)
+
+// SlateURI は番組の間や不足分に表示する静止画のURIです
+const SlateURI = "/static/images/picture.jpg"
+
+// timelineEpsilon はセグメントの長さの丸め誤差として許容する秒数です
+const timelineEpsilon = 0.001
+
+// TimelineOptions は番組の放送内容を組み立てる際の設定です
+type TimelineOptions struct {
+	// Shortfall は番組で指定されていない場合に使用する不足分の埋め方です
+	Shortfall ShortfallPolicy
+	// Fillers は不足分をフィラーで埋める場合に使用するプレイリストです（先頭から順に使用します）
+	Fillers []*M3U8Playlist
+	// Bumper は番組の終わりに入れるバンパーのプレイリストです（nilの場合は入れません）
+	Bumper *M3U8Playlist
+}
+
+// ParseShortfallPolicy は不足分の埋め方を解釈します（空の場合はslate）
+func ParseShortfallPolicy(policy string) (ShortfallPolicy, error) {
+	switch ShortfallPolicy(policy) {
+	case "", ShortfallSlate:
+		return ShortfallSlate, nil
+	case ShortfallLoop, ShortfallFiller:
+		return ShortfallPolicy(policy), nil
+	}
+	return "", fmt.Errorf("不足分の埋め方はslate、loop、fillerのいずれかを指定してください: %q", policy)
+}
+
+// ShortfallPolicyFor は番組に適用する不足分の埋め方を返します
+func (o TimelineOptions) ShortfallPolicyFor(program *ProgramItem) ShortfallPolicy {
+	if program.Shortfall != "" {
+		return ShortfallPolicy(program.Shortfall)
+	}
+	if o.Shortfall != "" {
+		return o.Shortfall
+	}
+	return ShortfallSlate
+}
+
+// BuildProgramTimeline は番組の設定を素材のプレイリストに適用し、番組の放送内容となるプレイリストを返します。
+// イン点・アウト点でトリミングした後、放送時間を超えるセグメントは切り捨て、
+// 不足する時間は番組または既定の設定に従ってループ・フィラー・静止画で埋めます。
+// バンパーを指定した場合は枠の終わりに入れ、その分だけ番組本体を短くします（番組表の時刻はずらしません）。
+// 番組の放送時間が0以下の場合は長さを調整しません。素材から引き継いだ広告枠の開始時刻は放送時刻に置き換え、
+// 番組に広告枠やインタースティシャルがある場合はその合図を配置します
+func BuildProgramTimeline(program *ProgramItem, playlist *M3U8Playlist, options TimelineOptions) *M3U8Playlist {
+	timeline := buildTimeline(program, playlist, options)
+	programStart, err := program.GetStartTime()
+	if err != nil {
+		return timeline
+	}
+
+	withCues := *timeline
+	withCues.rebaseCarriedCues(programStart)
+	if len(program.AdBreaks) > 0 || len(program.Interstitials) > 0 {
+		withCues.placeCues(program, programStart)
+	}
+	return &withCues
+}
+
+func buildTimeline(program *ProgramItem, playlist *M3U8Playlist, options TimelineOptions) *M3U8Playlist {
+	content := ProgramContent(program, playlist)
+
+	slot := float64(program.DurationSec)
+	if slot <= 0 {
+		return content
+	}
+
+	bumper := bumperSegments(options.Bumper, slot)
+	slot -= segmentsDuration(bumper)
+
+	policy := options.ShortfallPolicyFor(program)
+	if program.Loop || policy == ShortfallLoop {
+		if timeline := buildLoopTimeline(content, slot, bumper); timeline != nil {
+			return timeline
+		}
+	}
+
+	timeline := *content
+	timeline.Segments = make([]M3U8Segment, 0, len(content.Segments))
+	timeline.TargetDuration = maxTargetDuration(timeline.TargetDuration, bumper)
+
+	// 番組の開始位置（StartOffset）を0としたときの現在の位置
+	position := -content.StartOffset
+	appendSegments := func(segments []M3U8Segment, discontinuity bool) int {
+		added := 0
+		for _, segment := range segments {
+			if position+segment.Duration > slot+timelineEpsilon {
+				break
+			}
+			if added == 0 && discontinuity {
+				segment.Discontinuity = true
+			}
+			timeline.Segments = append(timeline.Segments, segment)
+			position += segment.Duration
+			added++
+		}
+		return added
+	}
+
+	appendSegments(content.Segments, false)
+
+	if policy == ShortfallFiller {
+		for _, filler := range options.Fillers {
+			if slot-position <= timelineEpsilon {
+				break
+			}
+			appendSegments(filler.Segments, true)
+		}
+	}
+
+	timeline.Segments = append(timeline.Segments, slateSegments(content.TargetDuration, slot-position)...)
+	timeline.Segments = append(timeline.Segments, bumper...)
+	return &timeline
+}
+
+// buildLoopTimeline は素材を放送時間いっぱいまで繰り返すプレイリストを返します。
+// 繰り返し分のセグメントは展開せず、インデックスを素材のセグメント数で割った余りで参照します。
+// 1セグメントも枠に収まらない場合や、素材の長さが0で繰り返しても枠が進まない場合はnilを返します
+func buildLoopTimeline(content *M3U8Playlist, slot float64, bumper []M3U8Segment) *M3U8Playlist {
+	count := len(content.Segments)
+	if count == 0 || content.TotalDuration() <= timelineEpsilon {
+		return nil
+	}
+
+	position := -content.StartOffset
+	length := 0
+	for {
+		segment := content.Segments[length%count]
+		if position+segment.Duration > slot+timelineEpsilon {
+			break
+		}
+		position += segment.Duration
+		length++
+	}
+	if length == 0 {
+		return nil
+	}
+
+	timeline := *content
+	timeline.loopLength = length
+	timeline.tail = append(slateSegments(content.TargetDuration, slot-position), bumper...)
+	timeline.TargetDuration = maxTargetDuration(timeline.TargetDuration, bumper)
+	return &timeline
+}
+
+// bumperSegments は枠の終わりに入れるバンパーのセグメントを返します。
+// バンパーが枠に収まらない場合は入れません
+func bumperSegments(bumper *M3U8Playlist, slot float64) []M3U8Segment {
+	if bumper == nil || len(bumper.Segments) == 0 || bumper.TotalDuration() >= slot {
+		return nil
+	}
+
+	segments := make([]M3U8Segment, len(bumper.Segments))
+	copy(segments, bumper.Segments)
+	segments[0].Discontinuity = true
+	return segments
+}
+
+// maxTargetDuration は追加するセグメントを含めたEXT-X-TARGETDURATIONを返します
+func maxTargetDuration(targetDuration int, segments []M3U8Segment) int {
+	for _, segment := range segments {
+		if duration := int(math.Ceil(segment.Duration)); duration > targetDuration {
+			targetDuration = duration
+		}
+	}
+	return targetDuration
+}
+
+func segmentsDuration(segments []M3U8Segment) float64 {
+	var total float64
+	for _, segment := range segments {
+		total += segment.Duration
+	}
+	return total
+}
+
+// StitchPlaylists はブロック番組の各アセットのプレイリストを放送順につなげます。
+// 2つ目以降のアセットの先頭には不連続を付けます
+func StitchPlaylists(parts []*M3U8Playlist) *M3U8Playlist {
+	if len(parts) == 1 {
+		return parts[0]
+	}
+
+	stitched := NewM3U8Playlist()
+	for i, part := range parts {
+		if i == 0 {
+			stitched.Version = part.Version
+			stitched.PlaylistType = part.PlaylistType
+			stitched.AllowCache = part.AllowCache
+		}
+		if part.TargetDuration > stitched.TargetDuration {
+			stitched.TargetDuration = part.TargetDuration
+		}
+		for j, segment := range part.Segments {
+			segment.Discontinuity = segment.Discontinuity || (j == 0 && i > 0)
+			stitched.Segments = append(stitched.Segments, segment)
+		}
+	}
+	return stitched
+}
+
+// ProgramContent は番組のイン点・アウト点でトリミングした素材のプレイリストを返します
+func ProgramContent(program *ProgramItem, playlist *M3U8Playlist) *M3U8Playlist {
+	if program.InPoint > 0 || program.OutPoint > 0 {
+		return playlist.Trim(program.InPoint, program.OutPoint)
+	}
+	return playlist
+}
+
+// slateSegments は残りの時間を埋める静止画のセグメントを返します
+func slateSegments(targetDuration int, remaining float64) []M3U8Segment {
+	segmentDuration := float64(targetDuration)
+	if segmentDuration <= 0 {
+		segmentDuration = SegmentDuration
+	}
+
+	segments := make([]M3U8Segment, 0)
+	for remaining > timelineEpsilon {
+		duration := segmentDuration
+		if remaining < duration {
+			duration = remaining
+		}
+		segments = append(segments, M3U8Segment{
+			Duration:      duration,
+			Filename:      SlateURI,
+			Discontinuity: len(segments) == 0,
+		})
+		remaining -= duration
+	}
+	return segments
+}
+
+// PlanShortfallFillers は不足する秒数を埋めるフィラー素材を選びます（選ぶ順序はFillGapsと同じです）
+func PlanShortfallFillers(shortfallSec float64, pool []Asset) []string {
+	if shortfallSec < 1 || len(pool) == 0 {
+		return nil
+	}
+
+	start := time.Unix(0, 0)
+	end := start.Add(time.Duration(shortfallSec * float64(time.Second)))
+	result := FillGaps(nil, start, end, pool, time.UTC)
+
+	ids := make([]string, 0, len(result.Fillers))
+	for _, filler := range result.Fillers {
+		ids = append(ids, filler.AssetID)
+	}
+	return ids
+}
+
+// StartTag は番組の開始位置がセグメントの途中にある場合に、
+// 再生開始位置を示すEXT-X-STARTタグを返します（不要な場合は空文字）
+func (p *M3U8Playlist) StartTag() string {
+	if p.StartOffset <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("#EXT-X-START:TIME-OFFSET=%.3f,PRECISE=YES", p.StartOffset)
+}
+
+// DiscontinuitySequence はstartIndex番目のセグメントの番組内での不連続シーケンス番号（2番目からstartIndex番目までの
+// セグメントに付いている不連続の数）を返します（EXT-X-DISCONTINUITY-SEQUENCEに使用します）。
+// ウィンドウの先頭セグメントの不連続は外すため、startIndex番目の不連続も数えます。
+// 番組の先頭セグメントの不連続は番組の境目の不連続と同じものとして数えません
+func (p *M3U8Playlist) DiscontinuitySequence(startIndex int) int {
+	count := 0
+	for i := 1; i <= startIndex && i < p.Len(); i++ {
+		if p.SegmentAt(i).Discontinuity {
+			count++
+		}
+	}
+	return count
+}
+
+// Len は番組全体のセグメント数を返します（ループする場合は繰り返し分を含みます）
+func (p *M3U8Playlist) Len() int {
+	if p.loopLength > 0 {
+		return p.loopLength + len(p.tail)
+	}
+	return len(p.Segments)
+}
+
+// SegmentAt は番組全体でindex番目のセグメントを返します。
+// ループする場合は素材の先頭に戻るセグメントに不連続を付けます
+func (p *M3U8Playlist) SegmentAt(index int) M3U8Segment {
+	var segment M3U8Segment
+	switch {
+	case p.loopLength == 0:
+		segment = p.Segments[index]
+	case index >= p.loopLength:
+		segment = p.tail[index-p.loopLength]
+	default:
+		segment = p.Segments[index%len(p.Segments)]
+		if index > 0 && index%len(p.Segments) == 0 {
+			segment.Discontinuity = true
+		}
+	}
+
+	if cues := p.cues[index]; len(cues) > 0 {
+		segment.Cues = append(append([]Cue{}, segment.Cues...), cues...)
+	}
+	return segment
+}
+
+// Lines はセグメントをプレイリストの行（必要に応じてEXT-X-DISCONTINUITYや広告枠のタグを含む）に変換します
+func (s M3U8Segment) Lines() []string {
+	lines := make([]string, 0, 3)
+	if s.Discontinuity {
+		lines = append(lines, "#EXT-X-DISCONTINUITY")
+	}
+	for _, cue := range s.Cues {
+		lines = append(lines, cue.Lines()...)
+	}
+	lines = append(lines, fmt.Sprintf("#EXTINF:%.1f,", s.Duration))
+	lines = append(lines, s.Filename)
+	return lines
+}
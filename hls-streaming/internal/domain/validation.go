@@ -0,0 +1,205 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const ProgramTypeVideo = "video"
+
+// KnownProgramTypes は番組表で使用できる番組種別の一覧です
+var KnownProgramTypes = map[string]bool{
+	ProgramTypeVideo: true,
+}
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+const (
+	ViolationInvalidStartTime    = "invalid_start_time"
+	ViolationInvalidDuration     = "invalid_duration"
+	ViolationUnknownType         = "unknown_type"
+	ViolationMissingMedia        = "missing_media"
+	ViolationUnknownAsset        = "unknown_asset"
+	ViolationOverlap             = "overlap"
+	ViolationInvalidTrim         = "invalid_trim"
+	ViolationInvalidShortfall    = "invalid_shortfall"
+	ViolationInvalidBlock        = "invalid_block"
+	ViolationInvalidBumper       = "invalid_bumper"
+	ViolationInvalidAdBreak      = "invalid_ad_break"
+	ViolationInvalidInterstitial = "invalid_interstitial"
+)
+
+// Violation は番組表の検証で見つかった1件の問題です
+type Violation struct {
+	Index     int      `json:"index"`
+	ProgramID string   `json:"program_id,omitempty"`
+	RelatedID string   `json:"related_id,omitempty"`
+	Field     string   `json:"field,omitempty"`
+	Code      string   `json:"code"`
+	Severity  Severity `json:"severity"`
+	Message   string   `json:"message"`
+}
+
+type ValidationReport struct {
+	Valid      bool        `json:"valid"`
+	Violations []Violation `json:"violations"`
+}
+
+// ValidationError は検証エラーを含むため番組表を保存できなかったことを表します
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, violation := range e.Violations {
+		messages = append(messages, violation.Message)
+	}
+	return "番組表の検証に失敗しました: " + strings.Join(messages, "; ")
+}
+
+// ValidateSchedule は番組表全体を検証し、問題点の一覧を返します。
+// assetExistsがnilの場合、アセットの存在確認は行いません
+func ValidateSchedule(programs []ProgramItem, assetExists func(id string) bool) ValidationReport {
+	violations := make([]Violation, 0)
+
+	type timedProgram struct {
+		index int
+		start time.Time
+		end   time.Time
+	}
+	timed := make([]timedProgram, 0, len(programs))
+
+	for index, program := range programs {
+		add := func(field, code string, severity Severity, message string) {
+			violations = append(violations, Violation{
+				Index:     index,
+				ProgramID: program.ID,
+				Field:     field,
+				Code:      code,
+				Severity:  severity,
+				Message:   fmt.Sprintf("番組%d(%s): %s", index, program.Title, message),
+			})
+		}
+
+		startTime, err := program.GetStartTime()
+		if err != nil {
+			add("start_time", ViolationInvalidStartTime, SeverityError, "開始時刻がRFC3339形式ではありません")
+		}
+
+		if program.DurationSec <= 0 {
+			add("duration_sec", ViolationInvalidDuration, SeverityError, "放送時間は1秒以上である必要があります")
+		}
+
+		if !KnownProgramTypes[program.Type] {
+			add("type", ViolationUnknownType, SeverityError, fmt.Sprintf("不明な番組種別です: %q", program.Type))
+		}
+
+		if program.IsBlock() {
+			if program.AssetID != "" {
+				add("assets", ViolationInvalidBlock, SeverityError, "asset_idとassetsは同時に指定できません")
+			}
+			for _, id := range program.Assets {
+				if id == "" {
+					add("assets", ViolationInvalidBlock, SeverityError, "assetsに空のIDが含まれています")
+				} else if assetExists != nil && !assetExists(id) {
+					add("assets", ViolationUnknownAsset, SeverityError, fmt.Sprintf("アセットが存在しません: %s", id))
+				}
+			}
+		} else if program.AssetID == "" && program.Title == "" {
+			add("asset_id", ViolationMissingMedia, SeverityError, "asset_id、assetsまたはtitleで素材を指定する必要があります")
+		} else if program.AssetID != "" && assetExists != nil && !assetExists(program.AssetID) {
+			add("asset_id", ViolationUnknownAsset, SeverityError, fmt.Sprintf("アセットが存在しません: %s", program.AssetID))
+		}
+
+		if program.InPoint < 0 {
+			add("in_point", ViolationInvalidTrim, SeverityError, "in_pointは0以上を指定してください")
+		} else if program.OutPoint != 0 && program.OutPoint <= program.InPoint {
+			add("out_point", ViolationInvalidTrim, SeverityError, "out_pointはin_pointより後の位置を指定してください")
+		}
+
+		if _, shortfallErr := ParseShortfallPolicy(program.Shortfall); shortfallErr != nil {
+			add("shortfall", ViolationInvalidShortfall, SeverityError, shortfallErr.Error())
+		}
+
+		if bumperErr := validateProgramBumper(program.Bumper); bumperErr != nil {
+			add("bumper", ViolationInvalidBumper, SeverityError, bumperErr.Error())
+		}
+
+		for i, adBreak := range program.AdBreaks {
+			if adBreakErr := adBreak.validate(&program); adBreakErr != nil {
+				add(fmt.Sprintf("ad_breaks[%d]", i), ViolationInvalidAdBreak, SeverityError, "広告枠: "+adBreakErr.Error())
+			}
+		}
+
+		for i, interstitial := range program.Interstitials {
+			if interstitialErr := interstitial.validate(&program, assetExists); interstitialErr != nil {
+				add(fmt.Sprintf("interstitials[%d]", i), ViolationInvalidInterstitial, SeverityError, "インタースティシャル: "+interstitialErr.Error())
+			}
+		}
+
+		if err == nil && program.DurationSec > 0 {
+			timed = append(timed, timedProgram{
+				index: index,
+				start: startTime,
+				end:   startTime.Add(time.Duration(program.DurationSec) * time.Second),
+			})
+		}
+	}
+
+	sort.SliceStable(timed, func(i, j int) bool {
+		return timed[i].start.Before(timed[j].start)
+	})
+
+	// それまでで終了時刻が最も遅い番組と比較して重複を検出する
+	if len(timed) > 0 {
+		latest := timed[0]
+		for _, current := range timed[1:] {
+			if current.start.Before(latest.end) {
+				program := programs[current.index]
+				other := programs[latest.index]
+				violations = append(violations, Violation{
+					Index:     current.index,
+					ProgramID: program.ID,
+					RelatedID: other.ID,
+					Field:     "start_time",
+					Code:      ViolationOverlap,
+					Severity:  SeverityError,
+					Message:   fmt.Sprintf("番組%d(%s): 番組%d(%s)と放送時間が重複しています", current.index, program.Title, latest.index, other.Title),
+				})
+			}
+			if current.end.After(latest.end) {
+				latest = current
+			}
+		}
+	}
+
+	report := ValidationReport{Valid: true, Violations: violations}
+	for _, violation := range violations {
+		if violation.Severity == SeverityError {
+			report.Valid = false
+		}
+	}
+	return report
+}
+
+// ErrorsFor は指定した番組に関係するエラーのみを抽出します
+func (r ValidationReport) ErrorsFor(programID string) []Violation {
+	result := make([]Violation, 0)
+	for _, violation := range r.Violations {
+		if violation.Severity != SeverityError {
+			continue
+		}
+		if violation.ProgramID == programID || violation.RelatedID == programID {
+			result = append(result, violation)
+		}
+	}
+	return result
+}
@@ -0,0 +1,99 @@
+package domain
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"sort"
+	"strings"
+)
+
+const (
+	// WatermarkVariantA、WatermarkVariantB は透かしを入れたセグメントを置くディレクトリです
+	WatermarkVariantA = "a"
+	WatermarkVariantB = "b"
+	// WatermarkPatternBits はセッションごとのビット列の長さです（これを超えるセグメントは先頭のビットから繰り返します）
+	WatermarkPatternBits = sha256.Size * 8
+)
+
+// Watermark はセグメントごとにAとBのどちらの透かしを配信するかをセッションIDから決める
+// フォレンジック透かしの設定です。流出した録画のA/Bの並びから視聴者のセッションを特定できます
+type Watermark struct {
+	// Secret はビット列の生成に使う秘密鍵です（空の場合は透かしを使用しません）
+	Secret string
+}
+
+// WatermarkMatch は観測したA/Bの並びとセッションのビット列の一致度です
+type WatermarkMatch struct {
+	SessionID string `json:"session_id"`
+	Matched   int    `json:"matched"`
+	Compared  int    `json:"compared"`
+}
+
+func (w Watermark) Enabled() bool {
+	return w.Secret != ""
+}
+
+// Pattern はセッションIDから求めたビット列です（trueのセグメントはBを配信します）
+func (w Watermark) Pattern(sessionID string) []bool {
+	mac := hmac.New(sha256.New, []byte(w.Secret))
+	mac.Write([]byte(sessionID))
+	sum := mac.Sum(nil)
+
+	pattern := make([]bool, WatermarkPatternBits)
+	for i := range pattern {
+		pattern[i] = sum[i/8]&(0x80>>(i%8)) != 0
+	}
+	return pattern
+}
+
+// Variant は素材のindex番目のセグメントでセッションに配信する透かしを返します
+func (w Watermark) Variant(sessionID string, index int) string {
+	if w.Pattern(sessionID)[index%WatermarkPatternBits] {
+		return WatermarkVariantB
+	}
+	return WatermarkVariantA
+}
+
+// Apply は透かし入りの素材のプレイリスト（セグメントが a/ を参照するもの）で、
+// セッションのビット列に従ってセグメントごとにAまたはBを選びます。署名付きURLに置き換える前に使用します
+func (w Watermark) Apply(playlist *M3U8Playlist, sessionID string) {
+	if !w.Enabled() || sessionID == "" {
+		return
+	}
+
+	pattern := w.Pattern(sessionID)
+	prefixA := WatermarkVariantA + "/"
+	for i, segment := range playlist.Segments {
+		if !strings.HasPrefix(segment.Filename, prefixA) {
+			continue
+		}
+		if pattern[i%WatermarkPatternBits] {
+			playlist.Segments[i].Filename = WatermarkVariantB + "/" + strings.TrimPrefix(segment.Filename, prefixA)
+		}
+	}
+}
+
+// Match は録画から観測したセグメントごとの透かし（素材のセグメント番号 → a または b）と
+// 候補のセッションのビット列を比べ、一致したセグメントの多い順に返します
+func (w Watermark) Match(candidates []string, observed map[int]string) []WatermarkMatch {
+	matches := make([]WatermarkMatch, 0, len(candidates))
+	for _, sessionID := range candidates {
+		pattern := w.Pattern(sessionID)
+		match := WatermarkMatch{SessionID: sessionID}
+		for index, variant := range observed {
+			if index < 0 || (variant != WatermarkVariantA && variant != WatermarkVariantB) {
+				continue
+			}
+			match.Compared++
+			if pattern[index%WatermarkPatternBits] == (variant == WatermarkVariantB) {
+				match.Matched++
+			}
+		}
+		matches = append(matches, match)
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Matched > matches[j].Matched
+	})
+	return matches
+}
@@ -2,10 +2,12 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -15,27 +17,83 @@ import (
 )
 
 type HTTPHandler struct {
-	scheduleService  *service.ScheduleService
-	streamingService *service.StreamingService
-	mediaService     *service.MediaService
+	scheduleService     *service.ScheduleService
+	streamingService    *service.StreamingService
+	mediaService        *service.MediaService
+	readinessService    *service.ReadinessService
+	templateService     *service.TemplateService
+	fillerService       *service.FillerService
+	autoProgramService  *service.AutoProgramService
+	interstitialService *service.InterstitialService
+	sessionService      *service.SessionService
+	playbackService     *service.PlaybackTokenService
+	authService         *service.AuthService
+	auditService        *service.AuditService
 }
 
-func NewHTTPHandler(scheduleService *service.ScheduleService, streamingService *service.StreamingService, mediaService *service.MediaService) *HTTPHandler {
+func NewHTTPHandler(scheduleService *service.ScheduleService, streamingService *service.StreamingService, mediaService *service.MediaService, readinessService *service.ReadinessService, templateService *service.TemplateService, fillerService *service.FillerService, autoProgramService *service.AutoProgramService, interstitialService *service.InterstitialService, sessionService *service.SessionService, playbackService *service.PlaybackTokenService, authService *service.AuthService, auditService *service.AuditService) *HTTPHandler {
 	return &HTTPHandler{
-		scheduleService:  scheduleService,
-		streamingService: streamingService,
-		mediaService:     mediaService,
+		scheduleService:     scheduleService,
+		streamingService:    streamingService,
+		mediaService:        mediaService,
+		readinessService:    readinessService,
+		templateService:     templateService,
+		fillerService:       fillerService,
+		autoProgramService:  autoProgramService,
+		interstitialService: interstitialService,
+		sessionService:      sessionService,
+		playbackService:     playbackService,
+		authService:         authService,
+		auditService:        auditService,
 	}
 }
 
 func (h *HTTPHandler) SetupRoutes(router *gin.Engine) {
+	viewer := h.requireRole(domain.RoleViewer)
+	scheduler := h.requireRole(domain.RoleScheduler)
+	uploader := h.requireRole(domain.RoleUploader)
+	admin := h.requireRole(domain.RoleAdmin)
+
 	router.GET("/", h.serveIndex)
-	router.GET("/live/video.m3u8", h.getLivePlaylist)
+	router.GET("/live/video.m3u8", h.requirePlayback, h.getLivePlaylist)
+	router.GET("/api/preview/video.m3u8", viewer, h.getPreviewPlaylist)
 	router.HEAD("/live/status", h.getStreamStatus)
-	router.POST("/api/refresh-schedule", h.refreshSchedule)
-	router.GET("/api/schedule", h.getSchedule)
-	router.POST("/api/schedule", h.postSchedule)
-	router.POST("/api/upload-video", h.uploadVideo)
+	router.POST("/api/refresh-schedule", scheduler, h.refreshSchedule)
+	router.GET("/api/schedule", viewer, h.getSchedule)
+	router.GET("/api/epg", h.getEPG)
+	router.POST("/api/schedule", scheduler, h.postSchedule)
+	router.POST("/api/schedule/validate", viewer, h.validateSchedule)
+	router.GET("/api/schedule/:date", viewer, h.getScheduleByDate)
+	router.GET("/api/schedule/:date/programs/:id", viewer, h.getProgram)
+	router.PUT("/api/schedule/:date/programs/:id", scheduler, h.putProgram)
+	router.PATCH("/api/schedule/:date/programs/:id", scheduler, h.patchProgram)
+	router.DELETE("/api/schedule/:date/programs/:id", scheduler, h.deleteProgram)
+	router.POST("/api/upload-video", uploader, h.uploadVideo)
+	router.GET("/api/readiness", viewer, h.getReadiness)
+	router.GET("/api/assets", viewer, h.listAssets)
+	router.POST("/api/assets", uploader, h.createAsset)
+	router.GET("/api/assets/:id", viewer, h.getAsset)
+	router.PATCH("/api/assets/:id", uploader, h.patchAsset)
+	router.DELETE("/api/assets/:id", uploader, h.deleteAsset)
+	router.GET("/api/assets/:id/video.m3u8", h.requirePlayback, h.getAssetPlaylist)
+	router.GET("/api/ads/:key/video.m3u8", h.requirePlayback, h.getAdCreativePlaylist)
+	router.GET("/api/interstitials/asset-list", h.requirePlayback, h.getInterstitialAssetList)
+	router.POST("/api/playback-token", viewer, h.postPlaybackToken)
+	router.GET("/api/recurrences", viewer, h.listRecurrences)
+	router.POST("/api/recurrences", scheduler, h.createRecurrence)
+	router.GET("/api/recurrences/:id", viewer, h.getRecurrence)
+	router.PUT("/api/recurrences/:id", scheduler, h.putRecurrence)
+	router.DELETE("/api/recurrences/:id", scheduler, h.deleteRecurrence)
+	router.GET("/api/recurrences/:id/occurrences", viewer, h.getRecurrenceOccurrences)
+	router.GET("/api/templates", viewer, h.listTemplates)
+	router.POST("/api/templates", scheduler, h.createTemplate)
+	router.GET("/api/templates/:id", viewer, h.getTemplate)
+	router.PUT("/api/templates/:id", scheduler, h.putTemplate)
+	router.DELETE("/api/templates/:id", scheduler, h.deleteTemplate)
+	router.POST("/api/templates/:id/apply", scheduler, h.applyTemplate)
+	router.GET("/api/fillers", viewer, h.getFillers)
+	router.POST("/api/auto-program", scheduler, h.postAutoProgram)
+	router.GET("/api/audit", admin, h.getAuditLog)
 	router.Static("/static", "./static")
 }
 
@@ -43,22 +101,197 @@ func (h *HTTPHandler) serveIndex(c *gin.Context) {
 	c.File("./index.html")
 }
 
+// getLivePlaylist は視聴者のセッションごとのライブプレイリストを返します。
+// sidクエリがない、または無効なセッションの場合は新しいセッションを作成してsid付きのURLにリダイレクトします
+// （再生トークンはセッションに引き継ぐため、リダイレクト先のURLには含めません）
 func (h *HTTPHandler) getLivePlaylist(c *gin.Context) {
-	schedule := h.scheduleService.GetSchedule()
+	ctx := c.Request.Context()
+	session, created, err := h.sessionService.Resume(ctx, c.Query("sid"), playbackClaims(c))
+	if err != nil {
+		log.Printf("セッションの取得に失敗: %v", err)
+		c.String(http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	if created {
+		query := c.Request.URL.Query()
+		query.Set("sid", session.ID)
+		query.Del("token")
+		c.Header("Cache-Control", "no-store")
+		c.Redirect(http.StatusFound, c.Request.URL.Path+"?"+query.Encode())
+		return
+	}
 
-	playlist, err := h.streamingService.GenerateVODPlaylist(c.Request.Context(), schedule)
+	original := *session
+	schedule := h.fillerService.PlayoutSchedule(h.scheduleService.GetScheduleWithRevision())
+
+	playlist, err := h.streamingService.GenerateSessionPlaylist(ctx, schedule, session)
 	if err != nil {
 		log.Printf("プレイリスト生成エラー: %v", err)
 		c.String(http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
+	if err := h.sessionService.Commit(ctx, original, session); err != nil {
+		log.Printf("セッションの保存に失敗: %v", err)
+	}
+
+	c.Header("Content-Type", "application/vnd.apple.mpegurl")
+	c.String(http.StatusOK, playlist)
+}
+
+// requireRole は管理APIの認証が有効な場合に、roleの操作を許可された利用者のリクエストのみを通します。
+// X-API-KeyヘッダーのAPIキー、またはAuthorizationヘッダー（Bearer）のJWTで認証します
+func (h *HTTPHandler) requireRole(role domain.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !h.authService.Enabled() {
+			c.Next()
+			return
+		}
+
+		credentials := domain.Credentials{APIKey: c.GetHeader("X-API-Key")}
+		if bearer, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+			credentials.BearerToken = bearer
+		}
+		principal, err := h.authService.Authorize(c.Request.Context(), credentials, role)
+		if err != nil {
+			if errors.Is(err, domain.ErrForbidden) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+				return
+			}
+			c.Header("WWW-Authenticate", "Bearer")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.Set(principalKey, principal)
+		c.Next()
+	}
+}
+
+const principalKey = "principal"
+
+// principal はrequireRoleで認証した利用者を返します（認証が無効な場合はnil）
+func principal(c *gin.Context) *domain.Principal {
+	value, _ := c.Get(principalKey)
+	principal, _ := value.(*domain.Principal)
+	return principal
+}
+
+// requirePlayback は再生トークンによる保護が有効な場合に、視聴を許可されたリクエストのみを通します。
+// tokenクエリまたはAuthorizationヘッダー（Bearer）の再生トークン、
+// または再生トークンで開始したセッションのsidクエリで許可します
+func (h *HTTPHandler) requirePlayback(c *gin.Context) {
+	if !h.playbackService.Enabled() {
+		c.Next()
+		return
+	}
+
+	token := c.Query("token")
+	if bearer, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok && token == "" {
+		token = bearer
+	}
+	if token != "" {
+		claims, err := h.playbackService.Verify(token, c.ClientIP())
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.Set(playbackClaimsKey, claims)
+		c.Next()
+		return
+	}
+
+	if sid := c.Query("sid"); sid != "" {
+		err := h.sessionService.VerifyPlayback(c.Request.Context(), sid, h.playbackService.Channel(), c.ClientIP())
+		if err == nil {
+			c.Next()
+			return
+		}
+		if !errors.Is(err, domain.ErrSessionNotFound) && !errors.Is(err, domain.ErrInvalidPlaybackToken) {
+			log.Printf("セッションの取得に失敗: %v", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+			return
+		}
+	}
+
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "再生トークンが必要です"})
+}
+
+const playbackClaimsKey = "playbackClaims"
+
+// playbackClaims はrequirePlaybackで検証した再生トークンの内容を返します（トークンがない場合はnil）
+func playbackClaims(c *gin.Context) *domain.PlaybackClaims {
+	claims, _ := c.Get(playbackClaimsKey)
+	playback, _ := claims.(*domain.PlaybackClaims)
+	return playback
+}
+
+// postPlaybackToken は認証済みのアプリ（viewerロール）に視聴者の再生トークンを発行します
+func (h *HTTPHandler) postPlaybackToken(c *gin.Context) {
+	if !h.playbackService.Enabled() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "再生トークンは使用していません"})
+		return
+	}
+
+	var request struct {
+		// Viewer はアプリでの視聴者のIDです（流出した録画から視聴者を特定するためにセッションに記録します）
+		Viewer string `json:"viewer"`
+		// BindIP がtrueの場合はリクエスト元のIPアドレスからの視聴のみを許可します
+		BindIP bool `json:"bind_ip"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの形式が不正です: " + err.Error()})
+		return
+	}
+	if request.Viewer == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "viewerを指定してください"})
+		return
+	}
+
+	token, expiresAt, err := h.playbackService.Issue(request.Viewer, c.ClientIP(), request.BindIP)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{
+		"token":        token,
+		"expires_at":   expiresAt,
+		"playlist_url": "/live/video.m3u8?token=" + token,
+	})
+}
+
+// getPreviewPlaylist は指定した時刻（atクエリ、RFC3339形式）に配信されるライブプレイリストを返します
+func (h *HTTPHandler) getPreviewPlaylist(c *gin.Context) {
+	at, err := time.Parse(time.RFC3339, c.Query("at"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "atクエリパラメータはRFC3339形式で指定してください"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	schedule, err := h.scheduleService.LoadWindowAt(ctx, at)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "番組表の取得に失敗しました: " + err.Error()})
+		return
+	}
+
+	schedule = h.fillerService.PlayoutScheduleAt(schedule, at)
+
+	playlist, err := h.streamingService.GeneratePlaylistAt(ctx, schedule, at)
+	if err != nil {
+		log.Printf("プレビュープレイリスト生成エラー: %v", err)
+		c.String(http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
 
 	c.Header("Content-Type", "application/vnd.apple.mpegurl")
 	c.String(http.StatusOK, playlist)
 }
 
 func (h *HTTPHandler) getStreamStatus(c *gin.Context) {
-	schedule := h.scheduleService.GetSchedule()
+	schedule := h.fillerService.PlayoutSchedule(h.scheduleService.GetScheduleWithRevision())
 	status := h.streamingService.CheckStreamStatus(schedule)
 	c.Status(status)
 }
@@ -67,12 +300,22 @@ func (h *HTTPHandler) refreshSchedule(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	before := h.scheduleService.GetSchedule()
 	if err := h.scheduleService.RefreshFromRepository(ctx); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	schedule := h.scheduleService.GetSchedule()
+	changes := domain.DiffPrograms(before, schedule)
+	if !h.audit(c, domain.AuditScheduleRefresh, "schedule", gin.H{"count": len(before)}, gin.H{
+		"count":   len(schedule),
+		"added":   changes.Added,
+		"removed": changes.Removed,
+		"changed": changes.Changed,
+	}) {
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"message": "番組表を更新しました",
 		"count":   len(schedule),
@@ -82,8 +325,21 @@ func (h *HTTPHandler) refreshSchedule(c *gin.Context) {
 func (h *HTTPHandler) getSchedule(c *gin.Context) {
 	schedule := h.scheduleService.GetSchedule()
 	c.JSON(http.StatusOK, gin.H{
-		"schedule": schedule,
-		"count":    len(schedule),
+		"schedule":  schedule,
+		"count":     len(schedule),
+		"time_zone": h.scheduleService.Location().String(),
+	})
+}
+
+// getEPG は番組表をチャンネルのタイムゾーンの時刻で返します
+func (h *HTTPHandler) getEPG(c *gin.Context) {
+	location := h.scheduleService.Location()
+	epg := domain.BuildEPG(h.scheduleService.GetSchedule(), location)
+
+	c.JSON(http.StatusOK, gin.H{
+		"time_zone": location.String(),
+		"programs":  epg,
+		"count":     len(epg),
 	})
 }
 
@@ -94,6 +350,11 @@ func (h *HTTPHandler) postSchedule(c *gin.Context) {
 		return
 	}
 
+	expectedVersion, ok := parseIfMatch(c)
+	if !ok {
+		return
+	}
+
 	var programItem domain.RequestProgramItem
 	if err := c.ShouldBindJSON(&programItem); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストボディが不正です: " + err.Error()})
@@ -103,18 +364,213 @@ func (h *HTTPHandler) postSchedule(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	if err := h.scheduleService.AddProgramToSchedule(ctx, programItem, date); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "番組の追加に失敗しました: " + err.Error()})
+	program, version, err := h.scheduleService.AddProgramToSchedule(ctx, programItem, date, expectedVersion, auditRecorder(h, c, domain.AuditProgramCreate, func(program *domain.ProgramItem) string {
+		return programTarget(date, program.ID)
+	}))
+	if err != nil {
+		respondScheduleError(c, "番組の追加に失敗しました", err)
 		return
 	}
 
+	setScheduleETag(c, version)
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "番組を追加しました",
-		"program": programItem,
+		"program": program,
 		"date":    date,
+		"version": version,
+	})
+}
+
+func (h *HTTPHandler) getScheduleByDate(c *gin.Context) {
+	schedule, err := h.scheduleService.GetScheduleByDate(c.Request.Context(), c.Param("date"))
+	if err != nil {
+		c.JSON(scheduleErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	setScheduleETag(c, schedule.Version)
+	c.JSON(http.StatusOK, gin.H{
+		"date":     c.Param("date"),
+		"schedule": schedule.Programs,
+		"count":    len(schedule.Programs),
+		"version":  schedule.Version,
+	})
+}
+
+func (h *HTTPHandler) getProgram(c *gin.Context) {
+	program, version, err := h.scheduleService.GetProgram(c.Request.Context(), c.Param("date"), c.Param("id"))
+	if err != nil {
+		respondScheduleError(c, "番組の取得に失敗しました", err)
+		return
+	}
+
+	setScheduleETag(c, version)
+	c.JSON(http.StatusOK, gin.H{
+		"program": program,
+		"date":    c.Param("date"),
+		"version": version,
+	})
+}
+
+func (h *HTTPHandler) putProgram(c *gin.Context) {
+	expectedVersion, ok := parseIfMatch(c)
+	if !ok {
+		return
+	}
+
+	var programItem domain.RequestProgramItem
+	if err := c.ShouldBindJSON(&programItem); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストボディが不正です: " + err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	program, _, version, err := h.scheduleService.ReplaceProgram(ctx, c.Param("date"), c.Param("id"), programItem, expectedVersion, h.programAuditRecorder(c, domain.AuditProgramUpdate))
+	if err != nil {
+		respondScheduleError(c, "番組の更新に失敗しました", err)
+		return
+	}
+
+	setScheduleETag(c, version)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "番組を更新しました",
+		"program": program,
+		"date":    c.Param("date"),
+		"version": version,
+	})
+}
+
+func (h *HTTPHandler) patchProgram(c *gin.Context) {
+	expectedVersion, ok := parseIfMatch(c)
+	if !ok {
+		return
+	}
+
+	var patch domain.ProgramPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストボディが不正です: " + err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	program, _, version, err := h.scheduleService.PatchProgram(ctx, c.Param("date"), c.Param("id"), patch, expectedVersion, h.programAuditRecorder(c, domain.AuditProgramUpdate))
+	if err != nil {
+		respondScheduleError(c, "番組の更新に失敗しました", err)
+		return
+	}
+
+	setScheduleETag(c, version)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "番組を更新しました",
+		"program": program,
+		"date":    c.Param("date"),
+		"version": version,
+	})
+}
+
+func (h *HTTPHandler) deleteProgram(c *gin.Context) {
+	expectedVersion, ok := parseIfMatch(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, version, err := h.scheduleService.DeleteProgram(ctx, c.Param("date"), c.Param("id"), expectedVersion, h.programAuditRecorder(c, domain.AuditProgramDelete))
+	if err != nil {
+		respondScheduleError(c, "番組の削除に失敗しました", err)
+		return
+	}
+
+	setScheduleETag(c, version)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "番組を削除しました",
+		"id":      c.Param("id"),
+		"date":    c.Param("date"),
+		"version": version,
 	})
 }
 
+func (h *HTTPHandler) validateSchedule(c *gin.Context) {
+	var request domain.RequestSchedule
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストボディが不正です: " + err.Error()})
+		return
+	}
+
+	report, err := h.scheduleService.ValidatePrograms(c.Request.Context(), c.Query("date"), request.Programs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// scheduleErrorStatus は番組表操作のエラーをHTTPステータスに変換します
+func scheduleErrorStatus(err error) int {
+	var validationErr *domain.ValidationError
+	switch {
+	case errors.Is(err, domain.ErrScheduleNotFound), errors.Is(err, domain.ErrProgramNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrVersionConflict):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, domain.ErrOccurrenceReadOnly):
+		return http.StatusConflict
+	case errors.As(err, &validationErr):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// respondScheduleError は番組表操作のエラーレスポンスを返します。検証エラーの場合は違反内容も含めます
+func respondScheduleError(c *gin.Context, message string, err error) {
+	if respondNotRecorded(c, err) {
+		return
+	}
+	body := gin.H{"error": message + ": " + err.Error()}
+
+	var validationErr *domain.ValidationError
+	if errors.As(err, &validationErr) {
+		body["violations"] = validationErr.Violations
+	}
+	// 繰り返し番組の放送回は元の繰り返し番組のAPIで変更する
+	var occurrenceErr *domain.OccurrenceError
+	if errors.As(err, &occurrenceErr) {
+		body["recurrence_id"] = occurrenceErr.RecurrenceID
+		body["recurrence_url"] = "/api/recurrences/" + occurrenceErr.RecurrenceID
+	}
+
+	c.JSON(scheduleErrorStatus(err), body)
+}
+
+// parseIfMatch はIf-Matchヘッダーから期待する番組表のバージョンを取得します。
+// ヘッダーが無い場合や"*"の場合はAnyVersionを返します
+func parseIfMatch(c *gin.Context) (int64, bool) {
+	value := strings.TrimSpace(c.GetHeader("If-Match"))
+	if value == "" || value == "*" {
+		return domain.AnyVersion, true
+	}
+
+	value = strings.Trim(strings.TrimPrefix(value, "W/"), `"`)
+	version, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || version < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "If-Matchヘッダーが不正です"})
+		return 0, false
+	}
+	return version, true
+}
+
+func setScheduleETag(c *gin.Context, version int64) {
+	c.Header("ETag", strconv.Quote(strconv.FormatInt(version, 10)))
+}
+
 // uploadVideo は動画ファイルをアップロードするエンドポイントです
 func (h *HTTPHandler) uploadVideo(c *gin.Context) {
 	// 1. ファイルサイズ制限の確認（例：100MB）
@@ -191,8 +647,15 @@ func (h *HTTPHandler) uploadVideo(c *gin.Context) {
 		return
 	}
 
-	// 9. 成功レスポンス
+	// 9. 監査ログへの記録と成功レスポンス
 	log.Printf("HLS変換・アップロード成功: 日付=%s, プログラム=%s (元サイズ: %d bytes)", date, programName, len(fileData))
+	if !h.audit(c, domain.AuditAssetUpload, "uploads/"+date+"/"+programName, nil, gin.H{
+		"file_name":     fileName,
+		"original_size": len(fileData),
+		"content_type":  contentType,
+	}) {
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"message":       "動画のHLS変換・アップロードが完了しました",
 		"date":          date,
@@ -204,6 +667,714 @@ func (h *HTTPHandler) uploadVideo(c *gin.Context) {
 	})
 }
 
+// getReadiness は放送前チェックの結果を返します。refresh=trueの場合はその場でチェックを実行します
+func (h *HTTPHandler) getReadiness(c *gin.Context) {
+	report := h.readinessService.LastReport()
+
+	if report == nil || c.Query("refresh") == "true" {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Minute)
+		defer cancel()
+
+		var err error
+		report, err = h.readinessService.CheckUpcoming(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "放送前チェックに失敗しました: " + err.Error()})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// createAsset は動画をアップロードしてアセットカタログに登録します
+func (h *HTTPHandler) createAsset(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, 100*1024*1024) // 100MB制限
+
+	file, header, err := c.Request.FormFile("video")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "動画ファイルが見つかりません。'video'フィールドでファイルを送信してください",
+		})
+		return
+	}
+	defer file.Close()
+
+	contentType := header.Header.Get("Content-Type")
+	if !isValidVideoType(contentType) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("サポートされていないファイル形式です: %s", contentType),
+		})
+		return
+	}
+
+	title := c.PostForm("title")
+	if title == "" {
+		title = header.Filename
+	}
+
+	fileData, err := io.ReadAll(file)
+	if err != nil {
+		log.Printf("ファイル読み込みエラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ファイルの読み込みに失敗しました"})
+		return
+	}
+	if len(fileData) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "空のファイルはアップロードできません"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Minute) // HLS変換用に15分
+	defer cancel()
+
+	asset, err := h.mediaService.CreateAsset(ctx, fileData, title, parseTags(c.PostForm("tags")), auditRecorder(h, c, domain.AuditAssetCreate, assetTarget))
+	if respondNotRecorded(c, err) {
+		return
+	}
+	if err != nil {
+		log.Printf("アセット登録エラー: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "アセットの登録に失敗しました"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "アセットを登録しました",
+		"asset":   asset,
+	})
+}
+
+func (h *HTTPHandler) listAssets(c *gin.Context) {
+	assets, err := h.mediaService.ListAssets(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"assets": assets,
+		"count":  len(assets),
+	})
+}
+
+func (h *HTTPHandler) getAsset(c *gin.Context) {
+	asset, err := h.mediaService.GetAsset(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, domain.ErrAssetNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"asset": asset})
+}
+
+// patchAsset はアセットのタイトルやタグを更新します
+func (h *HTTPHandler) patchAsset(c *gin.Context) {
+	var patch domain.AssetPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストボディが不正です: " + err.Error()})
+		return
+	}
+
+	asset, err := h.mediaService.UpdateAsset(c.Request.Context(), c.Param("id"), patch, auditRecorder(h, c, domain.AuditAssetUpdate, assetTarget))
+	var notRecorded *domain.ChangeNotRecordedError
+	if err != nil && !errors.As(err, &notRecorded) {
+		if errors.Is(err, domain.ErrAssetNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "アセットの更新に失敗しました: " + err.Error()})
+		return
+	}
+	// タグの変更をフィラー素材に反映する（監査ログの記録に失敗した場合も更新は保存されている）
+	if err := h.fillerService.RefreshPool(c.Request.Context()); err != nil {
+		log.Printf("フィラー素材の更新に失敗: %v", err)
+	}
+	if respondNotRecorded(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "アセットを更新しました",
+		"asset":   asset,
+	})
+}
+
+func (h *HTTPHandler) deleteAsset(c *gin.Context) {
+	id := c.Param("id")
+	force := c.Query("force") == "true"
+	err := h.mediaService.DeleteAsset(c.Request.Context(), id, force, auditRecorder(h, c, domain.AuditAssetDelete, assetTarget))
+	if respondNotRecorded(c, err) {
+		return
+	}
+	if err != nil {
+		if errors.Is(err, domain.ErrAssetNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		var inUseErr *domain.AssetInUseError
+		if errors.As(err, &inUseErr) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":      inUseErr.Error() + "。削除する場合はforce=trueを指定してください",
+				"references": inUseErr.References,
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "アセットの削除に失敗しました: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "アセットを削除しました",
+		"id":      id,
+	})
+}
+
+// getAssetPlaylist はインタースティシャルで再生するアセットのVODプレイリストを返します
+func (h *HTTPHandler) getAssetPlaylist(c *gin.Context) {
+	playlist, err := h.interstitialService.AssetPlaylist(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, domain.ErrAssetNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("アセットのプレイリスト生成エラー: %v", err)
+		c.String(http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	c.Header("Content-Type", "application/vnd.apple.mpegurl")
+	c.String(http.StatusOK, playlist)
+}
+
+// getAdCreativePlaylist はインタースティシャルで再生する広告素材のVODプレイリストを返します
+func (h *HTTPHandler) getAdCreativePlaylist(c *gin.Context) {
+	key := c.Param("key")
+	if !domain.IsCreativeKey(key) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "広告素材が見つかりません"})
+		return
+	}
+
+	playlist, err := h.interstitialService.AdCreativePlaylist(c.Request.Context(), key)
+	if err != nil {
+		log.Printf("広告素材のプレイリスト生成エラー: %v", err)
+		c.String(http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	c.Header("Content-Type", "application/vnd.apple.mpegurl")
+	c.String(http.StatusOK, playlist)
+}
+
+// getInterstitialAssetList はEXT-X-DATERANGEのX-ASSET-LISTが参照するアセットリストを返します
+func (h *HTTPHandler) getInterstitialAssetList(c *gin.Context) {
+	list, err := h.interstitialService.AssetList(c.Request.Context(), c.Request.URL.Query())
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidAssetListQuery):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, domain.ErrAssetNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, list)
+}
+
+func (h *HTTPHandler) listRecurrences(c *gin.Context) {
+	recurrences, err := h.scheduleService.ListRecurrences(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recurrences": recurrences,
+		"count":       len(recurrences),
+	})
+}
+
+func (h *HTTPHandler) createRecurrence(c *gin.Context) {
+	var recurrence domain.RecurringProgram
+	if err := c.ShouldBindJSON(&recurrence); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストボディが不正です: " + err.Error()})
+		return
+	}
+
+	created, err := h.scheduleService.CreateRecurrence(c.Request.Context(), recurrence, auditRecorder(h, c, domain.AuditRecurrenceCreate, recurrenceTarget))
+	if err != nil {
+		respondRecurrenceError(c, "繰り返し番組の登録に失敗しました", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":    "繰り返し番組を登録しました",
+		"recurrence": created,
+	})
+}
+
+func (h *HTTPHandler) getRecurrence(c *gin.Context) {
+	recurrence, err := h.scheduleService.GetRecurrence(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(recurrenceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recurrence": recurrence})
+}
+
+func (h *HTTPHandler) putRecurrence(c *gin.Context) {
+	var recurrence domain.RecurringProgram
+	if err := c.ShouldBindJSON(&recurrence); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストボディが不正です: " + err.Error()})
+		return
+	}
+
+	updated, err := h.scheduleService.UpdateRecurrence(c.Request.Context(), c.Param("id"), recurrence, auditRecorder(h, c, domain.AuditRecurrenceUpdate, recurrenceTarget))
+	if err != nil {
+		respondRecurrenceError(c, "繰り返し番組の更新に失敗しました", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "繰り返し番組を更新しました",
+		"recurrence": updated,
+	})
+}
+
+func (h *HTTPHandler) deleteRecurrence(c *gin.Context) {
+	id := c.Param("id")
+	err := h.scheduleService.DeleteRecurrence(c.Request.Context(), id, auditRecorder(h, c, domain.AuditRecurrenceDelete, recurrenceTarget))
+	if respondNotRecorded(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(recurrenceErrorStatus(err), gin.H{"error": "繰り返し番組の削除に失敗しました: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "繰り返し番組を削除しました",
+		"id":      id,
+	})
+}
+
+// getRecurrenceOccurrences は繰り返し番組を指定期間で展開した結果を返します
+func (h *HTTPHandler) getRecurrenceOccurrences(c *gin.Context) {
+	from := c.Query("from")
+	to := c.Query("to")
+	if from == "" || to == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "fromとtoパラメータ（YYYY-MM-DD）が必要です"})
+		return
+	}
+
+	occurrences, err := h.scheduleService.PreviewOccurrences(c.Request.Context(), c.Param("id"), from, to)
+	if err != nil {
+		c.JSON(recurrenceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"occurrences": occurrences,
+		"count":       len(occurrences),
+	})
+}
+
+// recurrenceErrorStatus は繰り返し番組の操作で発生したエラーをHTTPステータスに変換します
+func recurrenceErrorStatus(err error) int {
+	var validationErr *domain.ValidationError
+	switch {
+	case errors.Is(err, domain.ErrRecurrenceNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrInvalidRecurrence):
+		return http.StatusBadRequest
+	case errors.As(err, &validationErr):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// respondRecurrenceError は繰り返し番組の保存のエラーレスポンスを返します。
+// 放送回が日ごとの番組表と重なる場合は番組表の検証と同じ形式で違反内容も含めます
+func respondRecurrenceError(c *gin.Context, message string, err error) {
+	if respondNotRecorded(c, err) {
+		return
+	}
+	body := gin.H{"error": message + ": " + err.Error()}
+
+	var validationErr *domain.ValidationError
+	if errors.As(err, &validationErr) {
+		body["violations"] = validationErr.Violations
+	}
+
+	c.JSON(recurrenceErrorStatus(err), body)
+}
+
+func (h *HTTPHandler) listTemplates(c *gin.Context) {
+	templates, err := h.templateService.ListTemplates(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"templates": templates,
+		"count":     len(templates),
+	})
+}
+
+func (h *HTTPHandler) createTemplate(c *gin.Context) {
+	var template domain.ScheduleTemplate
+	if err := c.ShouldBindJSON(&template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストボディが不正です: " + err.Error()})
+		return
+	}
+
+	created, err := h.templateService.CreateTemplate(c.Request.Context(), template, auditRecorder(h, c, domain.AuditTemplateCreate, templateTarget))
+	if respondNotRecorded(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(templateErrorStatus(err), gin.H{"error": "テンプレートの登録に失敗しました: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "テンプレートを登録しました",
+		"template": created,
+	})
+}
+
+func (h *HTTPHandler) getTemplate(c *gin.Context) {
+	template, err := h.templateService.GetTemplate(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(templateErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"template": template})
+}
+
+func (h *HTTPHandler) putTemplate(c *gin.Context) {
+	var template domain.ScheduleTemplate
+	if err := c.ShouldBindJSON(&template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストボディが不正です: " + err.Error()})
+		return
+	}
+
+	updated, err := h.templateService.UpdateTemplate(c.Request.Context(), c.Param("id"), template, auditRecorder(h, c, domain.AuditTemplateUpdate, templateTarget))
+	if respondNotRecorded(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(templateErrorStatus(err), gin.H{"error": "テンプレートの更新に失敗しました: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "テンプレートを更新しました",
+		"template": updated,
+	})
+}
+
+func (h *HTTPHandler) deleteTemplate(c *gin.Context) {
+	id := c.Param("id")
+	err := h.templateService.DeleteTemplate(c.Request.Context(), id, auditRecorder(h, c, domain.AuditTemplateDelete, templateTarget))
+	if respondNotRecorded(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(templateErrorStatus(err), gin.H{"error": "テンプレートの削除に失敗しました: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "テンプレートを削除しました",
+		"id":      id,
+	})
+}
+
+// applyTemplate はテンプレートを期間に適用して日ごとの番組表を生成します
+func (h *HTTPHandler) applyTemplate(c *gin.Context) {
+	var request struct {
+		From   string `json:"from"`
+		To     string `json:"to"`
+		Mode   string `json:"mode"`
+		DryRun bool   `json:"dry_run"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストボディが不正です: " + err.Error()})
+		return
+	}
+
+	dates, err := domain.DateRange(request.From, request.To)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	mode, err := domain.ParseTemplateApplyMode(request.Mode)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	result, err := h.templateService.ApplyTemplate(ctx, c.Param("id"), dates, mode, request.DryRun, h.generatedDayRecorder(c, domain.AuditTemplateApply, request.DryRun))
+	if err != nil {
+		body := gin.H{"error": "テンプレートの適用に失敗しました: " + err.Error()}
+		// 失敗した日より前に反映した日の結果も返す
+		if result != nil {
+			body["result"] = result
+		}
+		c.JSON(templateErrorStatus(err), body)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// templateErrorStatus はテンプレートの操作で発生したエラーをHTTPステータスに変換します
+func templateErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrTemplateNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrInvalidTemplate):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// getFillers は指定日の番組の隙間に入るフィラーと、静止画を表示する時間帯を返します
+func (h *HTTPHandler) getFillers(c *gin.Context) {
+	date := c.Query("date")
+	location := h.scheduleService.Location()
+	noon, err := domain.LocalDateTime(date, "12:00", location)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "dateクエリパラメータ（YYYY-MM-DD）が必要です"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	programs, err := h.scheduleService.LoadWindowAt(ctx, noon)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "番組表の取得に失敗しました: " + err.Error()})
+		return
+	}
+
+	result, err := h.fillerService.FillDate(date, programs)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"date":       date,
+		"pool_size":  len(h.fillerService.Pool()),
+		"fillers":    result.Fillers,
+		"slate":      result.Slate,
+		"filled_sec": result.FilledSec,
+		"slate_sec":  result.SlateSec,
+	})
+}
+
+// postAutoProgram はアセットライブラリから指定期間の番組表を自動で組み立てます
+func (h *HTTPHandler) postAutoProgram(c *gin.Context) {
+	var request struct {
+		From   string                  `json:"from"`
+		To     string                  `json:"to"`
+		Mode   string                  `json:"mode"`
+		DryRun bool                    `json:"dry_run"`
+		Rules  domain.AutoProgramRules `json:"rules"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストボディが不正です: " + err.Error()})
+		return
+	}
+
+	dates, err := domain.DateRange(request.From, request.To)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	mode, err := domain.ParseTemplateApplyMode(request.Mode)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	result, err := h.autoProgramService.Generate(ctx, dates, request.Rules, mode, request.DryRun, h.generatedDayRecorder(c, domain.AuditAutoProgram, request.DryRun))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, domain.ErrInvalidAutoProgramRules) {
+			status = http.StatusBadRequest
+		}
+		body := gin.H{"error": "自動編成に失敗しました: " + err.Error()}
+		// 失敗した日より前に反映した日の結果も返す
+		if result != nil {
+			body["result"] = result
+		}
+		c.JSON(status, body)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// getAuditLog は条件（from・to（RFC3339形式）、actor、action、target、limit）に一致する監査ログを新しい順に返します
+func (h *HTTPHandler) getAuditLog(c *gin.Context) {
+	query := domain.AuditQuery{
+		Actor:  c.Query("actor"),
+		Action: domain.AuditAction(c.Query("action")),
+		Target: c.Query("target"),
+	}
+	for name, value := range map[string]*time.Time{"from": &query.From, "to": &query.To} {
+		if c.Query(name) == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, c.Query(name))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": name + "クエリパラメータはRFC3339形式で指定してください"})
+			return
+		}
+		*value = parsed
+	}
+	if limit := c.Query("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed <= 0 || parsed > 1000 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limitクエリパラメータは1〜1000で指定してください"})
+			return
+		}
+		query.Limit = parsed
+	}
+
+	entries, err := h.auditService.Query(c.Request.Context(), query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "監査ログの取得に失敗しました: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"count":   len(entries),
+	})
+}
+
+// audit は変更を監査ログに記録します。記録に失敗した場合は変更を取り消せないため、
+// 変更が保存されたことと記録に失敗したことを500で返してfalseを返します（呼び出し元はそのまま終了します）
+func (h *HTTPHandler) audit(c *gin.Context, action domain.AuditAction, target string, before, after any) bool {
+	if err := h.recordAudit(c, action, target, before, after); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":  "変更は保存されましたが、" + err.Error(),
+			"target": target,
+		})
+		return false
+	}
+	return true
+}
+
+// recordAudit は変更を監査ログに記録します。記録に失敗した場合はエラーをログに出力して返します
+func (h *HTTPHandler) recordAudit(c *gin.Context, action domain.AuditAction, target string, before, after any) error {
+	if h.auditService == nil {
+		return nil
+	}
+
+	actor := domain.AuditActor{Subject: "anonymous", SourceIP: c.ClientIP()}
+	if principal := principal(c); principal != nil {
+		actor.Subject = principal.Subject
+		actor.Method = principal.Method
+	}
+	// リクエストが切断されても記録する
+	ctx := context.WithoutCancel(c.Request.Context())
+	if err := h.auditService.Record(ctx, actor, action, target, before, after); err != nil {
+		log.Printf("監査ログの記録に失敗: action=%s, target=%s, actor=%s: %v", action, target, actor.Subject, err)
+		return fmt.Errorf("監査ログの記録に失敗しました: %w", err)
+	}
+	return nil
+}
+
+// auditRecorder はサービスが変更を保存した直後に、変更前後の内容を監査ログに記録する関数を返します。
+// targetには変更後（削除の場合は変更前）の内容を渡し、監査ログに記録する識別子を返します
+func auditRecorder[T any](h *HTTPHandler, c *gin.Context, action domain.AuditAction, target func(value *T) string) domain.ChangeRecorder[T] {
+	return func(before, after *T) error {
+		subject := after
+		if subject == nil {
+			subject = before
+		}
+		return h.recordAudit(c, action, target(subject), before, after)
+	}
+}
+
+// respondNotRecorded は変更の保存後に監査ログの記録に失敗した場合に、
+// 変更が保存されたことと記録に失敗したことを500で返してtrueを返します
+func respondNotRecorded(c *gin.Context, err error) bool {
+	var notRecorded *domain.ChangeNotRecordedError
+	if !errors.As(err, &notRecorded) {
+		return false
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": notRecorded.Error()})
+	return true
+}
+
+// programAuditRecorder はパスの日付の番組の変更を監査ログに記録する関数を返します
+func (h *HTTPHandler) programAuditRecorder(c *gin.Context, action domain.AuditAction) domain.ChangeRecorder[domain.ProgramItem] {
+	return auditRecorder(h, c, action, func(program *domain.ProgramItem) string {
+		return programTarget(c.Param("date"), program.ID)
+	})
+}
+
+// programTarget は監査ログに記録する番組の識別子です
+func programTarget(date, id string) string {
+	return "schedule/" + date + "/programs/" + id
+}
+
+func assetTarget(asset *domain.Asset) string {
+	return "assets/" + asset.ID
+}
+
+func recurrenceTarget(recurrence *domain.RecurringProgram) string {
+	return "recurrences/" + recurrence.ID
+}
+
+func templateTarget(template *domain.ScheduleTemplate) string {
+	return "templates/" + template.ID
+}
+
+// generatedDayRecorder はテンプレートや自動編成で番組表に反映した日ごとに、変更前後の番組を監査ログに記録する関数を返します
+// （dryRunの場合はnil）
+func (h *HTTPHandler) generatedDayRecorder(c *gin.Context, action domain.AuditAction, dryRun bool) domain.GeneratedDayRecorder {
+	if dryRun {
+		return nil
+	}
+	return func(date string, before, after []domain.ProgramItem) error {
+		return h.recordAudit(c, action, "schedule/"+date, before, after)
+	}
+}
+
+// parseTags はカンマ区切りのタグを分割します
+func parseTags(value string) []string {
+	tags := make([]string, 0)
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
 // isValidVideoType は動画ファイルの形式が有効かチェックします
 func isValidVideoType(contentType string) bool {
 	validTypes := []string{
@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 )
 
 type FFmpegService struct{}
@@ -93,3 +94,91 @@ func (f *FFmpegService) ConvertByteDataToHLS(data []byte, outputPath string) err
 	log.Println("FFmpegコマンド（一時ファイル版）が正常に完了しました")
 	return nil
 }
+
+// 透かしは映像の隅に置く薄い四角形で、AとBで位置を変えます
+const (
+	watermarkFilterA = "drawbox=x=iw*0.04:y=ih*0.04:w=6:h=6:color=white@0.06:t=fill"
+	watermarkFilterB = "drawbox=x=iw*0.96-6:y=ih*0.04:w=6:h=6:color=white@0.06:t=fill"
+)
+
+// ConvertByteDataToWatermarkedHLS は動画をA/Bの透かし入りのHLSに変換し、outputPathのa/とb/に出力します。
+// AとBはセグメントの区切りが同じになるよう同じキーフレームで分割し、
+// outputPath/video.m3u8にはAのセグメントを参照するプレイリストを出力します
+func (f *FFmpegService) ConvertByteDataToWatermarkedHLS(data []byte, outputPath string) error {
+	tempFile, err := os.CreateTemp("", "video_input_*.mp4")
+	if err != nil {
+		return fmt.Errorf("一時ファイル作成エラー: %w", err)
+	}
+	tempFilePath := tempFile.Name()
+	defer os.Remove(tempFilePath)
+
+	if _, err := tempFile.Write(data); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("一時ファイル書き込みエラー: %w", err)
+	}
+	tempFile.Close()
+
+	args := []string{
+		"-i", tempFilePath,
+		"-filter_complex", "[0:v]split=2[va][vb];[va]" + watermarkFilterA + "[a];[vb]" + watermarkFilterB + "[b]",
+	}
+	for _, variant := range []string{"a", "b"} {
+		variantPath := filepath.Join(outputPath, variant)
+		if err := os.MkdirAll(variantPath, 0o755); err != nil {
+			return fmt.Errorf("出力ディレクトリ作成エラー: %w", err)
+		}
+		args = append(args,
+			"-map", "["+variant+"]",
+			"-map", "0:a?",
+			"-c:v", "libx264",
+			"-c:a", "aac",
+			"-preset", "fast",
+			"-f", "hls",
+			"-hls_time", "2",
+			"-hls_list_size", "0",
+			"-hls_allow_cache", "1",
+			"-hls_segment_type", "mpegts",
+			"-hls_flags", "split_by_time",
+			"-hls_playlist_type", "vod",
+			"-force_key_frames", "expr:gte(t,n_forced*2)",
+			"-hls_segment_filename", filepath.Join(variantPath, "video%03d.ts"),
+			filepath.Join(variantPath, "video.m3u8"))
+	}
+
+	cmd := exec.Command("ffmpeg", args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	log.Printf("FFmpegコマンド（透かし版）を実行中: %s", cmd.String())
+
+	if err := cmd.Run(); err != nil {
+		log.Printf("FFmpegコマンドの実行に失敗: %v", err)
+		return err
+	}
+
+	if err := writeVariantPlaylist(outputPath, "a"); err != nil {
+		return err
+	}
+
+	log.Println("FFmpegコマンド（透かし版）が正常に完了しました")
+	return nil
+}
+
+// writeVariantPlaylist はvariantのプレイリストのセグメントをvariant/で参照するプレイリストをoutputPath/video.m3u8に出力します
+func writeVariantPlaylist(outputPath, variant string) error {
+	data, err := os.ReadFile(filepath.Join(outputPath, variant, "video.m3u8"))
+	if err != nil {
+		return fmt.Errorf("m3u8ファイル読み込みエラー: %w", err)
+	}
+
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			lines[i] = variant + "/" + line
+		}
+	}
+	if err := os.WriteFile(filepath.Join(outputPath, "video.m3u8"), []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+		return fmt.Errorf("m3u8ファイル書き込みエラー: %w", err)
+	}
+	return nil
+}
@@ -6,6 +6,8 @@ import (
 
 	"cloud.google.com/go/firestore"
 	"github.com/genki0524/hls_striming_go/internal/domain"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type FirestoreScheduleRepository struct {
@@ -23,6 +25,9 @@ func (r *FirestoreScheduleRepository) GetScheduleByDate(ctx context.Context, dat
 
 	doc, err := docRef.Get(ctx)
 	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, domain.ErrScheduleNotFound
+		}
 		return nil, err
 	}
 
@@ -38,41 +43,40 @@ func (r *FirestoreScheduleRepository) GetScheduleByDate(ctx context.Context, dat
 	return &data, nil
 }
 
-func (r *FirestoreScheduleRepository) PostSchedule(ctx context.Context, request domain.RequestProgramItem, date string) error {
+func (r *FirestoreScheduleRepository) UpdateScheduleByDate(ctx context.Context, date string, expectedVersion int64, mutate func(schedule *domain.Schedule) error) (*domain.Schedule, error) {
 	docRef := r.client.Collection("schedules").Doc(date)
 
-	// 既存のスケジュールを取得
-	doc, err := docRef.Get(ctx)
-
-	program := request2ProgramItem(request)
+	var result domain.Schedule
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		var schedule domain.Schedule
 
-	if err != nil {
-		// ドキュメントが存在しない場合は新規作成
-		schedule := domain.Schedule{
-			Programs: []domain.ProgramItem{program},
+		// 既存のスケジュールを取得（存在しない場合は空の番組表として扱う）
+		doc, err := tx.Get(docRef)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if err == nil {
+			if err := doc.DataTo(&schedule); err != nil {
+				return err
+			}
 		}
-		_, err = docRef.Set(ctx, schedule)
-		return err
-	}
-
-	// 既存のスケジュールに番組を追加
-	var existingSchedule domain.Schedule
-	if err := doc.DataTo(&existingSchedule); err != nil {
-		return err
-	}
 
-	existingSchedule.Programs = append(existingSchedule.Programs, program)
+		if err := schedule.CheckVersion(expectedVersion); err != nil {
+			return err
+		}
 
-	_, err = docRef.Set(ctx, existingSchedule)
-	return err
-}
+		schedule.EnsureProgramIDs()
+		if err := mutate(&schedule); err != nil {
+			return err
+		}
+		schedule.Version++
 
-func request2ProgramItem(request domain.RequestProgramItem) domain.ProgramItem {
-	return domain.ProgramItem{
-		StartTime:    request.StartTime,
-		DurationSec:  request.DurationSec,
-		Type:         request.Type,
-		PathTemplate: request.PathTemplate,
-		Title:        request.Title,
+		result = schedule
+		return tx.Set(docRef, schedule)
+	})
+	if err != nil {
+		return nil, err
 	}
+
+	return &result, nil
 }
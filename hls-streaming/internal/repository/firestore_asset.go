@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"sort"
+
+	"cloud.google.com/go/firestore"
+	"github.com/genki0524/hls_striming_go/internal/domain"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type FirestoreAssetRepository struct {
+	client *firestore.Client
+}
+
+func NewFirestoreAssetRepository(client *firestore.Client) *FirestoreAssetRepository {
+	return &FirestoreAssetRepository{
+		client: client,
+	}
+}
+
+func (r *FirestoreAssetRepository) ListAssets(ctx context.Context) ([]domain.Asset, error) {
+	docs, err := r.client.Collection("assets").Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	assets := make([]domain.Asset, 0, len(docs))
+	for _, doc := range docs {
+		var asset domain.Asset
+		if err := doc.DataTo(&asset); err != nil {
+			return nil, err
+		}
+		assets = append(assets, asset)
+	}
+
+	sort.Slice(assets, func(i, j int) bool {
+		return assets[i].CreatedAt.After(assets[j].CreatedAt)
+	})
+
+	return assets, nil
+}
+
+func (r *FirestoreAssetRepository) GetAsset(ctx context.Context, id string) (*domain.Asset, error) {
+	doc, err := r.client.Collection("assets").Doc(id).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, domain.ErrAssetNotFound
+		}
+		return nil, err
+	}
+
+	var asset domain.Asset
+	if err := doc.DataTo(&asset); err != nil {
+		return nil, err
+	}
+	return &asset, nil
+}
+
+func (r *FirestoreAssetRepository) CreateAsset(ctx context.Context, asset domain.Asset) error {
+	_, err := r.client.Collection("assets").Doc(asset.ID).Create(ctx, asset)
+	return err
+}
+
+func (r *FirestoreAssetRepository) UpdateAsset(ctx context.Context, asset domain.Asset) error {
+	docRef := r.client.Collection("assets").Doc(asset.ID)
+	if _, err := docRef.Get(ctx); err != nil {
+		if status.Code(err) == codes.NotFound {
+			return domain.ErrAssetNotFound
+		}
+		return err
+	}
+
+	_, err := docRef.Set(ctx, asset)
+	return err
+}
+
+func (r *FirestoreAssetRepository) DeleteAsset(ctx context.Context, id string) error {
+	docRef := r.client.Collection("assets").Doc(id)
+	if _, err := docRef.Get(ctx); err != nil {
+		if status.Code(err) == codes.NotFound {
+			return domain.ErrAssetNotFound
+		}
+		return err
+	}
+
+	_, err := docRef.Delete(ctx)
+	return err
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/genki0524/hls_striming_go/internal/domain"
+)
+
+// FirestoreAuditRepository は監査ログをFirestoreのaudit_logコレクションに保存します。
+// ドキュメントは作成のみ行い、更新・削除はしません（セキュリティルールでも書き換えを禁止してください）
+type FirestoreAuditRepository struct {
+	client *firestore.Client
+}
+
+func NewFirestoreAuditRepository(client *firestore.Client) *FirestoreAuditRepository {
+	return &FirestoreAuditRepository{
+		client: client,
+	}
+}
+
+// auditDocument は監査ログのドキュメントです（変更前後の内容はJSONの文字列で保存します）
+type auditDocument struct {
+	ID         string    `firestore:"id"`
+	Time       time.Time `firestore:"time"`
+	Actor      string    `firestore:"actor"`
+	AuthMethod string    `firestore:"auth_method"`
+	SourceIP   string    `firestore:"source_ip"`
+	Action     string    `firestore:"action"`
+	Target     string    `firestore:"target"`
+	Before     string    `firestore:"before"`
+	After      string    `firestore:"after"`
+}
+
+func (r *FirestoreAuditRepository) AppendAuditEntry(ctx context.Context, entry domain.AuditEntry) error {
+	// Createは同じIDのドキュメントがある場合に失敗するため、既存の監査ログを上書きしない
+	_, err := r.client.Collection("audit_log").Doc(entry.ID).Create(ctx, auditDocument{
+		ID:         entry.ID,
+		Time:       entry.Time,
+		Actor:      entry.Actor,
+		AuthMethod: entry.AuthMethod,
+		SourceIP:   entry.SourceIP,
+		Action:     string(entry.Action),
+		Target:     entry.Target,
+		Before:     string(entry.Before),
+		After:      string(entry.After),
+	})
+	return err
+}
+
+func (r *FirestoreAuditRepository) QueryAuditEntries(ctx context.Context, query domain.AuditQuery) ([]domain.AuditEntry, error) {
+	q := r.client.Collection("audit_log").Query
+	if !query.From.IsZero() {
+		q = q.Where("time", ">=", query.From)
+	}
+	if !query.To.IsZero() {
+		q = q.Where("time", "<", query.To)
+	}
+	if query.Actor != "" {
+		q = q.Where("actor", "==", query.Actor)
+	}
+	if query.Action != "" {
+		q = q.Where("action", "==", string(query.Action))
+	}
+	if query.Target != "" {
+		q = q.Where("target", "==", query.Target)
+	}
+	q = q.OrderBy("time", firestore.Desc)
+	if query.Limit > 0 {
+		q = q.Limit(query.Limit)
+	}
+
+	docs, err := q.Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]domain.AuditEntry, 0, len(docs))
+	for _, doc := range docs {
+		var document auditDocument
+		if err := doc.DataTo(&document); err != nil {
+			return nil, err
+		}
+		entry := domain.AuditEntry{
+			ID:         document.ID,
+			Time:       document.Time,
+			Actor:      document.Actor,
+			AuthMethod: document.AuthMethod,
+			SourceIP:   document.SourceIP,
+			Action:     domain.AuditAction(document.Action),
+			Target:     document.Target,
+		}
+		if document.Before != "" {
+			entry.Before = []byte(document.Before)
+		}
+		if document.After != "" {
+			entry.After = []byte(document.After)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+
+	"cloud.google.com/go/firestore"
+	"github.com/genki0524/hls_striming_go/internal/domain"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type FirestoreRecurrenceRepository struct {
+	client *firestore.Client
+}
+
+func NewFirestoreRecurrenceRepository(client *firestore.Client) *FirestoreRecurrenceRepository {
+	return &FirestoreRecurrenceRepository{
+		client: client,
+	}
+}
+
+func (r *FirestoreRecurrenceRepository) ListRecurrences(ctx context.Context) ([]domain.RecurringProgram, error) {
+	docs, err := r.client.Collection("recurrences").Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	recurrences := make([]domain.RecurringProgram, 0, len(docs))
+	for _, doc := range docs {
+		var recurrence domain.RecurringProgram
+		if err := doc.DataTo(&recurrence); err != nil {
+			return nil, err
+		}
+		recurrences = append(recurrences, recurrence)
+	}
+	return recurrences, nil
+}
+
+func (r *FirestoreRecurrenceRepository) GetRecurrence(ctx context.Context, id string) (*domain.RecurringProgram, error) {
+	doc, err := r.client.Collection("recurrences").Doc(id).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, domain.ErrRecurrenceNotFound
+		}
+		return nil, err
+	}
+
+	var recurrence domain.RecurringProgram
+	if err := doc.DataTo(&recurrence); err != nil {
+		return nil, err
+	}
+	return &recurrence, nil
+}
+
+func (r *FirestoreRecurrenceRepository) SaveRecurrence(ctx context.Context, recurrence domain.RecurringProgram) error {
+	_, err := r.client.Collection("recurrences").Doc(recurrence.ID).Set(ctx, recurrence)
+	return err
+}
+
+func (r *FirestoreRecurrenceRepository) DeleteRecurrence(ctx context.Context, id string) error {
+	docRef := r.client.Collection("recurrences").Doc(id)
+	if _, err := docRef.Get(ctx); err != nil {
+		if status.Code(err) == codes.NotFound {
+			return domain.ErrRecurrenceNotFound
+		}
+		return err
+	}
+
+	_, err := docRef.Delete(ctx)
+	return err
+}
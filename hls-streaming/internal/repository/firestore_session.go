@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/genki0524/hls_striming_go/internal/domain"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// FirestoreSessionStore は複数のレプリカでセッションを共有するためのストアです
+type FirestoreSessionStore struct {
+	client *firestore.Client
+}
+
+func NewFirestoreSessionStore(client *firestore.Client) *FirestoreSessionStore {
+	return &FirestoreSessionStore{
+		client: client,
+	}
+}
+
+func (r *FirestoreSessionStore) GetSession(ctx context.Context, id string) (*domain.Session, error) {
+	doc, err := r.client.Collection("sessions").Doc(id).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, domain.ErrSessionNotFound
+		}
+		return nil, err
+	}
+
+	var session domain.Session
+	if err := doc.DataTo(&session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *FirestoreSessionStore) SaveSession(ctx context.Context, session domain.Session) error {
+	_, err := r.client.Collection("sessions").Doc(session.ID).Set(ctx, session)
+	return err
+}
+
+func (r *FirestoreSessionStore) DeleteExpiredSessions(ctx context.Context, now time.Time) (int, error) {
+	docs, err := r.client.Collection("sessions").Where("expires_at", "<=", now).Documents(ctx).GetAll()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, doc := range docs {
+		if _, err := doc.Ref.Delete(ctx); err != nil {
+			return 0, err
+		}
+	}
+	return len(docs), nil
+}
+
+// FirestoreSessionViewerStore はセッションの視聴者の記録をsession_viewersコレクションに保持するストアです。
+// sessionsコレクションと異なり、TTLポリシーや期限切れのセッションの削除の対象にしません
+type FirestoreSessionViewerStore struct {
+	client *firestore.Client
+}
+
+func NewFirestoreSessionViewerStore(client *firestore.Client) *FirestoreSessionViewerStore {
+	return &FirestoreSessionViewerStore{
+		client: client,
+	}
+}
+
+func (r *FirestoreSessionViewerStore) RecordSessionViewer(ctx context.Context, viewer domain.SessionViewer) error {
+	_, _, err := r.client.Collection("session_viewers").Add(ctx, viewer)
+	return err
+}
+
+func (r *FirestoreSessionViewerStore) ListSessionViewers(ctx context.Context, sessionID string) ([]domain.SessionViewer, error) {
+	docs, err := r.client.Collection("session_viewers").Where("session_id", "==", sessionID).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	viewers := make([]domain.SessionViewer, 0, len(docs))
+	for _, doc := range docs {
+		var viewer domain.SessionViewer
+		if err := doc.DataTo(&viewer); err != nil {
+			return nil, err
+		}
+		viewers = append(viewers, viewer)
+	}
+	// 複合インデックスを不要にするため、並べ替えはクエリではなくここで行う
+	sort.Slice(viewers, func(i, j int) bool {
+		return viewers[i].AuthorizedAt.Before(viewers[j].AuthorizedAt)
+	})
+	return viewers, nil
+}
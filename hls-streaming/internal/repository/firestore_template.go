@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+
+	"cloud.google.com/go/firestore"
+	"github.com/genki0524/hls_striming_go/internal/domain"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type FirestoreTemplateRepository struct {
+	client *firestore.Client
+}
+
+func NewFirestoreTemplateRepository(client *firestore.Client) *FirestoreTemplateRepository {
+	return &FirestoreTemplateRepository{
+		client: client,
+	}
+}
+
+func (r *FirestoreTemplateRepository) ListTemplates(ctx context.Context) ([]domain.ScheduleTemplate, error) {
+	docs, err := r.client.Collection("templates").Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	templates := make([]domain.ScheduleTemplate, 0, len(docs))
+	for _, doc := range docs {
+		var template domain.ScheduleTemplate
+		if err := doc.DataTo(&template); err != nil {
+			return nil, err
+		}
+		templates = append(templates, template)
+	}
+	return templates, nil
+}
+
+func (r *FirestoreTemplateRepository) GetTemplate(ctx context.Context, id string) (*domain.ScheduleTemplate, error) {
+	doc, err := r.client.Collection("templates").Doc(id).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, domain.ErrTemplateNotFound
+		}
+		return nil, err
+	}
+
+	var template domain.ScheduleTemplate
+	if err := doc.DataTo(&template); err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+func (r *FirestoreTemplateRepository) SaveTemplate(ctx context.Context, template domain.ScheduleTemplate) error {
+	_, err := r.client.Collection("templates").Doc(template.ID).Set(ctx, template)
+	return err
+}
+
+func (r *FirestoreTemplateRepository) DeleteTemplate(ctx context.Context, id string) error {
+	docRef := r.client.Collection("templates").Doc(id)
+	if _, err := docRef.Get(ctx); err != nil {
+		if status.Code(err) == codes.NotFound {
+			return domain.ErrTemplateNotFound
+		}
+		return err
+	}
+
+	_, err := docRef.Delete(ctx)
+	return err
+}
@@ -5,11 +5,14 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"time"
 
 	"cloud.google.com/go/storage"
 	"github.com/genki0524/hls_striming_go/internal/domain"
+	"google.golang.org/api/iterator"
 )
 
 type GCSRepository struct {
@@ -126,13 +129,23 @@ func (r *GCSRepository) DownloadFileToMemory(ctx context.Context, bucket, object
 }
 
 func (r *GCSRepository) CreateSignedURL(bucket, object string) (string, error) {
+	return r.createSignedURL(bucket, object, "")
+}
+
+// createSignedURL は署名付きURLを作成します。sessionIDを指定した場合はsidクエリを含めて署名し、
+// 視聴者ごとに異なるURLにします（アクセスログからセッションを特定できるようにするため）
+func (r *GCSRepository) createSignedURL(bucket, object, sessionID string) (string, error) {
 	expiresTime := 3 * time.Minute
 
-	u, err := r.client.Bucket(bucket).SignedURL(object, &storage.SignedURLOptions{
+	options := &storage.SignedURLOptions{
 		Scheme:  storage.SigningSchemeV4,
 		Method:  http.MethodGet,
 		Expires: time.Now().Add(expiresTime),
-	})
+	}
+	if sessionID != "" {
+		options.QueryParameters = url.Values{"sid": {sessionID}}
+	}
+	u, err := r.client.Bucket(bucket).SignedURL(object, options)
 	if err != nil {
 		return "", fmt.Errorf("Bucket(%q).SignedURL: %w", bucket, err)
 	}
@@ -141,7 +154,19 @@ func (r *GCSRepository) CreateSignedURL(bucket, object string) (string, error) {
 
 func (r *GCSRepository) GetM3U8WithSignedURLs(ctx context.Context, bucket, date, programName string) (*domain.M3U8Playlist, error) {
 	resourcePath := date + "/" + programName
-	m3u8Data, err := r.DownloadFileToMemory(ctx, bucket, resourcePath+"/video.m3u8")
+	return r.GetPlaylistWithSignedURLs(ctx, bucket, resourcePath+"/video.m3u8")
+}
+
+// GetPlaylistWithSignedURLs は任意のパスのm3u8を読み込み、セグメントを署名付きURLに置き換えます
+func (r *GCSRepository) GetPlaylistWithSignedURLs(ctx context.Context, bucket, playlistObject string) (*domain.M3U8Playlist, error) {
+	return r.GetPlaylistWithSessionURLs(ctx, bucket, playlistObject, "", domain.Watermark{})
+}
+
+// GetPlaylistWithSessionURLs はGetPlaylistWithSignedURLsと同様に、セグメントをセッションごとの署名付きURLに置き換えます。
+// 透かし入りの素材の場合は、署名する前にセッションに配信するA/Bのセグメントを選びます
+func (r *GCSRepository) GetPlaylistWithSessionURLs(ctx context.Context, bucket, playlistObject, sessionID string, watermark domain.Watermark) (*domain.M3U8Playlist, error) {
+	resourcePath := path.Dir(playlistObject)
+	m3u8Data, err := r.DownloadFileToMemory(ctx, bucket, playlistObject)
 	if err != nil {
 		return nil, fmt.Errorf("downloadFileIntoMemory: %w", err)
 	}
@@ -150,10 +175,11 @@ func (r *GCSRepository) GetM3U8WithSignedURLs(ctx context.Context, bucket, date,
 	if err != nil {
 		return nil, fmt.Errorf("ParseM3U8Content: %w", err)
 	}
+	watermark.Apply(playlist, sessionID)
 
 	for index, segment := range playlist.Segments {
 		fileName := segment.Filename
-		url, err := r.CreateSignedURL(bucket, resourcePath+"/"+fileName)
+		url, err := r.createSignedURL(bucket, resourcePath+"/"+fileName, sessionID)
 		if err != nil {
 			return nil, fmt.Errorf("createSignedURL: %w", err)
 		}
@@ -188,3 +214,20 @@ func (r *GCSRepository) DeleteObject(ctx context.Context, bucket, object string)
 	}
 	return nil
 }
+
+// DeletePrefix は指定したプレフィックス配下のオブジェクトをすべて削除します
+func (r *GCSRepository) DeletePrefix(ctx context.Context, bucket, prefix string) error {
+	it := r.client.Bucket(bucket).Objects(ctx, &storage.Query{Prefix: prefix + "/"})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("オブジェクト一覧取得エラー: %w", err)
+		}
+		if err := r.DeleteObject(ctx, bucket, attrs.Name); err != nil {
+			return err
+		}
+	}
+}
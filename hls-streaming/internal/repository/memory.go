@@ -0,0 +1,364 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/genki0524/hls_striming_go/internal/domain"
+)
+
+// InMemoryScheduleRepository はプロセス内に番組表を保持するリポジトリです。
+// ローカル開発やテストでFirestoreの代わりに使用します
+type InMemoryScheduleRepository struct {
+	mutex     sync.Mutex
+	schedules map[string]*domain.Schedule
+}
+
+func NewInMemoryScheduleRepository() *InMemoryScheduleRepository {
+	return &InMemoryScheduleRepository{
+		schedules: make(map[string]*domain.Schedule),
+	}
+}
+
+func (r *InMemoryScheduleRepository) GetScheduleByDate(ctx context.Context, date string) (*domain.Schedule, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	schedule, ok := r.schedules[date]
+	if !ok {
+		return nil, domain.ErrScheduleNotFound
+	}
+	return cloneSchedule(schedule), nil
+}
+
+func (r *InMemoryScheduleRepository) UpdateScheduleByDate(ctx context.Context, date string, expectedVersion int64, mutate func(schedule *domain.Schedule) error) (*domain.Schedule, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	schedule := &domain.Schedule{}
+	if existing, ok := r.schedules[date]; ok {
+		schedule = cloneSchedule(existing)
+	}
+
+	if err := schedule.CheckVersion(expectedVersion); err != nil {
+		return nil, err
+	}
+
+	schedule.EnsureProgramIDs()
+	if err := mutate(schedule); err != nil {
+		return nil, err
+	}
+	schedule.Version++
+
+	r.schedules[date] = schedule
+	return cloneSchedule(schedule), nil
+}
+
+func cloneSchedule(schedule *domain.Schedule) *domain.Schedule {
+	programs := make([]domain.ProgramItem, len(schedule.Programs))
+	copy(programs, schedule.Programs)
+
+	sort.Slice(programs, func(i, j int) bool {
+		return programs[i].StartTime < programs[j].StartTime
+	})
+
+	return &domain.Schedule{Programs: programs, Version: schedule.Version}
+}
+
+// InMemoryRecurrenceRepository はプロセス内に繰り返し番組を保持するリポジトリです
+type InMemoryRecurrenceRepository struct {
+	mutex       sync.Mutex
+	recurrences map[string]domain.RecurringProgram
+}
+
+func NewInMemoryRecurrenceRepository() *InMemoryRecurrenceRepository {
+	return &InMemoryRecurrenceRepository{
+		recurrences: make(map[string]domain.RecurringProgram),
+	}
+}
+
+func (r *InMemoryRecurrenceRepository) ListRecurrences(ctx context.Context) ([]domain.RecurringProgram, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	recurrences := make([]domain.RecurringProgram, 0, len(r.recurrences))
+	for _, recurrence := range r.recurrences {
+		recurrences = append(recurrences, recurrence)
+	}
+	sort.Slice(recurrences, func(i, j int) bool {
+		return recurrences[i].ID < recurrences[j].ID
+	})
+	return recurrences, nil
+}
+
+func (r *InMemoryRecurrenceRepository) GetRecurrence(ctx context.Context, id string) (*domain.RecurringProgram, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	recurrence, ok := r.recurrences[id]
+	if !ok {
+		return nil, domain.ErrRecurrenceNotFound
+	}
+	return &recurrence, nil
+}
+
+func (r *InMemoryRecurrenceRepository) SaveRecurrence(ctx context.Context, recurrence domain.RecurringProgram) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.recurrences[recurrence.ID] = recurrence
+	return nil
+}
+
+func (r *InMemoryRecurrenceRepository) DeleteRecurrence(ctx context.Context, id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.recurrences[id]; !ok {
+		return domain.ErrRecurrenceNotFound
+	}
+	delete(r.recurrences, id)
+	return nil
+}
+
+// InMemoryTemplateRepository はプロセス内に週間テンプレートを保持するリポジトリです
+type InMemoryTemplateRepository struct {
+	mutex     sync.Mutex
+	templates map[string]domain.ScheduleTemplate
+}
+
+func NewInMemoryTemplateRepository() *InMemoryTemplateRepository {
+	return &InMemoryTemplateRepository{
+		templates: make(map[string]domain.ScheduleTemplate),
+	}
+}
+
+func (r *InMemoryTemplateRepository) ListTemplates(ctx context.Context) ([]domain.ScheduleTemplate, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	templates := make([]domain.ScheduleTemplate, 0, len(r.templates))
+	for _, template := range r.templates {
+		templates = append(templates, template)
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].ID < templates[j].ID
+	})
+	return templates, nil
+}
+
+func (r *InMemoryTemplateRepository) GetTemplate(ctx context.Context, id string) (*domain.ScheduleTemplate, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	template, ok := r.templates[id]
+	if !ok {
+		return nil, domain.ErrTemplateNotFound
+	}
+	return &template, nil
+}
+
+func (r *InMemoryTemplateRepository) SaveTemplate(ctx context.Context, template domain.ScheduleTemplate) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.templates[template.ID] = template
+	return nil
+}
+
+func (r *InMemoryTemplateRepository) DeleteTemplate(ctx context.Context, id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.templates[id]; !ok {
+		return domain.ErrTemplateNotFound
+	}
+	delete(r.templates, id)
+	return nil
+}
+
+// InMemoryAssetRepository はプロセス内にアセットカタログを保持するリポジトリです
+type InMemoryAssetRepository struct {
+	mutex  sync.Mutex
+	assets map[string]domain.Asset
+}
+
+func NewInMemoryAssetRepository() *InMemoryAssetRepository {
+	return &InMemoryAssetRepository{
+		assets: make(map[string]domain.Asset),
+	}
+}
+
+func (r *InMemoryAssetRepository) ListAssets(ctx context.Context) ([]domain.Asset, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	assets := make([]domain.Asset, 0, len(r.assets))
+	for _, asset := range r.assets {
+		assets = append(assets, asset)
+	}
+	sort.Slice(assets, func(i, j int) bool {
+		return assets[i].CreatedAt.After(assets[j].CreatedAt)
+	})
+	return assets, nil
+}
+
+func (r *InMemoryAssetRepository) GetAsset(ctx context.Context, id string) (*domain.Asset, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	asset, ok := r.assets[id]
+	if !ok {
+		return nil, domain.ErrAssetNotFound
+	}
+	return &asset, nil
+}
+
+func (r *InMemoryAssetRepository) CreateAsset(ctx context.Context, asset domain.Asset) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.assets[asset.ID] = asset
+	return nil
+}
+
+func (r *InMemoryAssetRepository) UpdateAsset(ctx context.Context, asset domain.Asset) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.assets[asset.ID]; !ok {
+		return domain.ErrAssetNotFound
+	}
+	r.assets[asset.ID] = asset
+	return nil
+}
+
+func (r *InMemoryAssetRepository) DeleteAsset(ctx context.Context, id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.assets[id]; !ok {
+		return domain.ErrAssetNotFound
+	}
+	delete(r.assets, id)
+	return nil
+}
+
+// InMemorySessionStore はプロセス内にセッションを保持するストアです。
+// レプリカが1つの場合に使用します
+type InMemorySessionStore struct {
+	mutex    sync.Mutex
+	sessions map[string]domain.Session
+}
+
+func NewInMemorySessionStore() *InMemorySessionStore {
+	return &InMemorySessionStore{
+		sessions: make(map[string]domain.Session),
+	}
+}
+
+func (r *InMemorySessionStore) GetSession(ctx context.Context, id string) (*domain.Session, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	session, ok := r.sessions[id]
+	if !ok {
+		return nil, domain.ErrSessionNotFound
+	}
+	return &session, nil
+}
+
+func (r *InMemorySessionStore) SaveSession(ctx context.Context, session domain.Session) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.sessions[session.ID] = session
+	return nil
+}
+
+func (r *InMemorySessionStore) DeleteExpiredSessions(ctx context.Context, now time.Time) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	count := 0
+	for id, session := range r.sessions {
+		if session.Expired(now) {
+			delete(r.sessions, id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+// InMemorySessionViewerStore はプロセス内にセッションの視聴者の記録を保持するストアです。
+// 再起動すると記録は失われるため、ローカル開発やテストで使用します
+type InMemorySessionViewerStore struct {
+	mutex   sync.Mutex
+	viewers map[string][]domain.SessionViewer
+}
+
+func NewInMemorySessionViewerStore() *InMemorySessionViewerStore {
+	return &InMemorySessionViewerStore{
+		viewers: make(map[string][]domain.SessionViewer),
+	}
+}
+
+func (r *InMemorySessionViewerStore) RecordSessionViewer(ctx context.Context, viewer domain.SessionViewer) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.viewers[viewer.SessionID] = append(r.viewers[viewer.SessionID], viewer)
+	return nil
+}
+
+func (r *InMemorySessionViewerStore) ListSessionViewers(ctx context.Context, sessionID string) ([]domain.SessionViewer, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	viewers := make([]domain.SessionViewer, len(r.viewers[sessionID]))
+	copy(viewers, r.viewers[sessionID])
+	return viewers, nil
+}
+
+// InMemoryAuditRepository はプロセス内に監査ログを保持するリポジトリです。
+// 再起動すると監査ログは失われるため、ローカル開発やテストで使用します
+type InMemoryAuditRepository struct {
+	mutex   sync.Mutex
+	entries []domain.AuditEntry
+}
+
+func NewInMemoryAuditRepository() *InMemoryAuditRepository {
+	return &InMemoryAuditRepository{
+		entries: make([]domain.AuditEntry, 0),
+	}
+}
+
+func (r *InMemoryAuditRepository) AppendAuditEntry(ctx context.Context, entry domain.AuditEntry) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.entries = append(r.entries, entry)
+	return nil
+}
+
+func (r *InMemoryAuditRepository) QueryAuditEntries(ctx context.Context, query domain.AuditQuery) ([]domain.AuditEntry, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// 同じ時刻の監査ログは後から追加したものを新しいとみなす
+	entries := make([]domain.AuditEntry, 0)
+	for i := len(r.entries) - 1; i >= 0; i-- {
+		if query.Matches(r.entries[i]) {
+			entries = append(entries, r.entries[i])
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.After(entries[j].Time)
+	})
+	if query.Limit > 0 && len(entries) > query.Limit {
+		entries = entries[:query.Limit]
+	}
+	return entries, nil
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/genki0524/hls_striming_go/internal/domain"
+)
+
+// maxVASTResponseSize はVASTレスポンスとして読み込む最大のサイズです
+const maxVASTResponseSize = 1 << 20
+
+// VASTClient はVASTを返す広告判定サーバーのクライアントです
+type VASTClient struct {
+	endpoint   string
+	httpClient *http.Client
+}
+
+// NewVASTClient はendpoint（マクロを含むURL）に広告をリクエストするクライアントを生成します
+func NewVASTClient(endpoint string, timeout time.Duration) *VASTClient {
+	return &VASTClient{
+		endpoint:   endpoint,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+func (c *VASTClient) RequestAds(ctx context.Context, request domain.AdRequest) ([]domain.AdCreative, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, domain.ExpandAdURL(c.endpoint, request), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("広告判定サーバーがエラーを返しました: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxVASTResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	return domain.ParseVAST(data)
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/genki0524/hls_striming_go/internal/domain"
+)
+
+// AssetReferenceService はアセットを参照している番組・繰り返し番組・テンプレート・設定を探します
+type AssetReferenceService struct {
+	scheduleRepo   domain.ScheduleRepository
+	recurrenceRepo domain.RecurrenceRepository
+	templateRepo   domain.TemplateRepository
+	bumper         domain.BumperConfig
+	lookahead      time.Duration
+	location       *time.Location
+	clock          domain.Clock
+}
+
+func NewAssetReferenceService(scheduleRepo domain.ScheduleRepository, recurrenceRepo domain.RecurrenceRepository, templateRepo domain.TemplateRepository, bumper domain.BumperConfig, lookahead time.Duration, location *time.Location, clock domain.Clock) *AssetReferenceService {
+	return &AssetReferenceService{
+		scheduleRepo:   scheduleRepo,
+		recurrenceRepo: recurrenceRepo,
+		templateRepo:   templateRepo,
+		bumper:         bumper,
+		lookahead:      lookahead,
+		location:       location,
+		clock:          clock,
+	}
+}
+
+// FindReferences はアセットの参照元を返します。番組表は放送中と先読み期間内に放送される番組のみを対象にします
+func (s *AssetReferenceService) FindReferences(ctx context.Context, assetID string) ([]domain.AssetReference, error) {
+	references := make([]domain.AssetReference, 0)
+	if s.bumper.AssetID == assetID {
+		references = append(references, domain.AssetReference{Kind: domain.AssetReferenceBumper})
+	}
+
+	now := s.clock.Now().In(s.location)
+	to := now.Add(s.lookahead)
+	// 前日の番組表には日付をまたいで放送中の番組が含まれる可能性がある
+	for _, date := range domain.DatesBetween(now.AddDate(0, 0, -1), to, s.location) {
+		schedule, err := s.scheduleRepo.GetScheduleByDate(ctx, date)
+		if errors.Is(err, domain.ErrScheduleNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("番組表(%s)の取得に失敗: %w", date, err)
+		}
+		for _, program := range domain.ProgramsInWindow(schedule.Programs, now, to) {
+			references = append(references, domain.ProgramAssetReferences(&program, date, assetID)...)
+		}
+	}
+
+	recurrences, err := s.recurrenceRepo.ListRecurrences(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("繰り返し番組の取得に失敗: %w", err)
+	}
+	for _, recurrence := range recurrences {
+		if recurrence.ReferencesAsset(assetID) {
+			references = append(references, domain.AssetReference{Kind: domain.AssetReferenceRecurrence, ID: recurrence.ID, Title: recurrence.Title})
+		}
+	}
+
+	templates, err := s.templateRepo.ListTemplates(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("テンプレートの取得に失敗: %w", err)
+	}
+	for _, template := range templates {
+		if template.ReferencesAsset(assetID) {
+			references = append(references, domain.AssetReference{Kind: domain.AssetReferenceTemplate, ID: template.ID, Title: template.Name})
+		}
+	}
+
+	return references, nil
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/genki0524/hls_striming_go/internal/domain"
+)
+
+// defaultAuditQueryLimit は監査ログの検索で件数が指定されていない場合に返す件数です
+const defaultAuditQueryLimit = 100
+
+// AuditService は番組表・素材・設定の変更を監査ログに記録します
+type AuditService struct {
+	repository domain.AuditRepository
+	clock      domain.Clock
+}
+
+func NewAuditService(repository domain.AuditRepository, clock domain.Clock) *AuditService {
+	return &AuditService{
+		repository: repository,
+		clock:      clock,
+	}
+}
+
+// Record はactorによる変更を監査ログに記録します
+func (s *AuditService) Record(ctx context.Context, actor domain.AuditActor, action domain.AuditAction, target string, before, after any) error {
+	entry, err := domain.NewAuditEntry(s.clock.Now(), actor, action, target, before, after)
+	if err != nil {
+		return err
+	}
+	return s.repository.AppendAuditEntry(ctx, entry)
+}
+
+// Query は条件に一致する監査ログを新しい順に返します
+func (s *AuditService) Query(ctx context.Context, query domain.AuditQuery) ([]domain.AuditEntry, error) {
+	if query.Limit <= 0 {
+		query.Limit = defaultAuditQueryLimit
+	}
+	return s.repository.QueryAuditEntries(ctx, query)
+}
+
+// RecordConfig は起動時の設定が前回記録した設定と異なる場合に、変更前後の設定を記録します。
+// 設定は環境変数で変更するため、再起動のたびに比較します
+func (s *AuditService) RecordConfig(ctx context.Context, config map[string]string) error {
+	latest, err := s.repository.QueryAuditEntries(ctx, domain.AuditQuery{Action: domain.AuditConfigUpdate, Limit: 1})
+	if err != nil {
+		return err
+	}
+
+	var before json.RawMessage
+	if len(latest) > 0 {
+		before = latest[0].After
+	}
+	after, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	if before != nil && bytes.Equal(before, after) {
+		return nil
+	}
+
+	return s.Record(ctx, domain.AuditActor{Subject: domain.AuditSystemActor}, domain.AuditConfigUpdate, "config", before, json.RawMessage(after))
+}
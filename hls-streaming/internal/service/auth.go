@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+
+	"github.com/genki0524/hls_striming_go/internal/domain"
+)
+
+// AuthService は管理APIのリクエストを認証し、ロールで認可します。
+// 認証方式が1つも設定されていない場合は認証しません
+type AuthService struct {
+	authenticators []domain.Authenticator
+}
+
+func NewAuthService(authenticators ...domain.Authenticator) *AuthService {
+	return &AuthService{authenticators: authenticators}
+}
+
+// Enabled は認証が有効かどうかを返します
+func (s *AuthService) Enabled() bool {
+	return s != nil && len(s.authenticators) > 0
+}
+
+// Authorize は認証情報を認証し、roleの操作を許可する場合は利用者を返します
+func (s *AuthService) Authorize(ctx context.Context, credentials domain.Credentials, role domain.Role) (*domain.Principal, error) {
+	for _, authenticator := range s.authenticators {
+		principal, err := authenticator.Authenticate(ctx, credentials)
+		if errors.Is(err, domain.ErrNoCredentials) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !principal.HasRole(role) {
+			return nil, fmt.Errorf("%w: %sロールが必要です", domain.ErrForbidden, role)
+		}
+		return principal, nil
+	}
+	return nil, fmt.Errorf("%w: 認証情報が必要です", domain.ErrUnauthenticated)
+}
+
+// APIKeyAuthenticator はX-API-Keyヘッダーのキーで認証します
+type APIKeyAuthenticator struct {
+	keys []domain.APIKey
+}
+
+func NewAPIKeyAuthenticator(keys []domain.APIKey) *APIKeyAuthenticator {
+	return &APIKeyAuthenticator{keys: keys}
+}
+
+func (a *APIKeyAuthenticator) Authenticate(ctx context.Context, credentials domain.Credentials) (*domain.Principal, error) {
+	if credentials.APIKey == "" {
+		return nil, domain.ErrNoCredentials
+	}
+
+	// キーの長さによって比較時間が変わらないよう、ハッシュ値を比較する
+	digest := sha256.Sum256([]byte(credentials.APIKey))
+	for _, key := range a.keys {
+		expected := sha256.Sum256([]byte(key.Key))
+		if subtle.ConstantTimeCompare(digest[:], expected[:]) == 1 {
+			return &domain.Principal{Subject: key.Name, Roles: key.Roles, Method: "api_key"}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: APIキーが不正です", domain.ErrUnauthenticated)
+}
+
+// JWTAuthenticator はAuthorizationヘッダー（Bearer）のJWTをJWKSの公開鍵で検証して認証します
+type JWTAuthenticator struct {
+	verifier domain.JWTVerifier
+	clock    domain.Clock
+}
+
+func NewJWTAuthenticator(verifier domain.JWTVerifier, clock domain.Clock) *JWTAuthenticator {
+	return &JWTAuthenticator{
+		verifier: verifier,
+		clock:    clock,
+	}
+}
+
+func (a *JWTAuthenticator) Authenticate(ctx context.Context, credentials domain.Credentials) (*domain.Principal, error) {
+	if credentials.BearerToken == "" {
+		return nil, domain.ErrNoCredentials
+	}
+	return a.verifier.Verify(credentials.BearerToken, a.clock.Now())
+}
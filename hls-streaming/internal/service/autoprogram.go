@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/genki0524/hls_striming_go/internal/domain"
+)
+
+// AutoProgramService はアセットライブラリから番組表を自動で組み立てます（FASTチャンネル向け）
+type AutoProgramService struct {
+	scheduleService *ScheduleService
+	assetRepo       domain.AssetRepository
+}
+
+func NewAutoProgramService(scheduleService *ScheduleService, assetRepo domain.AssetRepository) *AutoProgramService {
+	return &AutoProgramService{
+		scheduleService: scheduleService,
+		assetRepo:       assetRepo,
+	}
+}
+
+// Generate は指定した日付の番組表をルールに従って組み立て、番組表に書き込みます。
+// dryRunの場合は番組表を変更せずに結果のみを返します。recordを指定した場合は日ごとに反映した直後に変更前後の番組を渡します。
+// 途中の日で失敗した場合は、それまでに反映した日の結果とエラーを返します
+func (s *AutoProgramService) Generate(ctx context.Context, dates []string, rules domain.AutoProgramRules, mode domain.TemplateApplyMode, dryRun bool, record domain.GeneratedDayRecorder) (*domain.AutoProgramResult, error) {
+	if err := rules.Validate(); err != nil {
+		return nil, err
+	}
+
+	assets, err := s.assetRepo.ListAssets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &domain.AutoProgramResult{
+		Mode:   mode,
+		DryRun: dryRun,
+		Days:   make([]domain.AutoProgramDayResult, 0, len(dates)),
+	}
+	if len(dates) == 0 {
+		return result, nil
+	}
+	result.From = dates[0]
+	result.To = dates[len(dates)-1]
+
+	loc := s.scheduleService.Location()
+	assetLookup := s.scheduleService.newAssetLookup(ctx)
+
+	history, err := s.previousDayPrograms(ctx, dates[0], loc)
+	if err != nil {
+		return nil, err
+	}
+	// 反映済みの日は取り消さないため、途中で失敗した場合も保持している番組表に反映する
+	fail := func(date string, err error) (*domain.AutoProgramResult, error) {
+		log.Printf("自動編成の反映に失敗: 日付=%s, 反映済みの日数=%d, %v", date, result.AppliedCount, err)
+		s.scheduleService.refreshGeneratedDays(ctx, result.AppliedCount, dryRun)
+		return result, err
+	}
+
+	for _, date := range dates {
+		day, err := domain.BuildAutoSchedule(date, loc, assets, rules, history)
+		if err != nil {
+			return fail(date, err)
+		}
+		occurrences, err := s.scheduleService.adjacentOccurrences(ctx, date)
+		if err != nil {
+			return fail(date, err)
+		}
+
+		applied, change, err := s.scheduleService.applyGeneratedDay(ctx, date, day.Programs, occurrences, mode, assetLookup, dryRun)
+		if err != nil {
+			return fail(date, err)
+		}
+		applied.Date = date
+		if applied.Status == domain.TemplateDayConflict {
+			log.Printf("自動編成をスキップしました: 日付=%s, 既存の番組数=%d", date, applied.ExistingCount)
+		}
+
+		result.Add(domain.AutoProgramDayResult{
+			TemplateDayResult: applied,
+			Programs:          day.Programs,
+			FilledSec:         day.FilledSec,
+			UnfilledSec:       day.UnfilledSec,
+		})
+		history = day.Programs
+		if change != nil && record != nil {
+			if err := record(date, change.before, change.after); err != nil {
+				return fail(date, err)
+			}
+		}
+	}
+
+	s.scheduleService.refreshGeneratedDays(ctx, result.AppliedCount, dryRun)
+	return result, nil
+}
+
+// previousDayPrograms は最初の日の前日の番組を返します（再放送間隔の判定に使用します）
+func (s *AutoProgramService) previousDayPrograms(ctx context.Context, date string, loc *time.Location) ([]domain.ProgramItem, error) {
+	noon, err := domain.LocalDateTime(date, "12:00", loc)
+	if err != nil {
+		return nil, err
+	}
+	previous := noon.AddDate(0, 0, -1).Format("2006-01-02")
+
+	schedule, err := s.scheduleService.GetScheduleByDate(ctx, previous)
+	if errors.Is(err, domain.ErrScheduleNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return schedule.Programs, nil
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/genki0524/hls_striming_go/internal/domain"
+)
+
+// FillerService は番組の隙間を埋めるフィラー素材（プロモ、ジングル、ミュージックビデオなど）を管理します
+type FillerService struct {
+	assetRepo domain.AssetRepository
+	tags      []string
+	location  *time.Location
+	clock     domain.Clock
+
+	mutex sync.RWMutex
+	pool  []domain.Asset
+	// poolRevision はフィラー素材が変わるたびに増えます
+	poolRevision uint64
+	plan         *playoutPlan
+}
+
+// playoutPlan はフィラーで隙間を埋めた配信用の番組リストのキャッシュです。
+// 番組表・フィラー素材・対象の日付のいずれかが変わった場合に作り直します
+type playoutPlan struct {
+	scheduleRevision uint64
+	poolRevision     uint64
+	windowStart      string
+	programs         []domain.ProgramItem
+}
+
+func NewFillerService(assetRepo domain.AssetRepository, tags []string, location *time.Location, clock domain.Clock) *FillerService {
+	return &FillerService{
+		assetRepo: assetRepo,
+		tags:      tags,
+		location:  location,
+		clock:     clock,
+		pool:      make([]domain.Asset, 0),
+	}
+}
+
+// RefreshPool はフィラー用のタグが付いたアセットを読み込み直します
+func (s *FillerService) RefreshPool(ctx context.Context) error {
+	assets, err := s.assetRepo.ListAssets(ctx)
+	if err != nil {
+		return err
+	}
+
+	pool := make([]domain.Asset, 0)
+	for _, asset := range assets {
+		if asset.HasAnyTag(s.tags) {
+			pool = append(pool, asset)
+		}
+	}
+
+	s.mutex.Lock()
+	if !samePool(s.pool, pool) {
+		s.poolRevision++
+	}
+	s.pool = pool
+	s.mutex.Unlock()
+
+	log.Printf("フィラー素材を更新しました。素材数: %d", len(pool))
+	return nil
+}
+
+func (s *FillerService) Pool() []domain.Asset {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	result := make([]domain.Asset, len(s.pool))
+	copy(result, s.pool)
+	return result
+}
+
+// PlayoutSchedule は現在時刻を基準に、番組表の隙間をフィラーで埋めた配信用の番組リストを返します。
+// 結果はrevision（番組表のリビジョン）とフィラー素材が変わるか日付が変わるまでキャッシュします。
+// 作り直す場合も放送中のフィラーは途中で切り替わらないようそのまま残します
+func (s *FillerService) PlayoutSchedule(programs []domain.ProgramItem, revision uint64) []domain.ProgramItem {
+	now := s.clock.Now()
+	windowStart := domain.WindowDates(now, s.location)[0]
+
+	s.mutex.RLock()
+	plan, poolRevision := s.plan, s.poolRevision
+	s.mutex.RUnlock()
+	if plan != nil && plan.scheduleRevision == revision && plan.poolRevision == poolRevision && plan.windowStart == windowStart {
+		return copyPrograms(plan.programs)
+	}
+
+	var pinned []domain.ProgramItem
+	if plan != nil {
+		if onAir := domain.OnAirFiller(plan.programs, programs, now); onAir != nil {
+			pinned = append(pinned, *onAir)
+		}
+	}
+	result := s.fill(programs, now, pinned)
+
+	s.mutex.Lock()
+	s.plan = &playoutPlan{
+		scheduleRevision: revision,
+		poolRevision:     poolRevision,
+		windowStart:      windowStart,
+		programs:         result,
+	}
+	s.mutex.Unlock()
+	return copyPrograms(result)
+}
+
+// PlayoutScheduleAt は指定した時刻の前日・当日・翌日の範囲で隙間をフィラーで埋めます（キャッシュは使用しません）
+func (s *FillerService) PlayoutScheduleAt(programs []domain.ProgramItem, at time.Time) []domain.ProgramItem {
+	return s.fill(programs, at, nil)
+}
+
+// fill はatの前日・当日・翌日の範囲で、番組とpinnedのフィラー以外の隙間をフィラーで埋めます
+func (s *FillerService) fill(programs []domain.ProgramItem, at time.Time, pinned []domain.ProgramItem) []domain.ProgramItem {
+	dates := domain.WindowDates(at, s.location)
+	from, to, err := s.dayBounds(dates[0], dates[len(dates)-1])
+	if err != nil {
+		log.Printf("フィラーの計算に失敗: %v", err)
+		return programs
+	}
+
+	occupied := make([]domain.ProgramItem, 0, len(programs)+len(pinned))
+	occupied = append(occupied, programs...)
+	occupied = append(occupied, pinned...)
+	result := domain.FillGaps(occupied, from, to, s.Pool(), s.location)
+	return domain.MergeFillers(occupied, result.Fillers)
+}
+
+// FillDate は指定した日の隙間を埋めるフィラーと、埋められずに静止画となる時間帯を返します
+func (s *FillerService) FillDate(date string, programs []domain.ProgramItem) (domain.FillResult, error) {
+	from, to, err := s.dayBounds(date, date)
+	if err != nil {
+		return domain.FillResult{}, err
+	}
+	return domain.FillGaps(programs, from, to, s.Pool(), s.location), nil
+}
+
+// dayBounds はfromの日の0時からtoの翌日の0時までの時刻を返します
+func (s *FillerService) dayBounds(fromDate, toDate string) (time.Time, time.Time, error) {
+	from, err := domain.LocalDateTime(fromDate, "00:00", s.location)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	last, err := domain.LocalDateTime(toDate, "00:00", s.location)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	to := time.Date(last.Year(), last.Month(), last.Day()+1, 0, 0, 0, 0, s.location)
+	return from, to, nil
+}
+
+// samePool はフィラー素材の構成（ID、タイトル、長さ）が同じかどうかを返します
+func samePool(a, b []domain.Asset) bool {
+	return slices.EqualFunc(a, b, func(x, y domain.Asset) bool {
+		return x.ID == y.ID && x.Title == y.Title && x.DurationSec == y.DurationSec
+	})
+}
+
+func copyPrograms(programs []domain.ProgramItem) []domain.ProgramItem {
+	result := make([]domain.ProgramItem, len(programs))
+	copy(result, programs)
+	return result
+}
+
+func (s *FillerService) StartPeriodicRefresh(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.RefreshPool(ctx); err != nil {
+			log.Printf("フィラー素材の更新でエラーが発生: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("フィラー素材の定期更新を停止します")
+			return
+		case <-ticker.C:
+		}
+	}
+}
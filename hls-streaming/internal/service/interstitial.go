@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/genki0524/hls_striming_go/internal/domain"
+	"github.com/genki0524/hls_striming_go/internal/repository"
+)
+
+// InterstitialService はHLS Interstitialsでプレイヤーが取得するアセットリストとプレイリストを返します
+type InterstitialService struct {
+	gcsRepo   *repository.GCSRepository
+	assetRepo domain.AssetRepository
+	adService *AdInsertionService
+}
+
+func NewInterstitialService(gcsRepo *repository.GCSRepository, assetRepo domain.AssetRepository, adService *AdInsertionService) *InterstitialService {
+	return &InterstitialService{
+		gcsRepo:   gcsRepo,
+		assetRepo: assetRepo,
+		adService: adService,
+	}
+}
+
+// AssetList はX-ASSET-LISTのクエリに応じたアセットリストを返します。
+// assetsを指定した場合は告知などのアセットを、breakを指定した場合は広告枠の広告を返します
+func (s *InterstitialService) AssetList(ctx context.Context, query url.Values) (*domain.InterstitialAssetList, error) {
+	list, err := s.assetList(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	// セッションから取得した場合はアセットのURIにもセッションIDを付ける
+	for i := range list.Assets {
+		list.Assets[i].URI = domain.SessionURI(list.Assets[i].URI, query.Get("sid"))
+	}
+	return list, nil
+}
+
+func (s *InterstitialService) assetList(ctx context.Context, query url.Values) (*domain.InterstitialAssetList, error) {
+	if assets := query.Get("assets"); assets != "" {
+		return s.promoAssetList(ctx, strings.Split(assets, ","))
+	}
+
+	request, err := domain.ParseAdAssetListQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	list := &domain.InterstitialAssetList{Assets: []domain.InterstitialAsset{}}
+	if s.adService != nil {
+		list.Assets = s.adService.InterstitialAds(request)
+	}
+	return list, nil
+}
+
+func (s *InterstitialService) promoAssetList(ctx context.Context, assetIDs []string) (*domain.InterstitialAssetList, error) {
+	list := &domain.InterstitialAssetList{Assets: make([]domain.InterstitialAsset, 0, len(assetIDs))}
+	for _, id := range assetIDs {
+		asset, err := s.assetRepo.GetAsset(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("アセット(%s)の取得に失敗: %w", id, err)
+		}
+		list.Assets = append(list.Assets, domain.InterstitialAsset{
+			URI:      domain.AssetPlaylistURI(id),
+			Duration: asset.DurationSec,
+		})
+	}
+	return list, nil
+}
+
+// AssetPlaylist はアセットを単体で再生するVODのプレイリストを返します
+func (s *InterstitialService) AssetPlaylist(ctx context.Context, assetID string) (string, error) {
+	asset, err := s.assetRepo.GetAsset(ctx, assetID)
+	if err != nil {
+		return "", fmt.Errorf("アセット(%s)の取得に失敗: %w", assetID, err)
+	}
+	playlist, err := s.gcsRepo.GetPlaylistWithSignedURLs(ctx, os.Getenv("BUCKET"), asset.MainPlaylistPath())
+	if err != nil {
+		return "", err
+	}
+	return playlist.VODContent(), nil
+}
+
+// AdCreativePlaylist は変換済みの広告素材を再生するVODのプレイリストを返します
+func (s *InterstitialService) AdCreativePlaylist(ctx context.Context, creativeKey string) (string, error) {
+	if !domain.IsCreativeKey(creativeKey) {
+		return "", fmt.Errorf("広告素材のキーが不正です: %s", creativeKey)
+	}
+	playlist, err := s.gcsRepo.GetPlaylistWithSignedURLs(ctx, os.Getenv("BUCKET"), creativeBasePath(creativeKey)+"/video.m3u8")
+	if err != nil {
+		return "", err
+	}
+	return playlist.VODContent(), nil
+}
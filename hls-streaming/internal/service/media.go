@@ -4,24 +4,41 @@ import (
 	"context"
 	"fmt"
 	"io/fs"
+	"log"
 	"os"
 	"path/filepath"
+	"time"
 
+	"github.com/genki0524/hls_striming_go/internal/domain"
 	"github.com/genki0524/hls_striming_go/internal/media"
 	"github.com/genki0524/hls_striming_go/internal/repository"
 )
 
 type MediaService struct {
 	gcsRepo       *repository.GCSRepository
+	assetRepo     domain.AssetRepository
 	ffmpegService *media.FFmpegService
+	watermark     domain.Watermark
+	references    *AssetReferenceService
 }
 
-func NewMediaService(gcsRepo *repository.GCSRepository, ffmpegService *media.FFmpegService) *MediaService {
+func NewMediaService(gcsRepo *repository.GCSRepository, assetRepo domain.AssetRepository, ffmpegService *media.FFmpegService, watermark domain.Watermark, references *AssetReferenceService) *MediaService {
 	return &MediaService{
 		gcsRepo:       gcsRepo,
+		assetRepo:     assetRepo,
 		ffmpegService: ffmpegService,
+		watermark:     watermark,
+		references:    references,
 	}
 }
+
+// convertToHLS は動画をHLSに変換します。透かしが有効な場合はA/Bの透かし入りの素材を出力します
+func (s *MediaService) convertToHLS(videoData []byte, tempDir string) error {
+	if s.watermark.Enabled() {
+		return s.ffmpegService.ConvertByteDataToWatermarkedHLS(videoData, tempDir)
+	}
+	return s.ffmpegService.ConvertByteDataToHLS(videoData, tempDir)
+}
 func (s *MediaService) UploadVideo(ctx context.Context, object string, data []byte) error {
 	bucket := os.Getenv("BUCKET")
 	if err := s.gcsRepo.UploadVideoData(ctx, bucket, object, data); err != nil {
@@ -43,23 +60,139 @@ func (s *MediaService) ConvertAndUploadHLS(ctx context.Context, videoData []byte
 	}
 	defer os.RemoveAll(tempDir) // 処理完了後にクリーンアップ
 
-	if err := s.ffmpegService.ConvertByteDataToHLS(videoData, tempDir); err != nil {
+	if err := s.convertToHLS(videoData, tempDir); err != nil {
 		return fmt.Errorf("HLS変換エラー: %w", err)
 	}
 
 	// 変換されたファイルをGCSにアップロード
 	basePath := fmt.Sprintf("%s/%s", date, programName)
+	_, err = uploadHLSDirectory(ctx, s.gcsRepo, bucket, tempDir, basePath)
+	return err
+}
+
+// CreateAsset は動画をHLS変換してアセットとして登録します。recordには登録した直後に登録したアセットを渡します
+func (s *MediaService) CreateAsset(ctx context.Context, videoData []byte, title string, tags []string, record domain.ChangeRecorder[domain.Asset]) (*domain.Asset, error) {
+	bucket := os.Getenv("BUCKET")
+	if bucket == "" {
+		return nil, fmt.Errorf("BUCKET環境変数が設定されていません")
+	}
+
+	tempDir, err := os.MkdirTemp("", "hls_conversion_")
+	if err != nil {
+		return nil, fmt.Errorf("一時ディレクトリ作成エラー: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	if err := s.convertToHLS(videoData, tempDir); err != nil {
+		return nil, fmt.Errorf("HLS変換エラー: %w", err)
+	}
 
+	id := domain.NewID()
+	basePath := "assets/" + id
+	playlist, err := uploadHLSDirectory(ctx, s.gcsRepo, bucket, tempDir, basePath)
+	if err != nil {
+		return nil, err
+	}
+
+	asset := domain.Asset{
+		ID:          id,
+		Title:       title,
+		DurationSec: playlist.TotalDuration(),
+		Renditions: []domain.Rendition{
+			{Name: "default", Playlist: "video.m3u8"},
+		},
+		StoragePrefix: basePath,
+		Tags:          tags,
+		CreatedAt:     time.Now(),
+		Watermarked:   s.watermark.Enabled(),
+	}
+
+	if err := s.assetRepo.CreateAsset(ctx, asset); err != nil {
+		// カタログに登録できなかった素材は参照されないため、アップロードしたファイルを削除する
+		if deleteErr := s.gcsRepo.DeletePrefix(context.WithoutCancel(ctx), bucket, basePath); deleteErr != nil {
+			log.Printf("登録に失敗したアセットのファイルの削除に失敗: %s: %v", basePath, deleteErr)
+		}
+		return nil, fmt.Errorf("アセット登録エラー: %w", err)
+	}
+	if err := record.Record(nil, &asset); err != nil {
+		return nil, err
+	}
+
+	return &asset, nil
+}
+
+func (s *MediaService) ListAssets(ctx context.Context) ([]domain.Asset, error) {
+	return s.assetRepo.ListAssets(ctx)
+}
+
+func (s *MediaService) GetAsset(ctx context.Context, id string) (*domain.Asset, error) {
+	return s.assetRepo.GetAsset(ctx, id)
+}
+
+// UpdateAsset はアセットのタイトルやタグを更新します。recordには更新した直後に変更前後のアセットを渡します。
+// 記録に失敗した場合も、更新は保存されているため更新後のアセットを返します
+func (s *MediaService) UpdateAsset(ctx context.Context, id string, patch domain.AssetPatch, record domain.ChangeRecorder[domain.Asset]) (*domain.Asset, error) {
+	asset, err := s.assetRepo.GetAsset(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	previous := *asset
+
+	if patch.Title != nil {
+		asset.Title = *patch.Title
+	}
+	if patch.Tags != nil {
+		asset.Tags = *patch.Tags
+	}
+
+	if err := s.assetRepo.UpdateAsset(ctx, *asset); err != nil {
+		return nil, fmt.Errorf("アセット更新エラー: %w", err)
+	}
+	return asset, record.Record(&previous, asset)
+}
+
+// DeleteAsset はアセットのカタログ情報とストレージ上のファイルを削除します。
+// 放送予定の番組などから参照されている場合は、forceがtrueでなければ*domain.AssetInUseErrorを返します。
+// recordには削除した直後に削除したアセットを渡します
+func (s *MediaService) DeleteAsset(ctx context.Context, id string, force bool, record domain.ChangeRecorder[domain.Asset]) error {
+	asset, err := s.assetRepo.GetAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if !force {
+		references, err := s.references.FindReferences(ctx, id)
+		if err != nil {
+			return fmt.Errorf("アセットの参照元の確認に失敗: %w", err)
+		}
+		if len(references) > 0 {
+			return &domain.AssetInUseError{References: references}
+		}
+	}
+
+	bucket := os.Getenv("BUCKET")
+	if err := s.gcsRepo.DeletePrefix(ctx, bucket, asset.StoragePrefix); err != nil {
+		return fmt.Errorf("アセットファイル削除エラー: %w", err)
+	}
+
+	if err := s.assetRepo.DeleteAsset(ctx, id); err != nil {
+		return err
+	}
+	return record.Record(asset, nil)
+}
+
+// uploadHLSDirectory は変換済みのm3u8とtsファイルをbasePath配下にアップロードします（サブディレクトリの構成を保ちます）
+func uploadHLSDirectory(ctx context.Context, gcsRepo *repository.GCSRepository, bucket, tempDir, basePath string) (*domain.M3U8Playlist, error) {
 	// m3u8ファイルをアップロード
 	m3u8Path := filepath.Join(tempDir, "video.m3u8")
 	m3u8Data, err := os.ReadFile(m3u8Path)
 	if err != nil {
-		return fmt.Errorf("m3u8ファイル読み込みエラー: %w", err)
+		return nil, fmt.Errorf("m3u8ファイル読み込みエラー: %w", err)
 	}
 
 	m3u8Object := basePath + "/video.m3u8"
-	if err := s.gcsRepo.UploadVideoData(ctx, bucket, m3u8Object, m3u8Data); err != nil {
-		return fmt.Errorf("m3u8ファイルアップロードエラー: %w", err)
+	if err := gcsRepo.UploadVideoData(ctx, bucket, m3u8Object, m3u8Data); err != nil {
+		return nil, fmt.Errorf("m3u8ファイルアップロードエラー: %w", err)
 	}
 
 	// tsファイルをアップロード
@@ -75,9 +208,14 @@ func (s *MediaService) ConvertAndUploadHLS(ctx context.Context, videoData []byte
 				return fmt.Errorf("tsファイル読み込みエラー (%s): %w", path, err)
 			}
 
-			fileName := d.Name()
+			// 透かし入りの素材はa/とb/のサブディレクトリごとアップロードする
+			relativePath, err := filepath.Rel(tempDir, path)
+			if err != nil {
+				return err
+			}
+			fileName := filepath.ToSlash(relativePath)
 			tsObject := basePath + "/" + fileName
-			if err := s.gcsRepo.UploadVideoData(ctx, bucket, tsObject, tsData); err != nil {
+			if err := gcsRepo.UploadVideoData(ctx, bucket, tsObject, tsData); err != nil {
 				return fmt.Errorf("tsファイルアップロードエラー (%s): %w", fileName, err)
 			}
 		}
@@ -85,8 +223,8 @@ func (s *MediaService) ConvertAndUploadHLS(ctx context.Context, videoData []byte
 	})
 
 	if err != nil {
-		return fmt.Errorf("tsファイル処理エラー: %w", err)
+		return nil, fmt.Errorf("tsファイル処理エラー: %w", err)
 	}
 
-	return nil
+	return domain.ParseM3U8Content(string(m3u8Data))
 }
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"time"
+
+	"github.com/genki0524/hls_striming_go/internal/domain"
+)
+
+// PlaybackTokenService は視聴者の再生トークンを発行・検証します。
+// 秘密鍵が設定されていない場合は再生を保護しません
+type PlaybackTokenService struct {
+	signer  domain.PlaybackTokenSigner
+	channel string
+	ttl     time.Duration
+	clock   domain.Clock
+}
+
+func NewPlaybackTokenService(secret, channel string, ttl time.Duration, clock domain.Clock) *PlaybackTokenService {
+	return &PlaybackTokenService{
+		signer:  domain.PlaybackTokenSigner{Secret: []byte(secret)},
+		channel: channel,
+		ttl:     ttl,
+		clock:   clock,
+	}
+}
+
+// Enabled は再生トークンによる保護が有効かどうかを返します
+func (s *PlaybackTokenService) Enabled() bool {
+	return s != nil && len(s.signer.Secret) > 0
+}
+
+// Channel は再生トークンで視聴を許可するチャンネルです
+func (s *PlaybackTokenService) Channel() string {
+	return s.channel
+}
+
+// Issue はviewerの再生トークンを発行します。bindIPがtrueの場合はipからの視聴のみを許可します
+func (s *PlaybackTokenService) Issue(viewer, ip string, bindIP bool) (string, time.Time, error) {
+	expiresAt := s.clock.Now().Add(s.ttl).Truncate(time.Second)
+	claims := domain.PlaybackClaims{Subject: viewer, Channel: s.channel, ExpiresAt: expiresAt.Unix()}
+	if bindIP {
+		claims.IP = ip
+	}
+	token, err := s.signer.Sign(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// Verify はipからのリクエストの再生トークンを検証します
+func (s *PlaybackTokenService) Verify(token, ip string) (*domain.PlaybackClaims, error) {
+	return s.signer.Verify(token, s.channel, ip, s.clock.Now())
+}
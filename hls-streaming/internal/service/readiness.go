@@ -0,0 +1,272 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/genki0524/hls_striming_go/internal/domain"
+	"github.com/genki0524/hls_striming_go/internal/repository"
+)
+
+// segmentCheckConcurrency はセグメントの存在確認を並列に行う数です
+const segmentCheckConcurrency = 8
+
+type ReadinessService struct {
+	scheduleService *ScheduleService
+	assetRepo       domain.AssetRepository
+	gcsRepo         *repository.GCSRepository
+	bumper          domain.BumperConfig
+	lookahead       time.Duration
+	location        *time.Location
+	clock           domain.Clock
+
+	mutex      sync.RWMutex
+	lastReport *domain.ReadinessReport
+}
+
+func NewReadinessService(scheduleService *ScheduleService, assetRepo domain.AssetRepository, gcsRepo *repository.GCSRepository, bumper domain.BumperConfig, lookahead time.Duration, location *time.Location, clock domain.Clock) *ReadinessService {
+	return &ReadinessService{
+		scheduleService: scheduleService,
+		assetRepo:       assetRepo,
+		gcsRepo:         gcsRepo,
+		bumper:          bumper,
+		lookahead:       lookahead,
+		location:        location,
+		clock:           clock,
+	}
+}
+
+// LastReport は直近に実行したチェック結果を返します（未実行の場合はnil）
+func (s *ReadinessService) LastReport() *domain.ReadinessReport {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.lastReport
+}
+
+// CheckUpcoming は現在から先読み期間内に放送される番組の素材を確認します
+func (s *ReadinessService) CheckUpcoming(ctx context.Context) (*domain.ReadinessReport, error) {
+	loc := s.location
+	now := s.clock.Now().In(loc)
+	to := now.Add(s.lookahead)
+
+	bucket := os.Getenv("BUCKET")
+	report := &domain.ReadinessReport{
+		CheckedAt: now,
+		From:      now,
+		To:        to,
+		Results:   make([]domain.ReadinessResult, 0),
+	}
+
+	programs, err := s.upcomingPrograms(ctx, now, to)
+	if err != nil {
+		return nil, err
+	}
+	needsBumper := false
+	for _, program := range domain.ProgramsInWindow(programs, now, to) {
+		result := s.checkProgram(ctx, bucket, &program, program.ScheduleDate)
+		if !result.Ready() {
+			log.Printf("[ALERT] 放送前チェックで問題を検出: 日付=%s, 番組=%s, 開始=%s, 状態=%s, %s",
+				program.ScheduleDate, program.Title, program.StartTime, result.Status, result.Message)
+		}
+		report.Add(result)
+		needsBumper = needsBumper || s.bumper.AppliesTo(&program)
+	}
+
+	// 先読み期間内にバンパーを入れる番組がある場合は、チャンネルのバンパーの素材も確認する
+	if needsBumper {
+		bumper := domain.ProgramItem{Title: "バンパー", AssetID: s.bumper.AssetID}
+		result := s.checkProgram(ctx, bucket, &bumper, "")
+		result.Bumper = true
+		if !result.Ready() {
+			log.Printf("[ALERT] 放送前チェックで問題を検出: バンパー=%s, 状態=%s, %s", s.bumper.AssetID, result.Status, result.Message)
+		}
+		report.Add(result)
+	}
+
+	log.Printf("放送前チェック完了: 準備完了=%d, 問題あり=%d", report.ReadyCount, report.NotReadyCount)
+
+	s.mutex.Lock()
+	s.lastReport = report
+	s.mutex.Unlock()
+
+	return report, nil
+}
+
+// upcomingPrograms はfrom〜toに放送される可能性がある番組を、繰り返し番組を展開した放送用の番組表から集めます。
+// 前日・当日・翌日の番組表を1日ずつずらして読み込み、先読み期間が1日を超える場合にも対応します
+func (s *ReadinessService) upcomingPrograms(ctx context.Context, from, to time.Time) ([]domain.ProgramItem, error) {
+	programs := make([]domain.ProgramItem, 0)
+	seen := make(map[string]bool)
+	for at := from; ; at = at.AddDate(0, 0, 1) {
+		window, err := s.scheduleService.LoadWindowAt(ctx, at)
+		if err != nil {
+			return nil, fmt.Errorf("番組表の取得に失敗: %w", err)
+		}
+		for _, program := range window {
+			if program.ID != "" {
+				if seen[program.ID] {
+					continue
+				}
+				seen[program.ID] = true
+			}
+			programs = append(programs, program)
+		}
+		// 読み込んだ範囲は翌日の終わりまで（基準時刻から1日以上先）を含む
+		if !at.AddDate(0, 0, 1).Before(to) {
+			return programs, nil
+		}
+	}
+}
+
+func (s *ReadinessService) checkProgram(ctx context.Context, bucket string, program *domain.ProgramItem, date string) domain.ReadinessResult {
+	result := domain.ReadinessResult{
+		ProgramID:           program.ID,
+		Title:               program.Title,
+		ScheduleDate:        date,
+		StartTime:           program.StartTime,
+		RequiredDurationSec: program.DurationSec,
+	}
+
+	playlistObjects, err := resolvePlaylistObjects(ctx, s.assetRepo, program, date)
+	if err != nil {
+		result.Status = domain.ReadinessError
+		result.Message = err.Error()
+		return result
+	}
+	result.PlaylistPath = playlistObjects[0]
+	if program.IsBlock() {
+		result.PlaylistPaths = playlistObjects
+	}
+
+	parts := make([]*domain.M3U8Playlist, 0, len(playlistObjects))
+	for _, playlistObject := range playlistObjects {
+		exists, err := s.gcsRepo.ObjectExists(ctx, bucket, playlistObject)
+		if err != nil {
+			result.Status = domain.ReadinessError
+			result.Message = err.Error()
+			return result
+		}
+		if !exists {
+			result.Status = domain.ReadinessMissingPlaylist
+			result.Message = "m3u8ファイルが存在しません"
+			if program.IsBlock() {
+				result.Message += ": " + playlistObject
+			}
+			return result
+		}
+
+		m3u8Data, err := s.gcsRepo.DownloadFileToMemory(ctx, bucket, playlistObject)
+		if err != nil {
+			result.Status = domain.ReadinessError
+			result.Message = err.Error()
+			return result
+		}
+		part, err := domain.ParseM3U8Content(string(m3u8Data))
+		if err != nil {
+			result.Status = domain.ReadinessError
+			result.Message = err.Error()
+			return result
+		}
+		// ブロック番組では素材ごとにディレクトリが異なるためセグメントをオブジェクトパスで扱う
+		if program.IsBlock() {
+			for i := range part.Segments {
+				part.Segments[i].Filename = path.Dir(playlistObject) + "/" + part.Segments[i].Filename
+			}
+		}
+		parts = append(parts, part)
+	}
+	playlist := domain.ProgramContent(program, domain.StitchPlaylists(parts))
+
+	resourcePath := path.Dir(playlistObjects[0])
+	if program.IsBlock() {
+		resourcePath = ""
+	}
+	missing, err := s.findMissingSegments(ctx, bucket, resourcePath, playlist)
+	if err != nil {
+		result.Status = domain.ReadinessError
+		result.Message = err.Error()
+		return result
+	}
+
+	// ループする番組は素材が短くても放送時間を埋められる
+	requiredSec := program.DurationSec
+	if program.Loop {
+		requiredSec = 0
+	}
+
+	result.MissingSegments = missing
+	result.Status, result.MediaDurationSec = domain.EvaluateMediaReadiness(playlist, missing, requiredSec)
+	switch result.Status {
+	case domain.ReadinessMissingSegments:
+		result.Message = fmt.Sprintf("%d個のセグメントが存在しません", len(missing))
+	case domain.ReadinessShortMedia:
+		result.Message = fmt.Sprintf("素材の長さ(%.1f秒)が放送時間(%d秒)に足りません", result.MediaDurationSec, program.DurationSec)
+	}
+	return result
+}
+
+// findMissingSegments はプレイリストが参照するセグメントのうちストレージに存在しないものを返します。
+// セグメントはresourcePathからの相対パスとして扱います（空の場合はオブジェクトパス）
+func (s *ReadinessService) findMissingSegments(ctx context.Context, bucket, resourcePath string, playlist *domain.M3U8Playlist) ([]string, error) {
+	var (
+		mutex    sync.Mutex
+		wg       sync.WaitGroup
+		missing  = make([]string, 0)
+		firstErr error
+	)
+	semaphore := make(chan struct{}, segmentCheckConcurrency)
+
+	for _, segment := range playlist.Segments {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(fileName string) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			exists, err := s.gcsRepo.ObjectExists(ctx, bucket, path.Join(resourcePath, fileName))
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			if !exists {
+				missing = append(missing, fileName)
+			}
+		}(segment.Filename)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	sort.Strings(missing)
+	return missing, nil
+}
+
+func (s *ReadinessService) StartPeriodicCheck(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.CheckUpcoming(ctx); err != nil {
+			log.Printf("放送前チェックでエラーが発生: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("放送前チェックを停止します")
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/genki0524/hls_striming_go/internal/domain"
+)
+
+func (s *ScheduleService) ListRecurrences(ctx context.Context) ([]domain.RecurringProgram, error) {
+	return s.recurrenceRepo.ListRecurrences(ctx)
+}
+
+func (s *ScheduleService) GetRecurrence(ctx context.Context, id string) (*domain.RecurringProgram, error) {
+	return s.recurrenceRepo.GetRecurrence(ctx, id)
+}
+
+// CreateRecurrence は繰り返し番組を登録し、番組表に反映します。recordには保存した直後に登録した繰り返し番組を渡します
+func (s *ScheduleService) CreateRecurrence(ctx context.Context, recurrence domain.RecurringProgram, record domain.ChangeRecorder[domain.RecurringProgram]) (*domain.RecurringProgram, error) {
+	recurrence.ID = domain.NewID()
+	if err := s.saveRecurrence(ctx, recurrence, func() error {
+		return record.Record(nil, &recurrence)
+	}); err != nil {
+		return nil, err
+	}
+	return &recurrence, nil
+}
+
+// UpdateRecurrence は繰り返し番組の設定を置き換えます。recordには保存した直後に変更前後の繰り返し番組を渡します
+func (s *ScheduleService) UpdateRecurrence(ctx context.Context, id string, recurrence domain.RecurringProgram, record domain.ChangeRecorder[domain.RecurringProgram]) (*domain.RecurringProgram, error) {
+	previous, err := s.recurrenceRepo.GetRecurrence(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	recurrence.ID = id
+	if err := s.saveRecurrence(ctx, recurrence, func() error {
+		return record.Record(previous, &recurrence)
+	}); err != nil {
+		return nil, err
+	}
+	return &recurrence, nil
+}
+
+// DeleteRecurrence は繰り返し番組を削除します。recordには削除した直後に削除した繰り返し番組を渡します
+func (s *ScheduleService) DeleteRecurrence(ctx context.Context, id string, record domain.ChangeRecorder[domain.RecurringProgram]) error {
+	previous, err := s.recurrenceRepo.GetRecurrence(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.recurrenceRepo.DeleteRecurrence(ctx, id); err != nil {
+		return err
+	}
+	// 削除した変更はリフレッシュの結果にかかわらず記録する
+	recordErr := record.Record(previous, nil)
+
+	// 保存は完了しているため、リフレッシュの失敗はログに記録するだけにする
+	if err := s.RefreshFromRepository(ctx); err != nil {
+		log.Printf("繰り返し番組削除後のリフレッシュに失敗: %v", err)
+	}
+	return recordErr
+}
+
+// PreviewOccurrences は繰り返し番組をfrom〜toの日付で展開した結果を返します
+func (s *ScheduleService) PreviewOccurrences(ctx context.Context, id, from, to string) ([]domain.ProgramItem, error) {
+	recurrence, err := s.recurrenceRepo.GetRecurrence(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return recurrence.Expand(from, to, s.location)
+}
+
+// recurrenceCheckDays は繰り返し番組の保存時に日ごとの番組表と合わせて検証する期間（日数）です
+const recurrenceCheckDays = 366
+
+// saveRecurrence は繰り返し番組を検証して保存し、保存した直後にrecordで変更を記録してから番組表をリフレッシュします
+func (s *ScheduleService) saveRecurrence(ctx context.Context, recurrence domain.RecurringProgram, record func() error) error {
+	if err := recurrence.Validate(); err != nil {
+		return err
+	}
+	if err := s.validateRecurrenceOccurrences(ctx, recurrence); err != nil {
+		return err
+	}
+
+	if err := s.recurrenceRepo.SaveRecurrence(ctx, recurrence); err != nil {
+		log.Printf("繰り返し番組の保存に失敗: %v", err)
+		return err
+	}
+	// 保存した変更はリフレッシュの結果にかかわらず記録する
+	recordErr := record()
+
+	// 保存は完了しているため、リフレッシュの失敗はログに記録するだけにする
+	if err := s.RefreshFromRepository(ctx); err != nil {
+		log.Printf("繰り返し番組保存後のリフレッシュに失敗: %v", err)
+	}
+	return recordErr
+}
+
+// validateRecurrenceOccurrences は検証期間内のすべての放送回を、前後の日を含む番組表と他の繰り返し番組と合わせて検証します。
+// 番組と重なる放送回がある場合は*domain.ValidationErrorを返します
+func (s *ScheduleService) validateRecurrenceOccurrences(ctx context.Context, recurrence domain.RecurringProgram) error {
+	today := s.clock.Now().In(s.location)
+	from := today.Format("2006-01-02")
+	to := today.AddDate(0, 0, recurrenceCheckDays).Format("2006-01-02")
+	occurrences, err := recurrence.Expand(from, to, s.location)
+	if err != nil {
+		return err
+	}
+	if len(occurrences) == 0 {
+		return nil
+	}
+
+	// 他の繰り返し番組は期間全体でまとめて展開し、日付ごとに分ける（更新の場合は保存済みの同じ繰り返し番組を除く）
+	others, err := s.recurrenceRepo.ListRecurrences(ctx)
+	if err != nil {
+		return fmt.Errorf("繰り返し番組の取得に失敗: %w", err)
+	}
+	otherOccurrences := make(map[string][]domain.ProgramItem)
+	for _, other := range others {
+		if other.ID == recurrence.ID {
+			continue
+		}
+		expanded, err := other.Expand(adjacentDate(from, -1), adjacentDate(to, 1), s.location)
+		if err != nil {
+			continue
+		}
+		for _, program := range expanded {
+			otherOccurrences[program.ScheduleDate] = append(otherOccurrences[program.ScheduleDate], program)
+		}
+	}
+
+	schedules := make(map[string][]domain.ProgramItem)
+	programsOn := func(date string) ([]domain.ProgramItem, error) {
+		if programs, ok := schedules[date]; ok {
+			return programs, nil
+		}
+		schedule, err := s.getSchedule(ctx, date)
+		if err != nil && !errors.Is(err, domain.ErrScheduleNotFound) {
+			return nil, err
+		}
+		var programs []domain.ProgramItem
+		if err == nil {
+			programs = schedule.Programs
+		}
+		schedules[date] = programs
+		return programs, nil
+	}
+
+	assets := s.newAssetLookup(ctx)
+	violations := make([]domain.Violation, 0)
+	for _, occurrence := range occurrences {
+		// 日付をまたぐ番組と重なる場合も検出できるよう、前日と翌日の番組も加える
+		programs := make([]domain.ProgramItem, 0)
+		for _, date := range []string{adjacentDate(occurrence.ScheduleDate, -1), occurrence.ScheduleDate, adjacentDate(occurrence.ScheduleDate, 1)} {
+			dayPrograms, err := programsOn(date)
+			if err != nil {
+				return err
+			}
+			programs = append(programs, dayPrograms...)
+			programs = append(programs, otherOccurrences[date]...)
+		}
+
+		programs = append(programs, occurrence)
+		report := domain.ValidateSchedule(programs, assets.existsFunc())
+		if assets.err != nil {
+			return assets.err
+		}
+		violations = append(violations, report.ErrorsFor(occurrence.ID)...)
+	}
+
+	if len(violations) > 0 {
+		return &domain.ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+// expandRecurrences は登録されているすべての繰り返し番組をfrom〜toの日付で展開します
+func (s *ScheduleService) expandRecurrences(ctx context.Context, from, to string) ([]domain.ProgramItem, error) {
+	if s.recurrenceRepo == nil {
+		return nil, nil
+	}
+
+	recurrences, err := s.recurrenceRepo.ListRecurrences(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("繰り返し番組の取得に失敗: %w", err)
+	}
+
+	programs := make([]domain.ProgramItem, 0)
+	for _, recurrence := range recurrences {
+		occurrences, err := recurrence.Expand(from, to, s.location)
+		if err != nil {
+			log.Printf("繰り返し番組(%s)の展開に失敗: %v", recurrence.ID, err)
+			continue
+		}
+		programs = append(programs, occurrences...)
+	}
+	return programs, nil
+}
+
+// adjacentOccurrences は日付をまたいで放送される回も重複を検出できるよう、前日〜翌日に展開される繰り返し番組を返します
+func (s *ScheduleService) adjacentOccurrences(ctx context.Context, date string) ([]domain.ProgramItem, error) {
+	return s.expandRecurrences(ctx, adjacentDate(date, -1), adjacentDate(date, 1))
+}
+
+// adjacentDate はYYYY-MM-DD形式の日付のdays日後の日付を返します（解析できない場合はそのまま返します）
+func adjacentDate(date string, days int) string {
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return date
+	}
+	return day.AddDate(0, 0, days).Format("2006-01-02")
+}
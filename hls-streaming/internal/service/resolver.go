@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/genki0524/hls_striming_go/internal/domain"
+)
+
+// resolvePlaylistObject は番組が参照するm3u8のオブジェクトパスを返します。
+// アセットを参照しない番組は従来通り {date}/{title}/video.m3u8 を参照します
+func resolvePlaylistObject(ctx context.Context, assetRepo domain.AssetRepository, program *domain.ProgramItem, date string) (string, error) {
+	if program.AssetID == "" {
+		return date + "/" + program.Title + "/video.m3u8", nil
+	}
+
+	asset, err := assetRepo.GetAsset(ctx, program.AssetID)
+	if err != nil {
+		return "", fmt.Errorf("アセット(%s)の取得に失敗: %w", program.AssetID, err)
+	}
+	return asset.MainPlaylistPath(), nil
+}
+
+// resolvePlaylistObjects は番組が放送順に参照するm3u8のオブジェクトパスを返します。
+// ブロック番組の場合はアセットごとのパスを返します
+func resolvePlaylistObjects(ctx context.Context, assetRepo domain.AssetRepository, program *domain.ProgramItem, date string) ([]string, error) {
+	if !program.IsBlock() {
+		playlistObject, err := resolvePlaylistObject(ctx, assetRepo, program, date)
+		if err != nil {
+			return nil, err
+		}
+		return []string{playlistObject}, nil
+	}
+
+	objects := make([]string, 0, len(program.Assets))
+	for _, id := range program.Assets {
+		asset, err := assetRepo.GetAsset(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("アセット(%s)の取得に失敗: %w", id, err)
+		}
+		objects = append(objects, asset.MainPlaylistPath())
+	}
+	return objects, nil
+}
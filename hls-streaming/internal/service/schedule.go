@@ -2,26 +2,47 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/genki0524/hls_striming_go/internal/domain"
 )
 
+// errGeneratedDayConflict は生成した番組が既存の番組と競合した日の更新を中止するために使用します
+var errGeneratedDayConflict = errors.New("生成した番組が既存の番組と競合しました")
+
 type ScheduleService struct {
-	schedule   []domain.ProgramItem
-	mutex      sync.RWMutex
-	repository domain.ScheduleRepository
+	schedule       []domain.ProgramItem
+	mutex          sync.RWMutex
+	repository     domain.ScheduleRepository
+	assetRepo      domain.AssetRepository
+	recurrenceRepo domain.RecurrenceRepository
+	location       *time.Location
+	clock          domain.Clock
+	// revision は保持している番組表を更新するたびに増えます（フィラーの計画のキャッシュに使用します）
+	revision uint64
 }
 
-func NewScheduleService(repository domain.ScheduleRepository) *ScheduleService {
+func NewScheduleService(repository domain.ScheduleRepository, assetRepo domain.AssetRepository, recurrenceRepo domain.RecurrenceRepository, location *time.Location, clock domain.Clock) *ScheduleService {
 	return &ScheduleService{
-		schedule:   make([]domain.ProgramItem, 0),
-		repository: repository,
+		schedule:       make([]domain.ProgramItem, 0),
+		repository:     repository,
+		assetRepo:      assetRepo,
+		recurrenceRepo: recurrenceRepo,
+		location:       location,
+		clock:          clock,
 	}
 }
 
+// Location はチャンネルのタイムゾーンを返します
+func (s *ScheduleService) Location() *time.Location {
+	return s.location
+}
+
 func (s *ScheduleService) GetSchedule() []domain.ProgramItem {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
@@ -31,46 +52,395 @@ func (s *ScheduleService) GetSchedule() []domain.ProgramItem {
 	return result
 }
 
+// GetScheduleWithRevision は保持している番組表とそのリビジョンを返します
+func (s *ScheduleService) GetScheduleWithRevision() ([]domain.ProgramItem, uint64) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	result := make([]domain.ProgramItem, len(s.schedule))
+	copy(result, s.schedule)
+	return result, s.revision
+}
+
 func (s *ScheduleService) UpdateSchedule(newSchedule []domain.ProgramItem) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	s.schedule = newSchedule
+	s.revision++
 	log.Printf("番組表を更新しました。番組数: %d", len(newSchedule))
 }
 
+// RefreshFromRepository は前日・当日・翌日の番組表を読み込み、1つの番組リストにまとめて保持します。
+// 日付をまたぐ番組や翌日の最初の番組もこれにより参照できます
 func (s *ScheduleService) RefreshFromRepository(ctx context.Context) error {
-	jst := time.FixedZone("JST", 9*60*60)
-	todayString := time.Now().In(jst).Format("2006-01-02")
+	loc := s.location
 
-	schedule, err := s.repository.GetScheduleByDate(ctx, todayString)
+	programs, err := s.loadWindow(ctx, s.clock.Now(), loc)
 	if err != nil {
 		log.Printf("Repositoryからの取得に失敗: %v", err)
 		return err
 	}
 
-	s.UpdateSchedule(schedule.Programs)
+	report := domain.ValidateSchedule(programs, nil)
+	for _, violation := range report.Violations {
+		log.Printf("番組表に問題があります(%s): %s", violation.Severity, violation.Message)
+	}
+
+	s.UpdateSchedule(programs)
 	return nil
 }
 
-func (s *ScheduleService) AddProgramToSchedule(ctx context.Context, programItem domain.RequestProgramItem, date string) error {
-	if err := s.repository.PostSchedule(ctx, programItem, date); err != nil {
+// LoadWindowAt は指定した時刻を基準に前日・当日・翌日の番組表を読み込みます（保持している番組表は変更しません）
+func (s *ScheduleService) LoadWindowAt(ctx context.Context, at time.Time) ([]domain.ProgramItem, error) {
+	return s.loadWindow(ctx, at, s.location)
+}
+
+// loadWindow は基準時刻の前日・当日・翌日の番組表を読み込んでまとめます
+func (s *ScheduleService) loadWindow(ctx context.Context, currentTime time.Time, loc *time.Location) ([]domain.ProgramItem, error) {
+	dates := domain.WindowDates(currentTime, loc)
+
+	schedules := make(map[string]*domain.Schedule)
+	for _, date := range dates {
+		schedule, err := s.getSchedule(ctx, date)
+		if errors.Is(err, domain.ErrScheduleNotFound) {
+			schedule = &domain.Schedule{}
+		} else if err != nil {
+			return nil, fmt.Errorf("番組表(%s)の取得に失敗: %w", date, err)
+		}
+		schedules[date] = schedule
+	}
+
+	// 繰り返し番組を日付ごとの番組として展開して加える
+	occurrences, err := s.expandRecurrences(ctx, dates[0], dates[len(dates)-1])
+	if err != nil {
+		return nil, err
+	}
+	for _, occurrence := range occurrences {
+		schedules[occurrence.ScheduleDate].Programs = append(schedules[occurrence.ScheduleDate].Programs, occurrence)
+	}
+
+	return domain.MergeSchedules(schedules), nil
+}
+
+func (s *ScheduleService) AddProgramToSchedule(ctx context.Context, programItem domain.RequestProgramItem, date string, expectedVersion int64, record domain.ChangeRecorder[domain.ProgramItem]) (*domain.ProgramItem, int64, error) {
+	program := domain.NewProgramItem(programItem)
+	assets := s.newAssetLookup(ctx)
+	occurrences, err := s.adjacentOccurrences(ctx, date)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	schedule, err := s.mutateSchedule(ctx, date, expectedVersion, func(schedule *domain.Schedule) error {
+		schedule.Programs = append(schedule.Programs, program)
+		return validateProgramChange(schedule, occurrences, program.ID, assets)
+	}, func() error {
+		return record.Record(nil, &program)
+	})
+	if err != nil {
 		log.Printf("番組の追加に失敗: %v", err)
-		return err
+		return nil, 0, err
+	}
+
+	return &program, schedule.Version, nil
+}
+
+func (s *ScheduleService) GetScheduleByDate(ctx context.Context, date string) (*domain.Schedule, error) {
+	return s.getSchedule(ctx, date)
+}
+
+// getSchedule は番組表を読み込みます。IDを持たない番組（IDの導入前に登録された番組）がある場合は
+// IDを割り当てて保存し、すべての番組をIDで操作できるようにします
+func (s *ScheduleService) getSchedule(ctx context.Context, date string) (*domain.Schedule, error) {
+	schedule, err := s.repository.GetScheduleByDate(ctx, date)
+	if err != nil || !schedule.HasProgramsWithoutID() {
+		return schedule, err
+	}
+
+	// IDは保存時にリポジトリがトランザクション内で割り当てるため、番組の内容は変更しない
+	updated, err := s.repository.UpdateScheduleByDate(ctx, date, domain.AnyVersion, func(schedule *domain.Schedule) error {
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("番組表(%s)の番組IDの割り当てに失敗: %w", date, err)
+	}
+	log.Printf("IDを持たない番組にIDを割り当てました: 日付=%s", date)
+	return updated, nil
+}
+
+func (s *ScheduleService) GetProgram(ctx context.Context, date, id string) (*domain.ProgramItem, int64, error) {
+	if err := domain.CheckOccurrenceID(id); err != nil {
+		return nil, 0, err
+	}
+	schedule, err := s.getSchedule(ctx, date)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	program, _ := schedule.FindProgramByID(id)
+	if program == nil {
+		return nil, 0, domain.ErrProgramNotFound
+	}
+	return program, schedule.Version, nil
+}
+
+// ReplaceProgram は番組の内容をリクエストで置き換えます（IDは維持されます）。
+// 更新後の番組と更新前の番組を返します。recordには保存した直後に変更前後の番組を渡します
+func (s *ScheduleService) ReplaceProgram(ctx context.Context, date, id string, request domain.RequestProgramItem, expectedVersion int64, record domain.ChangeRecorder[domain.ProgramItem]) (*domain.ProgramItem, *domain.ProgramItem, int64, error) {
+	if err := domain.CheckOccurrenceID(id); err != nil {
+		return nil, nil, 0, err
+	}
+	program := request.ToProgramItem()
+	program.ID = id
+	var previous domain.ProgramItem
+	assets := s.newAssetLookup(ctx)
+	occurrences, err := s.adjacentOccurrences(ctx, date)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	schedule, err := s.mutateSchedule(ctx, date, expectedVersion, func(schedule *domain.Schedule) error {
+		if current, _ := schedule.FindProgramByID(id); current != nil {
+			previous = *current
+		}
+		if err := schedule.ReplaceProgram(program); err != nil {
+			return err
+		}
+		return validateProgramChange(schedule, occurrences, program.ID, assets)
+	}, func() error {
+		return record.Record(&previous, &program)
+	})
+	if err != nil {
+		log.Printf("番組の更新に失敗: %v", err)
+		return nil, nil, 0, err
+	}
+
+	return &program, &previous, schedule.Version, nil
+}
+
+// PatchProgram は指定されたフィールドのみ番組を更新します。
+// 更新後の番組と更新前の番組を返します。recordには保存した直後に変更前後の番組を渡します
+func (s *ScheduleService) PatchProgram(ctx context.Context, date, id string, patch domain.ProgramPatch, expectedVersion int64, record domain.ChangeRecorder[domain.ProgramItem]) (*domain.ProgramItem, *domain.ProgramItem, int64, error) {
+	if err := domain.CheckOccurrenceID(id); err != nil {
+		return nil, nil, 0, err
+	}
+	var program, previous domain.ProgramItem
+	assets := s.newAssetLookup(ctx)
+	occurrences, err := s.adjacentOccurrences(ctx, date)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	schedule, err := s.mutateSchedule(ctx, date, expectedVersion, func(schedule *domain.Schedule) error {
+		current, _ := schedule.FindProgramByID(id)
+		if current == nil {
+			return domain.ErrProgramNotFound
+		}
+		previous = *current
+		current.ApplyPatch(patch)
+		program = *current
+		return validateProgramChange(schedule, occurrences, id, assets)
+	}, func() error {
+		return record.Record(&previous, &program)
+	})
+	if err != nil {
+		log.Printf("番組の更新に失敗: %v", err)
+		return nil, nil, 0, err
 	}
 
-	// 追加後にスケジュールをリフレッシュして最新状態を取得
+	return &program, &previous, schedule.Version, nil
+}
+
+// DeleteProgram は番組を削除し、削除した番組を返します。recordには保存した直後に削除した番組を渡します
+func (s *ScheduleService) DeleteProgram(ctx context.Context, date, id string, expectedVersion int64, record domain.ChangeRecorder[domain.ProgramItem]) (*domain.ProgramItem, int64, error) {
+	if err := domain.CheckOccurrenceID(id); err != nil {
+		return nil, 0, err
+	}
+	var deleted domain.ProgramItem
+	schedule, err := s.mutateSchedule(ctx, date, expectedVersion, func(schedule *domain.Schedule) error {
+		if current, _ := schedule.FindProgramByID(id); current != nil {
+			deleted = *current
+		}
+		return schedule.RemoveProgram(id)
+	}, func() error {
+		return record.Record(&deleted, nil)
+	})
+	if err != nil {
+		log.Printf("番組の削除に失敗: %v", err)
+		return nil, 0, err
+	}
+
+	return &deleted, schedule.Version, nil
+}
+
+// ValidatePrograms は番組表を保存せずに検証します。
+// dateが指定された場合は、その日の既存の番組と合わせて検証します
+func (s *ScheduleService) ValidatePrograms(ctx context.Context, date string, requests []domain.RequestProgramItem) (domain.ValidationReport, error) {
+	programs := make([]domain.ProgramItem, 0, len(requests))
+
+	if date != "" {
+		schedule, err := s.getSchedule(ctx, date)
+		if err != nil && !errors.Is(err, domain.ErrScheduleNotFound) {
+			return domain.ValidationReport{}, err
+		}
+		if err == nil {
+			programs = append(programs, schedule.Programs...)
+		}
+
+		occurrences, err := s.adjacentOccurrences(ctx, date)
+		if err != nil {
+			return domain.ValidationReport{}, err
+		}
+		programs = append(programs, occurrences...)
+	}
+
+	for _, request := range requests {
+		programs = append(programs, request.ToProgramItem())
+	}
+
+	assets := s.newAssetLookup(ctx)
+	report := domain.ValidateSchedule(programs, assets.existsFunc())
+	if assets.err != nil {
+		return domain.ValidationReport{}, assets.err
+	}
+	return report, nil
+}
+
+// generatedDayChange はテンプレートや自動編成で番組表に反映した1日分の変更前後の番組です
+type generatedDayChange struct {
+	before []domain.ProgramItem
+	after  []domain.ProgramItem
+}
+
+// applyGeneratedDay はテンプレートや自動編成で生成した1日分の番組を番組表に反映し、反映した場合は変更前後の番組を返します。
+// dryRunの場合は番組表を変更せずに結果のみを返します
+func (s *ScheduleService) applyGeneratedDay(ctx context.Context, date string, programs, occurrences []domain.ProgramItem, mode domain.TemplateApplyMode, assets *assetLookup, dryRun bool) (domain.TemplateDayResult, *generatedDayChange, error) {
+	if dryRun || len(programs) == 0 {
+		schedule, err := s.GetScheduleByDate(ctx, date)
+		if errors.Is(err, domain.ErrScheduleNotFound) {
+			schedule = &domain.Schedule{}
+		} else if err != nil {
+			return domain.TemplateDayResult{}, nil, err
+		}
+		day := domain.ApplyTemplateDay(schedule, programs, occurrences, mode, assets.existsFunc())
+		if assets.err != nil {
+			return domain.TemplateDayResult{}, nil, assets.err
+		}
+		return day, nil, nil
+	}
+
+	var day domain.TemplateDayResult
+	var before []domain.ProgramItem
+	updated, err := s.repository.UpdateScheduleByDate(ctx, date, domain.AnyVersion, func(schedule *domain.Schedule) error {
+		before = slices.Clone(schedule.Programs)
+		day = domain.ApplyTemplateDay(schedule, programs, occurrences, mode, assets.existsFunc())
+		if assets.err != nil {
+			return assets.err
+		}
+		if day.Status == domain.TemplateDayConflict {
+			return errGeneratedDayConflict
+		}
+		return nil
+	})
+	if errors.Is(err, errGeneratedDayConflict) {
+		return day, nil, nil
+	}
+	if err != nil {
+		return day, nil, err
+	}
+	return day, &generatedDayChange{before: before, after: updated.Programs}, nil
+}
+
+// refreshGeneratedDays はテンプレートや自動編成で反映した日がある場合に、保持している番組表をリフレッシュします。
+// 反映済みの日は保存されているため、リフレッシュの失敗はログに記録するだけにします
+func (s *ScheduleService) refreshGeneratedDays(ctx context.Context, appliedCount int, dryRun bool) {
+	if dryRun || appliedCount == 0 {
+		return
+	}
 	if err := s.RefreshFromRepository(ctx); err != nil {
-		log.Printf("番組追加後のリフレッシュに失敗: %v", err)
-		return err
+		log.Printf("番組表の生成後のリフレッシュに失敗: %v", err)
 	}
+}
 
+// validateProgramChange は変更した番組に関係する検証エラーがあれば保存を中止します。
+// occurrencesには同じ日と前後の日に展開される繰り返し番組を渡し、重複の検出に使用します
+func validateProgramChange(schedule *domain.Schedule, occurrences []domain.ProgramItem, programID string, assets *assetLookup) error {
+	programs := make([]domain.ProgramItem, 0, len(schedule.Programs)+len(occurrences))
+	programs = append(programs, schedule.Programs...)
+	programs = append(programs, occurrences...)
+
+	report := domain.ValidateSchedule(programs, assets.existsFunc())
+	if assets.err != nil {
+		return assets.err
+	}
+	if violations := report.ErrorsFor(programID); len(violations) > 0 {
+		return &domain.ValidationError{Violations: violations}
+	}
 	return nil
 }
 
+// assetLookup はアセットの存在確認を行います（同じIDの問い合わせはキャッシュされます）。
+// リポジトリのエラーは存在扱いにせず保持し、呼び出し側がerrで確認して処理を中止します
+type assetLookup struct {
+	ctx   context.Context
+	repo  domain.AssetRepository
+	cache map[string]bool
+	err   error
+}
+
+func (s *ScheduleService) newAssetLookup(ctx context.Context) *assetLookup {
+	return &assetLookup{ctx: ctx, repo: s.assetRepo, cache: make(map[string]bool)}
+}
+
+// existsFunc はValidateScheduleに渡す存在確認の関数を返します。リポジトリが未設定の場合はnilです
+func (l *assetLookup) existsFunc() func(id string) bool {
+	if l.repo == nil {
+		return nil
+	}
+	return l.exists
+}
+
+func (l *assetLookup) exists(id string) bool {
+	if exists, ok := l.cache[id]; ok {
+		return exists
+	}
+	_, err := l.repo.GetAsset(l.ctx, id)
+	if err != nil && !errors.Is(err, domain.ErrAssetNotFound) {
+		if l.err == nil {
+			l.err = fmt.Errorf("アセット(%s)の存在確認に失敗: %w", id, err)
+		}
+		return true
+	}
+	l.cache[id] = err == nil
+	return l.cache[id]
+}
+
+// mutateSchedule は番組表をトランザクションで更新し、保存した直後にrecordで変更を記録してからメモリ上の番組表をリフレッシュします
+func (s *ScheduleService) mutateSchedule(ctx context.Context, date string, expectedVersion int64, mutate func(schedule *domain.Schedule) error, record func() error) (*domain.Schedule, error) {
+	schedule, err := s.repository.UpdateScheduleByDate(ctx, date, expectedVersion, mutate)
+	if err != nil {
+		return nil, err
+	}
+	// 保存した変更はリフレッシュの結果にかかわらず記録する
+	recordErr := record()
+
+	// 更新後にスケジュールをリフレッシュして最新状態を取得する。
+	// 保存は完了しているため、失敗しても定期リフレッシュに任せて保存結果を返す
+	if err := s.RefreshFromRepository(ctx); err != nil {
+		log.Printf("番組表更新後のリフレッシュに失敗: %v", err)
+	}
+	return schedule, recordErr
+}
+
+// StartPeriodicRefresh は一定間隔で番組表を更新します。日付が変わった直後にも番組表を読み直します
 func (s *ScheduleService) StartPeriodicRefresh(ctx context.Context, interval time.Duration) {
+	loc := s.location
+
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
+	midnight := time.NewTimer(untilNextDay(s.clock.Now(), loc))
+	defer midnight.Stop()
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -81,6 +451,19 @@ func (s *ScheduleService) StartPeriodicRefresh(ctx context.Context, interval tim
 			if err := s.RefreshFromRepository(ctx); err != nil {
 				log.Printf("定期更新でエラーが発生: %v", err)
 			}
+		case <-midnight.C:
+			log.Println("日付が変わったため番組表を更新中...")
+			if err := s.RefreshFromRepository(ctx); err != nil {
+				log.Printf("日付変更時の更新でエラーが発生: %v", err)
+			}
+			midnight.Reset(untilNextDay(s.clock.Now(), loc))
 		}
 	}
 }
+
+// untilNextDay は次の日付に切り替わるまでの時間を返します
+func untilNextDay(currentTime time.Time, loc *time.Location) time.Duration {
+	local := currentTime.In(loc)
+	next := time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 1, 0, loc)
+	return next.Sub(local)
+}
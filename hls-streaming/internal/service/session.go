@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/genki0524/hls_striming_go/internal/domain"
+)
+
+// SessionService は視聴者ごとのライブプレイリストのセッションを管理します
+type SessionService struct {
+	store   domain.SessionStore
+	viewers domain.SessionViewerStore
+	ttl     time.Duration
+	clock   domain.Clock
+}
+
+func NewSessionService(store domain.SessionStore, viewers domain.SessionViewerStore, ttl time.Duration, clock domain.Clock) *SessionService {
+	return &SessionService{
+		store:   store,
+		viewers: viewers,
+		ttl:     ttl,
+		clock:   clock,
+	}
+}
+
+// Resume はidのセッションを返します。idが空、存在しない、または期限切れの場合は
+// 新しいセッションを作成し、createdにtrueを返します。
+// claimsを指定した場合は再生トークンの内容でセッションの再生を許可し、視聴者を記録します
+func (s *SessionService) Resume(ctx context.Context, id string, claims *domain.PlaybackClaims) (*domain.Session, bool, error) {
+	now := s.clock.Now()
+	if domain.IsSessionID(id) {
+		session, err := s.store.GetSession(ctx, id)
+		if err == nil && !session.Expired(now) {
+			if claims != nil && !session.AuthorizedBy(claims) {
+				if err := s.authorize(ctx, session, claims, now); err != nil {
+					return nil, false, err
+				}
+			}
+			return session, false, nil
+		}
+		if err != nil && !errors.Is(err, domain.ErrSessionNotFound) {
+			return nil, false, err
+		}
+	}
+
+	session := domain.NewSession(now, s.ttl)
+	if claims != nil {
+		if err := s.authorize(ctx, &session, claims, now); err != nil {
+			return nil, false, err
+		}
+		return &session, true, nil
+	}
+	if err := s.store.SaveSession(ctx, session); err != nil {
+		return nil, false, err
+	}
+	return &session, true, nil
+}
+
+// authorize は再生トークンの内容でセッションの再生を許可して保存します。
+// 視聴者の記録はセッションより先に保存し、記録できない場合は再生を許可しません
+func (s *SessionService) authorize(ctx context.Context, session *domain.Session, claims *domain.PlaybackClaims, now time.Time) error {
+	viewer := domain.SessionViewer{
+		SessionID:    session.ID,
+		Viewer:       claims.Subject,
+		Channel:      claims.Channel,
+		IP:           claims.IP,
+		AuthorizedAt: now,
+	}
+	if err := s.viewers.RecordSessionViewer(ctx, viewer); err != nil {
+		return fmt.Errorf("セッションの視聴者の記録に失敗: %w", err)
+	}
+	session.AuthorizePlayback(claims)
+	session.Extend(now, s.ttl)
+	return s.store.SaveSession(ctx, *session)
+}
+
+// SessionViewers はセッションで再生を許可した視聴者の記録を返します（セッションの削除後も取得できます）
+func (s *SessionService) SessionViewers(ctx context.Context, id string) ([]domain.SessionViewer, error) {
+	return s.viewers.ListSessionViewers(ctx, id)
+}
+
+// VerifyPlayback はidのセッションでchannelのipからの再生を許可するかどうかを検証します
+func (s *SessionService) VerifyPlayback(ctx context.Context, id, channel, ip string) error {
+	if !domain.IsSessionID(id) {
+		return domain.ErrSessionNotFound
+	}
+	session, err := s.store.GetSession(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !session.PlaybackAllowed(channel, ip, s.clock.Now()) {
+		return domain.ErrInvalidPlaybackToken
+	}
+	return nil
+}
+
+// Commit はプレイリストの生成で更新したセッションを保存し、有効期限を延長します（再生トークンの有効期限まで）。
+// ストアへの書き込みを減らすため、内容が変わらず延長してもあまり変わらない場合は保存しません
+func (s *SessionService) Commit(ctx context.Context, original domain.Session, session *domain.Session) error {
+	extended := *session
+	extended.Extend(s.clock.Now(), s.ttl)
+	if *session == original && extended.ExpiresAt.Sub(session.ExpiresAt) < s.ttl/2 {
+		return nil
+	}
+	*session = extended
+	return s.store.SaveSession(ctx, *session)
+}
+
+// StartPeriodicCleanup は期限切れのセッションを定期的に削除します
+func (s *SessionService) StartPeriodicCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("セッションの削除を停止します")
+			return
+		case <-ticker.C:
+		}
+
+		count, err := s.store.DeleteExpiredSessions(ctx, s.clock.Now())
+		if err != nil {
+			log.Printf("期限切れのセッションの削除に失敗: %v", err)
+			continue
+		}
+		if count > 0 {
+			log.Printf("期限切れのセッションを削除しました: %d件", count)
+		}
+	}
+}
@@ -0,0 +1,387 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/genki0524/hls_striming_go/internal/domain"
+	"github.com/genki0524/hls_striming_go/internal/media"
+	"github.com/genki0524/hls_striming_go/internal/repository"
+)
+
+const (
+	// adPrepareTimeout は広告判定から素材の変換・保存までにかける最大の時間です
+	adPrepareTimeout = 5 * time.Minute
+	// maxCreativeSize はダウンロードする広告素材の最大のサイズです
+	maxCreativeSize = 200 << 20
+	// adBreakRetention は放送済みの広告枠の状態を保持する期間です
+	adBreakRetention = 24 * time.Hour
+	// adPrepareLookahead は広告の準備を始める、広告枠の開始前の時間です。
+	// 準備が開始までに終わるよう、adPrepareTimeoutと同じ長さにします
+	adPrepareLookahead = adPrepareTimeout
+)
+
+type adBreakStatus int
+
+const (
+	adBreakPreparing adBreakStatus = iota
+	adBreakReady
+	// adBreakSkipped は広告枠の開始までに準備が終わらず、広告を挿入しないことが確定した状態です
+	adBreakSkipped
+)
+
+type adBreakState struct {
+	status    adBreakStatus
+	startDate time.Time
+	creatives []domain.AdCreative
+	// playlists は変換済みの広告素材のm3u8のオブジェクトパスです（creativesと同じ順）
+	playlists       []string
+	impressionsSent bool
+}
+
+// AdInsertionService は広告枠に広告判定サーバーが返した広告を挿入します（サーバーサイド広告挿入）
+type AdInsertionService struct {
+	client        domain.AdDecisionClient
+	gcsRepo       *repository.GCSRepository
+	ffmpegService *media.FFmpegService
+	httpClient    *http.Client
+
+	mutex  sync.Mutex
+	breaks map[string]*adBreakState
+	// creativeMutexes は同じ広告素材の変換を同時に1つだけ行うための素材ごとのロックです
+	creativeMutexes map[string]*sync.Mutex
+}
+
+func NewAdInsertionService(client domain.AdDecisionClient, gcsRepo *repository.GCSRepository, ffmpegService *media.FFmpegService) *AdInsertionService {
+	return &AdInsertionService{
+		client:        client,
+		gcsRepo:       gcsRepo,
+		ffmpegService: ffmpegService,
+		httpClient:    &http.Client{Timeout: 10 * time.Second},
+		breaks:        make(map[string]*adBreakState),
+
+		creativeMutexes: make(map[string]*sync.Mutex),
+	}
+}
+
+// InsertAds は番組の放送内容の広告枠に、準備が終わった広告を挿入します。
+// 広告枠の開始前に広告判定と素材の変換を始め、開始までに終わらなかった広告枠には広告を挿入しません
+// （広告枠の途中で内容が変わらないようにするため）。sessionIDを指定した場合は視聴者ごとに広告を判定します
+// （インタースティシャルの広告枠はtimelineのアセットリストのセッションIDで判定します）。
+// nowはプレイリストの時刻で、開始までadPrepareLookahead以内の広告枠だけを準備します
+func (s *AdInsertionService) InsertAds(ctx context.Context, bucket, sessionID string, program *domain.ProgramItem, timeline *domain.M3U8Playlist, now time.Time) *domain.M3U8Playlist {
+	s.prefetchInterstitials(timeline, now)
+	ads := make(map[string]*domain.M3U8Playlist)
+
+	for _, cue := range timeline.CueOuts() {
+		request := domain.AdRequest{
+			BreakID:     cue.ID,
+			ProgramID:   program.ID,
+			DurationSec: cue.Duration,
+			StartDate:   cue.StartDate,
+			SessionID:   sessionID,
+		}
+		if beforeLookahead(request, now) {
+			continue
+		}
+		key := adBreakKey(request)
+		state := s.breakState(key, request, now)
+		if state == nil {
+			continue
+		}
+
+		parts := make([]*domain.M3U8Playlist, 0, len(state.playlists))
+		for _, playlistObject := range state.playlists {
+			part, err := s.gcsRepo.GetPlaylistWithSessionURLs(ctx, bucket, playlistObject, sessionID, domain.Watermark{})
+			if err != nil {
+				log.Printf("広告素材(%s)の読み込みに失敗: %v", playlistObject, err)
+				parts = nil
+				break
+			}
+			parts = append(parts, part)
+		}
+		if len(parts) == 0 {
+			continue
+		}
+		ads[cue.ID] = domain.StitchPlaylists(parts)
+
+		if !now.Before(request.StartDate) {
+			s.sendImpressions(key)
+		}
+	}
+
+	if len(ads) == 0 {
+		return timeline
+	}
+	return timeline.InsertAds(ads)
+}
+
+// breakState は広告枠の状態を更新し、挿入できる広告がある場合はその状態を返します
+func (s *AdInsertionService) breakState(key string, request domain.AdRequest, now time.Time) *adBreakState {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	state := s.lookupBreak(key, request, now, now.Before(request.StartDate))
+
+	if state.status == adBreakPreparing && !now.Before(request.StartDate) {
+		log.Printf("広告枠(%s)の開始までに広告の準備が終わらなかったため挿入しません", key)
+		state.status = adBreakSkipped
+	}
+	if state.status != adBreakReady {
+		return nil
+	}
+	return state
+}
+
+// lookupBreak は広告枠の状態を返します。状態がない場合は作成し、prepareがtrueなら広告の準備を始めます
+// （s.mutexを保持して呼び出します）
+func (s *AdInsertionService) lookupBreak(key string, request domain.AdRequest, now time.Time, prepare bool) *adBreakState {
+	s.pruneBreaks(now)
+
+	state := s.breaks[key]
+	if state == nil {
+		state = &adBreakState{status: adBreakSkipped, startDate: request.StartDate}
+		s.breaks[key] = state
+		if prepare {
+			state.status = adBreakPreparing
+			go s.prepareBreak(key, request)
+		}
+	}
+	return state
+}
+
+// prefetchInterstitials はインタースティシャルとして再生する広告枠の広告を、枠が終わる前に準備します
+func (s *AdInsertionService) prefetchInterstitials(timeline *domain.M3U8Playlist, now time.Time) {
+	requests := timeline.InterstitialAdRequests()
+	if len(requests) == 0 {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, request := range requests {
+		if beforeLookahead(request, now) {
+			continue
+		}
+		end := request.StartDate.Add(time.Duration(request.DurationSec * float64(time.Second)))
+		s.lookupBreak(adBreakKey(request), request, now, now.Before(end))
+	}
+}
+
+// InterstitialAds はインタースティシャルとして再生する広告枠の準備済みの広告をアセットリストの形式で返します。
+// 放送内容にない広告枠や、準備が終わっていない広告枠の場合は空のリストを返します
+func (s *AdInsertionService) InterstitialAds(request domain.AdRequest) []domain.InterstitialAsset {
+	key := adBreakKey(request)
+	s.mutex.Lock()
+	state := s.breaks[key]
+	if state == nil || state.status != adBreakReady {
+		s.mutex.Unlock()
+		return []domain.InterstitialAsset{}
+	}
+	creatives := state.creatives
+	s.mutex.Unlock()
+
+	assets := make([]domain.InterstitialAsset, 0, len(creatives))
+	for _, creative := range creatives {
+		assets = append(assets, domain.InterstitialAsset{
+			URI:      domain.AdCreativePlaylistURI(domain.CreativeKey(creative.MediaURL)),
+			Duration: creative.DurationSec,
+		})
+	}
+
+	// アセットリストはプレイヤーが再生の直前に取得するため、最初の取得時にインプレッションを送信する
+	// （再取得やリトライで重複しないよう、広告枠ごと・セッションごとに一度だけ）
+	s.sendImpressions(key)
+	return assets
+}
+
+// beforeLookahead は広告枠の開始までadPrepareLookaheadより長く、まだ準備を始めない場合にtrueを返します
+func beforeLookahead(request domain.AdRequest, now time.Time) bool {
+	return now.Before(request.StartDate.Add(-adPrepareLookahead))
+}
+
+// adBreakKey は放送ごとに広告枠を識別するキーを返します（視聴者ごとに判定する場合はセッションごと）
+func adBreakKey(request domain.AdRequest) string {
+	key := request.BreakID + "@" + request.StartDate.UTC().Format(time.RFC3339)
+	if request.SessionID != "" {
+		key += "/" + request.SessionID
+	}
+	return key
+}
+
+// pruneBreaks は放送済みの古い広告枠の状態を削除します
+func (s *AdInsertionService) pruneBreaks(now time.Time) {
+	for key, state := range s.breaks {
+		if now.Sub(state.startDate) > adBreakRetention {
+			delete(s.breaks, key)
+		}
+	}
+}
+
+// prepareBreak は広告判定サーバーに広告をリクエストし、素材をHLSに変換してストレージに保存します
+func (s *AdInsertionService) prepareBreak(key string, request domain.AdRequest) {
+	ctx, cancel := context.WithTimeout(context.Background(), adPrepareTimeout)
+	defer cancel()
+
+	creatives, err := s.client.RequestAds(ctx, request)
+	if err != nil {
+		log.Printf("広告枠(%s)の広告判定に失敗: %v", key, err)
+		s.finishBreak(key, nil, nil)
+		return
+	}
+	creatives = domain.SelectAds(creatives, request.DurationSec)
+
+	bucket := os.Getenv("BUCKET")
+	selected := make([]domain.AdCreative, 0, len(creatives))
+	playlists := make([]string, 0, len(creatives))
+	for _, creative := range creatives {
+		playlistObject, err := s.cacheCreative(ctx, bucket, creative)
+		if err != nil {
+			log.Printf("広告素材(%s)の変換に失敗: %v", creative.MediaURL, err)
+			continue
+		}
+		selected = append(selected, creative)
+		playlists = append(playlists, playlistObject)
+	}
+
+	log.Printf("広告枠(%s)の広告を準備しました: %d本", key, len(selected))
+	s.finishBreak(key, selected, playlists)
+}
+
+func (s *AdInsertionService) finishBreak(key string, creatives []domain.AdCreative, playlists []string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	state := s.breaks[key]
+	if state == nil || state.status != adBreakPreparing {
+		return
+	}
+	if len(playlists) == 0 {
+		state.status = adBreakSkipped
+		return
+	}
+	state.status = adBreakReady
+	state.creatives = creatives
+	state.playlists = playlists
+}
+
+// cacheCreative は広告素材をHLSに変換してads/配下に保存し、m3u8のオブジェクトパスを返します。
+// 同じURLの素材が変換済みの場合はそれを使用します
+func (s *AdInsertionService) cacheCreative(ctx context.Context, bucket string, creative domain.AdCreative) (string, error) {
+	creativeKey := domain.CreativeKey(creative.MediaURL)
+	basePath := creativeBasePath(creativeKey)
+	playlistObject := basePath + "/video.m3u8"
+
+	// セッションごとに広告を判定すると同じ素材が同時に選ばれるため、変換は1つずつ行う
+	creativeMutex := s.creativeMutex(creativeKey)
+	creativeMutex.Lock()
+	defer creativeMutex.Unlock()
+
+	exists, err := s.gcsRepo.ObjectExists(ctx, bucket, playlistObject)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return playlistObject, nil
+	}
+
+	data, err := s.downloadCreative(ctx, creative.MediaURL)
+	if err != nil {
+		return "", err
+	}
+
+	tempDir, err := os.MkdirTemp("", "ad_conversion_")
+	if err != nil {
+		return "", fmt.Errorf("一時ディレクトリ作成エラー: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	if err := s.ffmpegService.ConvertByteDataToHLS(data, tempDir); err != nil {
+		return "", fmt.Errorf("HLS変換エラー: %w", err)
+	}
+	if _, err := uploadHLSDirectory(ctx, s.gcsRepo, bucket, tempDir, basePath); err != nil {
+		return "", err
+	}
+	return playlistObject, nil
+}
+
+func (s *AdInsertionService) creativeMutex(creativeKey string) *sync.Mutex {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	creativeMutex, ok := s.creativeMutexes[creativeKey]
+	if !ok {
+		creativeMutex = &sync.Mutex{}
+		s.creativeMutexes[creativeKey] = creativeMutex
+	}
+	return creativeMutex
+}
+
+// creativeBasePath は変換済みの広告素材を保存するパスです
+func creativeBasePath(creativeKey string) string {
+	return "ads/" + creativeKey
+}
+
+func (s *AdInsertionService) downloadCreative(ctx context.Context, mediaURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, mediaURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("広告素材のダウンロードに失敗: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxCreativeSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxCreativeSize {
+		return nil, fmt.Errorf("広告素材が大きすぎます")
+	}
+	return data, nil
+}
+
+// sendImpressions は広告枠の放送開始時（インタースティシャルの場合はアセットリストの取得時）に広告のインプレッションビーコンを一度だけ送信します
+func (s *AdInsertionService) sendImpressions(key string) {
+	s.mutex.Lock()
+	state := s.breaks[key]
+	if state == nil || state.impressionsSent {
+		s.mutex.Unlock()
+		return
+	}
+	state.impressionsSent = true
+	creatives := state.creatives
+	s.mutex.Unlock()
+
+	go func() {
+		for _, creative := range creatives {
+			for _, impression := range creative.Impressions {
+				s.sendBeacon(impression)
+			}
+		}
+	}()
+}
+
+func (s *AdInsertionService) sendBeacon(beaconURL string) {
+	resp, err := s.httpClient.Get(beaconURL)
+	if err != nil {
+		log.Printf("インプレッションの送信に失敗(%s): %v", beaconURL, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		log.Printf("インプレッションの送信に失敗(%s): %s", beaconURL, resp.Status)
+	}
+}
@@ -17,20 +17,40 @@ import (
 )
 
 type StreamingService struct {
-	gcsRepo *repository.GCSRepository
+	gcsRepo       *repository.GCSRepository
+	assetRepo     domain.AssetRepository
+	fillerService *FillerService
+	shortfall     domain.ShortfallPolicy
+	bumper        domain.BumperConfig
+	adService     *AdInsertionService
+	watermark     domain.Watermark
+	location      *time.Location
+	clock         domain.Clock
 }
 
-func NewStreamingService(gcsRepo *repository.GCSRepository) *StreamingService {
+func NewStreamingService(gcsRepo *repository.GCSRepository, assetRepo domain.AssetRepository, fillerService *FillerService, shortfall domain.ShortfallPolicy, bumper domain.BumperConfig, adService *AdInsertionService, watermark domain.Watermark, location *time.Location, clock domain.Clock) *StreamingService {
 	return &StreamingService{
-		gcsRepo: gcsRepo,
+		gcsRepo:       gcsRepo,
+		assetRepo:     assetRepo,
+		fillerService: fillerService,
+		shortfall:     shortfall,
+		bumper:        bumper,
+		adService:     adService,
+		watermark:     watermark,
+		location:      location,
+		clock:         clock,
 	}
 }
 
 func (s *StreamingService) GenerateStaticImagePlaylist(schedule []domain.ProgramItem) string {
-	jst := time.FixedZone("JST", 9*60*60)
-	now := time.Now().In(jst)
+	return s.generateStaticImagePlaylistAt(schedule, s.clock.Now())
+}
+
+func (s *StreamingService) generateStaticImagePlaylistAt(schedule []domain.ProgramItem, at time.Time) string {
+	loc := s.location
+	now := at.In(loc)
 
-	nextProgram := domain.FindNextProgram(schedule, now, jst)
+	nextProgram := domain.FindNextProgram(schedule, now, loc)
 
 	var segmentDuration float64
 	var segmentCount int
@@ -38,8 +58,8 @@ func (s *StreamingService) GenerateStaticImagePlaylist(schedule []domain.Program
 	if nextProgram != nil {
 		nextStartTime, err := nextProgram.GetStartTime()
 		if err == nil {
-			nextStartTimeJST := nextStartTime.In(jst)
-			timeUntilNext := nextStartTimeJST.Sub(now).Seconds()
+			nextStartTimeLocal := nextStartTime.In(loc)
+			timeUntilNext := nextStartTimeLocal.Sub(now).Seconds()
 			segmentDuration = math.Min(math.Max(5, timeUntilNext), 30)
 			segmentCount = int(math.Max(1, timeUntilNext/segmentDuration))
 		} else {
@@ -68,12 +88,28 @@ func (s *StreamingService) GenerateStaticImagePlaylist(schedule []domain.Program
 }
 
 func (s *StreamingService) GenerateVODPlaylist(ctx context.Context, schedule []domain.ProgramItem) (string, error) {
-	jst := time.FixedZone("JST", 9*60*60)
-	now := time.Now().In(jst)
+	return s.GeneratePlaylistAt(ctx, schedule, s.clock.Now())
+}
+
+// GenerateSessionPlaylist は視聴者のセッションごとのライブプレイリストを生成します。
+// 広告と署名付きURLをセッションごとに生成し、メディアシーケンス番号を番組の切り替えをまたいで連続させます
+// （sessionの番号の基準を更新します）
+func (s *StreamingService) GenerateSessionPlaylist(ctx context.Context, schedule []domain.ProgramItem, session *domain.Session) (string, error) {
+	return s.generatePlaylist(ctx, schedule, s.clock.Now(), session)
+}
+
+// GeneratePlaylistAt は指定した時刻に配信されるライブプレイリストを生成します
+func (s *StreamingService) GeneratePlaylistAt(ctx context.Context, schedule []domain.ProgramItem, at time.Time) (string, error) {
+	return s.generatePlaylist(ctx, schedule, at, nil)
+}
+
+func (s *StreamingService) generatePlaylist(ctx context.Context, schedule []domain.ProgramItem, at time.Time, session *domain.Session) (string, error) {
+	loc := s.location
+	now := at.In(loc)
 
-	currentProgram, currentProgramIndex := domain.FindCurrentProgram(schedule, now, jst)
+	currentProgram, currentProgramIndex := domain.FindCurrentProgram(schedule, now, loc)
 	if currentProgram == nil {
-		return s.GenerateStaticImagePlaylist(schedule), nil
+		return s.generateStaticImagePlaylistAt(schedule, now), nil
 	}
 
 	if err := godotenv.Load(".env"); err != nil {
@@ -81,73 +117,187 @@ func (s *StreamingService) GenerateVODPlaylist(ctx context.Context, schedule []d
 	}
 
 	bucket := os.Getenv("BUCKET")
-	todayString := now.Format("2006-01-02")
-	programName := currentProgram.Title
+	sessionID := ""
+	if session != nil {
+		sessionID = session.ID
+	}
 
-	playlist, err := s.gcsRepo.GetM3U8WithSignedURLs(ctx, bucket, todayString, programName)
+	// プレビュー（セッションなし）では広告の判定・準備を行わない
+	ads := session != nil
+	playlist, err := s.loadProgramPlaylist(ctx, currentProgram, bucket, programDate(currentProgram, now), sessionID, now, ads)
 	if err != nil {
 		log.Printf("m3u8ファイルの読み込みに失敗: %v", err)
-		return s.GenerateStaticImagePlaylist(schedule), nil
+		return s.generateStaticImagePlaylistAt(schedule, now), nil
 	}
 
-	log.Printf("読み込んだセグメント数: %d", len(playlist.Segments))
+	log.Printf("読み込んだセグメント数: %d", playlist.Len())
 
 	programStartTime, err := currentProgram.GetStartTime()
 	if err != nil {
 		return "", err
 	}
-	programStartTimeJST := programStartTime.In(jst)
-	timeIntoProgram := now.Sub(programStartTimeJST).Seconds()
+	programStartTimeLocal := programStartTime.In(loc)
+	timeIntoProgram := now.Sub(programStartTimeLocal).Seconds()
 
 	currentSegmentIndex := playlist.GetCurrentSegmentIndex(timeIntoProgram)
 	startIndex, endIndex := playlist.GetSegmentRange(currentSegmentIndex)
 
+	var nextProgram *domain.ProgramItem
+	if currentProgramIndex >= 0 && currentProgramIndex+1 < len(schedule) {
+		nextProgram = &schedule[currentProgramIndex+1]
+	}
+	mediaSequence, discontinuitySequence := startIndex, playlist.DiscontinuitySequence(startIndex)
+	if session != nil {
+		mediaSequence, discontinuitySequence = session.Advance(currentProgram, nextProgram, playlist, startIndex)
+	}
+
 	var m3u8Content []string
 	m3u8Content = append(m3u8Content, "#EXTM3U")
 	m3u8Content = append(m3u8Content, "#EXT-X-VERSION:3")
 	m3u8Content = append(m3u8Content, "#EXT-X-TARGETDURATION:"+strconv.Itoa(playlist.TargetDuration))
-	m3u8Content = append(m3u8Content, "#EXT-X-MEDIA-SEQUENCE:"+strconv.Itoa(startIndex))
+	m3u8Content = append(m3u8Content, "#EXT-X-MEDIA-SEQUENCE:"+strconv.Itoa(mediaSequence))
+	if discontinuitySequence > 0 {
+		m3u8Content = append(m3u8Content, "#EXT-X-DISCONTINUITY-SEQUENCE:"+strconv.Itoa(discontinuitySequence))
+	}
 	m3u8Content = append(m3u8Content, "#EXT-X-ALLOW-CACHE:YES")
+	// トリミングで番組の開始位置がセグメントの途中にある場合は再生開始位置を指定する
+	if startTag := playlist.StartTag(); startIndex == 0 && startTag != "" {
+		m3u8Content = append(m3u8Content, startTag)
+	}
+
+	// EXT-X-DATERANGEの時刻の基準としてウィンドウ先頭のセグメントの放送時刻を示す
+	segmentStart := time.Duration(playlist.SegmentStart(startIndex) * float64(time.Second))
+	m3u8Content = append(m3u8Content, domain.ProgramDateTimeTag(programStartTimeLocal.Add(segmentStart)))
 
-	for i := startIndex; i <= endIndex && i < len(playlist.Segments); i++ {
-		segment := playlist.Segments[i]
-		m3u8Content = append(m3u8Content, fmt.Sprintf("#EXTINF:%.1f,", segment.Duration))
-		m3u8Content = append(m3u8Content, segment.Filename)
+	for i := startIndex; i <= endIndex && i < playlist.Len(); i++ {
+		segment := playlist.SegmentAt(i)
+		if i == startIndex {
+			// ウィンドウ外の不連続はEXT-X-DISCONTINUITY-SEQUENCEで数えているため先頭には付けない
+			segment.Discontinuity = false
+		}
+		m3u8Content = append(m3u8Content, segment.Lines()...)
 	}
 
-	if endIndex == len(playlist.Segments)-1 && (endIndex+1)-startIndex != domain.PlaylistLength {
-		if currentProgramIndex >= 0 && currentProgramIndex+1 < len(schedule) {
-			nextProgram := &schedule[currentProgramIndex+1]
-			s.appendNextProgramSegments(ctx, &m3u8Content, nextProgram, bucket, todayString, startIndex, endIndex)
+	if endIndex == playlist.Len()-1 && (endIndex+1)-startIndex != domain.PlaylistLength {
+		if nextProgram != nil {
+			s.appendNextProgramSegments(ctx, &m3u8Content, nextProgram, bucket, programDate(nextProgram, now), sessionID, now, ads, startIndex, endIndex)
 		}
 	}
 
 	return strings.Join(m3u8Content, "\n") + "\n", nil
 }
 
-func (s *StreamingService) appendNextProgramSegments(ctx context.Context, m3u8Content *[]string, nextProgram *domain.ProgramItem, bucket, todayString string, startIndex, endIndex int) {
+func (s *StreamingService) appendNextProgramSegments(ctx context.Context, m3u8Content *[]string, nextProgram *domain.ProgramItem, bucket, date, sessionID string, at time.Time, ads bool, startIndex, endIndex int) {
 	*m3u8Content = append(*m3u8Content, "#EXT-X-DISCONTINUITY")
 
 	neededSegments := domain.PlaylistLength - ((endIndex + 1) - startIndex)
 
-	nextPlaylist, err := s.gcsRepo.GetM3U8WithSignedURLs(ctx, bucket, todayString, nextProgram.Title)
+	nextPlaylist, err := s.loadProgramPlaylist(ctx, nextProgram, bucket, date, sessionID, at, ads)
 	if err != nil {
 		log.Printf("次の番組のm3u8ファイルの読み込みに失敗: %v", err)
 		return
 	}
+	if nextStartTime, err := nextProgram.GetStartTime(); err == nil {
+		segmentStart := time.Duration(nextPlaylist.SegmentStart(0) * float64(time.Second))
+		*m3u8Content = append(*m3u8Content, domain.ProgramDateTimeTag(nextStartTime.In(s.location).Add(segmentStart)))
+	}
+
+	for i := 0; i < neededSegments && i < nextPlaylist.Len(); i++ {
+		nextSegment := nextPlaylist.SegmentAt(i)
+		if i == 0 {
+			nextSegment.Discontinuity = false
+		}
+		*m3u8Content = append(*m3u8Content, nextSegment.Lines()...)
+	}
+}
+
+// loadProgramPlaylist は番組が参照するアセット、または日付/番組名のパスからプレイリストを読み込み、
+// 放送時間に合わせた番組の放送内容を返します。ブロック番組は各アセットのプレイリストをつなげます。
+// sessionIDを指定した場合は署名付きURLと広告をセッションごとに生成します。
+// adsがtrueの場合はプレイリストの時刻atを基準に広告を準備・挿入します
+func (s *StreamingService) loadProgramPlaylist(ctx context.Context, program *domain.ProgramItem, bucket, date, sessionID string, at time.Time, ads bool) (*domain.M3U8Playlist, error) {
+	playlistObjects, err := resolvePlaylistObjects(ctx, s.assetRepo, program, date)
+	if err != nil {
+		return nil, err
+	}
+	parts := make([]*domain.M3U8Playlist, 0, len(playlistObjects))
+	for _, playlistObject := range playlistObjects {
+		part, err := s.gcsRepo.GetPlaylistWithSessionURLs(ctx, bucket, playlistObject, sessionID, s.watermark)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+	}
+	playlist := domain.StitchPlaylists(parts)
+
+	options := domain.TimelineOptions{Shortfall: s.shortfall}
+	if options.ShortfallPolicyFor(program) == domain.ShortfallFiller {
+		options.Fillers = s.loadShortfallFillers(ctx, bucket, sessionID, program, playlist)
+	}
+	if s.bumper.AppliesTo(program) {
+		options.Bumper = s.loadBumper(ctx, bucket, sessionID)
+	}
+	timeline := domain.BuildProgramTimeline(program, playlist, options).ForSession(sessionID)
+	if s.adService != nil && ads {
+		timeline = s.adService.InsertAds(ctx, bucket, sessionID, program, timeline, at)
+	}
+	return timeline, nil
+}
+
+// loadShortfallFillers は素材が放送時間に足りない分を埋めるフィラーのプレイリストを読み込みます
+func (s *StreamingService) loadShortfallFillers(ctx context.Context, bucket, sessionID string, program *domain.ProgramItem, playlist *domain.M3U8Playlist) []*domain.M3U8Playlist {
+	if s.fillerService == nil {
+		return nil
+	}
+
+	shortfall := float64(program.DurationSec) - domain.ProgramContent(program, playlist).PlayableDuration()
+	pool := s.fillerService.Pool()
+	assets := make(map[string]domain.Asset, len(pool))
+	for _, asset := range pool {
+		assets[asset.ID] = asset
+	}
+
+	fillers := make([]*domain.M3U8Playlist, 0)
+	for _, id := range domain.PlanShortfallFillers(shortfall, pool) {
+		asset := assets[id]
+		filler, err := s.gcsRepo.GetPlaylistWithSessionURLs(ctx, bucket, asset.MainPlaylistPath(), sessionID, s.watermark)
+		if err != nil {
+			log.Printf("フィラー(%s)の読み込みに失敗: %v", id, err)
+			continue
+		}
+		fillers = append(fillers, filler)
+	}
+	return fillers
+}
+
+// loadBumper はチャンネルのバンパーのプレイリストを読み込みます（読み込めない場合はnil）
+func (s *StreamingService) loadBumper(ctx context.Context, bucket, sessionID string) *domain.M3U8Playlist {
+	asset, err := s.assetRepo.GetAsset(ctx, s.bumper.AssetID)
+	if err != nil {
+		log.Printf("バンパー(%s)の取得に失敗: %v", s.bumper.AssetID, err)
+		return nil
+	}
+	bumper, err := s.gcsRepo.GetPlaylistWithSessionURLs(ctx, bucket, asset.MainPlaylistPath(), sessionID, s.watermark)
+	if err != nil {
+		log.Printf("バンパー(%s)の読み込みに失敗: %v", s.bumper.AssetID, err)
+		return nil
+	}
+	return bumper
+}
 
-	for i := 0; i < neededSegments && i < len(nextPlaylist.Segments); i++ {
-		nextSegment := nextPlaylist.Segments[i]
-		*m3u8Content = append(*m3u8Content, fmt.Sprintf("#EXTINF:%.1f,", nextSegment.Duration))
-		*m3u8Content = append(*m3u8Content, nextSegment.Filename)
+// programDate は番組が登録されている番組表の日付を返します（不明な場合は現在の日付）
+func programDate(program *domain.ProgramItem, now time.Time) string {
+	if program.ScheduleDate != "" {
+		return program.ScheduleDate
 	}
+	return now.Format("2006-01-02")
 }
 
 func (s *StreamingService) CheckStreamStatus(schedule []domain.ProgramItem) int {
-	jst := time.FixedZone("JST", 9*60*60)
-	now := time.Now().In(jst)
+	loc := s.location
+	now := s.clock.Now().In(loc)
 
-	currentProgram, _ := domain.FindCurrentProgram(schedule, now, jst)
+	currentProgram, _ := domain.FindCurrentProgram(schedule, now, loc)
 	if currentProgram != nil {
 		return http.StatusOK
 	}
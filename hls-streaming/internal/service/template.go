@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"log"
+
+	"github.com/genki0524/hls_striming_go/internal/domain"
+)
+
+type TemplateService struct {
+	templateRepo    domain.TemplateRepository
+	scheduleService *ScheduleService
+}
+
+func NewTemplateService(templateRepo domain.TemplateRepository, scheduleService *ScheduleService) *TemplateService {
+	return &TemplateService{
+		templateRepo:    templateRepo,
+		scheduleService: scheduleService,
+	}
+}
+
+func (s *TemplateService) ListTemplates(ctx context.Context) ([]domain.ScheduleTemplate, error) {
+	return s.templateRepo.ListTemplates(ctx)
+}
+
+func (s *TemplateService) GetTemplate(ctx context.Context, id string) (*domain.ScheduleTemplate, error) {
+	return s.templateRepo.GetTemplate(ctx, id)
+}
+
+// CreateTemplate はテンプレートを登録します。recordには保存した直後に登録したテンプレートを渡します
+func (s *TemplateService) CreateTemplate(ctx context.Context, template domain.ScheduleTemplate, record domain.ChangeRecorder[domain.ScheduleTemplate]) (*domain.ScheduleTemplate, error) {
+	template.ID = domain.NewID()
+	if err := s.saveTemplate(ctx, template); err != nil {
+		return nil, err
+	}
+	if err := record.Record(nil, &template); err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// UpdateTemplate はテンプレートを置き換えます。recordには保存した直後に変更前後のテンプレートを渡します
+func (s *TemplateService) UpdateTemplate(ctx context.Context, id string, template domain.ScheduleTemplate, record domain.ChangeRecorder[domain.ScheduleTemplate]) (*domain.ScheduleTemplate, error) {
+	previous, err := s.templateRepo.GetTemplate(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	template.ID = id
+	if err := s.saveTemplate(ctx, template); err != nil {
+		return nil, err
+	}
+	if err := record.Record(previous, &template); err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// DeleteTemplate はテンプレートを削除します。recordには削除した直後に削除したテンプレートを渡します
+func (s *TemplateService) DeleteTemplate(ctx context.Context, id string, record domain.ChangeRecorder[domain.ScheduleTemplate]) error {
+	previous, err := s.templateRepo.GetTemplate(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.templateRepo.DeleteTemplate(ctx, id); err != nil {
+		return err
+	}
+	return record.Record(previous, nil)
+}
+
+func (s *TemplateService) saveTemplate(ctx context.Context, template domain.ScheduleTemplate) error {
+	if err := template.Validate(); err != nil {
+		return err
+	}
+	if err := s.templateRepo.SaveTemplate(ctx, template); err != nil {
+		log.Printf("テンプレートの保存に失敗: %v", err)
+		return err
+	}
+	return nil
+}
+
+// ApplyTemplate はテンプレートを指定した日付に適用し、日ごとの番組表を生成します。
+// dryRunの場合は番組表を変更せずに結果のみを返します。recordを指定した場合は日ごとに反映した直後に変更前後の番組を渡します。
+// 途中の日で失敗した場合は、それまでに反映した日の結果とエラーを返します
+func (s *TemplateService) ApplyTemplate(ctx context.Context, id string, dates []string, mode domain.TemplateApplyMode, dryRun bool, record domain.GeneratedDayRecorder) (*domain.TemplateApplyResult, error) {
+	template, err := s.templateRepo.GetTemplate(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &domain.TemplateApplyResult{
+		TemplateID: id,
+		Mode:       mode,
+		DryRun:     dryRun,
+		Days:       make([]domain.TemplateDayResult, 0, len(dates)),
+	}
+	if len(dates) > 0 {
+		result.From = dates[0]
+		result.To = dates[len(dates)-1]
+	}
+
+	loc := s.scheduleService.Location()
+	assets := s.scheduleService.newAssetLookup(ctx)
+	// 反映済みの日は取り消さないため、途中で失敗した場合も保持している番組表に反映する
+	fail := func(date string, err error) (*domain.TemplateApplyResult, error) {
+		log.Printf("テンプレートの適用に失敗: 日付=%s, 反映済みの日数=%d, %v", date, result.AppliedCount, err)
+		s.scheduleService.refreshGeneratedDays(ctx, result.AppliedCount, dryRun)
+		return result, err
+	}
+
+	for _, date := range dates {
+		programs, err := template.ProgramsForDate(date, loc)
+		if err != nil {
+			return fail(date, err)
+		}
+		occurrences, err := s.scheduleService.adjacentOccurrences(ctx, date)
+		if err != nil {
+			return fail(date, err)
+		}
+
+		day, change, err := s.scheduleService.applyGeneratedDay(ctx, date, programs, occurrences, mode, assets, dryRun)
+		if err != nil {
+			return fail(date, err)
+		}
+		day.Date = date
+		if day.Status == domain.TemplateDayConflict {
+			log.Printf("テンプレートの適用をスキップしました: 日付=%s, 既存の番組数=%d", date, day.ExistingCount)
+		}
+		result.Add(day)
+		if change != nil && record != nil {
+			if err := record(date, change.before, change.after); err != nil {
+				return fail(date, err)
+			}
+		}
+	}
+
+	s.scheduleService.refreshGeneratedDays(ctx, result.AppliedCount, dryRun)
+	return result, nil
+}
@@ -3,14 +3,54 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/genki0524/hls_striming_go/internal/domain"
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	ProjectID string
-	Bucket    string
-	Port      string
+	ProjectID          string
+	Bucket             string
+	Port               string
+	ReadinessLookahead time.Duration
+	// Location はチャンネルのタイムゾーンです（日付の区切り、EPG、静止画のカウントダウンに使用）
+	Location *time.Location
+	// FillerTags はフィラー素材として番組の隙間に使用するアセットのタグです
+	FillerTags []string
+	// Shortfall は素材が番組の放送時間より短い場合の既定の埋め方です
+	Shortfall domain.ShortfallPolicy
+	// Bumper は番組の境目に入れるバンパーの設定です
+	Bumper domain.BumperConfig
+	// AdDecisionURL は広告枠に挿入する広告をリクエストするVASTのURLです（空の場合は広告を挿入しません）
+	AdDecisionURL string
+	// SessionStore は視聴者のセッションの保存先です（memory または firestore）
+	SessionStore string
+	// SessionTTL は最後のプレイリストの取得からセッションが失効するまでの時間です
+	SessionTTL time.Duration
+	// Watermark はセッションごとにA/Bのセグメントを配信するフォレンジック透かしの設定です
+	Watermark domain.Watermark
+	// PlaybackTokenSecret は再生トークンの署名に使用する秘密鍵です（空の場合は再生を保護しません）
+	PlaybackTokenSecret string
+	// PlaybackTokenTTL は再生トークンの有効期限です
+	PlaybackTokenTTL time.Duration
+	// ChannelID は再生トークンで視聴を許可するチャンネルです
+	ChannelID string
+	// AuthAPIKeys は管理APIのキーとロールです
+	AuthAPIKeys []domain.APIKey
+	// AuthJWKSFile はJWTの署名を検証するJWKSのファイルです（空の場合はJWTで認証しません）
+	AuthJWKSFile string
+	// AuthJWTIssuer、AuthJWTAudience はJWTのiss、audに指定された値です（AuthJWKSFileを設定する場合は必須です）
+	AuthJWTIssuer   string
+	AuthJWTAudience string
+	// AuthJWTRolesClaim はJWTのロールを含むクレームです
+	AuthJWTRolesClaim string
+	// AuthDisabled は管理APIを認証なしで公開することを明示的に許可したかどうかです（ローカル開発用）
+	AuthDisabled bool
+	// AuditStore は監査ログの保存先です（firestore または memory）
+	AuditStore string
 }
 
 func Load() (*Config, error) {
@@ -22,6 +62,98 @@ func Load() (*Config, error) {
 		ProjectID: getEnv("PROJECT_ID", ""),
 		Bucket:    getEnv("BUCKET", ""),
 		Port:      getEnv("PORT", "8080"),
+
+		AdDecisionURL: getEnv("AD_DECISION_URL", ""),
+		Watermark:     domain.Watermark{Secret: getEnv("WATERMARK_SECRET", "")},
+
+		PlaybackTokenSecret: getEnv("PLAYBACK_TOKEN_SECRET", ""),
+		ChannelID:           getEnv("CHANNEL_ID", "default"),
+
+		AuthJWKSFile:      getEnv("AUTH_JWKS_FILE", ""),
+		AuthJWTIssuer:     getEnv("AUTH_JWT_ISSUER", ""),
+		AuthJWTAudience:   getEnv("AUTH_JWT_AUDIENCE", ""),
+		AuthJWTRolesClaim: getEnv("AUTH_JWT_ROLES_CLAIM", "roles"),
+	}
+
+	lookaheadHours, err := strconv.Atoi(getEnv("READINESS_LOOKAHEAD_HOURS", "24"))
+	if err != nil || lookaheadHours <= 0 {
+		return nil, fmt.Errorf("READINESS_LOOKAHEAD_HOURS環境変数が不正です")
+	}
+	config.ReadinessLookahead = time.Duration(lookaheadHours) * time.Hour
+
+	timeZone := getEnv("TIME_ZONE", "Asia/Tokyo")
+	location, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return nil, fmt.Errorf("TIME_ZONE環境変数が不正です(%s): %w", timeZone, err)
+	}
+	config.Location = location
+
+	for _, tag := range strings.Split(getEnv("FILLER_TAGS", "filler"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			config.FillerTags = append(config.FillerTags, tag)
+		}
+	}
+
+	shortfall, err := domain.ParseShortfallPolicy(getEnv("SHORTFALL_POLICY", string(domain.ShortfallSlate)))
+	if err != nil {
+		return nil, fmt.Errorf("SHORTFALL_POLICY環境変数が不正です: %w", err)
+	}
+	config.Shortfall = shortfall
+
+	bumperMode, err := domain.ParseBumperMode(getEnv("BUMPER_MODE", string(domain.BumperAll)))
+	if err != nil {
+		return nil, fmt.Errorf("BUMPER_MODE環境変数が不正です: %w", err)
+	}
+	config.Bumper = domain.BumperConfig{
+		AssetID: getEnv("BUMPER_ASSET_ID", ""),
+		Mode:    bumperMode,
+	}
+
+	config.SessionStore = getEnv("SESSION_STORE", "memory")
+	if config.SessionStore != "memory" && config.SessionStore != "firestore" {
+		return nil, fmt.Errorf("SESSION_STORE環境変数はmemoryまたはfirestoreを指定してください")
+	}
+	sessionTTLMinutes, err := strconv.Atoi(getEnv("SESSION_TTL_MINUTES", "10"))
+	if err != nil || sessionTTLMinutes <= 0 {
+		return nil, fmt.Errorf("SESSION_TTL_MINUTES環境変数が不正です")
+	}
+	config.SessionTTL = time.Duration(sessionTTLMinutes) * time.Minute
+
+	playbackTokenTTLMinutes, err := strconv.Atoi(getEnv("PLAYBACK_TOKEN_TTL_MINUTES", "10"))
+	if err != nil || playbackTokenTTLMinutes <= 0 {
+		return nil, fmt.Errorf("PLAYBACK_TOKEN_TTL_MINUTES環境変数が不正です")
+	}
+	config.PlaybackTokenTTL = time.Duration(playbackTokenTTLMinutes) * time.Minute
+
+	apiKeys, err := domain.ParseAPIKeys(getEnv("AUTH_API_KEYS", ""))
+	if err != nil {
+		return nil, fmt.Errorf("AUTH_API_KEYS環境変数が不正です: %w", err)
+	}
+	config.AuthAPIKeys = apiKeys
+	authDisabled, err := strconv.ParseBool(getEnv("AUTH_DISABLED", "false"))
+	if err != nil {
+		return nil, fmt.Errorf("AUTH_DISABLED環境変数はtrueまたはfalseを指定してください")
+	}
+	config.AuthDisabled = authDisabled
+	// 設定漏れで管理APIが公開されないよう、認証なしで起動するにはAUTH_DISABLED=trueの指定を必須にする
+	switch {
+	case config.AuthDisabled && config.AuthEnabled():
+		return nil, fmt.Errorf("AUTH_DISABLED=trueを指定する場合はAUTH_API_KEYSとAUTH_JWKS_FILE環境変数を設定しないでください")
+	case !config.AuthDisabled && !config.AuthEnabled():
+		return nil, fmt.Errorf("AUTH_API_KEYSまたはAUTH_JWKS_FILE環境変数が設定されていません（認証なしで起動する場合はAUTH_DISABLED=trueを指定してください）")
+	}
+	// 他のIDプロバイダーやアプリ向けに発行されたJWTを受け付けないよう、iss、audの検証を必須にする
+	if config.AuthJWKSFile != "" && (config.AuthJWTIssuer == "" || config.AuthJWTAudience == "") {
+		return nil, fmt.Errorf("AUTH_JWKS_FILEを設定する場合はAUTH_JWT_ISSUERとAUTH_JWT_AUDIENCE環境変数も設定してください")
+	}
+	// 再生トークンの発行を認証なしで許可すると誰でも視聴できてしまう
+	if config.PlaybackTokenSecret != "" && !config.AuthEnabled() {
+		return nil, fmt.Errorf("PLAYBACK_TOKEN_SECRETを設定する場合はAUTH_API_KEYSまたはAUTH_JWKS_FILE環境変数も設定してください")
+	}
+
+	config.AuditStore = getEnv("AUDIT_STORE", "firestore")
+	if config.AuditStore != "memory" && config.AuditStore != "firestore" {
+		return nil, fmt.Errorf("AUDIT_STOREはfirestoreまたはmemoryを指定してください")
 	}
 
 	if config.ProjectID == "" {
@@ -35,6 +167,55 @@ func Load() (*Config, error) {
 	return config, nil
 }
 
+// AuthEnabled は管理APIの認証方式が設定されているかどうかを返します
+func (c *Config) AuthEnabled() bool {
+	return len(c.AuthAPIKeys) > 0 || c.AuthJWKSFile != ""
+}
+
+// AuditSnapshot は監査ログに記録する設定です。秘密鍵やAPIキーは設定の有無とキーの名前のみを含めます
+func (c *Config) AuditSnapshot() map[string]string {
+	apiKeys := make([]string, 0, len(c.AuthAPIKeys))
+	for _, key := range c.AuthAPIKeys {
+		roles := make([]string, 0, len(key.Roles))
+		for _, role := range key.Roles {
+			roles = append(roles, string(role))
+		}
+		apiKeys = append(apiKeys, key.Name+":"+strings.Join(roles, "+"))
+	}
+
+	return map[string]string{
+		"PROJECT_ID":                 c.ProjectID,
+		"BUCKET":                     c.Bucket,
+		"READINESS_LOOKAHEAD_HOURS":  c.ReadinessLookahead.String(),
+		"TIME_ZONE":                  c.Location.String(),
+		"FILLER_TAGS":                strings.Join(c.FillerTags, ","),
+		"SHORTFALL_POLICY":           string(c.Shortfall),
+		"BUMPER_ASSET_ID":            c.Bumper.AssetID,
+		"BUMPER_MODE":                string(c.Bumper.Mode),
+		"AD_DECISION_URL":            c.AdDecisionURL,
+		"SESSION_STORE":              c.SessionStore,
+		"SESSION_TTL_MINUTES":        c.SessionTTL.String(),
+		"WATERMARK_SECRET":           secretState(c.Watermark.Secret),
+		"PLAYBACK_TOKEN_SECRET":      secretState(c.PlaybackTokenSecret),
+		"PLAYBACK_TOKEN_TTL_MINUTES": c.PlaybackTokenTTL.String(),
+		"CHANNEL_ID":                 c.ChannelID,
+		"AUTH_API_KEYS":              strings.Join(apiKeys, ","),
+		"AUTH_JWKS_FILE":             c.AuthJWKSFile,
+		"AUTH_JWT_ISSUER":            c.AuthJWTIssuer,
+		"AUTH_JWT_AUDIENCE":          c.AuthJWTAudience,
+		"AUTH_JWT_ROLES_CLAIM":       c.AuthJWTRolesClaim,
+		"AUTH_DISABLED":              strconv.FormatBool(c.AuthDisabled),
+		"AUDIT_STORE":                c.AuditStore,
+	}
+}
+
+func secretState(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "(設定済み)"
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -0,0 +1,204 @@
+package test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/genki0524/hls_striming_go/internal/domain"
+)
+
+func TestEncodeSpliceInsert(t *testing.T) {
+	section := domain.EncodeSpliceInsert(42, true, 30)
+
+	if section[0] != 0xFC {
+		t.Errorf("期待したtable_id: 0xFC, 実際: %#x", section[0])
+	}
+	if length := int(section[1]&0x0F)<<8 | int(section[2]); length != len(section)-3 {
+		t.Errorf("section_lengthが正しくありません: %d, 実際の長さ: %d", length, len(section)-3)
+	}
+	if section[13] != 0x05 {
+		t.Errorf("期待したsplice_command_type: 0x05, 実際: %#x", section[13])
+	}
+	if section[19]&0x80 == 0 || section[19]&0x20 == 0 {
+		t.Errorf("out_of_network_indicatorとduration_flagが必要です: %#x", section[19])
+	}
+	// 30秒 = 2700000 (90kHz)
+	ticks := uint64(section[20]&0x01)<<32 | uint64(section[21])<<24 | uint64(section[22])<<16 | uint64(section[23])<<8 | uint64(section[24])
+	if ticks != 2700000 {
+		t.Errorf("期待したbreak_duration: 2700000, 実際: %d", ticks)
+	}
+	// CRCを含めたセクション全体のCRCは0になる
+	if crc := domain.MPEG2CRC32(section); crc != 0 {
+		t.Errorf("CRC_32が正しくありません: %#x", crc)
+	}
+
+	in := domain.EncodeSpliceInsert(42, false, 0)
+	if in[19]&0x80 != 0 || in[19]&0x20 != 0 || len(in) != len(section)-5 {
+		t.Errorf("CUE-INにはout_of_network_indicatorとbreak_durationを付けない想定です: %X", in)
+	}
+	if !strings.HasPrefix(domain.SCTE35Hex(in), "0xFC30") {
+		t.Errorf("16進数表記が正しくありません: %s", domain.SCTE35Hex(in))
+	}
+}
+
+func TestBuildProgramTimeline_AdBreaks(t *testing.T) {
+	program := &domain.ProgramItem{
+		ID:          "news",
+		StartTime:   "2025-09-15T19:00:00+09:00",
+		DurationSec: 30,
+		AdBreaks: []domain.AdBreak{
+			// 10秒の位置は12秒から始まるセグメントに合わせる
+			{Offset: 10, DurationSec: 9},
+			{ID: "cm2", At: "2025-09-15T19:00:27+09:00", DurationSec: 15},
+		},
+	}
+	timeline := domain.BuildProgramTimeline(program, tenSegmentPlaylist(), domain.TimelineOptions{})
+
+	out := timeline.SegmentAt(4).Cues
+	if len(out) != 1 || out[0].Type != domain.CueOut || out[0].ID != "news-break0" {
+		t.Fatalf("4番目のセグメントにCUE-OUTが必要です: %+v", out)
+	}
+	if start := out[0].StartDate.Format("15:04:05"); start != "19:00:12" {
+		t.Errorf("期待した広告枠の開始時刻: 19:00:12, 実際: %s", start)
+	}
+
+	in := timeline.SegmentAt(7).Cues
+	if len(in) != 1 || in[0].Type != domain.CueIn || in[0].Duration != 9 {
+		t.Fatalf("7番目のセグメントにCUE-INが必要です: %+v", in)
+	}
+
+	// 放送時間内に終わらない広告枠にはCUE-INを付けない
+	if cues := timeline.SegmentAt(9).Cues; len(cues) != 1 || cues[0].ID != "cm2" || cues[0].Type != domain.CueOut {
+		t.Errorf("9番目のセグメントにCUE-OUTだけが必要です: %+v", cues)
+	}
+
+	lines := strings.Join(timeline.SegmentAt(4).Lines(), "\n")
+	for _, expected := range []string{
+		`#EXT-X-DATERANGE:ID="news-break0",START-DATE="2025-09-15T19:00:12.000+09:00",PLANNED-DURATION=9.000,SCTE35-OUT=0xFC30`,
+		"#EXT-X-CUE-OUT:DURATION=9.000\n#EXTINF:3.0,\nsegment4.ts",
+	} {
+		if !strings.Contains(lines, expected) {
+			t.Errorf("期待した行がありません: %s\n%s", expected, lines)
+		}
+	}
+
+	if cues := tenSegmentPlaylist().Segments[4].Cues; len(cues) != 0 {
+		t.Error("素材のプレイリストが変更されています")
+	}
+}
+
+func TestParseM3U8Content_AdMarkers(t *testing.T) {
+	content := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:3
+#EXTINF:3.0,
+segment0.ts
+#EXT-X-DATERANGE:ID="ad1",START-DATE="2025-09-15T19:00:03.000+09:00",PLANNED-DURATION=6.000,SCTE35-OUT=0xFC302000
+#EXT-X-CUE-OUT:DURATION=6
+#EXTINF:3.0,
+segment1.ts
+#EXT-X-CUE-OUT-CONT:ElapsedTime=3,Duration=6
+#EXTINF:3.0,
+segment2.ts
+#EXT-X-DATERANGE:ID="ad1",START-DATE="2025-09-15T19:00:03.000+09:00",DURATION=6.000,SCTE35-IN=0xFC301000
+#EXT-X-CUE-IN
+#EXTINF:3.0,
+segment3.ts
+#EXT-X-ENDLIST`
+
+	playlist, err := domain.ParseM3U8Content(content)
+	if err != nil {
+		t.Fatalf("パースエラー: %v", err)
+	}
+	if len(playlist.Segments) != 4 {
+		t.Fatalf("期待したセグメント数: 4, 実際: %d", len(playlist.Segments))
+	}
+
+	out := playlist.Segments[1].Cues
+	if len(out) != 1 || out[0].Type != domain.CueOut || out[0].ID != "ad1" || out[0].Duration != 6 || out[0].SCTE35 != "0xFC302000" {
+		t.Errorf("CUE-OUTが正しく読み込まれていません: %+v", out)
+	}
+	if len(playlist.Segments[2].Cues) != 0 {
+		t.Errorf("CUE-OUT-CONTは引き継がない想定です: %+v", playlist.Segments[2].Cues)
+	}
+	in := playlist.Segments[3].Cues
+	if len(in) != 1 || in[0].Type != domain.CueIn || in[0].SCTE35 != "0xFC301000" {
+		t.Errorf("CUE-INが正しく読み込まれていません: %+v", in)
+	}
+
+	lines := strings.Join(playlist.Segments[1].Lines(), "\n")
+	if !strings.Contains(lines, `SCTE35-OUT=0xFC302000`) || !strings.Contains(lines, "#EXT-X-CUE-OUT:DURATION=6.000") {
+		t.Errorf("読み込んだ合図が出力されません:\n%s", lines)
+	}
+}
+
+func TestBuildProgramTimeline_RebasesCarriedCues(t *testing.T) {
+	content := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:3
+#EXTINF:3.0,
+segment0.ts
+#EXT-X-DATERANGE:ID="ad1",START-DATE="2020-01-01T00:00:03.000Z",PLANNED-DURATION=3.000,SCTE35-OUT=0xFC302000
+#EXT-X-CUE-OUT:DURATION=3
+#EXTINF:3.0,
+segment1.ts
+#EXT-X-DATERANGE:ID="ad1",START-DATE="2020-01-01T00:00:03.000Z",DURATION=3.000,SCTE35-IN=0xFC301000
+#EXT-X-CUE-IN
+#EXTINF:3.0,
+segment2.ts
+#EXT-X-ENDLIST`
+	playlist, err := domain.ParseM3U8Content(content)
+	if err != nil {
+		t.Fatalf("パースエラー: %v", err)
+	}
+
+	// 9秒の素材を18秒の枠で2回繰り返す
+	program := &domain.ProgramItem{ID: "news", StartTime: "2025-09-15T19:00:00+09:00", DurationSec: 18, Loop: true}
+	timeline := domain.BuildProgramTimeline(program, playlist, domain.TimelineOptions{Shortfall: domain.ShortfallSlate})
+
+	// 素材のSTART-DATEではなく、周回ごとの放送時刻になる
+	outs := timeline.CueOuts()
+	expected := []string{"2025-09-15T19:00:03+09:00", "2025-09-15T19:00:12+09:00"}
+	if len(outs) != len(expected) {
+		t.Fatalf("期待したCUE-OUTの数: %d, 実際: %d", len(expected), len(outs))
+	}
+	for i, out := range outs {
+		if out.StartDate.Format(time.RFC3339) != expected[i] {
+			t.Errorf("%d回目: 期待した開始時刻: %s, 実際: %s", i+1, expected[i], out.StartDate.Format(time.RFC3339))
+		}
+	}
+
+	// CUE-INのEXT-X-DATERANGEはCUE-OUTと同じ開始時刻にする
+	in := timeline.SegmentAt(5).Cues
+	if len(in) != 1 || in[0].Type != domain.CueIn || !in[0].StartDate.Equal(outs[1].StartDate) {
+		t.Errorf("CUE-INの開始時刻が正しくありません: %+v", in)
+	}
+	// 元のプレイリストは変更しない
+	if start := playlist.Segments[1].Cues[0].StartDate; start.Year() != 2020 {
+		t.Errorf("素材のプレイリストが変更されています: %s", start)
+	}
+}
+
+func TestValidateSchedule_AdBreaks(t *testing.T) {
+	programs := []domain.ProgramItem{
+		{ID: "a", StartTime: "2025-09-15T19:00:00+09:00", DurationSec: 1800, Type: "video", Title: "番組", AdBreaks: []domain.AdBreak{
+			{Offset: 600, DurationSec: 90},
+			{At: "2025-09-15T19:20:00+09:00", DurationSec: 90},
+			{Offset: 1800, DurationSec: 90},
+			{At: "2025-09-15T20:00:00+09:00", DurationSec: 90},
+			{Offset: 60, DurationSec: 0},
+			{Offset: 60, At: "2025-09-15T19:10:00+09:00", DurationSec: 30},
+		}},
+	}
+
+	report := domain.ValidateSchedule(programs, nil)
+	if count := violationCodes(report)[domain.ViolationInvalidAdBreak]; count != 4 {
+		t.Errorf("期待した違反の件数: 4, 実際: %d (%+v)", count, report.Violations)
+	}
+	for _, violation := range report.Violations {
+		if violation.Field == "ad_breaks[0]" || violation.Field == "ad_breaks[1]" {
+			t.Errorf("正しい広告枠が不正と判定されました: %+v", violation)
+		}
+	}
+}
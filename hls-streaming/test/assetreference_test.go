@@ -0,0 +1,69 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/genki0524/hls_striming_go/internal/domain"
+	"github.com/genki0524/hls_striming_go/internal/repository"
+	"github.com/genki0524/hls_striming_go/internal/service"
+)
+
+func TestMediaService_DeleteAssetInUse(t *testing.T) {
+	ctx := context.Background()
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	now := time.Date(2025, 9, 15, 18, 0, 0, 0, tokyo)
+
+	scheduleRepo := repository.NewInMemoryScheduleRepository()
+	recurrenceRepo := repository.NewInMemoryRecurrenceRepository()
+	templateRepo := repository.NewInMemoryTemplateRepository()
+	assetRepo := repository.NewInMemoryAssetRepository()
+	for _, id := range []string{"news", "cm", "unused"} {
+		assetRepo.CreateAsset(ctx, domain.Asset{ID: id, Title: id})
+	}
+
+	scheduleRepo.UpdateScheduleByDate(ctx, "2025-09-15", domain.AnyVersion, func(schedule *domain.Schedule) error {
+		schedule.Programs = []domain.ProgramItem{
+			{ID: "p1", StartTime: "2025-09-15T19:00:00+09:00", DurationSec: 1800, Type: "video", Title: "ニュース", AssetID: "news",
+				Interstitials: []domain.Interstitial{{Offset: 600, Assets: []string{"cm"}}}},
+			// 放送が終わった番組は参照元に含めない
+			{ID: "p0", StartTime: "2025-09-15T09:00:00+09:00", DurationSec: 1800, Type: "video", Title: "朝のニュース", AssetID: "unused"},
+		}
+		return nil
+	})
+	recurrenceRepo.SaveRecurrence(ctx, domain.RecurringProgram{ID: "weekday", Title: "平日のニュース", AssetID: "other",
+		Overrides: []domain.OccurrenceOverride{{Date: "2025-09-16", AssetID: "news"}}})
+	templateRepo.SaveTemplate(ctx, domain.ScheduleTemplate{ID: "standard", Name: "標準", Slots: []domain.TemplateSlot{{Weekday: "MO", StartTime: "20:00", AssetID: "cm"}}})
+
+	references := service.NewAssetReferenceService(scheduleRepo, recurrenceRepo, templateRepo, domain.BumperConfig{AssetID: "cm"}, 24*time.Hour, tokyo, domain.FixedClock{Time: now})
+	mediaService := service.NewMediaService(nil, assetRepo, nil, domain.Watermark{}, references)
+
+	err := mediaService.DeleteAsset(ctx, "news", false, nil)
+	var inUseErr *domain.AssetInUseError
+	if !errors.As(err, &inUseErr) || !errors.Is(err, domain.ErrAssetInUse) {
+		t.Fatalf("AssetInUseErrorを期待しましたが、実際: %v", err)
+	}
+	if len(inUseErr.References) != 2 || inUseErr.References[0].Kind != domain.AssetReferenceProgram || inUseErr.References[0].ID != "p1" || inUseErr.References[1].Kind != domain.AssetReferenceRecurrence {
+		t.Errorf("番組と繰り返し番組の参照を期待しました: %+v", inUseErr.References)
+	}
+
+	cm, err := references.FindReferences(ctx, "cm")
+	if err != nil {
+		t.Fatalf("参照元の確認に失敗: %v", err)
+	}
+	kinds := make([]string, 0, len(cm))
+	for _, reference := range cm {
+		kinds = append(kinds, reference.Kind)
+	}
+	if strings.Join(kinds, ",") != "bumper,interstitial,template" {
+		t.Errorf("バンパー・インタースティシャル・テンプレートの参照を期待しました: %v", kinds)
+	}
+
+	unused, _ := references.FindReferences(ctx, "unused")
+	if len(unused) != 0 {
+		t.Errorf("放送済みの番組は参照元に含めない想定です: %+v", unused)
+	}
+}
@@ -0,0 +1,172 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/genki0524/hls_striming_go/internal/domain"
+	"github.com/genki0524/hls_striming_go/internal/repository"
+	"github.com/genki0524/hls_striming_go/internal/service"
+)
+
+func TestNewAuditEntry(t *testing.T) {
+	now := time.Date(2025, 9, 15, 10, 0, 0, 0, time.UTC)
+	actor := domain.AuditActor{Subject: "alice", Method: "jwt", SourceIP: "203.0.113.1"}
+	program := &domain.ProgramItem{ID: "news", Title: "ニュース"}
+
+	entry, err := domain.NewAuditEntry(now, actor, domain.AuditProgramDelete, "schedule/2025-09-15/programs/news", program, (*domain.ProgramItem)(nil))
+	if err != nil {
+		t.Fatalf("監査ログの作成に失敗: %v", err)
+	}
+	if entry.ID == "" || entry.Actor != "alice" || entry.AuthMethod != "jwt" || entry.SourceIP != "203.0.113.1" || !entry.Time.Equal(now) {
+		t.Errorf("利用者や時刻が記録されていません: %+v", entry)
+	}
+
+	var before domain.ProgramItem
+	if err := json.Unmarshal(entry.Before, &before); err != nil || before.Title != "ニュース" {
+		t.Errorf("変更前の内容が記録されていません: %s", entry.Before)
+	}
+	if entry.After != nil {
+		t.Errorf("削除の場合は変更後の内容を記録しない想定です: %s", entry.After)
+	}
+}
+
+func TestDiffPrograms(t *testing.T) {
+	before := []domain.ProgramItem{{ID: "a", Title: "ニュース"}, {ID: "b", Title: "天気"}, {ID: "c", Title: "映画"}}
+	after := []domain.ProgramItem{{ID: "a", Title: "ニュース"}, {ID: "b", Title: "天気予報"}, {ID: "d", Title: "ドラマ"}}
+
+	changes := domain.DiffPrograms(before, after)
+	if fmt.Sprint(changes.Added, changes.Removed, changes.Changed) != "[d] [c] [b]" {
+		t.Errorf("番組IDごとの変更を期待しました: %+v", changes)
+	}
+}
+
+func TestInMemoryAuditRepository_Query(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInMemoryAuditRepository()
+	base := time.Date(2025, 9, 15, 10, 0, 0, 0, time.UTC)
+	for i, entry := range []struct {
+		actor  string
+		action domain.AuditAction
+		target string
+	}{
+		{"alice", domain.AuditProgramCreate, "schedule/2025-09-15/programs/a"},
+		{"bob", domain.AuditAssetUpload, "uploads/2025-09-15/news"},
+		{"alice", domain.AuditProgramUpdate, "schedule/2025-09-15/programs/a"},
+		{"alice", domain.AuditProgramDelete, "schedule/2025-09-15/programs/a"},
+	} {
+		record, _ := domain.NewAuditEntry(base.Add(time.Duration(i)*time.Minute), domain.AuditActor{Subject: entry.actor}, entry.action, entry.target, nil, nil)
+		if err := repo.AppendAuditEntry(ctx, record); err != nil {
+			t.Fatalf("監査ログの追加に失敗: %v", err)
+		}
+	}
+
+	entries, _ := repo.QueryAuditEntries(ctx, domain.AuditQuery{Actor: "alice", Target: "schedule/2025-09-15/programs/a"})
+	if len(entries) != 3 || entries[0].Action != domain.AuditProgramDelete || entries[2].Action != domain.AuditProgramCreate {
+		t.Errorf("新しい順に3件を期待しました: %+v", entries)
+	}
+
+	entries, _ = repo.QueryAuditEntries(ctx, domain.AuditQuery{From: base.Add(time.Minute), To: base.Add(3 * time.Minute), Limit: 1})
+	if len(entries) != 1 || entries[0].Action != domain.AuditProgramUpdate {
+		t.Errorf("期間内で最新の1件を期待しました: %+v", entries)
+	}
+}
+
+func TestAuditService_RecordConfigOnlyWhenChanged(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInMemoryAuditRepository()
+	auditService := service.NewAuditService(repo, domain.FixedClock{Time: time.Date(2025, 9, 15, 10, 0, 0, 0, time.UTC)})
+
+	steps := []map[string]string{
+		{"TIME_ZONE": "Asia/Tokyo", "BUMPER_MODE": "all"},
+		{"TIME_ZONE": "Asia/Tokyo", "BUMPER_MODE": "all"},
+		{"TIME_ZONE": "Asia/Tokyo", "BUMPER_MODE": "marked"},
+	}
+	for _, config := range steps {
+		if err := auditService.RecordConfig(ctx, config); err != nil {
+			t.Fatalf("設定の記録に失敗: %v", err)
+		}
+	}
+
+	entries, _ := auditService.Query(ctx, domain.AuditQuery{Action: domain.AuditConfigUpdate})
+	if len(entries) != 2 {
+		t.Fatalf("設定が変わった場合のみ記録する想定です: %d件", len(entries))
+	}
+	if entries[0].Actor != domain.AuditSystemActor || string(entries[0].Before) != `{"BUMPER_MODE":"all","TIME_ZONE":"Asia/Tokyo"}` || string(entries[0].After) != `{"BUMPER_MODE":"marked","TIME_ZONE":"Asia/Tokyo"}` {
+		t.Errorf("変更前後の設定が記録されていません: %+v", entries[0])
+	}
+	if entries[1].Before != nil {
+		t.Errorf("初回の記録に変更前の設定は不要です: %s", entries[1].Before)
+	}
+}
+
+func TestScheduleService_RecordsChangeBeforeRefresh(t *testing.T) {
+	ctx := context.Background()
+	inner := repository.NewInMemoryScheduleRepository()
+	// 保存後のリフレッシュが失敗しても変更を記録する
+	scheduleService := service.NewScheduleService(&unreadableScheduleRepository{InMemoryScheduleRepository: inner}, nil, nil, time.UTC, domain.SystemClock{})
+	request := domain.RequestProgramItem{StartTime: "2025-09-15T19:00:00+09:00", DurationSec: 1800, Type: "video", Title: "ニュース"}
+
+	var recorded []string
+	record := domain.ChangeRecorder[domain.ProgramItem](func(before, after *domain.ProgramItem) error {
+		recorded = append(recorded, fmt.Sprintf("%v→%v", before != nil, after != nil))
+		return nil
+	})
+	created, _, err := scheduleService.AddProgramToSchedule(ctx, request, "2025-09-15", domain.AnyVersion, record)
+	if err != nil {
+		t.Fatalf("番組の追加に失敗: %v", err)
+	}
+	if _, _, err := scheduleService.DeleteProgram(ctx, "2025-09-15", created.ID, domain.AnyVersion, record); err != nil {
+		t.Fatalf("番組の削除に失敗: %v", err)
+	}
+	if fmt.Sprint(recorded) != "[false→true true→false]" {
+		t.Errorf("追加と削除の変更前後が記録されていません: %v", recorded)
+	}
+
+	// 記録に失敗した場合は、変更を保存したまま記録の失敗を返す
+	failing := domain.ChangeRecorder[domain.ProgramItem](func(before, after *domain.ProgramItem) error {
+		return errors.New("書き込みに失敗しました")
+	})
+	_, _, err = scheduleService.AddProgramToSchedule(ctx, request, "2025-09-16", domain.AnyVersion, failing)
+	var notRecorded *domain.ChangeNotRecordedError
+	if !errors.As(err, &notRecorded) {
+		t.Fatalf("ChangeNotRecordedErrorを期待しましたが、実際: %v", err)
+	}
+	if schedule, _ := inner.GetScheduleByDate(ctx, "2025-09-16"); schedule == nil || len(schedule.Programs) != 1 {
+		t.Errorf("記録に失敗しても変更は保存される想定です: %+v", schedule)
+	}
+}
+
+func TestTemplateService_RecordsChanges(t *testing.T) {
+	ctx := context.Background()
+	templateRepo := repository.NewInMemoryTemplateRepository()
+	templateService := service.NewTemplateService(templateRepo, nil)
+
+	var before, after []string
+	record := domain.ChangeRecorder[domain.ScheduleTemplate](func(previous, current *domain.ScheduleTemplate) error {
+		if previous != nil {
+			before = append(before, previous.Name)
+		}
+		if current != nil {
+			after = append(after, current.Name)
+		}
+		return nil
+	})
+	created, err := templateService.CreateTemplate(ctx, domain.ScheduleTemplate{Name: "平日"}, record)
+	if err != nil {
+		t.Fatalf("テンプレートの登録に失敗: %v", err)
+	}
+	if _, err := templateService.UpdateTemplate(ctx, created.ID, domain.ScheduleTemplate{Name: "週末"}, record); err != nil {
+		t.Fatalf("テンプレートの更新に失敗: %v", err)
+	}
+	if err := templateService.DeleteTemplate(ctx, created.ID, record); err != nil {
+		t.Fatalf("テンプレートの削除に失敗: %v", err)
+	}
+	if fmt.Sprint(before) != "[平日 週末]" || fmt.Sprint(after) != "[平日 週末]" {
+		t.Errorf("変更前後のテンプレートが記録されていません: %v, %v", before, after)
+	}
+}
@@ -0,0 +1,219 @@
+package test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/genki0524/hls_striming_go/internal/domain"
+	"github.com/genki0524/hls_striming_go/internal/service"
+	"github.com/genki0524/hls_striming_go/pkg/config"
+)
+
+type testJWTKeys struct {
+	rsa *rsa.PrivateKey
+	ec  *ecdsa.PrivateKey
+}
+
+func newTestJWTKeys(t *testing.T) testJWTKeys {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("RSA鍵の生成に失敗: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("EC鍵の生成に失敗: %v", err)
+	}
+	return testJWTKeys{rsa: rsaKey, ec: ecKey}
+}
+
+func (k testJWTKeys) jwks(t *testing.T) *domain.JWKS {
+	t.Helper()
+	encode := func(value *big.Int, size int) string {
+		return base64.RawURLEncoding.EncodeToString(value.FillBytes(make([]byte, size)))
+	}
+	document := map[string]any{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa1", "use": "sig", "n": encode(k.rsa.N, k.rsa.Size()), "e": encode(big.NewInt(int64(k.rsa.E)), 3)},
+		{"kty": "EC", "kid": "ec1", "crv": "P-256", "x": encode(k.ec.X, 32), "y": encode(k.ec.Y, 32)},
+		{"kty": "oct", "kid": "hmac", "k": "c2VjcmV0"},
+	}}
+	data, _ := json.Marshal(document)
+	jwks, err := domain.ParseJWKS(data)
+	if err != nil {
+		t.Fatalf("JWKSの解析に失敗: %v", err)
+	}
+	return jwks
+}
+
+func (k testJWTKeys) sign(t *testing.T, alg, kid string, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(input))
+
+	var signature []byte
+	switch alg {
+	case "RS256":
+		signature, _ = rsa.SignPKCS1v15(rand.Reader, k.rsa, crypto.SHA256, digest[:])
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, k.ec, digest[:])
+		if err != nil {
+			t.Fatalf("署名に失敗: %v", err)
+		}
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestJWTVerifier_Verify(t *testing.T) {
+	keys := newTestJWTKeys(t)
+	now := time.Date(2025, 9, 15, 10, 0, 0, 0, time.UTC)
+	verifier := domain.JWTVerifier{Keys: keys.jwks(t), Issuer: "https://idp.example.com", Audience: "hls-admin", RolesClaim: "realm_access.roles"}
+	claims := func(overrides map[string]any) map[string]any {
+		result := map[string]any{
+			"sub":          "alice",
+			"iss":          "https://idp.example.com",
+			"aud":          []string{"hls-admin", "other"},
+			"exp":          now.Add(time.Hour).Unix(),
+			"realm_access": map[string]any{"roles": []string{"scheduler", "offline_access"}},
+		}
+		for name, value := range overrides {
+			result[name] = value
+		}
+		return result
+	}
+
+	for _, alg := range []string{"RS256", "ES256"} {
+		kid := map[string]string{"RS256": "rsa1", "ES256": "ec1"}[alg]
+		principal, err := verifier.Verify(keys.sign(t, alg, kid, claims(nil)), now)
+		if err != nil {
+			t.Fatalf("%s: 検証に失敗: %v", alg, err)
+		}
+		if principal.Subject != "alice" || principal.Method != "jwt" || len(principal.Roles) != 1 || principal.Roles[0] != domain.RoleScheduler {
+			t.Errorf("%s: 期待した利用者ではありません: %+v", alg, principal)
+		}
+	}
+
+	valid := strings.Split(keys.sign(t, "RS256", "rsa1", claims(nil)), ".")
+	noneHeader := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"rsa1"}`))
+	forgedPayload := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"mallory","exp":9999999999,"realm_access":{"roles":["admin"]}}`))
+	for name, token := range map[string]string{
+		"有効期限切れ":    keys.sign(t, "RS256", "rsa1", claims(map[string]any{"exp": now.Add(-2 * time.Minute).Unix()})),
+		"有効期限なし":    keys.sign(t, "RS256", "rsa1", claims(map[string]any{"exp": nil})),
+		"有効期間前":     keys.sign(t, "RS256", "rsa1", claims(map[string]any{"nbf": now.Add(time.Hour).Unix()})),
+		"別の発行者":     keys.sign(t, "RS256", "rsa1", claims(map[string]any{"iss": "https://evil.example.com"})),
+		"別の対象":      keys.sign(t, "RS256", "rsa1", claims(map[string]any{"aud": "other"})),
+		"不明な鍵":      keys.sign(t, "RS256", "unknown", claims(nil)),
+		"algと鍵の不一致": keys.sign(t, "ES256", "rsa1", claims(nil)),
+		"alg=none":  noneHeader + "." + valid[1] + ".",
+		"内容の改ざん":    valid[0] + "." + forgedPayload + "." + valid[2],
+		"不正な形式":     "abc",
+	} {
+		if _, err := verifier.Verify(token, now); !errors.Is(err, domain.ErrUnauthenticated) {
+			t.Errorf("%s: ErrUnauthenticatedを期待しましたが、実際: %v", name, err)
+		}
+	}
+
+	// 発行者・対象を設定していない場合は、署名が正しくても受け付けない
+	for _, unchecked := range []domain.JWTVerifier{
+		{Keys: verifier.Keys, Audience: verifier.Audience, RolesClaim: verifier.RolesClaim},
+		{Keys: verifier.Keys, Issuer: verifier.Issuer, RolesClaim: verifier.RolesClaim},
+	} {
+		if _, err := unchecked.Verify(keys.sign(t, "RS256", "rsa1", claims(nil)), now); !errors.Is(err, domain.ErrUnauthenticated) {
+			t.Errorf("ErrUnauthenticatedを期待しましたが、実際: %v", err)
+		}
+	}
+}
+
+func TestParseAPIKeys(t *testing.T) {
+	keys, err := domain.ParseAPIKeys("ci:key1:scheduler+uploader, player:key2:viewer,")
+	if err != nil {
+		t.Fatalf("解析に失敗: %v", err)
+	}
+	if len(keys) != 2 || keys[0].Name != "ci" || keys[0].Key != "key1" || len(keys[0].Roles) != 2 || keys[1].Roles[0] != domain.RoleViewer {
+		t.Errorf("期待したAPIキーではありません: %+v", keys)
+	}
+
+	for _, value := range []string{"ci:key1", "ci:key1:owner", "ci::admin"} {
+		if _, err := domain.ParseAPIKeys(value); err == nil {
+			t.Errorf("%s: エラーを期待しました", value)
+		}
+	}
+}
+
+func TestAuthService_Authorize(t *testing.T) {
+	ctx := context.Background()
+	keys := newTestJWTKeys(t)
+	now := time.Date(2025, 9, 15, 10, 0, 0, 0, time.UTC)
+	authService := service.NewAuthService(
+		service.NewAPIKeyAuthenticator([]domain.APIKey{
+			{Name: "ci", Key: "scheduler-key", Roles: []domain.Role{domain.RoleScheduler}},
+			{Name: "ops", Key: "admin-key", Roles: []domain.Role{domain.RoleAdmin}},
+		}),
+		service.NewJWTAuthenticator(domain.JWTVerifier{Keys: keys.jwks(t), Issuer: "https://idp.example.com", Audience: "hls-admin", RolesClaim: "roles"}, domain.FixedClock{Time: now}),
+	)
+
+	steps := []struct {
+		name        string
+		credentials domain.Credentials
+		role        domain.Role
+		expected    error
+	}{
+		{"ロールを持つキー", domain.Credentials{APIKey: "scheduler-key"}, domain.RoleScheduler, nil},
+		{"いずれかのロールでviewerの操作", domain.Credentials{APIKey: "scheduler-key"}, domain.RoleViewer, nil},
+		{"ロールを持たないキー", domain.Credentials{APIKey: "scheduler-key"}, domain.RoleUploader, domain.ErrForbidden},
+		{"adminはすべての操作", domain.Credentials{APIKey: "admin-key"}, domain.RoleUploader, nil},
+		{"不正なキー", domain.Credentials{APIKey: "wrong"}, domain.RoleViewer, domain.ErrUnauthenticated},
+		{"認証情報なし", domain.Credentials{}, domain.RoleViewer, domain.ErrUnauthenticated},
+		{"JWT", domain.Credentials{BearerToken: keys.sign(t, "ES256", "ec1", map[string]any{"sub": "bob", "iss": "https://idp.example.com", "aud": "hls-admin", "exp": now.Add(time.Hour).Unix(), "roles": "uploader"})}, domain.RoleUploader, nil},
+		{"ロールのないJWT", domain.Credentials{BearerToken: keys.sign(t, "ES256", "ec1", map[string]any{"sub": "bob", "iss": "https://idp.example.com", "aud": "hls-admin", "exp": now.Add(time.Hour).Unix()})}, domain.RoleViewer, domain.ErrForbidden},
+	}
+	for _, step := range steps {
+		_, err := authService.Authorize(ctx, step.credentials, step.role)
+		if step.expected == nil && err != nil || step.expected != nil && !errors.Is(err, step.expected) {
+			t.Errorf("%s: 期待 %v, 実際 %v", step.name, step.expected, err)
+		}
+	}
+
+	if service.NewAuthService().Enabled() || !authService.Enabled() {
+		t.Error("認証方式が設定されている場合のみ認証する想定です")
+	}
+}
+
+func TestConfigLoad_JWKSRequiresIssuerAndAudience(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv("PROJECT_ID", "test-project")
+	t.Setenv("BUCKET", "test-bucket")
+	t.Setenv("AUTH_JWKS_FILE", "./credentials/jwks.json")
+
+	for _, env := range []map[string]string{
+		{},
+		{"AUTH_JWT_ISSUER": "https://idp.example.com"},
+		{"AUTH_JWT_AUDIENCE": "hls-admin"},
+	} {
+		t.Setenv("AUTH_JWT_ISSUER", env["AUTH_JWT_ISSUER"])
+		t.Setenv("AUTH_JWT_AUDIENCE", env["AUTH_JWT_AUDIENCE"])
+		if _, err := config.Load(); err == nil {
+			t.Errorf("%v: 発行者と対象のいずれかがない場合は起動しない想定です", env)
+		}
+	}
+
+	t.Setenv("AUTH_JWT_ISSUER", "https://idp.example.com")
+	t.Setenv("AUTH_JWT_AUDIENCE", "hls-admin")
+	if _, err := config.Load(); err != nil {
+		t.Errorf("設定の読み込みに失敗: %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/genki0524/hls_striming_go/internal/domain"
+	"github.com/genki0524/hls_striming_go/internal/repository"
+	"github.com/genki0524/hls_striming_go/internal/service"
+)
+
+func backCatalog() []domain.Asset {
+	assets := make([]domain.Asset, 0)
+	for i := 0; i < 4; i++ {
+		assets = append(assets,
+			domain.Asset{ID: fmt.Sprintf("drama-%d", i), Title: fmt.Sprintf("ドラマ%d", i), DurationSec: 1790, Tags: []string{"drama"}},
+			domain.Asset{ID: fmt.Sprintf("anime-%d", i), Title: fmt.Sprintf("アニメ%d", i), DurationSec: 1420.5, Tags: []string{"anime"}},
+		)
+	}
+	return assets
+}
+
+func TestBuildAutoSchedule_RotatesCategoriesAndAlignsSlots(t *testing.T) {
+	rules := domain.AutoProgramRules{
+		Categories:   []string{"drama", "anime"},
+		SlotAlignSec: 1800,
+		DayParts:     []domain.DayPart{{Name: "evening", Start: "18:00", End: "21:00"}},
+	}
+
+	day, err := domain.BuildAutoSchedule("2025-01-01", time.UTC, backCatalog(), rules, nil)
+	if err != nil {
+		t.Fatalf("自動編成に失敗: %v", err)
+	}
+
+	if len(day.Programs) != 6 {
+		t.Fatalf("期待した番組数: 6, 実際: %d", len(day.Programs))
+	}
+	for i, program := range day.Programs {
+		expectedStart := time.Date(2025, 1, 1, 18, 0, 0, 0, time.UTC).Add(time.Duration(i) * 30 * time.Minute)
+		if program.StartTime != expectedStart.Format(time.RFC3339) || program.DurationSec != 1800 {
+			t.Errorf("番組%d: 開始=%s, 放送時間=%d", i, program.StartTime, program.DurationSec)
+		}
+		category := []string{"drama", "anime"}[i%2]
+		if program.AssetID[:len(category)] != category {
+			t.Errorf("番組%d: 期待したカテゴリ: %s, 実際: %s", i, category, program.AssetID)
+		}
+	}
+	if day.UnfilledSec != 0 {
+		t.Errorf("期待した空き時間: 0, 実際: %.0f", day.UnfilledSec)
+	}
+}
+
+func TestBuildAutoSchedule_Deterministic(t *testing.T) {
+	rules := domain.AutoProgramRules{Categories: []string{"drama", "anime"}, Seed: 42}
+
+	first, _ := domain.BuildAutoSchedule("2025-01-01", time.UTC, backCatalog(), rules, nil)
+	second, _ := domain.BuildAutoSchedule("2025-01-01", time.UTC, backCatalog(), rules, nil)
+
+	if len(first.Programs) != len(second.Programs) {
+		t.Fatalf("番組数が一致しません: %d, %d", len(first.Programs), len(second.Programs))
+	}
+	for i := range first.Programs {
+		if first.Programs[i].AssetID != second.Programs[i].AssetID || first.Programs[i].StartTime != second.Programs[i].StartTime {
+			t.Errorf("番組%dが一致しません: %s, %s", i, first.Programs[i].AssetID, second.Programs[i].AssetID)
+		}
+	}
+}
+
+func TestBuildAutoSchedule_MinRepeatDistance(t *testing.T) {
+	rules := domain.AutoProgramRules{
+		Categories:       []string{"drama"},
+		MinRepeatMinutes: 180,
+		DayParts:         []domain.DayPart{{Start: "00:00", End: "06:00"}},
+	}
+
+	day, err := domain.BuildAutoSchedule("2025-01-01", time.UTC, backCatalog(), rules, nil)
+	if err != nil {
+		t.Fatalf("自動編成に失敗: %v", err)
+	}
+
+	lastAired := make(map[string]time.Time)
+	for _, program := range day.Programs {
+		startTime, _ := program.GetStartTime()
+		if aired, ok := lastAired[program.AssetID]; ok && startTime.Sub(aired) < 180*time.Minute {
+			t.Errorf("%sが%v後に再放送されています", program.AssetID, startTime.Sub(aired))
+		}
+		lastAired[program.AssetID] = startTime
+	}
+	// ドラマ4本 × 1790秒では3時間空けられないため、残りは空き時間になる
+	if day.UnfilledSec <= 0 {
+		t.Error("再放送間隔を満たせない時間が空き時間になっていません")
+	}
+}
+
+func TestBuildAutoSchedule_DayPartDurationLimits(t *testing.T) {
+	rules := domain.AutoProgramRules{
+		Categories: []string{"drama", "anime"},
+		DayParts:   []domain.DayPart{{Start: "06:00", End: "08:00", MaxDurationSec: 1500}},
+	}
+
+	day, err := domain.BuildAutoSchedule("2025-01-01", time.UTC, backCatalog(), rules, nil)
+	if err != nil {
+		t.Fatalf("自動編成に失敗: %v", err)
+	}
+	for _, program := range day.Programs {
+		if program.AssetID[:5] != "anime" {
+			t.Errorf("最大の長さを超える素材が編成されました: %s", program.AssetID)
+		}
+	}
+}
+
+func TestAutoProgramService_WritesThroughRepository(t *testing.T) {
+	ctx := context.Background()
+	assetRepo := repository.NewInMemoryAssetRepository()
+	for _, asset := range backCatalog() {
+		assetRepo.CreateAsset(ctx, asset)
+	}
+	scheduleService := service.NewScheduleService(repository.NewInMemoryScheduleRepository(), assetRepo, nil, time.UTC, domain.SystemClock{})
+	autoProgramService := service.NewAutoProgramService(scheduleService, assetRepo)
+
+	rules := domain.AutoProgramRules{Categories: []string{"drama", "anime"}, SlotAlignSec: 1800}
+
+	if _, err := autoProgramService.Generate(ctx, []string{"2025-01-01"}, domain.AutoProgramRules{}, domain.TemplateApplySkip, false, nil); !errors.Is(err, domain.ErrInvalidAutoProgramRules) {
+		t.Fatalf("カテゴリのないルールがエラーになりません: %v", err)
+	}
+
+	result, err := autoProgramService.Generate(ctx, []string{"2025-01-01", "2025-01-02"}, rules, domain.TemplateApplySkip, false, nil)
+	if err != nil {
+		t.Fatalf("自動編成に失敗: %v", err)
+	}
+	if result.AppliedCount != 2 {
+		t.Fatalf("期待した反映日数: 2, 実際: %d", result.AppliedCount)
+	}
+
+	schedule, err := scheduleService.GetScheduleByDate(ctx, "2025-01-02")
+	if err != nil {
+		t.Fatalf("番組表の取得に失敗: %v", err)
+	}
+	if len(schedule.Programs) != 48 {
+		t.Errorf("期待した番組数: 48, 実際: %d", len(schedule.Programs))
+	}
+
+	// 既に番組がある日はskipモードでは変更されない
+	result, err = autoProgramService.Generate(ctx, []string{"2025-01-01"}, rules, domain.TemplateApplySkip, false, nil)
+	if err != nil {
+		t.Fatalf("自動編成に失敗: %v", err)
+	}
+	if result.ConflictCount != 1 {
+		t.Errorf("既存の番組表との競合が報告されません: %+v", result.Days[0].TemplateDayResult)
+	}
+}
@@ -0,0 +1,66 @@
+package test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/option"
+
+	"github.com/genki0524/hls_striming_go/internal/repository"
+)
+
+// newFakeGCSRepository はobjects（バケット名を除くオブジェクトパスと内容）を返すローカルのサーバーに接続したGCSRepositoryを作成します。
+// 署名付きURLはテスト用に生成したサービスアカウントの鍵で署名します
+func newFakeGCSRepository(t *testing.T, objects map[string]string) *repository.GCSRepository {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"access_token":"test-token","token_type":"Bearer","expires_in":3600}`))
+			return
+		}
+		// XML APIのパスは /{bucket}/{object}
+		_, object, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		content, ok := objects[object]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(content))
+	}))
+	t.Cleanup(server.Close)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("鍵の生成に失敗: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("鍵の変換に失敗: %v", err)
+	}
+	credentials, _ := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     "test",
+		"private_key_id": "test",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"client_email":   "test@test.iam.gserviceaccount.com",
+		"token_uri":      server.URL + "/token",
+	})
+
+	client, err := storage.NewClient(context.Background(), option.WithEndpoint(server.URL+"/storage/v1/"), option.WithCredentialsJSON(credentials))
+	if err != nil {
+		t.Fatalf("GCSクライアントの初期化に失敗: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return repository.NewGCSRepository(client)
+}
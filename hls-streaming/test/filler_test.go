@@ -0,0 +1,194 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/genki0524/hls_striming_go/internal/domain"
+	"github.com/genki0524/hls_striming_go/internal/repository"
+	"github.com/genki0524/hls_striming_go/internal/service"
+)
+
+func fillerPool() []domain.Asset {
+	return []domain.Asset{
+		{ID: "promo", Title: "番組宣伝", DurationSec: 30.5, Tags: []string{"filler"}},
+		{ID: "ident", Title: "ジングル", DurationSec: 10, Tags: []string{"filler"}},
+		{ID: "mv", Title: "ミュージックビデオ", DurationSec: 240, Tags: []string{"filler"}},
+	}
+}
+
+func TestFindGaps(t *testing.T) {
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	programs := []domain.ProgramItem{
+		{StartTime: base.Add(10 * time.Minute).Format(time.RFC3339), DurationSec: 600},
+		{StartTime: base.Add(15 * time.Minute).Format(time.RFC3339), DurationSec: 60}, // 前の番組に含まれる
+		{StartTime: base.Add(30 * time.Minute).Format(time.RFC3339), DurationSec: 600},
+	}
+
+	gaps := domain.FindGaps(programs, base, base.Add(time.Hour))
+
+	expected := []domain.Gap{
+		{Start: base, End: base.Add(10 * time.Minute)},
+		{Start: base.Add(20 * time.Minute), End: base.Add(30 * time.Minute)},
+		{Start: base.Add(40 * time.Minute), End: base.Add(time.Hour)},
+	}
+	if len(gaps) != len(expected) {
+		t.Fatalf("期待した隙間の数: %d, 実際: %d", len(expected), len(gaps))
+	}
+	for i := range expected {
+		if !gaps[i].Start.Equal(expected[i].Start) || !gaps[i].End.Equal(expected[i].End) {
+			t.Errorf("隙間%d: 期待: %v〜%v, 実際: %v〜%v", i, expected[i].Start, expected[i].End, gaps[i].Start, gaps[i].End)
+		}
+	}
+}
+
+func TestFillGaps_PacksAndLeavesSlateRemainder(t *testing.T) {
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	programs := []domain.ProgramItem{
+		{StartTime: base.Add(305 * time.Second).Format(time.RFC3339), DurationSec: 600},
+	}
+
+	// 305秒の隙間: 240 + 30 + 10 × 3 で埋め、5秒だけ静止画になる
+	result := domain.FillGaps(programs, base, base.Add(305*time.Second), fillerPool(), time.UTC)
+
+	if result.FilledSec != 300 {
+		t.Errorf("期待した埋めた秒数: 300, 実際: %.0f (%+v)", result.FilledSec, result.Fillers)
+	}
+	if len(result.Slate) != 1 || result.SlateSec != 5 {
+		t.Fatalf("期待した静止画: 5秒, 実際: %.0f秒 (%+v)", result.SlateSec, result.Slate)
+	}
+	if !result.Slate[0].End.Equal(base.Add(305 * time.Second)) {
+		t.Errorf("静止画は次の番組の直前に置かれる必要があります: %v", result.Slate[0])
+	}
+
+	cursor := base
+	for i, filler := range result.Fillers {
+		startTime, _ := filler.GetStartTime()
+		if !startTime.Equal(cursor) {
+			t.Errorf("フィラー%dが連続していません: 期待: %v, 実際: %v", i, cursor, startTime)
+		}
+		if filler.Type != domain.ProgramTypeFiller || filler.AssetID == "" {
+			t.Errorf("フィラーの内容が不正です: %+v", filler)
+		}
+		cursor = startTime.Add(time.Duration(filler.DurationSec) * time.Second)
+	}
+}
+
+func TestFillGaps_RotatesThroughPool(t *testing.T) {
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	pool := []domain.Asset{
+		{ID: "a", DurationSec: 60},
+		{ID: "b", DurationSec: 60},
+		{ID: "c", DurationSec: 60},
+	}
+
+	result := domain.FillGaps(nil, base, base.Add(6*time.Minute), pool, time.UTC)
+
+	expected := []string{"a", "b", "c", "a", "b", "c"}
+	if len(result.Fillers) != len(expected) {
+		t.Fatalf("期待したフィラー数: %d, 実際: %d", len(expected), len(result.Fillers))
+	}
+	for i, id := range expected {
+		if result.Fillers[i].AssetID != id {
+			t.Errorf("フィラー%d: 期待: %s, 実際: %s", i, id, result.Fillers[i].AssetID)
+		}
+	}
+}
+
+func TestFillGaps_EmptyPoolIsAllSlate(t *testing.T) {
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	result := domain.FillGaps(nil, base, base.Add(time.Hour), nil, time.UTC)
+
+	if len(result.Fillers) != 0 || result.SlateSec != 3600 {
+		t.Errorf("素材がない場合は隙間全体が静止画になる必要があります: %+v", result)
+	}
+}
+
+func TestAsset_HasAnyTag(t *testing.T) {
+	asset := domain.Asset{Tags: []string{"promo", "filler"}}
+	if !asset.HasAnyTag([]string{"filler"}) {
+		t.Error("タグが一致しません")
+	}
+	if asset.HasAnyTag([]string{"music"}) {
+		t.Error("持っていないタグが一致しました")
+	}
+}
+
+func newTestFillerService(t *testing.T, clock domain.Clock, assets ...domain.Asset) (*service.FillerService, *repository.InMemoryAssetRepository) {
+	t.Helper()
+	ctx := context.Background()
+	assetRepo := repository.NewInMemoryAssetRepository()
+	for _, asset := range assets {
+		assetRepo.CreateAsset(ctx, asset)
+	}
+	fillerService := service.NewFillerService(assetRepo, []string{"filler"}, time.UTC, clock)
+	if err := fillerService.RefreshPool(ctx); err != nil {
+		t.Fatalf("フィラー素材の読み込みに失敗: %v", err)
+	}
+	return fillerService, assetRepo
+}
+
+func fillerAt(programs []domain.ProgramItem, at time.Time) *domain.ProgramItem {
+	for _, program := range programs {
+		startTime, _ := program.GetStartTime()
+		if !at.Before(startTime) && at.Before(startTime.Add(time.Duration(program.DurationSec)*time.Second)) {
+			return &program
+		}
+	}
+	return nil
+}
+
+func TestFillerService_SameFillerAcrossMidnight(t *testing.T) {
+	fillerService, _ := newTestFillerService(t, domain.FixedClock{}, fillerPool()...)
+	programs := []domain.ProgramItem{
+		{ID: "news", StartTime: "2025-01-01T12:00:07Z", DurationSec: 3600, Type: domain.ProgramTypeVideo, Title: "ニュース"},
+		{ID: "movie", StartTime: "2025-01-02T20:00:00Z", DurationSec: 7200, Type: domain.ProgramTypeVideo, Title: "映画"},
+	}
+
+	// 0時をまたいで対象の日付の範囲が変わっても、同じ時刻のフィラーは変わらない
+	instant := time.Date(2025, 1, 2, 18, 0, 5, 0, time.UTC)
+	before := fillerAt(fillerService.PlayoutScheduleAt(programs, time.Date(2025, 1, 2, 23, 59, 59, 0, time.UTC)), instant)
+	after := fillerAt(fillerService.PlayoutScheduleAt(programs, time.Date(2025, 1, 3, 0, 0, 1, 0, time.UTC)), instant)
+	if before == nil || after == nil {
+		t.Fatalf("フィラーが見つかりません: %+v, %+v", before, after)
+	}
+	if before.AssetID != after.AssetID || before.StartTime != after.StartTime {
+		t.Errorf("0時の前後でフィラーが変わりました: %s(%s) → %s(%s)", before.AssetID, before.StartTime, after.AssetID, after.StartTime)
+	}
+}
+
+func TestFillerService_KeepsOnAirFillerWhenPoolChanges(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 2, 10, 2, 30, 0, time.UTC)
+	fillerService, assetRepo := newTestFillerService(t, domain.FixedClock{Time: now},
+		domain.Asset{ID: "a", DurationSec: 60, Tags: []string{"filler"}},
+		domain.Asset{ID: "b", DurationSec: 60, Tags: []string{"filler"}},
+	)
+
+	onAir := fillerAt(fillerService.PlayoutSchedule(nil, 1), now)
+	if onAir == nil {
+		t.Fatal("放送中のフィラーがありません")
+	}
+
+	// 素材が追加されると計画は作り直されるが、放送中のフィラーは変わらない
+	assetRepo.CreateAsset(ctx, domain.Asset{ID: "long", DurationSec: 90, Tags: []string{"filler"}})
+	if err := fillerService.RefreshPool(ctx); err != nil {
+		t.Fatalf("フィラー素材の読み込みに失敗: %v", err)
+	}
+	if replanned := fillerAt(fillerService.PlayoutScheduleAt(nil, now), now); replanned.AssetID == onAir.AssetID && replanned.StartTime == onAir.StartTime {
+		t.Fatalf("素材の追加で計画が変わる前提が成り立っていません: %+v", replanned)
+	}
+
+	current := fillerAt(fillerService.PlayoutSchedule(nil, 1), now)
+	if current == nil || current.AssetID != onAir.AssetID || current.StartTime != onAir.StartTime {
+		t.Errorf("放送中のフィラーが切り替わりました: %+v → %+v", onAir, current)
+	}
+
+	// 放送中のフィラーの後は新しい素材で埋める
+	next := fillerAt(fillerService.PlayoutSchedule(nil, 1), now.Add(time.Minute))
+	if next == nil {
+		t.Error("放送中のフィラーの後が埋められていません")
+	}
+}
@@ -0,0 +1,150 @@
+package test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/genki0524/hls_striming_go/internal/domain"
+	"github.com/genki0524/hls_striming_go/internal/handler"
+	"github.com/genki0524/hls_striming_go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// routeRoles は管理APIの各ルートに必要なロールです（空の場合は管理APIの認証を行いません）
+var routeRoles = map[string]domain.Role{
+	"GET /":                                   "",
+	"GET /live/video.m3u8":                    "",
+	"GET /api/preview/video.m3u8":             domain.RoleViewer,
+	"HEAD /live/status":                       "",
+	"POST /api/refresh-schedule":              domain.RoleScheduler,
+	"GET /api/schedule":                       domain.RoleViewer,
+	"GET /api/epg":                            "",
+	"POST /api/schedule":                      domain.RoleScheduler,
+	"POST /api/schedule/validate":             domain.RoleViewer,
+	"GET /api/schedule/:date":                 domain.RoleViewer,
+	"GET /api/schedule/:date/programs/:id":    domain.RoleViewer,
+	"PUT /api/schedule/:date/programs/:id":    domain.RoleScheduler,
+	"PATCH /api/schedule/:date/programs/:id":  domain.RoleScheduler,
+	"DELETE /api/schedule/:date/programs/:id": domain.RoleScheduler,
+	"POST /api/upload-video":                  domain.RoleUploader,
+	"GET /api/readiness":                      domain.RoleViewer,
+	"GET /api/assets":                         domain.RoleViewer,
+	"POST /api/assets":                        domain.RoleUploader,
+	"GET /api/assets/:id":                     domain.RoleViewer,
+	"PATCH /api/assets/:id":                   domain.RoleUploader,
+	"DELETE /api/assets/:id":                  domain.RoleUploader,
+	"GET /api/assets/:id/video.m3u8":          "",
+	"GET /api/ads/:key/video.m3u8":            "",
+	"GET /api/interstitials/asset-list":       "",
+	"POST /api/playback-token":                domain.RoleViewer,
+	"GET /api/recurrences":                    domain.RoleViewer,
+	"POST /api/recurrences":                   domain.RoleScheduler,
+	"GET /api/recurrences/:id":                domain.RoleViewer,
+	"PUT /api/recurrences/:id":                domain.RoleScheduler,
+	"DELETE /api/recurrences/:id":             domain.RoleScheduler,
+	"GET /api/recurrences/:id/occurrences":    domain.RoleViewer,
+	"GET /api/templates":                      domain.RoleViewer,
+	"POST /api/templates":                     domain.RoleScheduler,
+	"GET /api/templates/:id":                  domain.RoleViewer,
+	"PUT /api/templates/:id":                  domain.RoleScheduler,
+	"DELETE /api/templates/:id":               domain.RoleScheduler,
+	"POST /api/templates/:id/apply":           domain.RoleScheduler,
+	"GET /api/fillers":                        domain.RoleViewer,
+	"POST /api/auto-program":                  domain.RoleScheduler,
+	"GET /api/audit":                          domain.RoleAdmin,
+	"GET /static/*filepath":                   "",
+	"HEAD /static/*filepath":                  "",
+}
+
+// newAuthRouter は管理APIの認証のみを設定したルーターを返します。
+// 認証を通過したリクエストは未設定のサービスを参照して失敗するため、500として返します
+func newAuthRouter(authService *service.AuthService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, err any) {
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}))
+	handler.NewHTTPHandler(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, authService, nil).SetupRoutes(router)
+	return router
+}
+
+func TestHTTPHandler_RequireRole(t *testing.T) {
+	keys := map[string][]domain.Role{
+		"viewer-key":    {domain.RoleViewer},
+		"scheduler-key": {domain.RoleScheduler},
+		"uploader-key":  {domain.RoleUploader},
+		"admin-key":     {domain.RoleAdmin},
+	}
+	apiKeys := make([]domain.APIKey, 0, len(keys))
+	for key, roles := range keys {
+		apiKeys = append(apiKeys, domain.APIKey{Name: key, Key: key, Roles: roles})
+	}
+	router := newAuthRouter(service.NewAuthService(service.NewAPIKeyAuthenticator(apiKeys)))
+
+	request := func(method, path string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader("{}"))
+		req.Header.Set("Content-Type", "application/json")
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+	path := strings.NewReplacer(":date", "2025-09-15", ":id", "x", ":key", "x").Replace
+
+	registered := make(map[string]bool)
+	for _, route := range router.Routes() {
+		name := route.Method + " " + route.Path
+		registered[name] = true
+		role, ok := routeRoles[name]
+		if !ok {
+			t.Errorf("%s: 必要なロールがテストに登録されていません", name)
+			continue
+		}
+		if role == "" {
+			continue
+		}
+
+		url := path(route.Path)
+		if recorder := request(route.Method, url, nil); recorder.Code != http.StatusUnauthorized || recorder.Header().Get("WWW-Authenticate") != "Bearer" {
+			t.Errorf("%s: 認証情報なしは401の想定です、実際: %d", name, recorder.Code)
+		}
+		if recorder := request(route.Method, url, map[string]string{"X-API-Key": "wrong"}); recorder.Code != http.StatusUnauthorized {
+			t.Errorf("%s: 不正なキーは401の想定です、実際: %d", name, recorder.Code)
+		}
+		if recorder := request(route.Method, url, map[string]string{"Authorization": "Bearer abc"}); recorder.Code != http.StatusUnauthorized {
+			t.Errorf("%s: 不正なJWTは401の想定です、実際: %d", name, recorder.Code)
+		}
+
+		for key, roles := range keys {
+			allowed := (&domain.Principal{Roles: roles}).HasRole(role)
+			code := request(route.Method, url, map[string]string{"X-API-Key": key}).Code
+			switch {
+			case allowed && (code == http.StatusUnauthorized || code == http.StatusForbidden):
+				t.Errorf("%s: %sは許可する想定です、実際: %d", name, key, code)
+			case !allowed && code != http.StatusForbidden:
+				t.Errorf("%s: %sは403の想定です、実際: %d", name, key, code)
+			}
+		}
+	}
+
+	for name := range routeRoles {
+		if !registered[name] {
+			t.Errorf("%s: ルートが登録されていません", name)
+		}
+	}
+}
+
+func TestHTTPHandler_RequireRoleDisabled(t *testing.T) {
+	router := newAuthRouter(service.NewAuthService())
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/audit", nil))
+	if recorder.Code == http.StatusUnauthorized || recorder.Code == http.StatusForbidden {
+		t.Errorf("認証が無効な場合は認証しない想定です、実際: %d", recorder.Code)
+	}
+}
@@ -0,0 +1,109 @@
+package test
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/genki0524/hls_striming_go/internal/domain"
+)
+
+func TestBuildProgramTimeline_Interstitials(t *testing.T) {
+	program := &domain.ProgramItem{
+		ID:          "news",
+		StartTime:   "2025-09-15T19:00:00+09:00",
+		DurationSec: 30,
+		Interstitials: []domain.Interstitial{
+			{ID: "promo", Offset: 6, AssetID: "asset1", DurationSec: 15},
+			{Offset: 10, Assets: []string{"asset1", "asset2"}},
+		},
+		AdBreaks: []domain.AdBreak{{ID: "cm1", Offset: 18, DurationSec: 30, Interstitial: true}},
+	}
+	timeline := domain.BuildProgramTimeline(program, tenSegmentPlaylist(), domain.TimelineOptions{})
+
+	lines := strings.Join(timeline.SegmentAt(2).Lines(), "\n")
+	expected := `#EXT-X-DATERANGE:ID="promo",CLASS="com.apple.hls.interstitial",START-DATE="2025-09-15T19:00:06.000+09:00",DURATION=15.000,X-ASSET-URI="/api/assets/asset1/video.m3u8"`
+	if !strings.Contains(lines, expected) {
+		t.Errorf("インタースティシャルのEXT-X-DATERANGEが必要です:\n%s", lines)
+	}
+
+	cues := timeline.SegmentAt(4).Cues
+	if len(cues) != 1 || cues[0].ID != "news-interstitial1" || cues[0].AssetList != "/api/interstitials/asset-list?assets=asset1%2Casset2" {
+		t.Errorf("4番目のセグメントにアセットリストのインタースティシャルが必要です: %+v", cues)
+	}
+
+	// インタースティシャルとして再生する広告枠はセグメントを差し替えない
+	if len(timeline.CueOuts()) != 0 {
+		t.Errorf("インタースティシャルの広告枠にCUE-OUTは不要です: %+v", timeline.CueOuts())
+	}
+	ad := timeline.SegmentAt(6).Cues
+	if len(ad) != 1 || ad[0].Type != domain.CueInterstitial || ad[0].Restrict != "SKIP,JUMP" {
+		t.Fatalf("6番目のセグメントに広告枠のインタースティシャルが必要です: %+v", ad)
+	}
+
+	requests := timeline.InterstitialAdRequests()
+	if len(requests) != 1 {
+		t.Fatalf("期待した広告判定のリクエスト数: 1, 実際: %d", len(requests))
+	}
+	if request := requests[0]; request.BreakID != "cm1" || request.ProgramID != "news" || request.DurationSec != 30 || !request.StartDate.Equal(ad[0].StartDate) {
+		t.Errorf("アセットリストのURIから広告枠を復元できません: %+v", request)
+	}
+}
+
+func TestParseAdAssetListQuery(t *testing.T) {
+	request := domain.AdRequest{BreakID: "cm 1", ProgramID: "news", DurationSec: 60, StartDate: time.Unix(1757930418, 0)}
+	assetList, err := url.Parse(domain.AdAssetListURI(request))
+	if err != nil {
+		t.Fatalf("URIの解析に失敗: %v", err)
+	}
+
+	parsed, err := domain.ParseAdAssetListQuery(assetList.Query())
+	if err != nil {
+		t.Fatalf("クエリの解析に失敗: %v", err)
+	}
+	if parsed.BreakID != request.BreakID || parsed.ProgramID != request.ProgramID || parsed.DurationSec != 60 || !parsed.StartDate.Equal(request.StartDate) {
+		t.Errorf("期待: %+v, 実際: %+v", request, parsed)
+	}
+
+	if _, err := domain.ParseAdAssetListQuery(url.Values{"break": {"cm1"}, "start": {"1757930418"}}); !errors.Is(err, domain.ErrInvalidAssetListQuery) {
+		t.Errorf("ErrInvalidAssetListQueryを期待しましたが、実際: %v", err)
+	}
+}
+
+func TestM3U8Playlist_VODContent(t *testing.T) {
+	content := tenSegmentPlaylist().VODContent()
+	for _, expected := range []string{"#EXT-X-PLAYLIST-TYPE:VOD", "#EXTINF:3.0,\nsegment9.ts\n#EXT-X-ENDLIST\n"} {
+		if !strings.Contains(content, expected) {
+			t.Errorf("%qが含まれていません:\n%s", expected, content)
+		}
+	}
+
+	if !domain.IsCreativeKey(domain.CreativeKey("https://cdn.example.com/first.mp4")) || domain.IsCreativeKey("../assets") {
+		t.Error("広告素材のキーの判定が正しくありません")
+	}
+}
+
+func TestValidateSchedule_Interstitials(t *testing.T) {
+	programs := []domain.ProgramItem{
+		{ID: "a", StartTime: "2025-09-15T19:00:00+09:00", DurationSec: 1800, Type: "video", Title: "番組", Interstitials: []domain.Interstitial{
+			{Offset: 600, AssetID: "promo"},
+			{At: "2025-09-15T19:20:00+09:00", Assets: []string{"promo"}},
+			{Offset: 600},
+			{Offset: 600, AssetID: "promo", Assets: []string{"promo"}},
+			{Offset: 600, AssetID: "missing"},
+			{Offset: 1800, AssetID: "promo"},
+		}},
+	}
+
+	report := domain.ValidateSchedule(programs, func(id string) bool { return id == "promo" })
+	if count := violationCodes(report)[domain.ViolationInvalidInterstitial]; count != 4 {
+		t.Errorf("期待した違反の件数: 4, 実際: %d (%+v)", count, report.Violations)
+	}
+	for _, violation := range report.Violations {
+		if violation.Field == "interstitials[0]" || violation.Field == "interstitials[1]" {
+			t.Errorf("正しいインタースティシャルが不正と判定されました: %+v", violation)
+		}
+	}
+}
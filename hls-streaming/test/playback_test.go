@@ -0,0 +1,205 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/genki0524/hls_striming_go/internal/domain"
+	"github.com/genki0524/hls_striming_go/internal/repository"
+	"github.com/genki0524/hls_striming_go/internal/service"
+)
+
+func TestPlaybackTokenService_IssueAndVerify(t *testing.T) {
+	now := time.Date(2025, 9, 15, 10, 0, 0, 0, time.UTC)
+	playback := service.NewPlaybackTokenService("secret", "ch1", 10*time.Minute, domain.FixedClock{Time: now})
+
+	token, expiresAt, err := playback.Issue("viewer-1", "203.0.113.1", false)
+	if err != nil {
+		t.Fatalf("トークンの発行に失敗: %v", err)
+	}
+	if !expiresAt.Equal(now.Add(10 * time.Minute)) {
+		t.Errorf("期待した有効期限: %v, 実際: %v", now.Add(10*time.Minute), expiresAt)
+	}
+	if claims, err := playback.Verify(token, "198.51.100.1"); err != nil || claims.Channel != "ch1" || claims.Subject != "viewer-1" {
+		t.Errorf("IPアドレスを指定しないトークンはどこからでも視聴できる想定です: %+v, %v", claims, err)
+	}
+
+	bound, _, _ := playback.Issue("viewer-1", "203.0.113.1", true)
+	if _, err := playback.Verify(bound, "203.0.113.1"); err != nil {
+		t.Errorf("発行先のIPアドレスからは視聴できる想定です: %v", err)
+	}
+	if _, err := playback.Verify(bound, "198.51.100.1"); !errors.Is(err, domain.ErrInvalidPlaybackToken) {
+		t.Errorf("別のIPアドレスからはErrInvalidPlaybackTokenを期待しましたが、実際: %v", err)
+	}
+
+	expired := service.NewPlaybackTokenService("secret", "ch1", 10*time.Minute, domain.FixedClock{Time: now.Add(10 * time.Minute)})
+	if _, err := expired.Verify(token, ""); !errors.Is(err, domain.ErrPlaybackTokenExpired) {
+		t.Errorf("ErrPlaybackTokenExpiredを期待しましたが、実際: %v", err)
+	}
+
+	if !playback.Enabled() || service.NewPlaybackTokenService("", "ch1", time.Minute, domain.FixedClock{Time: now}).Enabled() {
+		t.Error("秘密鍵が設定されている場合のみ保護する想定です")
+	}
+}
+
+func TestPlaybackTokenSigner_RejectsForgedTokens(t *testing.T) {
+	now := time.Date(2025, 9, 15, 10, 0, 0, 0, time.UTC)
+	signer := domain.PlaybackTokenSigner{Secret: []byte("secret")}
+	token, _ := signer.Sign(domain.PlaybackClaims{Channel: "ch1", ExpiresAt: now.Add(time.Minute).Unix()})
+
+	payload, signature, _ := strings.Cut(token, ".")
+	other, _ := signer.Sign(domain.PlaybackClaims{Channel: "ch2", ExpiresAt: now.Add(time.Minute).Unix()})
+	otherPayload, _, _ := strings.Cut(other, ".")
+
+	for name, forged := range map[string]string{
+		"署名なし":    payload,
+		"内容の改ざん":  otherPayload + "." + signature,
+		"別の秘密鍵":   mustSign(t, domain.PlaybackTokenSigner{Secret: []byte("other")}, "ch1", now),
+		"別のチャンネル": other,
+		"不正な形式":   "abc.def",
+	} {
+		if _, err := signer.Verify(forged, "ch1", "", now); !errors.Is(err, domain.ErrInvalidPlaybackToken) {
+			t.Errorf("%s: ErrInvalidPlaybackTokenを期待しましたが、実際: %v", name, err)
+		}
+	}
+}
+
+func mustSign(t *testing.T, signer domain.PlaybackTokenSigner, channel string, now time.Time) string {
+	t.Helper()
+	token, err := signer.Sign(domain.PlaybackClaims{Channel: channel, ExpiresAt: now.Add(time.Minute).Unix()})
+	if err != nil {
+		t.Fatalf("トークンの署名に失敗: %v", err)
+	}
+	return token
+}
+
+func TestSessionService_VerifyPlayback(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 9, 15, 10, 0, 0, 0, time.UTC)
+	sessionService := service.NewSessionService(repository.NewInMemorySessionStore(), repository.NewInMemorySessionViewerStore(), 10*time.Minute, domain.FixedClock{Time: now})
+	exp := now.Add(30 * time.Minute).Unix()
+
+	unauthorized, _, _ := sessionService.Resume(ctx, "", nil)
+	if err := sessionService.VerifyPlayback(ctx, unauthorized.ID, "ch1", "203.0.113.1"); !errors.Is(err, domain.ErrInvalidPlaybackToken) {
+		t.Errorf("トークンなしで開始したセッションは再生を許可しない想定です: %v", err)
+	}
+
+	bound, _, _ := sessionService.Resume(ctx, "", &domain.PlaybackClaims{Channel: "ch1", ExpiresAt: exp, IP: "203.0.113.1"})
+	if err := sessionService.VerifyPlayback(ctx, bound.ID, "ch1", "203.0.113.1"); err != nil {
+		t.Errorf("トークンで開始したセッションは再生を許可する想定です: %v", err)
+	}
+	if err := sessionService.VerifyPlayback(ctx, bound.ID, "ch1", "198.51.100.1"); !errors.Is(err, domain.ErrInvalidPlaybackToken) {
+		t.Errorf("トークンで指定されたIPアドレス以外からは許可しない想定です: %v", err)
+	}
+	if err := sessionService.VerifyPlayback(ctx, bound.ID, "ch2", "203.0.113.1"); !errors.Is(err, domain.ErrInvalidPlaybackToken) {
+		t.Errorf("トークンと別のチャンネルでは許可しない想定です: %v", err)
+	}
+
+	// 既存のセッションもトークンを指定すれば再生を許可する
+	if _, created, _ := sessionService.Resume(ctx, unauthorized.ID, &domain.PlaybackClaims{Channel: "ch1", ExpiresAt: exp}); created {
+		t.Error("有効なセッションは引き続き使用する想定です")
+	}
+	if err := sessionService.VerifyPlayback(ctx, unauthorized.ID, "ch1", "198.51.100.1"); err != nil {
+		t.Errorf("トークンを指定したセッションは再生を許可する想定です: %v", err)
+	}
+
+	if err := sessionService.VerifyPlayback(ctx, "../sessions", "ch1", ""); !errors.Is(err, domain.ErrSessionNotFound) {
+		t.Errorf("ErrSessionNotFoundを期待しましたが、実際: %v", err)
+	}
+}
+
+func TestSessionService_PlaybackEndsWithToken(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 9, 15, 10, 0, 0, 0, time.UTC)
+	store := repository.NewInMemorySessionStore()
+	viewers := repository.NewInMemorySessionViewerStore()
+	at := func(elapsed time.Duration) *service.SessionService {
+		return service.NewSessionService(store, viewers, 10*time.Minute, domain.FixedClock{Time: now.Add(elapsed)})
+	}
+	claims := &domain.PlaybackClaims{Subject: "viewer-1", Channel: "ch1", ExpiresAt: now.Add(15 * time.Minute).Unix()}
+
+	session, _, _ := at(0).Resume(ctx, "", claims)
+	// トークンの有効期限が切れるまでプレイリストを取得し続け、セッションを延長する
+	for elapsed := time.Minute; elapsed < 15*time.Minute; elapsed += time.Minute {
+		current, _ := store.GetSession(ctx, session.ID)
+		original := *current
+		current.ProgramKey = fmt.Sprintf("p%d", elapsed/time.Minute)
+		if err := at(elapsed).Commit(ctx, original, current); err != nil {
+			t.Fatalf("セッションの保存に失敗: %v", err)
+		}
+		if current.ExpiresAt.After(time.Unix(claims.ExpiresAt, 0)) {
+			t.Fatalf("セッションの有効期限はトークンの有効期限を超えない想定です: %v", current.ExpiresAt)
+		}
+	}
+
+	if err := at(15*time.Minute).VerifyPlayback(ctx, session.ID, "ch1", ""); !errors.Is(err, domain.ErrInvalidPlaybackToken) {
+		t.Errorf("トークンの有効期限後はsidだけでは再生を許可しない想定です: %v", err)
+	}
+	if renewed, created, _ := at(15*time.Minute).Resume(ctx, session.ID, nil); !created || renewed.PlaybackAuthorized {
+		t.Errorf("トークンの有効期限後のセッションは引き継がない想定です: %+v", renewed)
+	}
+
+	// 有効期限の前に新しいトークンを指定すれば同じセッションのまま延長できる
+	refreshed, _, _ := at(0).Resume(ctx, "", claims)
+	fresh := &domain.PlaybackClaims{Subject: "viewer-1", Channel: "ch1", ExpiresAt: now.Add(40 * time.Minute).Unix()}
+	if resumed, created, _ := at(8*time.Minute).Resume(ctx, refreshed.ID, fresh); created || !resumed.ExpiresAt.Equal(now.Add(18*time.Minute)) {
+		t.Errorf("新しいトークンでセッションを延長する想定です: %+v, %v", resumed, created)
+	}
+	if err := at(17*time.Minute).VerifyPlayback(ctx, refreshed.ID, "ch1", ""); err != nil {
+		t.Errorf("新しいトークンで許可したセッションは再生を許可する想定です: %v", err)
+	}
+}
+
+func TestSessionService_KeepsViewerAfterCleanup(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 9, 15, 10, 0, 0, 0, time.UTC)
+	store := repository.NewInMemorySessionStore()
+	sessionService := service.NewSessionService(store, repository.NewInMemorySessionViewerStore(), 10*time.Minute, domain.FixedClock{Time: now})
+
+	exp := now.Add(30 * time.Minute).Unix()
+
+	session, _, err := sessionService.Resume(ctx, "", &domain.PlaybackClaims{Subject: "viewer-1", Channel: "ch1", ExpiresAt: exp})
+	if err != nil {
+		t.Fatalf("セッションの作成に失敗: %v", err)
+	}
+	if session.Viewer != "viewer-1" {
+		t.Errorf("セッションに視聴者を記録する想定です: %q", session.Viewer)
+	}
+	// 別の視聴者のトークンで同じセッションを再生した場合も記録する
+	sessionService.Resume(ctx, session.ID, &domain.PlaybackClaims{Subject: "viewer-2", Channel: "ch1", ExpiresAt: exp})
+
+	if count, _ := store.DeleteExpiredSessions(ctx, now.Add(time.Hour)); count != 1 {
+		t.Fatalf("期限切れのセッションを削除する想定です: %d件", count)
+	}
+	viewers, err := sessionService.SessionViewers(ctx, session.ID)
+	if err != nil {
+		t.Fatalf("視聴者の取得に失敗: %v", err)
+	}
+	if len(viewers) != 2 || viewers[0].Viewer != "viewer-1" || viewers[1].Viewer != "viewer-2" || viewers[0].Channel != "ch1" {
+		t.Errorf("セッションの削除後も視聴者の記録を保持する想定です: %+v", viewers)
+	}
+}
+
+func TestM3U8Playlist_ForSessionAddsSessionToPromos(t *testing.T) {
+	program := &domain.ProgramItem{
+		ID:            "news",
+		StartTime:     "2025-09-15T19:00:00+09:00",
+		DurationSec:   30,
+		Interstitials: []domain.Interstitial{{ID: "promo", Offset: 6, AssetID: "asset1"}, {Offset: 12, Assets: []string{"asset1", "asset2"}}},
+	}
+	timeline := domain.BuildProgramTimeline(program, tenSegmentPlaylist(), domain.TimelineOptions{}).ForSession("abc")
+
+	assetURI, _ := url.Parse(timeline.SegmentAt(2).Cues[0].AssetURI)
+	assetList, _ := url.Parse(timeline.SegmentAt(4).Cues[0].AssetList)
+	if assetURI.Path != "/api/assets/asset1/video.m3u8" || assetURI.Query().Get("sid") != "abc" {
+		t.Errorf("アセットのURIにセッションIDが必要です: %s", assetURI)
+	}
+	if assetList.Query().Get("assets") != "asset1,asset2" || assetList.Query().Get("sid") != "abc" {
+		t.Errorf("アセットリストのURIにセッションIDが必要です: %s", assetList)
+	}
+}
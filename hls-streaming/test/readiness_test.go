@@ -0,0 +1,56 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/genki0524/hls_striming_go/internal/domain"
+)
+
+func TestEvaluateMediaReadiness(t *testing.T) {
+	playlist := &domain.M3U8Playlist{
+		Segments: []domain.M3U8Segment{
+			{Duration: 2.0, Filename: "video000.ts"},
+			{Duration: 2.0, Filename: "video001.ts"},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		missing     []string
+		durationSec int32
+		want        domain.ReadinessStatus
+	}{
+		{"準備完了", nil, 4, domain.ReadinessReady},
+		{"セグメント欠落", []string{"video001.ts"}, 4, domain.ReadinessMissingSegments},
+		{"素材不足", nil, 5, domain.ReadinessShortMedia},
+	}
+
+	for _, tt := range tests {
+		status, duration := domain.EvaluateMediaReadiness(playlist, tt.missing, tt.durationSec)
+		if status != tt.want {
+			t.Errorf("%s: 期待した状態: %s, 実際: %s", tt.name, tt.want, status)
+		}
+		if duration != 4.0 {
+			t.Errorf("%s: 期待した素材の長さ: 4.0, 実際: %f", tt.name, duration)
+		}
+	}
+}
+
+func TestProgramsInWindow(t *testing.T) {
+	jst := time.FixedZone("JST", 9*60*60)
+	schedule := []domain.ProgramItem{
+		{StartTime: "2025-09-15T17:00:00+09:00", DurationSec: 1800, Title: "終了済み"},
+		{StartTime: "2025-09-15T17:50:00+09:00", DurationSec: 1800, Title: "放送中"},
+		{StartTime: "2025-09-15T20:00:00+09:00", DurationSec: 1800, Title: "期間内"},
+		{StartTime: "2025-09-16T20:00:00+09:00", DurationSec: 1800, Title: "期間外"},
+		{StartTime: "invalid", DurationSec: 1800, Title: "不正"},
+	}
+
+	from := time.Date(2025, 9, 15, 18, 0, 0, 0, jst)
+	programs := domain.ProgramsInWindow(schedule, from, from.Add(24*time.Hour))
+
+	if len(programs) != 2 || programs[0].Title != "放送中" || programs[1].Title != "期間内" {
+		t.Errorf("期間内の番組が正しく抽出されていません: %+v", programs)
+	}
+}
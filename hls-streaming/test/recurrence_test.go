@@ -0,0 +1,292 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/genki0524/hls_striming_go/internal/domain"
+	"github.com/genki0524/hls_striming_go/internal/repository"
+	"github.com/genki0524/hls_striming_go/internal/service"
+)
+
+func weekdayNews() domain.RecurringProgram {
+	return domain.RecurringProgram{
+		ID:          "news",
+		Title:       "ニュース",
+		Type:        domain.ProgramTypeVideo,
+		DurationSec: 1800,
+		StartDate:   "2025-01-06", // 月曜日
+		StartTime:   "19:00",
+		RRule:       "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR",
+	}
+}
+
+func occurrenceDates(programs []domain.ProgramItem) []string {
+	dates := make([]string, 0, len(programs))
+	for _, program := range programs {
+		dates = append(dates, program.ScheduleDate)
+	}
+	return dates
+}
+
+func assertDates(t *testing.T, actual, expected []string) {
+	t.Helper()
+	if len(actual) != len(expected) {
+		t.Fatalf("期待した回数: %d, 実際: %d (%v)", len(expected), len(actual), actual)
+	}
+	for i := range expected {
+		if actual[i] != expected[i] {
+			t.Errorf("期待した日付: %s, 実際: %s", expected[i], actual[i])
+		}
+	}
+}
+
+func TestRecurringProgram_ExpandWeekdays(t *testing.T) {
+	recurrence := weekdayNews()
+
+	programs, err := recurrence.Expand("2025-01-10", "2025-01-14", time.UTC)
+	if err != nil {
+		t.Fatalf("展開に失敗: %v", err)
+	}
+
+	assertDates(t, occurrenceDates(programs), []string{"2025-01-10", "2025-01-13", "2025-01-14"})
+	if programs[0].ID != "news@2025-01-10" {
+		t.Errorf("期待したID: news@2025-01-10, 実際: %s", programs[0].ID)
+	}
+	if programs[0].RecurrenceID != "news" {
+		t.Errorf("期待したRecurrenceID: news, 実際: %s", programs[0].RecurrenceID)
+	}
+	if programs[0].StartTime != "2025-01-10T19:00:00Z" {
+		t.Errorf("期待した開始時刻: 2025-01-10T19:00:00Z, 実際: %s", programs[0].StartTime)
+	}
+}
+
+func TestRecurringProgram_ExDatesAndOverrides(t *testing.T) {
+	recurrence := weekdayNews()
+	recurrence.ExDates = []string{"2025-01-07"}
+	recurrence.Overrides = []domain.OccurrenceOverride{
+		{Date: "2025-01-08", StartTime: "20:00", Title: "特別編"},
+		{Date: "2025-01-09", Cancelled: true},
+	}
+
+	programs, err := recurrence.Expand("2025-01-06", "2025-01-10", time.UTC)
+	if err != nil {
+		t.Fatalf("展開に失敗: %v", err)
+	}
+
+	assertDates(t, occurrenceDates(programs), []string{"2025-01-06", "2025-01-08", "2025-01-10"})
+	if programs[1].Title != "特別編" || programs[1].StartTime != "2025-01-08T20:00:00Z" {
+		t.Errorf("上書きが反映されていません: %+v", programs[1])
+	}
+}
+
+func TestRecurringProgram_CountAndUntil(t *testing.T) {
+	recurrence := weekdayNews()
+	recurrence.RRule = "FREQ=DAILY;INTERVAL=2;COUNT=3"
+
+	programs, err := recurrence.Expand("2025-01-01", "2025-01-31", time.UTC)
+	if err != nil {
+		t.Fatalf("展開に失敗: %v", err)
+	}
+	assertDates(t, occurrenceDates(programs), []string{"2025-01-06", "2025-01-08", "2025-01-10"})
+
+	// COUNTは期間より前の回も数える
+	programs, err = recurrence.Expand("2025-01-09", "2025-01-31", time.UTC)
+	if err != nil {
+		t.Fatalf("展開に失敗: %v", err)
+	}
+	assertDates(t, occurrenceDates(programs), []string{"2025-01-10"})
+
+	recurrence.RRule = "FREQ=WEEKLY;BYDAY=MO;UNTIL=20250120"
+	programs, err = recurrence.Expand("2025-01-01", "2025-01-31", time.UTC)
+	if err != nil {
+		t.Fatalf("展開に失敗: %v", err)
+	}
+	assertDates(t, occurrenceDates(programs), []string{"2025-01-06", "2025-01-13", "2025-01-20"})
+}
+
+func TestRecurringProgram_KeepsLocalTimeAcrossDST(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Fatalf("タイムゾーンの読み込みに失敗: %v", err)
+	}
+
+	recurrence := weekdayNews()
+	recurrence.StartDate = "2025-03-29"
+	recurrence.RRule = "FREQ=DAILY"
+
+	programs, err := recurrence.Expand("2025-03-29", "2025-03-30", london)
+	if err != nil {
+		t.Fatalf("展開に失敗: %v", err)
+	}
+
+	// 夏時間への切り替え前後でも現地時刻19:00を維持する
+	expected := []string{"2025-03-29T19:00:00Z", "2025-03-30T19:00:00+01:00"}
+	for i, program := range programs {
+		if program.StartTime != expected[i] {
+			t.Errorf("期待した開始時刻: %s, 実際: %s", expected[i], program.StartTime)
+		}
+	}
+}
+
+func TestParseRRule_Invalid(t *testing.T) {
+	for _, rrule := range []string{"", "FREQ=YEARLY", "FREQ=WEEKLY;BYDAY=XX", "FREQ=DAILY;INTERVAL=0"} {
+		if _, err := domain.ParseRRule(rrule); err == nil {
+			t.Errorf("不正なRRULEがエラーになりません: %q", rrule)
+		}
+	}
+}
+
+func TestScheduleService_RecurrenceInWindowAndOverlap(t *testing.T) {
+	ctx := context.Background()
+	clock := domain.FixedClock{Time: time.Date(2025, 1, 8, 12, 0, 0, 0, time.UTC)}
+	scheduleService := service.NewScheduleService(repository.NewInMemoryScheduleRepository(), nil, repository.NewInMemoryRecurrenceRepository(), time.UTC, clock)
+
+	if _, err := scheduleService.CreateRecurrence(ctx, domain.RecurringProgram{RRule: "FREQ=WEEKLY"}, nil); !errors.Is(err, domain.ErrInvalidRecurrence) {
+		t.Fatalf("不正な繰り返し番組がエラーになりません: %v", err)
+	}
+
+	if _, err := scheduleService.CreateRecurrence(ctx, weekdayNews(), nil); err != nil {
+		t.Fatalf("繰り返し番組の登録に失敗: %v", err)
+	}
+
+	// 前日・当日・翌日の3回分が番組表に展開される
+	if programs := scheduleService.GetSchedule(); len(programs) != 3 {
+		t.Fatalf("期待した番組数: 3, 実際: %d", len(programs))
+	}
+
+	_, _, err := scheduleService.AddProgramToSchedule(ctx, domain.RequestProgramItem{
+		StartTime:   "2025-01-08T19:10:00Z",
+		DurationSec: 600,
+		Type:        domain.ProgramTypeVideo,
+		Title:       "重複番組",
+	}, "2025-01-08", domain.AnyVersion, nil)
+	var validationErr *domain.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("繰り返し番組との重複が検出されません: %v", err)
+	}
+
+	// 展開された放送回は番組として変更できず、元の繰り返し番組を案内する
+	var occurrenceErr *domain.OccurrenceError
+	if _, _, err := scheduleService.DeleteProgram(ctx, "2025-01-08", "news@2025-01-08", domain.AnyVersion, nil); !errors.As(err, &occurrenceErr) || occurrenceErr.RecurrenceID != "news" {
+		t.Errorf("放送回の削除でOccurrenceErrorを期待しましたが、実際: %v", err)
+	}
+}
+
+func TestScheduleService_RecurrenceOverlapsDaySchedule(t *testing.T) {
+	ctx := context.Background()
+	clock := domain.FixedClock{Time: time.Date(2025, 1, 8, 12, 0, 0, 0, time.UTC)}
+	scheduleService := service.NewScheduleService(repository.NewInMemoryScheduleRepository(), nil, repository.NewInMemoryRecurrenceRepository(), time.UTC, clock)
+
+	// 2週間後の番組表に登録済みの番組と重なる
+	special, _, err := scheduleService.AddProgramToSchedule(ctx, domain.RequestProgramItem{
+		StartTime:   "2025-01-22T19:15:00Z",
+		DurationSec: 3600,
+		Type:        domain.ProgramTypeVideo,
+		Title:       "特番",
+	}, "2025-01-22", domain.AnyVersion, nil)
+	if err != nil {
+		t.Fatalf("番組の追加に失敗: %v", err)
+	}
+
+	_, err = scheduleService.CreateRecurrence(ctx, weekdayNews(), nil)
+	var validationErr *domain.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("番組表との重複が検出されません: %v", err)
+	}
+	if len(validationErr.Violations) != 1 || validationErr.Violations[0].RelatedID != special.ID && validationErr.Violations[0].ProgramID != special.ID {
+		t.Errorf("特番との重複を期待しました: %+v", validationErr.Violations)
+	}
+	if recurrences, _ := scheduleService.ListRecurrences(ctx); len(recurrences) != 0 {
+		t.Errorf("重複する繰り返し番組は保存しない想定です: %+v", recurrences)
+	}
+
+	// 重なる日を除外すれば登録できる
+	recurrence := weekdayNews()
+	recurrence.ExDates = []string{"2025-01-22"}
+	created, err := scheduleService.CreateRecurrence(ctx, recurrence, nil)
+	if err != nil {
+		t.Fatalf("繰り返し番組の登録に失敗: %v", err)
+	}
+
+	// 更新時は保存済みの自身の放送回とは重複とみなさない
+	created.Title = "夜のニュース"
+	if _, err := scheduleService.UpdateRecurrence(ctx, created.ID, *created, nil); err != nil {
+		t.Errorf("繰り返し番組の更新に失敗: %v", err)
+	}
+}
+
+func TestRecurringProgram_MonthlyByDayAndMonthDay(t *testing.T) {
+	recurrence := weekdayNews()
+	recurrence.StartDate = "2025-01-01"
+	// 13日の金曜日のみ（BYDAYとBYMONTHDAYの両方に一致する日）
+	recurrence.RRule = "FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13"
+
+	programs, err := recurrence.Expand("2025-01-01", "2025-12-31", time.UTC)
+	if err != nil {
+		t.Fatalf("展開に失敗: %v", err)
+	}
+	assertDates(t, occurrenceDates(programs), []string{"2025-06-13"})
+}
+
+func TestCheckOccurrenceID(t *testing.T) {
+	var occurrenceErr *domain.OccurrenceError
+	if err := domain.CheckOccurrenceID("news@2025-01-08"); !errors.As(err, &occurrenceErr) || occurrenceErr.RecurrenceID != "news" {
+		t.Errorf("放送回のIDでOccurrenceErrorを期待しましたが、実際: %v", err)
+	}
+	for _, id := range []string{"program-1", "user@example.com", "@2025-01-08", "news@2025-1-8", "news@2025-01-08x"} {
+		if err := domain.CheckOccurrenceID(id); err != nil {
+			t.Errorf("放送回の形式でないID(%s)がエラーになりました: %v", id, err)
+		}
+	}
+}
+
+func TestScheduleService_RecurrenceChecksWholeWindow(t *testing.T) {
+	ctx := context.Background()
+	clock := domain.FixedClock{Time: time.Date(2025, 1, 8, 12, 0, 0, 0, time.UTC)}
+	scheduleService := service.NewScheduleService(repository.NewInMemoryScheduleRepository(), nil, repository.NewInMemoryRecurrenceRepository(), time.UTC, clock)
+
+	// 30回目より後の放送回と重なる番組
+	if _, _, err := scheduleService.AddProgramToSchedule(ctx, domain.RequestProgramItem{
+		StartTime:   "2025-06-02T19:15:00Z",
+		DurationSec: 600,
+		Type:        domain.ProgramTypeVideo,
+		Title:       "特番",
+	}, "2025-06-02", domain.AnyVersion, nil); err != nil {
+		t.Fatalf("番組の追加に失敗: %v", err)
+	}
+
+	_, err := scheduleService.CreateRecurrence(ctx, weekdayNews(), nil)
+	var validationErr *domain.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("検証期間内の後半の放送回との重複が検出されません: %v", err)
+	}
+}
+
+func TestScheduleService_ProgramOverlapsPreviousDayOccurrence(t *testing.T) {
+	ctx := context.Background()
+	clock := domain.FixedClock{Time: time.Date(2025, 1, 8, 12, 0, 0, 0, time.UTC)}
+	scheduleService := service.NewScheduleService(repository.NewInMemoryScheduleRepository(), nil, repository.NewInMemoryRecurrenceRepository(), time.UTC, clock)
+
+	recurrence := weekdayNews()
+	recurrence.StartTime = "23:30"
+	recurrence.DurationSec = 3600
+	if _, err := scheduleService.CreateRecurrence(ctx, recurrence, nil); err != nil {
+		t.Fatalf("繰り返し番組の登録に失敗: %v", err)
+	}
+
+	// 前日23:30からの放送回が日付をまたいで続いている
+	_, _, err := scheduleService.AddProgramToSchedule(ctx, domain.RequestProgramItem{
+		StartTime:   "2025-01-09T00:10:00Z",
+		DurationSec: 600,
+		Type:        domain.ProgramTypeVideo,
+		Title:       "深夜番組",
+	}, "2025-01-09", domain.AnyVersion, nil)
+	var validationErr *domain.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("前日の放送回との重複が検出されません: %v", err)
+	}
+}
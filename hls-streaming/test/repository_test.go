@@ -75,7 +75,7 @@ func TestGCSRepository_GetM3U8WithSignedURLs(t *testing.T) {
 	date := "2025-09-09"
 	programName := "minecraft_1"
 
-	playlist, err := repo.GetM3U8WithSignedURLs(bucket, date, programName)
+	playlist, err := repo.GetM3U8WithSignedURLs(ctx, bucket, date, programName)
 	if err != nil {
 		t.Logf("M3U8取得テストをスキップ（認証エラーまたはネットワークエラーの可能性）: %v", err)
 		return
@@ -0,0 +1,221 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/genki0524/hls_striming_go/internal/domain"
+	"github.com/genki0524/hls_striming_go/internal/repository"
+	"github.com/genki0524/hls_striming_go/internal/service"
+)
+
+func TestScheduleService_ProgramCRUD(t *testing.T) {
+	ctx := context.Background()
+	scheduleService := service.NewScheduleService(repository.NewInMemoryScheduleRepository(), nil, nil, time.UTC, domain.SystemClock{})
+	date := "2025-09-15"
+
+	created, version, err := scheduleService.AddProgramToSchedule(ctx, domain.RequestProgramItem{
+		StartTime:   "2025-09-15T19:00:00+09:00",
+		DurationSec: 1800,
+		Type:        "video",
+		Title:       "ニュース",
+	}, date, domain.AnyVersion, nil)
+	if err != nil {
+		t.Fatalf("番組の追加に失敗: %v", err)
+	}
+	if created.ID == "" {
+		t.Fatal("追加した番組にIDが割り当てられていません")
+	}
+
+	newTitle := "夜のニュース"
+	patched, previous, version, err := scheduleService.PatchProgram(ctx, date, created.ID, domain.ProgramPatch{Title: &newTitle}, version, nil)
+	if err != nil {
+		t.Fatalf("番組の部分更新に失敗: %v", err)
+	}
+	if patched.Title != newTitle || patched.DurationSec != 1800 {
+		t.Errorf("部分更新の結果が不正です: %+v", patched)
+	}
+	if previous.Title != "ニュース" {
+		t.Errorf("更新前の番組が返されていません: %+v", previous)
+	}
+
+	replaced, previous, version, err := scheduleService.ReplaceProgram(ctx, date, created.ID, domain.RequestProgramItem{
+		StartTime:   "2025-09-15T20:00:00+09:00",
+		DurationSec: 600,
+		Type:        "video",
+		Title:       "天気予報",
+	}, version, nil)
+	if err != nil {
+		t.Fatalf("番組の置き換えに失敗: %v", err)
+	}
+	if replaced.ID != created.ID || replaced.Title != "天気予報" {
+		t.Errorf("置き換えの結果が不正です: %+v", replaced)
+	}
+	if previous.Title != newTitle {
+		t.Errorf("置き換え前の番組が返されていません: %+v", previous)
+	}
+
+	deleted, _, err := scheduleService.DeleteProgram(ctx, date, created.ID, version, nil)
+	if err != nil {
+		t.Fatalf("番組の削除に失敗: %v", err)
+	}
+	if deleted.Title != "天気予報" {
+		t.Errorf("削除した番組が返されていません: %+v", deleted)
+	}
+
+	if _, _, err := scheduleService.GetProgram(ctx, date, created.ID); !errors.Is(err, domain.ErrProgramNotFound) {
+		t.Errorf("削除後の取得でErrProgramNotFoundを期待しましたが、実際: %v", err)
+	}
+}
+
+func TestScheduleService_UnknownProgram(t *testing.T) {
+	ctx := context.Background()
+	scheduleService := service.NewScheduleService(repository.NewInMemoryScheduleRepository(), nil, nil, time.UTC, domain.SystemClock{})
+
+	if _, _, err := scheduleService.DeleteProgram(ctx, "2025-09-15", "missing", domain.AnyVersion, nil); !errors.Is(err, domain.ErrProgramNotFound) {
+		t.Errorf("存在しない番組の削除でErrProgramNotFoundを期待しましたが、実際: %v", err)
+	}
+}
+
+func TestScheduleService_VersionConflict(t *testing.T) {
+	ctx := context.Background()
+	scheduleService := service.NewScheduleService(repository.NewInMemoryScheduleRepository(), nil, nil, time.UTC, domain.SystemClock{})
+	date := "2025-09-15"
+	request := domain.RequestProgramItem{
+		StartTime:   "2025-09-15T19:00:00+09:00",
+		DurationSec: 1800,
+		Type:        "video",
+		Title:       "ニュース",
+	}
+
+	created, version, err := scheduleService.AddProgramToSchedule(ctx, request, date, 0, nil)
+	if err != nil {
+		t.Fatalf("番組の追加に失敗: %v", err)
+	}
+	if version != 1 {
+		t.Errorf("期待したバージョン: 1, 実際: %d", version)
+	}
+
+	// 古いバージョンを指定した更新は拒否される
+	if _, _, err := scheduleService.AddProgramToSchedule(ctx, request, date, 0, nil); !errors.Is(err, domain.ErrVersionConflict) {
+		t.Errorf("ErrVersionConflictを期待しましたが、実際: %v", err)
+	}
+	if _, _, err := scheduleService.DeleteProgram(ctx, date, created.ID, version+1, nil); !errors.Is(err, domain.ErrVersionConflict) {
+		t.Errorf("ErrVersionConflictを期待しましたが、実際: %v", err)
+	}
+}
+
+func TestScheduleService_ConcurrentAdds(t *testing.T) {
+	ctx := context.Background()
+	scheduleService := service.NewScheduleService(repository.NewInMemoryScheduleRepository(), nil, nil, time.UTC, domain.SystemClock{})
+	date := "2025-09-15"
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(minute int) {
+			defer wg.Done()
+			_, _, err := scheduleService.AddProgramToSchedule(ctx, domain.RequestProgramItem{
+				StartTime:   fmt.Sprintf("2025-09-15T19:%02d:00+09:00", minute),
+				DurationSec: 60,
+				Type:        "video",
+				Title:       "番組",
+			}, date, domain.AnyVersion, nil)
+			if err != nil {
+				t.Errorf("番組の追加に失敗: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	schedule, err := scheduleService.GetScheduleByDate(ctx, date)
+	if err != nil {
+		t.Fatalf("番組表の取得に失敗: %v", err)
+	}
+	if len(schedule.Programs) != 20 || schedule.Version != 20 {
+		t.Errorf("期待した番組数/バージョン: 20/20, 実際: %d/%d", len(schedule.Programs), schedule.Version)
+	}
+}
+
+// legacyScheduleRepository はIDの導入前に保存された番組表を返すリポジトリです
+type legacyScheduleRepository struct {
+	schedule domain.Schedule
+}
+
+func (r *legacyScheduleRepository) GetScheduleByDate(ctx context.Context, date string) (*domain.Schedule, error) {
+	schedule := r.schedule
+	schedule.Programs = append([]domain.ProgramItem(nil), r.schedule.Programs...)
+	return &schedule, nil
+}
+
+func (r *legacyScheduleRepository) UpdateScheduleByDate(ctx context.Context, date string, expectedVersion int64, mutate func(schedule *domain.Schedule) error) (*domain.Schedule, error) {
+	schedule, _ := r.GetScheduleByDate(ctx, date)
+	schedule.EnsureProgramIDs()
+	if err := mutate(schedule); err != nil {
+		return nil, err
+	}
+	schedule.Version++
+	r.schedule = *schedule
+	return r.GetScheduleByDate(ctx, date)
+}
+
+func TestScheduleService_BackfillsLegacyProgramIDs(t *testing.T) {
+	ctx := context.Background()
+	repo := &legacyScheduleRepository{schedule: domain.Schedule{Programs: []domain.ProgramItem{
+		{StartTime: "2025-09-15T19:00:00+09:00", DurationSec: 1800, Type: "video", Title: "ニュース"},
+	}}}
+	scheduleService := service.NewScheduleService(repo, nil, nil, time.UTC, domain.SystemClock{})
+
+	schedule, err := scheduleService.GetScheduleByDate(ctx, "2025-09-15")
+	if err != nil {
+		t.Fatalf("番組表の取得に失敗: %v", err)
+	}
+	id := schedule.Programs[0].ID
+	if id == "" {
+		t.Fatal("読み込み時にIDが割り当てられていません")
+	}
+
+	program, _, err := scheduleService.GetProgram(ctx, "2025-09-15", id)
+	if err != nil || program.Title != "ニュース" {
+		t.Errorf("割り当てたIDで番組を取得できません: %v", err)
+	}
+	if repo.schedule.Programs[0].ID != id {
+		t.Errorf("割り当てたIDが保存されていません: %q", repo.schedule.Programs[0].ID)
+	}
+}
+
+// unreadableScheduleRepository は番組表の読み込みに失敗するリポジトリです
+type unreadableScheduleRepository struct {
+	*repository.InMemoryScheduleRepository
+}
+
+func (r *unreadableScheduleRepository) GetScheduleByDate(ctx context.Context, date string) (*domain.Schedule, error) {
+	return nil, errors.New("読み込みに失敗しました")
+}
+
+func TestScheduleService_AddSucceedsWhenRefreshFails(t *testing.T) {
+	ctx := context.Background()
+	inner := repository.NewInMemoryScheduleRepository()
+	scheduleService := service.NewScheduleService(&unreadableScheduleRepository{InMemoryScheduleRepository: inner}, nil, nil, time.UTC, domain.SystemClock{})
+	date := "2025-09-15"
+
+	created, version, err := scheduleService.AddProgramToSchedule(ctx, domain.RequestProgramItem{
+		StartTime:   "2025-09-15T19:00:00+09:00",
+		DurationSec: 1800,
+		Type:        "video",
+		Title:       "ニュース",
+	}, date, domain.AnyVersion, nil)
+	if err != nil {
+		t.Fatalf("保存済みの追加がエラーになりました: %v", err)
+	}
+	if created == nil || version != 1 {
+		t.Fatalf("保存した番組とバージョン1を期待しました: %+v, %d", created, version)
+	}
+	if schedule, _ := inner.GetScheduleByDate(ctx, date); schedule == nil || len(schedule.Programs) != 1 {
+		t.Errorf("番組が保存されていません: %+v", schedule)
+	}
+}
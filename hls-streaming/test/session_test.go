@@ -0,0 +1,131 @@
+package test
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/genki0524/hls_striming_go/internal/domain"
+	"github.com/genki0524/hls_striming_go/internal/repository"
+	"github.com/genki0524/hls_striming_go/internal/service"
+)
+
+func TestSession_AdvanceKeepsSequenceAcrossPrograms(t *testing.T) {
+	programA := &domain.ProgramItem{ID: "a", StartTime: "2025-09-15T19:00:00+09:00", DurationSec: 30}
+	programB := &domain.ProgramItem{ID: "b", StartTime: "2025-09-15T19:00:30+09:00", DurationSec: 30}
+	programC := &domain.ProgramItem{ID: "c", StartTime: "2025-09-15T19:01:00+09:00", DurationSec: 30}
+	programD := &domain.ProgramItem{ID: "d", StartTime: "2025-09-15T19:01:00+09:00", DurationSec: 30}
+	playlist := tenSegmentPlaylist()
+
+	session := domain.NewSession(time.Now(), time.Minute)
+	steps := []struct {
+		current, next           *domain.ProgramItem
+		startIndex              int
+		sequence, discontinuity int
+	}{
+		// 新しいセッションは番組内のインデックスをそのまま使う
+		{programA, programB, 5, 5, 0},
+		// 予定通りの切り替えでは、Aの末尾につなげたBと同じ番号から続ける
+		{programB, programC, 0, 10, 1},
+		{programB, programC, 2, 12, 1},
+		// 予定外の番組（番組表の変更など）では、つなげた分より後の番号から続ける
+		{programD, nil, 3, 10 + 10 + domain.PlaylistLength, 2 + domain.PlaylistLength},
+		{programD, nil, 4, 10 + 10 + domain.PlaylistLength + 1, 2 + domain.PlaylistLength},
+	}
+	for i, step := range steps {
+		sequence, discontinuity := session.Advance(step.current, step.next, playlist, step.startIndex)
+		if sequence != step.sequence || discontinuity != step.discontinuity {
+			t.Errorf("%d: 期待 (%d, %d), 実際 (%d, %d)", i, step.sequence, step.discontinuity, sequence, discontinuity)
+		}
+	}
+}
+
+func TestSession_AdvanceCountsDiscontinuityAtWindowStart(t *testing.T) {
+	programA := &domain.ProgramItem{ID: "a", StartTime: "2025-09-15T19:00:00+09:00", DurationSec: 60, Loop: true}
+	programB := &domain.ProgramItem{ID: "b", StartTime: "2025-09-15T19:01:00+09:00", DurationSec: 30}
+	// 素材を2回繰り返すため、セグメント10の前に不連続が入る
+	looped := domain.BuildProgramTimeline(programA, tenSegmentPlaylist(), domain.TimelineOptions{Shortfall: domain.ShortfallSlate})
+
+	session := domain.NewSession(time.Now(), time.Minute)
+	if _, discontinuity := session.Advance(programA, programB, looped, 9); discontinuity != 0 {
+		t.Errorf("期待した不連続シーケンス: 0, 実際: %d", discontinuity)
+	}
+	// ウィンドウの先頭のセグメント10からは不連続を外すため、その分を数える
+	if _, discontinuity := session.Advance(programA, programB, looped, 10); discontinuity != 1 {
+		t.Errorf("期待した不連続シーケンス: 1, 実際: %d", discontinuity)
+	}
+	// 番組の境目の不連続を1つ加えて次の番組に続ける
+	if _, discontinuity := session.Advance(programB, nil, tenSegmentPlaylist(), 0); discontinuity != 2 {
+		t.Errorf("期待した不連続シーケンス: 2, 実際: %d", discontinuity)
+	}
+}
+
+func TestSessionService_ResumeAndCommit(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewInMemorySessionStore()
+	now := time.Date(2025, 9, 15, 10, 0, 0, 0, time.UTC)
+	sessionService := service.NewSessionService(store, repository.NewInMemorySessionViewerStore(), 10*time.Minute, domain.FixedClock{Time: now})
+
+	session, created, err := sessionService.Resume(ctx, "", nil)
+	if err != nil || !created || !domain.IsSessionID(session.ID) {
+		t.Fatalf("新しいセッションの作成に失敗: %+v, %v, %v", session, created, err)
+	}
+
+	resumed, created, err := sessionService.Resume(ctx, session.ID, nil)
+	if err != nil || created || resumed.ID != session.ID {
+		t.Fatalf("既存のセッションを返す想定です: %+v, %v, %v", resumed, created, err)
+	}
+
+	// プレイリストの生成でセッションが更新された場合は有効期限を延長して保存する
+	later := service.NewSessionService(store, repository.NewInMemorySessionViewerStore(), 10*time.Minute, domain.FixedClock{Time: now.Add(8 * time.Minute)})
+	original := *resumed
+	resumed.ProgramKey = "a@2025-09-15T19:00:00+09:00"
+	if err := later.Commit(ctx, original, resumed); err != nil {
+		t.Fatalf("セッションの保存に失敗: %v", err)
+	}
+	stored, _ := store.GetSession(ctx, session.ID)
+	if !stored.ExpiresAt.Equal(now.Add(18*time.Minute)) || stored.ProgramKey != resumed.ProgramKey {
+		t.Errorf("有効期限の延長または更新が保存されていません: %+v", stored)
+	}
+
+	// 期限切れのセッションや不正なIDの場合は新しいセッションを作成する
+	expired := service.NewSessionService(store, repository.NewInMemorySessionViewerStore(), 10*time.Minute, domain.FixedClock{Time: now.Add(time.Hour)})
+	if renewed, created, _ := expired.Resume(ctx, session.ID, nil); !created || renewed.ID == session.ID {
+		t.Errorf("期限切れのセッションは新しく作成する想定です: %+v", renewed)
+	}
+	if _, created, _ := expired.Resume(ctx, "../sessions", nil); !created {
+		t.Error("不正なセッションIDは新しく作成する想定です")
+	}
+
+	if count, _ := store.DeleteExpiredSessions(ctx, now.Add(time.Hour)); count != 1 {
+		t.Errorf("期待した削除件数: 1, 実際: %d", count)
+	}
+}
+
+func TestM3U8Playlist_ForSession(t *testing.T) {
+	program := &domain.ProgramItem{
+		ID:          "news",
+		StartTime:   "2025-09-15T19:00:00+09:00",
+		DurationSec: 30,
+		AdBreaks:    []domain.AdBreak{{ID: "cm1", Offset: 9, DurationSec: 9, Interstitial: true}},
+	}
+	timeline := domain.BuildProgramTimeline(program, tenSegmentPlaylist(), domain.TimelineOptions{})
+
+	personalized := timeline.ForSession("abc")
+	assetList, _ := url.Parse(personalized.SegmentAt(3).Cues[0].AssetList)
+	if assetList.Query().Get("sid") != "abc" {
+		t.Errorf("アセットリストにセッションIDが必要です: %s", assetList)
+	}
+	if requests := personalized.InterstitialAdRequests(); len(requests) != 1 || requests[0].SessionID != "abc" {
+		t.Errorf("広告判定のリクエストにセッションIDが必要です: %+v", requests)
+	}
+	if timeline.InterstitialAdRequests()[0].SessionID != "" {
+		t.Error("元の放送内容は変更しない想定です")
+	}
+
+	request := domain.AdRequest{BreakID: "cm1", SessionID: "abc"}
+	if expanded := domain.ExpandAdURL("https://ads.example.com/vast?sid=[SESSION_ID]", request); expanded != "https://ads.example.com/vast?sid=abc" {
+		t.Errorf("マクロの置き換えが正しくありません: %s", expanded)
+	}
+}
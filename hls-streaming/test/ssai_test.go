@@ -0,0 +1,224 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/genki0524/hls_striming_go/internal/domain"
+	"github.com/genki0524/hls_striming_go/internal/repository"
+	"github.com/genki0524/hls_striming_go/internal/service"
+)
+
+const sampleVAST = `<?xml version="1.0" encoding="UTF-8"?>
+<VAST version="4.0">
+  <Ad id="second" sequence="2">
+    <InLine>
+      <Impression><![CDATA[ https://ads.example.com/imp?ad=second ]]></Impression>
+      <Creatives>
+        <Creative id="c2">
+          <Linear>
+            <Duration>00:00:30.000</Duration>
+            <MediaFiles>
+              <MediaFile delivery="streaming" type="application/x-mpegURL"><![CDATA[https://cdn.example.com/second.m3u8]]></MediaFile>
+              <MediaFile delivery="progressive" type="video/mp4"><![CDATA[https://cdn.example.com/second.mp4]]></MediaFile>
+            </MediaFiles>
+          </Linear>
+        </Creative>
+      </Creatives>
+    </InLine>
+  </Ad>
+  <Ad id="first" sequence="1">
+    <InLine>
+      <Impression>https://ads.example.com/imp?ad=first</Impression>
+      <Impression>https://tracker.example.com/imp?ad=first</Impression>
+      <Creatives>
+        <Creative id="c1">
+          <Linear>
+            <Duration>00:00:15</Duration>
+            <MediaFiles>
+              <MediaFile delivery="progressive" type="video/mp4">https://cdn.example.com/first.mp4</MediaFile>
+            </MediaFiles>
+          </Linear>
+        </Creative>
+      </Creatives>
+    </InLine>
+  </Ad>
+  <Ad id="wrapper">
+    <Wrapper>
+      <VASTAdTagURI>https://ads.example.com/wrapped</VASTAdTagURI>
+    </Wrapper>
+  </Ad>
+</VAST>`
+
+func TestParseVAST(t *testing.T) {
+	creatives, err := domain.ParseVAST([]byte(sampleVAST))
+	if err != nil {
+		t.Fatalf("VASTの解析に失敗: %v", err)
+	}
+	if len(creatives) != 2 {
+		t.Fatalf("期待した広告数: 2, 実際: %d", len(creatives))
+	}
+
+	first, second := creatives[0], creatives[1]
+	if first.AdID != "first" || first.DurationSec != 15 || len(first.Impressions) != 2 {
+		t.Errorf("sequenceの順に並べる想定です: %+v", first)
+	}
+	if second.MediaURL != "https://cdn.example.com/second.mp4" {
+		t.Errorf("progressiveのmp4を優先する想定です: %s", second.MediaURL)
+	}
+	if second.DurationSec != 30 || second.Impressions[0] != "https://ads.example.com/imp?ad=second" {
+		t.Errorf("広告の内容が正しくありません: %+v", second)
+	}
+
+	if _, err := domain.ParseVAST([]byte("<VAST><Ad><InLine><Creatives><Creative><Linear><Duration>15秒</Duration></Linear></Creative></Creatives></InLine></Ad></VAST>")); !errors.Is(err, domain.ErrInvalidVAST) {
+		t.Errorf("ErrInvalidVASTを期待しましたが、実際: %v", err)
+	}
+}
+
+func TestSelectAdsAndExpandAdURL(t *testing.T) {
+	creatives := []domain.AdCreative{
+		{AdID: "a", DurationSec: 30},
+		{AdID: "b", DurationSec: 45},
+		{AdID: "c", DurationSec: 15},
+	}
+	selected := domain.SelectAds(creatives, 60)
+	if len(selected) != 2 || selected[0].AdID != "a" || selected[1].AdID != "c" {
+		t.Errorf("広告枠に収まる広告を先頭から選ぶ想定です: %+v", selected)
+	}
+
+	request := domain.AdRequest{BreakID: "news break", ProgramID: "news", DurationSec: 90, StartDate: time.Unix(1757930400, 0)}
+	url := domain.ExpandAdURL("https://ads.example.com/vast?b=[BREAK_ID]&p=[PROGRAM_ID]&d=[DURATION]&t=[TIMESTAMP]", request)
+	if url != "https://ads.example.com/vast?b=news+break&p=news&d=90&t=1757930400" {
+		t.Errorf("マクロの置き換えが正しくありません: %s", url)
+	}
+}
+
+func TestInsertAds(t *testing.T) {
+	program := &domain.ProgramItem{
+		ID:          "news",
+		StartTime:   "2025-09-15T19:00:00+09:00",
+		DurationSec: 30,
+		AdBreaks:    []domain.AdBreak{{ID: "cm1", Offset: 9, DurationSec: 9}},
+	}
+	timeline := domain.BuildProgramTimeline(program, tenSegmentPlaylist(), domain.TimelineOptions{})
+
+	ad := domain.NewM3U8Playlist()
+	for i := 0; i < 3; i++ {
+		ad.Segments = append(ad.Segments, domain.M3U8Segment{Duration: 2, Filename: fmt.Sprintf("ad%d.ts", i)})
+	}
+
+	cues := timeline.CueOuts()
+	if len(cues) != 1 || cues[0].ID != "cm1" {
+		t.Fatalf("広告枠の合図が見つかりません: %+v", cues)
+	}
+
+	stitched := timeline.InsertAds(map[string]*domain.M3U8Playlist{"cm1": ad})
+	if total := totalDuration(stitched.Segments); total != 30 {
+		t.Errorf("広告を挿入しても番組の長さは変わらない想定です: %.1f", total)
+	}
+
+	// segment0-2, ad0-2(6秒), 静止画3秒, segment6-9
+	expected := []string{"segment0.ts", "segment1.ts", "segment2.ts", "ad0.ts", "ad1.ts", "ad2.ts", domain.SlateURI, "segment6.ts"}
+	for i, filename := range expected {
+		if stitched.Segments[i].Filename != filename {
+			t.Errorf("%d番目: 期待 %s, 実際 %s", i, filename, stitched.Segments[i].Filename)
+		}
+	}
+	if !stitched.Segments[3].Discontinuity || !stitched.Segments[7].Discontinuity {
+		t.Error("広告の前後に不連続が必要です")
+	}
+	if len(stitched.Segments[3].Cues) != 1 || stitched.Segments[3].Cues[0].Type != domain.CueOut {
+		t.Errorf("広告の先頭にCUE-OUTが必要です: %+v", stitched.Segments[3].Cues)
+	}
+	if len(stitched.Segments[7].Cues) != 1 || stitched.Segments[7].Cues[0].Type != domain.CueIn {
+		t.Errorf("番組への復帰にCUE-INが必要です: %+v", stitched.Segments[7].Cues)
+	}
+
+	if unchanged := timeline.InsertAds(map[string]*domain.M3U8Playlist{"other": ad}); len(unchanged.Segments) != 10 {
+		t.Errorf("広告のない広告枠は変更しない想定です: %d", len(unchanged.Segments))
+	}
+}
+
+func TestVASTClient_RequestAds(t *testing.T) {
+	var requested string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.RawQuery
+		if r.URL.Query().Get("d") == "0" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Write([]byte(sampleVAST))
+	}))
+	defer server.Close()
+
+	client := repository.NewVASTClient(server.URL+"/vast?b=[BREAK_ID]&d=[DURATION]", time.Second)
+	creatives, err := client.RequestAds(context.Background(), domain.AdRequest{BreakID: "cm1", DurationSec: 60})
+	if err != nil {
+		t.Fatalf("広告のリクエストに失敗: %v", err)
+	}
+	if requested != "b=cm1&d=60" || len(creatives) != 2 {
+		t.Errorf("リクエストまたは結果が正しくありません: %s, %d件", requested, len(creatives))
+	}
+
+	creatives, err = client.RequestAds(context.Background(), domain.AdRequest{BreakID: "cm2"})
+	if err != nil || len(creatives) != 0 {
+		t.Errorf("204の場合は広告なしとして扱う想定です: %v, %d件", err, len(creatives))
+	}
+}
+
+// recordingAdClient は広告判定のリクエストを記録し、広告なしを返す広告判定サーバーです
+type recordingAdClient struct {
+	requests chan domain.AdRequest
+}
+
+func (c *recordingAdClient) RequestAds(ctx context.Context, request domain.AdRequest) ([]domain.AdCreative, error) {
+	c.requests <- request
+	return nil, nil
+}
+
+func TestAdInsertionService_PreparesOnlyUpcomingBreaks(t *testing.T) {
+	client := &recordingAdClient{requests: make(chan domain.AdRequest, 10)}
+	adService := service.NewAdInsertionService(client, nil, nil)
+
+	start := time.Date(2025, 9, 15, 19, 0, 0, 0, time.UTC)
+	program := &domain.ProgramItem{ID: "news", StartTime: start.Format(time.RFC3339), DurationSec: 7200, AdBreaks: []domain.AdBreak{
+		{ID: "soon", Offset: 60, DurationSec: 30},
+		{ID: "later", Offset: 3600, DurationSec: 30},
+	}}
+	playlist := domain.NewM3U8Playlist()
+	playlist.TargetDuration = 3
+	for i := 0; i < 2400; i++ {
+		playlist.Segments = append(playlist.Segments, domain.M3U8Segment{Duration: 3, Filename: fmt.Sprintf("segment%d.ts", i)})
+	}
+	timeline := domain.BuildProgramTimeline(program, playlist, domain.TimelineOptions{Shortfall: domain.ShortfallSlate})
+
+	expectRequests := func(at time.Time, expected ...string) {
+		t.Helper()
+		adService.InsertAds(context.Background(), "", "viewer", program, timeline, at)
+		for _, id := range expected {
+			select {
+			case request := <-client.requests:
+				if request.BreakID != id {
+					t.Errorf("期待した広告枠: %s, 実際: %s", id, request.BreakID)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("広告枠(%s)の広告判定が行われません", id)
+			}
+		}
+		select {
+		case request := <-client.requests:
+			t.Errorf("先読み期間外の広告枠(%s)を準備しました", request.BreakID)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	// プレイリストの時刻から近い広告枠だけを準備する
+	expectRequests(start, "soon")
+	// 時刻が進めば後の広告枠も準備する（準備済みの広告枠は再度判定しない）
+	expectRequests(start.Add(58*time.Minute), "later")
+}
@@ -0,0 +1,144 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/genki0524/hls_striming_go/internal/domain"
+	"github.com/genki0524/hls_striming_go/internal/service"
+)
+
+func TestStreamingService_StaticImageCountdown(t *testing.T) {
+	jst := time.FixedZone("JST", 9*60*60)
+	now := time.Date(2025, 9, 15, 18, 59, 0, 0, jst)
+	streamingService := service.NewStreamingService(nil, nil, nil, domain.ShortfallSlate, domain.BumperConfig{}, nil, domain.Watermark{}, jst, domain.FixedClock{Time: now})
+
+	schedule := []domain.ProgramItem{
+		{ID: "news", StartTime: "2025-09-15T19:00:00+09:00", DurationSec: 1800, Type: "video", Title: "ニュース"},
+	}
+
+	playlist := streamingService.GenerateStaticImagePlaylist(schedule)
+
+	// 次の番組まで60秒なので30秒の静止画セグメントが2つ
+	if count := strings.Count(playlist, "#EXTINF:30.0,"); count != 2 {
+		t.Errorf("期待した静止画セグメント数: 2, 実際: %d\n%s", count, playlist)
+	}
+}
+
+func TestStreamingService_PreviewBeforeFirstProgram(t *testing.T) {
+	jst := time.FixedZone("JST", 9*60*60)
+	at := time.Date(2025, 9, 15, 18, 59, 50, 0, jst)
+	streamingService := service.NewStreamingService(nil, nil, nil, domain.ShortfallSlate, domain.BumperConfig{}, nil, domain.Watermark{}, jst, domain.FixedClock{Time: at})
+
+	schedule := []domain.ProgramItem{
+		{ID: "news", StartTime: "2025-09-15T19:00:00+09:00", DurationSec: 1800, Type: "video", Title: "ニュース"},
+	}
+
+	// 番組開始10秒前のプレイリストは10秒の静止画1つ
+	playlist, err := streamingService.GeneratePlaylistAt(context.Background(), schedule, at)
+	if err != nil {
+		t.Fatalf("プレイリスト生成エラー: %v", err)
+	}
+	if !strings.Contains(playlist, "#EXTINF:10.0,\n/static/images/picture.jpg") {
+		t.Errorf("静止画のカウントダウンが正しくありません:\n%s", playlist)
+	}
+
+	if status := streamingService.CheckStreamStatus(schedule); status != 204 {
+		t.Errorf("番組が無い時間のステータス: 期待 204, 実際 %d", status)
+	}
+}
+
+func TestStreamingService_DiscontinuitySequenceAcrossWindow(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte("BUCKET=test-bucket\n"), 0o600); err != nil {
+		t.Fatalf(".envの作成に失敗: %v", err)
+	}
+	t.Chdir(dir)
+
+	var m3u8 strings.Builder
+	m3u8.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:3\n")
+	for i := 0; i < 10; i++ {
+		fmt.Fprintf(&m3u8, "#EXTINF:3.0,\nsegment%d.ts\n", i)
+	}
+	gcsRepo := newFakeGCSRepository(t, map[string]string{"2025-09-15/loop/video.m3u8": m3u8.String()})
+
+	jst := time.FixedZone("JST", 9*60*60)
+	start := time.Date(2025, 9, 15, 19, 0, 0, 0, jst)
+	streamingService := service.NewStreamingService(gcsRepo, nil, nil, domain.ShortfallSlate, domain.BumperConfig{}, nil, domain.Watermark{}, jst, domain.FixedClock{Time: start})
+
+	// 30秒の素材を120秒の枠で繰り返すため、セグメント10・20・30の前に不連続が入る
+	schedule := []domain.ProgramItem{
+		{ID: "loop", StartTime: "2025-09-15T19:00:00+09:00", DurationSec: 120, Type: "video", Title: "loop", Loop: true, ScheduleDate: "2025-09-15"},
+	}
+
+	cases := []struct {
+		segment               int
+		discontinuitySequence int
+		discontinuityTags     int
+	}{
+		// ウィンドウはセグメント9〜23で、セグメント10・20の不連続を含む
+		{23, 0, 2},
+		// ウィンドウの先頭がセグメント10になり、先頭の不連続を外した分を数える
+		{24, 1, 1},
+		{25, 1, 1},
+	}
+	for _, c := range cases {
+		at := start.Add(time.Duration(c.segment*3)*time.Second + time.Second)
+		playlist, err := streamingService.GeneratePlaylistAt(context.Background(), schedule, at)
+		if err != nil {
+			t.Fatalf("プレイリスト生成エラー: %v", err)
+		}
+
+		sequence := 0
+		if _, value, found := strings.Cut(playlist, "#EXT-X-DISCONTINUITY-SEQUENCE:"); found {
+			sequence, _ = strconv.Atoi(strings.SplitN(value, "\n", 2)[0])
+		}
+		if sequence != c.discontinuitySequence {
+			t.Errorf("セグメント%d: 期待したEXT-X-DISCONTINUITY-SEQUENCE: %d, 実際: %d\n%s", c.segment, c.discontinuitySequence, sequence, playlist)
+		}
+		if tags := strings.Count(playlist, "#EXT-X-DISCONTINUITY\n"); tags != c.discontinuityTags {
+			t.Errorf("セグメント%d: 期待した不連続の数: %d, 実際: %d\n%s", c.segment, c.discontinuityTags, tags, playlist)
+		}
+	}
+}
+
+func TestStreamingService_PreviewDoesNotPrepareAds(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte("BUCKET=test-bucket\n"), 0o600); err != nil {
+		t.Fatalf(".envの作成に失敗: %v", err)
+	}
+	t.Chdir(dir)
+
+	var m3u8 strings.Builder
+	m3u8.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:3\n")
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&m3u8, "#EXTINF:3.0,\nsegment%d.ts\n", i)
+	}
+	gcsRepo := newFakeGCSRepository(t, map[string]string{"2025-09-15/news/video.m3u8": m3u8.String()})
+
+	client := &recordingAdClient{requests: make(chan domain.AdRequest, 10)}
+	adService := service.NewAdInsertionService(client, gcsRepo, nil)
+	jst := time.FixedZone("JST", 9*60*60)
+	at := time.Date(2025, 9, 15, 19, 0, 10, 0, jst)
+	streamingService := service.NewStreamingService(gcsRepo, nil, nil, domain.ShortfallSlate, domain.BumperConfig{}, adService, domain.Watermark{}, jst, domain.FixedClock{Time: at})
+
+	schedule := []domain.ProgramItem{
+		{ID: "news", StartTime: "2025-09-15T19:00:00+09:00", DurationSec: 60, Type: "video", Title: "news", ScheduleDate: "2025-09-15",
+			AdBreaks: []domain.AdBreak{{ID: "cm", Offset: 30, DurationSec: 15}}},
+	}
+	if _, err := streamingService.GeneratePlaylistAt(context.Background(), schedule, at); err != nil {
+		t.Fatalf("プレイリスト生成エラー: %v", err)
+	}
+
+	select {
+	case request := <-client.requests:
+		t.Errorf("プレビューで広告枠(%s)の広告を準備しました", request.BreakID)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
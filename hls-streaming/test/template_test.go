@@ -0,0 +1,238 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/genki0524/hls_striming_go/internal/domain"
+	"github.com/genki0524/hls_striming_go/internal/repository"
+	"github.com/genki0524/hls_striming_go/internal/service"
+)
+
+func weekdayTemplate() domain.ScheduleTemplate {
+	slot := func(weekday, startTime, title string) domain.TemplateSlot {
+		return domain.TemplateSlot{Weekday: weekday, StartTime: startTime, DurationSec: 3600, Type: domain.ProgramTypeVideo, Title: title}
+	}
+	return domain.ScheduleTemplate{
+		Name: "平日編成",
+		Slots: []domain.TemplateSlot{
+			slot("MO", "20:00", "月曜ドラマ"),
+			slot("MO", "09:00", "朝の番組"),
+			slot("TU", "09:00", "朝の番組"),
+		},
+	}
+}
+
+func TestScheduleTemplate_ProgramsForDate(t *testing.T) {
+	template := weekdayTemplate()
+
+	programs, err := template.ProgramsForDate("2025-01-06", time.UTC) // 月曜日
+	if err != nil {
+		t.Fatalf("番組の生成に失敗: %v", err)
+	}
+	if len(programs) != 2 {
+		t.Fatalf("期待した番組数: 2, 実際: %d", len(programs))
+	}
+	if programs[0].StartTime != "2025-01-06T09:00:00Z" || programs[1].Title != "月曜ドラマ" {
+		t.Errorf("番組が開始時刻順に生成されていません: %+v", programs)
+	}
+	if programs[0].ID == "" {
+		t.Error("番組にIDが割り当てられていません")
+	}
+
+	programs, err = template.ProgramsForDate("2025-01-08", time.UTC) // 水曜日
+	if err != nil {
+		t.Fatalf("番組の生成に失敗: %v", err)
+	}
+	if len(programs) != 0 {
+		t.Errorf("枠のない曜日に番組が生成されました: %d", len(programs))
+	}
+}
+
+func TestScheduleTemplate_ValidateOverlap(t *testing.T) {
+	template := weekdayTemplate()
+	template.Slots = append(template.Slots, domain.TemplateSlot{
+		Weekday: "MO", StartTime: "20:30", DurationSec: 600, Type: domain.ProgramTypeVideo, Title: "重複",
+	})
+
+	if err := template.Validate(); !errors.Is(err, domain.ErrInvalidTemplate) {
+		t.Errorf("重複する枠がエラーになりません: %v", err)
+	}
+}
+
+func TestScheduleTemplate_ValidateSundayIntoMonday(t *testing.T) {
+	template := domain.ScheduleTemplate{
+		Name: "深夜編成",
+		Slots: []domain.TemplateSlot{
+			{Weekday: "MO", StartTime: "00:00", DurationSec: 1800, Type: domain.ProgramTypeVideo, Title: "月曜深夜"},
+			{Weekday: "SU", StartTime: "23:30", DurationSec: 3600, Type: domain.ProgramTypeVideo, Title: "日曜深夜映画"},
+		},
+	}
+
+	if err := template.Validate(); !errors.Is(err, domain.ErrInvalidTemplate) {
+		t.Errorf("日曜日から翌週の月曜日にかかる重複がエラーになりません: %v", err)
+	}
+}
+
+func TestTemplateService_ApplyModes(t *testing.T) {
+	ctx := context.Background()
+	scheduleService := service.NewScheduleService(repository.NewInMemoryScheduleRepository(), nil, nil, time.UTC, domain.SystemClock{})
+	templateService := service.NewTemplateService(repository.NewInMemoryTemplateRepository(), scheduleService)
+
+	template, err := templateService.CreateTemplate(ctx, weekdayTemplate(), nil)
+	if err != nil {
+		t.Fatalf("テンプレートの登録に失敗: %v", err)
+	}
+
+	// 火曜日には既存の番組がある
+	_, _, err = scheduleService.AddProgramToSchedule(ctx, domain.RequestProgramItem{
+		StartTime:   "2025-01-07T12:00:00Z",
+		DurationSec: 600,
+		Type:        domain.ProgramTypeVideo,
+		Title:       "既存番組",
+	}, "2025-01-07", domain.AnyVersion, nil)
+	if err != nil {
+		t.Fatalf("番組の追加に失敗: %v", err)
+	}
+
+	dates, _ := domain.DateRange("2025-01-06", "2025-01-08")
+	result, err := templateService.ApplyTemplate(ctx, template.ID, dates, domain.TemplateApplySkip, false, nil)
+	if err != nil {
+		t.Fatalf("テンプレートの適用に失敗: %v", err)
+	}
+
+	expected := []domain.TemplateDayStatus{domain.TemplateDayApplied, domain.TemplateDayConflict, domain.TemplateDayEmpty}
+	for i, day := range result.Days {
+		if day.Status != expected[i] {
+			t.Errorf("%s: 期待した状態: %s, 実際: %s", day.Date, expected[i], day.Status)
+		}
+	}
+	if result.AppliedCount != 1 || result.ConflictCount != 1 {
+		t.Errorf("集計が不正です: applied=%d, conflict=%d", result.AppliedCount, result.ConflictCount)
+	}
+
+	schedule, _ := scheduleService.GetScheduleByDate(ctx, "2025-01-07")
+	if len(schedule.Programs) != 1 {
+		t.Fatalf("skipモードで既存の番組表が変更されました: %d", len(schedule.Programs))
+	}
+
+	// mergeモードでは既存の番組を残したまま追加する
+	result, err = templateService.ApplyTemplate(ctx, template.ID, []string{"2025-01-07"}, domain.TemplateApplyMerge, false, nil)
+	if err != nil {
+		t.Fatalf("テンプレートの適用に失敗: %v", err)
+	}
+	if result.Days[0].Status != domain.TemplateDayMerged {
+		t.Errorf("期待した状態: merged, 実際: %s", result.Days[0].Status)
+	}
+	schedule, _ = scheduleService.GetScheduleByDate(ctx, "2025-01-07")
+	if len(schedule.Programs) != 2 {
+		t.Errorf("期待した番組数: 2, 実際: %d", len(schedule.Programs))
+	}
+
+	// 同じテンプレートを再度mergeすると重複として競合になる
+	result, err = templateService.ApplyTemplate(ctx, template.ID, []string{"2025-01-07"}, domain.TemplateApplyMerge, false, nil)
+	if err != nil {
+		t.Fatalf("テンプレートの適用に失敗: %v", err)
+	}
+	if result.Days[0].Status != domain.TemplateDayConflict || len(result.Days[0].Violations) == 0 {
+		t.Errorf("重複が競合として報告されません: %+v", result.Days[0])
+	}
+
+	// replaceのドライランでは番組表を変更しない
+	result, err = templateService.ApplyTemplate(ctx, template.ID, []string{"2025-01-07"}, domain.TemplateApplyReplace, true, nil)
+	if err != nil {
+		t.Fatalf("テンプレートの適用に失敗: %v", err)
+	}
+	if result.Days[0].Status != domain.TemplateDayReplaced {
+		t.Errorf("期待した状態: replaced, 実際: %s", result.Days[0].Status)
+	}
+	schedule, _ = scheduleService.GetScheduleByDate(ctx, "2025-01-07")
+	if len(schedule.Programs) != 2 {
+		t.Errorf("ドライランで番組表が変更されました: %d", len(schedule.Programs))
+	}
+}
+
+func TestDateRange_Invalid(t *testing.T) {
+	if _, err := domain.DateRange("2025-01-10", "2025-01-01"); err == nil {
+		t.Error("逆順の期間がエラーになりません")
+	}
+	if _, err := domain.DateRange("2025-01-01", "2026-12-31"); err == nil {
+		t.Error("長すぎる期間がエラーになりません")
+	}
+}
+
+// failingScheduleRepository は指定した日付の番組表の保存に失敗するリポジトリです
+type failingScheduleRepository struct {
+	*repository.InMemoryScheduleRepository
+	failDate string
+}
+
+func (r *failingScheduleRepository) UpdateScheduleByDate(ctx context.Context, date string, expectedVersion int64, mutate func(schedule *domain.Schedule) error) (*domain.Schedule, error) {
+	if date == r.failDate {
+		return nil, errors.New("保存に失敗しました")
+	}
+	return r.InMemoryScheduleRepository.UpdateScheduleByDate(ctx, date, expectedVersion, mutate)
+}
+
+func TestTemplateService_ApplyReturnsPartialResult(t *testing.T) {
+	ctx := context.Background()
+	repo := &failingScheduleRepository{InMemoryScheduleRepository: repository.NewInMemoryScheduleRepository(), failDate: "2025-01-07"}
+	scheduleService := service.NewScheduleService(repo, nil, nil, time.UTC, domain.SystemClock{})
+	templateService := service.NewTemplateService(repository.NewInMemoryTemplateRepository(), scheduleService)
+
+	template, err := templateService.CreateTemplate(ctx, weekdayTemplate(), nil)
+	if err != nil {
+		t.Fatalf("テンプレートの登録に失敗: %v", err)
+	}
+
+	dates, _ := domain.DateRange("2025-01-06", "2025-01-08")
+	result, err := templateService.ApplyTemplate(ctx, template.ID, dates, domain.TemplateApplySkip, false, nil)
+	if err == nil {
+		t.Fatal("保存の失敗がエラーになりません")
+	}
+	if result == nil || len(result.Days) != 1 || result.Days[0].Date != "2025-01-06" || result.Days[0].Status != domain.TemplateDayApplied {
+		t.Fatalf("失敗した日より前に反映した日の結果を期待しました: %+v", result)
+	}
+	if schedule, _ := scheduleService.GetScheduleByDate(ctx, "2025-01-06"); schedule == nil || len(schedule.Programs) != 2 {
+		t.Errorf("反映済みの日の番組表が保存されていません: %+v", schedule)
+	}
+}
+
+func TestTemplateService_ApplyRecordsEachDay(t *testing.T) {
+	ctx := context.Background()
+	scheduleService := service.NewScheduleService(repository.NewInMemoryScheduleRepository(), nil, nil, time.UTC, domain.SystemClock{})
+	templateService := service.NewTemplateService(repository.NewInMemoryTemplateRepository(), scheduleService)
+
+	template, err := templateService.CreateTemplate(ctx, weekdayTemplate(), nil)
+	if err != nil {
+		t.Fatalf("テンプレートの登録に失敗: %v", err)
+	}
+
+	// 2日目の記録に失敗した場合は、その日までの反映で中止する
+	recorded := make([]string, 0)
+	record := func(date string, before, after []domain.ProgramItem) error {
+		recorded = append(recorded, date)
+		if len(before) != 0 || len(after) == 0 {
+			t.Errorf("%s: 変更前後の番組を期待しました: %d件 → %d件", date, len(before), len(after))
+		}
+		if len(recorded) == 2 {
+			return errors.New("記録に失敗しました")
+		}
+		return nil
+	}
+
+	dates, _ := domain.DateRange("2025-01-06", "2025-01-08")
+	result, err := templateService.ApplyTemplate(ctx, template.ID, dates, domain.TemplateApplySkip, false, record)
+	if err == nil {
+		t.Fatal("記録の失敗がエラーになりません")
+	}
+	assertDates(t, recorded, []string{"2025-01-06", "2025-01-07"})
+	if result == nil || result.AppliedCount != 2 {
+		t.Fatalf("記録に失敗した日までの結果を期待しました: %+v", result)
+	}
+	if _, err := scheduleService.GetScheduleByDate(ctx, "2025-01-08"); !errors.Is(err, domain.ErrScheduleNotFound) {
+		t.Errorf("記録に失敗した後の日は反映しない想定です: %v", err)
+	}
+}
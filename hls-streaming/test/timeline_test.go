@@ -0,0 +1,383 @@
+package test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/genki0524/hls_striming_go/internal/domain"
+)
+
+// tenSegmentPlaylist は3秒のセグメント10個（合計30秒）のプレイリストです
+func tenSegmentPlaylist() *domain.M3U8Playlist {
+	playlist := domain.NewM3U8Playlist()
+	playlist.TargetDuration = 3
+	for i := 0; i < 10; i++ {
+		playlist.Segments = append(playlist.Segments, domain.M3U8Segment{Duration: 3, Filename: fmt.Sprintf("segment%d.ts", i)})
+	}
+	return playlist
+}
+
+func TestM3U8Playlist_Trim(t *testing.T) {
+	trimmed := tenSegmentPlaylist().Trim(7.5, 16)
+
+	// 6〜9秒のセグメント2から、15〜18秒のセグメント5まで
+	if len(trimmed.Segments) != 4 {
+		t.Fatalf("期待したセグメント数: 4, 実際: %d", len(trimmed.Segments))
+	}
+	if trimmed.Segments[0].Filename != "segment2.ts" || trimmed.Segments[3].Filename != "segment5.ts" {
+		t.Errorf("残ったセグメントが正しくありません: %+v", trimmed.Segments)
+	}
+	if trimmed.StartOffset != 1.5 {
+		t.Errorf("期待した開始位置のずれ: 1.5, 実際: %.1f", trimmed.StartOffset)
+	}
+	if trimmed.PlayableDuration() != 10.5 {
+		t.Errorf("期待した放送可能時間: 10.5, 実際: %.1f", trimmed.PlayableDuration())
+	}
+	if tag := trimmed.StartTag(); tag != "#EXT-X-START:TIME-OFFSET=1.500,PRECISE=YES" {
+		t.Errorf("EXT-X-STARTタグが正しくありません: %s", tag)
+	}
+}
+
+func TestM3U8Playlist_CurrentSegmentIndexAfterTrim(t *testing.T) {
+	trimmed := tenSegmentPlaylist().Trim(7.5, 0)
+
+	// 番組開始直後はイン点を含むセグメント（元のsegment2）
+	if index := trimmed.GetCurrentSegmentIndex(0); trimmed.Segments[index].Filename != "segment2.ts" {
+		t.Errorf("開始直後のセグメントが正しくありません: %s", trimmed.Segments[index].Filename)
+	}
+	// 番組開始から2秒後は素材の9.5秒の位置（segment3）
+	if index := trimmed.GetCurrentSegmentIndex(2); trimmed.Segments[index].Filename != "segment3.ts" {
+		t.Errorf("2秒後のセグメントが正しくありません: %s", trimmed.Segments[index].Filename)
+	}
+	if len(trimmed.Segments) != 8 {
+		t.Errorf("アウト点がない場合は最後まで残る必要があります: %d", len(trimmed.Segments))
+	}
+}
+
+func TestBuildProgramTimeline_WithoutTrim(t *testing.T) {
+	playlist := tenSegmentPlaylist()
+	timeline := domain.BuildProgramTimeline(&domain.ProgramItem{}, playlist, domain.TimelineOptions{})
+
+	if len(timeline.Segments) != 10 || timeline.StartTag() != "" {
+		t.Errorf("トリミングしない番組の内容が変わりました: %d", len(timeline.Segments))
+	}
+}
+
+func TestValidateSchedule_InvalidTrim(t *testing.T) {
+	programs := []domain.ProgramItem{
+		{ID: "a", StartTime: "2025-01-01T10:00:00Z", DurationSec: 60, Type: domain.ProgramTypeVideo, Title: "a", InPoint: 30, OutPoint: 10},
+	}
+
+	report := domain.ValidateSchedule(programs, nil)
+	if report.Valid || report.Violations[0].Code != domain.ViolationInvalidTrim {
+		t.Errorf("不正なイン点・アウト点がエラーになりません: %+v", report)
+	}
+}
+
+func totalDuration(segments []domain.M3U8Segment) float64 {
+	var total float64
+	for _, segment := range segments {
+		total += segment.Duration
+	}
+	return total
+}
+
+func TestBuildProgramTimeline_HardCutAtSlotEnd(t *testing.T) {
+	program := &domain.ProgramItem{DurationSec: 10}
+	timeline := domain.BuildProgramTimeline(program, tenSegmentPlaylist(), domain.TimelineOptions{})
+
+	// 3秒×3で9秒、枠の終わりを越えるセグメントは切り捨てて残り1秒を静止画で埋める
+	if len(timeline.Segments) != 4 {
+		t.Fatalf("期待したセグメント数: 4, 実際: %d", len(timeline.Segments))
+	}
+	last := timeline.Segments[3]
+	if last.Filename != domain.SlateURI || last.Duration != 1 || !last.Discontinuity {
+		t.Errorf("最後のセグメントが1秒の静止画になっていません: %+v", last)
+	}
+	if total := totalDuration(timeline.Segments); total != 10 {
+		t.Errorf("期待した合計時間: 10, 実際: %.1f", total)
+	}
+}
+
+func TestBuildProgramTimeline_SlateShortfall(t *testing.T) {
+	program := &domain.ProgramItem{DurationSec: 40}
+	timeline := domain.BuildProgramTimeline(program, tenSegmentPlaylist(), domain.TimelineOptions{Shortfall: domain.ShortfallSlate})
+
+	// 30秒の素材の後に静止画3秒×3 + 1秒
+	if len(timeline.Segments) != 14 {
+		t.Fatalf("期待したセグメント数: 14, 実際: %d", len(timeline.Segments))
+	}
+	if !timeline.Segments[10].Discontinuity || timeline.Segments[11].Discontinuity {
+		t.Error("静止画の先頭にだけ不連続が必要です")
+	}
+	if total := totalDuration(timeline.Segments); total != 40 {
+		t.Errorf("期待した合計時間: 40, 実際: %.1f", total)
+	}
+}
+
+func TestBuildProgramTimeline_LoopShortfall(t *testing.T) {
+	program := &domain.ProgramItem{DurationSec: 70, Shortfall: string(domain.ShortfallLoop)}
+	timeline := domain.BuildProgramTimeline(program, tenSegmentPlaylist(), domain.TimelineOptions{Shortfall: domain.ShortfallSlate})
+
+	// 素材2回分（60秒）+ 3回目の3セグメント（9秒）+ 静止画1秒
+	if timeline.Len() != 24 {
+		t.Fatalf("期待したセグメント数: 24, 実際: %d", timeline.Len())
+	}
+	if !timeline.SegmentAt(10).Discontinuity || !timeline.SegmentAt(20).Discontinuity {
+		t.Error("素材の先頭に戻る位置に不連続が必要です")
+	}
+	if timeline.SegmentAt(20).Filename != "segment0.ts" || timeline.SegmentAt(23).Filename != domain.SlateURI {
+		t.Errorf("ループの内容が正しくありません: %+v, %+v", timeline.SegmentAt(20), timeline.SegmentAt(23))
+	}
+	if timeline.DiscontinuitySequence(21) != 2 {
+		t.Errorf("期待した不連続の数: 2, 実際: %d", timeline.DiscontinuitySequence(21))
+	}
+}
+
+func TestBuildProgramTimeline_LoopProgram(t *testing.T) {
+	program := &domain.ProgramItem{DurationSec: 3600, Loop: true}
+	timeline := domain.BuildProgramTimeline(program, tenSegmentPlaylist(), domain.TimelineOptions{Shortfall: domain.ShortfallSlate})
+
+	// 30秒の素材を120回繰り返す
+	if timeline.Len() != 1200 {
+		t.Fatalf("期待したセグメント数: 1200, 実際: %d", timeline.Len())
+	}
+	if len(timeline.Segments) != 10 {
+		t.Errorf("繰り返し分のセグメントは展開しない想定です: %d", len(timeline.Segments))
+	}
+
+	cases := []struct {
+		time     float64
+		index    int
+		filename string
+	}{
+		{0, 0, "segment0.ts"},
+		{29.9, 9, "segment9.ts"},
+		{30, 10, "segment0.ts"},
+		{95, 31, "segment1.ts"},
+		{3599, 1199, "segment9.ts"},
+	}
+	for _, c := range cases {
+		index := timeline.GetCurrentSegmentIndex(c.time)
+		if index != c.index {
+			t.Errorf("%.1f秒: 期待したインデックス: %d, 実際: %d", c.time, c.index, index)
+			continue
+		}
+		if filename := timeline.SegmentAt(index).Filename; filename != c.filename {
+			t.Errorf("%.1f秒: 期待したセグメント: %s, 実際: %s", c.time, c.filename, filename)
+		}
+	}
+
+	if timeline.SegmentAt(0).Discontinuity || !timeline.SegmentAt(30).Discontinuity || timeline.SegmentAt(31).Discontinuity {
+		t.Error("素材の先頭に戻るセグメントにだけ不連続が必要です")
+	}
+	if seq := timeline.DiscontinuitySequence(31); seq != 3 {
+		t.Errorf("期待した不連続の数: 3, 実際: %d", seq)
+	}
+}
+
+func TestBuildProgramTimeline_LoopZeroDuration(t *testing.T) {
+	// セグメントの長さがすべて0の素材は繰り返しても枠が進まないため、静止画で埋める
+	playlist := domain.NewM3U8Playlist()
+	playlist.TargetDuration = 3
+	playlist.Segments = []domain.M3U8Segment{{Duration: 0, Filename: "empty0.ts"}, {Duration: 0, Filename: "empty1.ts"}}
+	program := &domain.ProgramItem{DurationSec: 9, Loop: true}
+	timeline := domain.BuildProgramTimeline(program, playlist, domain.TimelineOptions{Shortfall: domain.ShortfallSlate})
+
+	if timeline.Len() != 5 || timeline.SegmentAt(4).Filename != domain.SlateURI {
+		t.Fatalf("素材の後に静止画が続く想定です: %+v", timeline.Segments)
+	}
+	if index := timeline.GetCurrentSegmentIndex(4); timeline.SegmentAt(index).Filename != domain.SlateURI {
+		t.Errorf("4秒の位置は静止画の想定です: %+v", timeline.SegmentAt(index))
+	}
+}
+
+func TestBuildProgramTimeline_LoopTrimmedProgram(t *testing.T) {
+	// 4.5〜12秒の区間を20秒の枠で繰り返す。2周目以降はセグメント単位で繰り返すため1周は9秒になる
+	program := &domain.ProgramItem{DurationSec: 20, Loop: true, InPoint: 4.5, OutPoint: 12}
+	timeline := domain.BuildProgramTimeline(program, tenSegmentPlaylist(), domain.TimelineOptions{Shortfall: domain.ShortfallSlate})
+
+	if index := timeline.GetCurrentSegmentIndex(0); index != 0 {
+		t.Errorf("期待したインデックス: 0, 実際: %d", index)
+	}
+	// in_pointまでの1.5秒を飛ばすのは1周目だけ
+	if index := timeline.GetCurrentSegmentIndex(7.5); index != 3 {
+		t.Errorf("期待したインデックス: 3, 実際: %d", index)
+	}
+	if last := timeline.SegmentAt(timeline.Len() - 1); last.Filename != domain.SlateURI {
+		t.Errorf("枠の残りは静止画で埋める想定です: %+v", last)
+	}
+}
+
+func TestStitchPlaylists(t *testing.T) {
+	stitched := domain.StitchPlaylists([]*domain.M3U8Playlist{tenSegmentPlaylist(), bumperPlaylist(), tenSegmentPlaylist()})
+	if len(stitched.Segments) != 21 {
+		t.Fatalf("期待したセグメント数: 21, 実際: %d", len(stitched.Segments))
+	}
+	if stitched.TargetDuration != 5 {
+		t.Errorf("期待したTargetDuration: 5, 実際: %d", stitched.TargetDuration)
+	}
+	if stitched.Segments[0].Discontinuity || !stitched.Segments[10].Discontinuity || !stitched.Segments[11].Discontinuity {
+		t.Error("2つ目以降のアセットの先頭にだけ不連続が必要です")
+	}
+	if stitched.Segments[10].Filename != "bumper0.ts" || stitched.Segments[11].Filename != "segment0.ts" {
+		t.Errorf("アセットの順序が正しくありません: %+v", stitched.Segments[9:12])
+	}
+}
+
+func TestBuildProgramTimeline_BlockProgram(t *testing.T) {
+	// 30秒の素材2本をつなげたブロックを70秒の枠で放送する
+	program := &domain.ProgramItem{DurationSec: 70, Assets: []string{"episode1", "episode2"}}
+	stitched := domain.StitchPlaylists([]*domain.M3U8Playlist{tenSegmentPlaylist(), tenSegmentPlaylist()})
+	timeline := domain.BuildProgramTimeline(program, stitched, domain.TimelineOptions{Shortfall: domain.ShortfallSlate})
+
+	if index := timeline.GetCurrentSegmentIndex(31); index != 10 {
+		t.Errorf("期待したインデックス: 10, 実際: %d", index)
+	}
+	// 2本目の先頭と静止画の先頭で不連続になる
+	if seq := timeline.DiscontinuitySequence(timeline.Len()); seq != 2 {
+		t.Errorf("期待した不連続の数: 2, 実際: %d", seq)
+	}
+	if total := totalDuration(timeline.Segments); total != 70 {
+		t.Errorf("期待した合計時間: 70, 実際: %.1f", total)
+	}
+}
+
+func bumperPlaylist() *domain.M3U8Playlist {
+	bumper := domain.NewM3U8Playlist()
+	bumper.TargetDuration = 5
+	bumper.Segments = append(bumper.Segments, domain.M3U8Segment{Duration: 5, Filename: "bumper0.ts"})
+	return bumper
+}
+
+func TestBuildProgramTimeline_Bumper(t *testing.T) {
+	// 30秒の素材を30秒の枠で放送し、終わりの5秒をバンパーにする
+	program := &domain.ProgramItem{DurationSec: 30}
+	timeline := domain.BuildProgramTimeline(program, tenSegmentPlaylist(), domain.TimelineOptions{Bumper: bumperPlaylist()})
+
+	if total := totalDuration(timeline.Segments); total != 30 {
+		t.Errorf("バンパーを含めて枠の長さに収める想定です: %.1f", total)
+	}
+	last := timeline.Segments[len(timeline.Segments)-1]
+	if last.Filename != "bumper0.ts" || !last.Discontinuity {
+		t.Errorf("枠の終わりに不連続付きでバンパーが必要です: %+v", last)
+	}
+	// 素材は8セグメント(24秒)まで、残り1秒は静止画
+	if timeline.Segments[7].Filename != "segment7.ts" || timeline.Segments[8].Filename != domain.SlateURI {
+		t.Errorf("バンパーの分だけ素材を短くする想定です: %+v", timeline.Segments[7:])
+	}
+	if timeline.TargetDuration != 5 {
+		t.Errorf("期待したTargetDuration: 5, 実際: %d", timeline.TargetDuration)
+	}
+}
+
+func TestBuildProgramTimeline_BumperWithLoop(t *testing.T) {
+	program := &domain.ProgramItem{DurationSec: 65, Loop: true}
+	timeline := domain.BuildProgramTimeline(program, tenSegmentPlaylist(), domain.TimelineOptions{Bumper: bumperPlaylist()})
+
+	// 素材2周（60秒）の後にバンパー
+	if timeline.Len() != 21 {
+		t.Fatalf("期待したセグメント数: 21, 実際: %d", timeline.Len())
+	}
+	if index := timeline.GetCurrentSegmentIndex(62); index != 20 || timeline.SegmentAt(index).Filename != "bumper0.ts" {
+		t.Errorf("62秒の時点ではバンパーを配信する想定です: %d", index)
+	}
+}
+
+func TestBuildProgramTimeline_BumperLongerThanSlot(t *testing.T) {
+	program := &domain.ProgramItem{DurationSec: 4}
+	timeline := domain.BuildProgramTimeline(program, tenSegmentPlaylist(), domain.TimelineOptions{Bumper: bumperPlaylist()})
+
+	for _, segment := range timeline.Segments {
+		if segment.Filename == "bumper0.ts" {
+			t.Fatal("枠に収まらないバンパーは入れない想定です")
+		}
+	}
+}
+
+func TestBumperConfig_AppliesTo(t *testing.T) {
+	cases := []struct {
+		config   domain.BumperConfig
+		bumper   string
+		expected bool
+	}{
+		{domain.BumperConfig{}, domain.ProgramBumperOn, false},
+		{domain.BumperConfig{AssetID: "ident", Mode: domain.BumperAll}, "", true},
+		{domain.BumperConfig{AssetID: "ident", Mode: domain.BumperAll}, domain.ProgramBumperOff, false},
+		{domain.BumperConfig{AssetID: "ident", Mode: domain.BumperMarked}, "", false},
+		{domain.BumperConfig{AssetID: "ident", Mode: domain.BumperMarked}, domain.ProgramBumperOn, true},
+	}
+	for _, c := range cases {
+		if actual := c.config.AppliesTo(&domain.ProgramItem{Bumper: c.bumper}); actual != c.expected {
+			t.Errorf("%+v, bumper=%q: 期待 %v, 実際 %v", c.config, c.bumper, c.expected, actual)
+		}
+	}
+
+	if _, err := domain.ParseBumperMode("sometimes"); err == nil {
+		t.Error("不明なバンパーの挿入方法でエラーを期待しました")
+	}
+	report := domain.ValidateSchedule([]domain.ProgramItem{
+		{ID: "a", StartTime: "2025-09-15T18:00:00+09:00", DurationSec: 1800, Type: "video", Title: "番組", Bumper: "yes"},
+	}, nil)
+	if violationCodes(report)[domain.ViolationInvalidBumper] != 1 {
+		t.Errorf("不正なbumperの指定が検出されません: %+v", report.Violations)
+	}
+}
+
+func TestBuildProgramTimeline_FillerShortfall(t *testing.T) {
+	filler := domain.NewM3U8Playlist()
+	for i := 0; i < 2; i++ {
+		filler.Segments = append(filler.Segments, domain.M3U8Segment{Duration: 2, Filename: fmt.Sprintf("filler%d.ts", i)})
+	}
+
+	program := &domain.ProgramItem{DurationSec: 36}
+	timeline := domain.BuildProgramTimeline(program, tenSegmentPlaylist(), domain.TimelineOptions{
+		Shortfall: domain.ShortfallFiller,
+		Fillers:   []*domain.M3U8Playlist{filler},
+	})
+
+	// 素材30秒 + フィラー4秒 + 静止画2秒
+	expected := []string{"filler0.ts", "filler1.ts", domain.SlateURI}
+	for i, filename := range expected {
+		if timeline.Segments[10+i].Filename != filename {
+			t.Errorf("セグメント%d: 期待: %s, 実際: %s", 10+i, filename, timeline.Segments[10+i].Filename)
+		}
+	}
+	if total := totalDuration(timeline.Segments); total != 36 {
+		t.Errorf("期待した合計時間: 36, 実際: %.1f", total)
+	}
+}
+
+func TestBuildProgramTimeline_TrimmedSlotCountsFromInPoint(t *testing.T) {
+	// イン点7.5秒から10秒間: 開始位置のずれ1.5秒を除いて枠に収める
+	program := &domain.ProgramItem{DurationSec: 10, InPoint: 7.5}
+	timeline := domain.BuildProgramTimeline(program, tenSegmentPlaylist(), domain.TimelineOptions{})
+
+	if timeline.PlayableDuration() != 10 {
+		t.Errorf("期待した放送時間: 10, 実際: %.1f", timeline.PlayableDuration())
+	}
+	if timeline.Segments[0].Filename != "segment2.ts" {
+		t.Errorf("イン点のセグメントから始まっていません: %s", timeline.Segments[0].Filename)
+	}
+}
+
+func TestPlanShortfallFillers(t *testing.T) {
+	ids := domain.PlanShortfallFillers(45, fillerPool())
+	if len(ids) == 0 || ids[0] != "promo" {
+		t.Errorf("不足分に収まるフィラーが選ばれていません: %v", ids)
+	}
+	if ids := domain.PlanShortfallFillers(0.5, fillerPool()); len(ids) != 0 {
+		t.Errorf("1秒未満の不足にフィラーが選ばれました: %v", ids)
+	}
+}
+
+func TestValidateSchedule_InvalidShortfall(t *testing.T) {
+	programs := []domain.ProgramItem{
+		{ID: "a", StartTime: "2025-01-01T10:00:00Z", DurationSec: 60, Type: domain.ProgramTypeVideo, Title: "a", Shortfall: "rewind"},
+	}
+
+	report := domain.ValidateSchedule(programs, nil)
+	if report.Valid || report.Violations[0].Code != domain.ViolationInvalidShortfall {
+		t.Errorf("不正な不足分の埋め方がエラーになりません: %+v", report)
+	}
+}
@@ -0,0 +1,74 @@
+package test
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/genki0524/hls_striming_go/internal/domain"
+)
+
+func TestWindowDates_DST(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Fatalf("タイムゾーンの読み込みに失敗: %v", err)
+	}
+
+	// 2025-03-30 01:00 UTC に夏時間へ切り替わる（この日は23時間）
+	currentTime := time.Date(2025, 3, 30, 23, 30, 0, 0, time.UTC) // BST 3/31 00:30
+	dates := domain.WindowDates(currentTime, london)
+
+	expected := []string{"2025-03-30", "2025-03-31", "2025-04-01"}
+	for i, date := range expected {
+		if dates[i] != date {
+			t.Errorf("期待した日付: %s, 実際: %s", date, dates[i])
+		}
+	}
+}
+
+func TestDatesBetween_DST(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Fatalf("タイムゾーンの読み込みに失敗: %v", err)
+	}
+
+	// 2025-10-26 は冬時間に戻るため25時間ある
+	from := time.Date(2025, 10, 25, 23, 30, 0, 0, london)
+	to := from.Add(48 * time.Hour)
+	dates := domain.DatesBetween(from, to, london)
+
+	expected := []string{"2025-10-25", "2025-10-26", "2025-10-27"}
+	if len(dates) != len(expected) {
+		t.Fatalf("期待した日付: %v, 実際: %v", expected, dates)
+	}
+	for i, date := range expected {
+		if dates[i] != date {
+			t.Errorf("期待した日付: %s, 実際: %s", date, dates[i])
+		}
+	}
+}
+
+func TestBuildEPG_DST(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Fatalf("タイムゾーンの読み込みに失敗: %v", err)
+	}
+
+	programs := []domain.ProgramItem{
+		{ID: "a", StartTime: "2025-03-30T00:30:00Z", DurationSec: 3600, Title: "切り替え前後"},
+		{ID: "b", StartTime: "invalid", DurationSec: 3600, Title: "不正"},
+	}
+
+	epg := domain.BuildEPG(programs, london)
+	if len(epg) != 1 {
+		t.Fatalf("期待したEPG件数: 1, 実際: %d", len(epg))
+	}
+
+	// 00:30 GMT に開始し、1時間後は夏時間の 02:30 BST になる
+	if epg[0].Start != "2025-03-30T00:30:00Z" {
+		t.Errorf("期待した開始時刻: 2025-03-30T00:30:00Z, 実際: %s", epg[0].Start)
+	}
+	if epg[0].End != "2025-03-30T02:30:00+01:00" {
+		t.Errorf("期待した終了時刻: 2025-03-30T02:30:00+01:00, 実際: %s", epg[0].End)
+	}
+}
@@ -0,0 +1,176 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/genki0524/hls_striming_go/internal/domain"
+	"github.com/genki0524/hls_striming_go/internal/repository"
+	"github.com/genki0524/hls_striming_go/internal/service"
+)
+
+func violationCodes(report domain.ValidationReport) map[string]int {
+	codes := make(map[string]int)
+	for _, violation := range report.Violations {
+		codes[violation.Code]++
+	}
+	return codes
+}
+
+func TestValidateSchedule_Valid(t *testing.T) {
+	programs := []domain.ProgramItem{
+		{ID: "a", StartTime: "2025-09-15T18:00:00+09:00", DurationSec: 1800, Type: "video", Title: "番組1"},
+		{ID: "b", StartTime: "2025-09-15T18:30:00+09:00", DurationSec: 1800, Type: "video", AssetID: "asset1"},
+	}
+
+	report := domain.ValidateSchedule(programs, func(id string) bool { return id == "asset1" })
+	if !report.Valid || len(report.Violations) != 0 {
+		t.Errorf("正しい番組表が不正と判定されました: %+v", report.Violations)
+	}
+}
+
+func TestValidateSchedule_Violations(t *testing.T) {
+	programs := []domain.ProgramItem{
+		{ID: "a", StartTime: "2025/09/15 18:00", DurationSec: 1800, Type: "video", Title: "不正な開始時刻"},
+		{ID: "b", StartTime: "2025-09-15T18:00:00+09:00", DurationSec: 0, Type: "video", Title: "放送時間ゼロ"},
+		{ID: "c", StartTime: "2025-09-15T19:00:00+09:00", DurationSec: 600, Type: "radio", Title: "不明な種別"},
+		{ID: "d", StartTime: "2025-09-15T20:00:00+09:00", DurationSec: 600, Type: "video"},
+		{ID: "e", StartTime: "2025-09-15T21:00:00+09:00", DurationSec: 600, Type: "video", AssetID: "missing"},
+	}
+
+	report := domain.ValidateSchedule(programs, func(id string) bool { return false })
+	if report.Valid {
+		t.Fatal("不正な番組表が正しいと判定されました")
+	}
+
+	codes := violationCodes(report)
+	for _, code := range []string{
+		domain.ViolationInvalidStartTime,
+		domain.ViolationInvalidDuration,
+		domain.ViolationUnknownType,
+		domain.ViolationMissingMedia,
+		domain.ViolationUnknownAsset,
+	} {
+		if codes[code] != 1 {
+			t.Errorf("違反コード%sの件数: 期待 1, 実際 %d", code, codes[code])
+		}
+	}
+}
+
+func TestValidateSchedule_BlockProgram(t *testing.T) {
+	programs := []domain.ProgramItem{
+		{ID: "a", StartTime: "2025-09-15T18:00:00+09:00", DurationSec: 1800, Type: "video", Title: "ブロック", Assets: []string{"episode1", "bumper", "episode2"}},
+		{ID: "b", StartTime: "2025-09-15T18:30:00+09:00", DurationSec: 1800, Type: "video", Assets: []string{"episode1", "missing", ""}},
+		{ID: "c", StartTime: "2025-09-15T19:00:00+09:00", DurationSec: 1800, Type: "video", AssetID: "episode1", Assets: []string{"episode2"}},
+	}
+	known := map[string]bool{"episode1": true, "episode2": true, "bumper": true}
+
+	report := domain.ValidateSchedule(programs, func(id string) bool { return known[id] })
+	if len(report.ErrorsFor("a")) != 0 {
+		t.Errorf("正しいブロック番組が不正と判定されました: %+v", report.ErrorsFor("a"))
+	}
+
+	codes := violationCodes(report)
+	if codes[domain.ViolationUnknownAsset] != 1 || codes[domain.ViolationInvalidBlock] != 2 {
+		t.Errorf("期待した違反: unknown_asset 1件, invalid_block 2件, 実際: %v", codes)
+	}
+	if codes[domain.ViolationMissingMedia] != 0 {
+		t.Error("assetsを指定した番組はtitleがなくても素材の指定として扱う想定です")
+	}
+}
+
+func TestValidateSchedule_Overlap(t *testing.T) {
+	programs := []domain.ProgramItem{
+		{ID: "long", StartTime: "2025-09-15T18:00:00+09:00", DurationSec: 7200, Type: "video", Title: "長時間番組"},
+		{ID: "short", StartTime: "2025-09-15T18:30:00+09:00", DurationSec: 600, Type: "video", Title: "短い番組"},
+		{ID: "later", StartTime: "2025-09-15T19:30:00+09:00", DurationSec: 600, Type: "video", Title: "後の番組"},
+		{ID: "after", StartTime: "2025-09-15T20:00:00+09:00", DurationSec: 600, Type: "video", Title: "終了後の番組"},
+	}
+
+	report := domain.ValidateSchedule(programs, nil)
+	if codes := violationCodes(report); codes[domain.ViolationOverlap] != 2 {
+		t.Fatalf("期待した重複件数: 2, 実際: %d", codes[domain.ViolationOverlap])
+	}
+
+	if len(report.ErrorsFor("after")) != 0 {
+		t.Error("重複していない番組にエラーが報告されています")
+	}
+	if len(report.ErrorsFor("long")) != 2 {
+		t.Errorf("長時間番組に関係する重複件数: 期待 2, 実際 %d", len(report.ErrorsFor("long")))
+	}
+}
+
+func TestScheduleService_RejectsInvalidProgram(t *testing.T) {
+	ctx := context.Background()
+	scheduleService := service.NewScheduleService(repository.NewInMemoryScheduleRepository(), nil, nil, time.UTC, domain.SystemClock{})
+	date := "2025-09-15"
+
+	_, _, err := scheduleService.AddProgramToSchedule(ctx, domain.RequestProgramItem{
+		StartTime:   "2025-09-15T19:00:00+09:00",
+		DurationSec: 1800,
+		Type:        "video",
+		Title:       "ニュース",
+	}, date, domain.AnyVersion, nil)
+	if err != nil {
+		t.Fatalf("番組の追加に失敗: %v", err)
+	}
+
+	_, _, err = scheduleService.AddProgramToSchedule(ctx, domain.RequestProgramItem{
+		StartTime:   "2025-09-15T19:15:00+09:00",
+		DurationSec: 600,
+		Type:        "video",
+		Title:       "重複番組",
+	}, date, domain.AnyVersion, nil)
+
+	var validationErr *domain.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("ValidationErrorを期待しましたが、実際: %v", err)
+	}
+	if validationErr.Violations[0].Code != domain.ViolationOverlap {
+		t.Errorf("期待した違反コード: overlap, 実際: %s", validationErr.Violations[0].Code)
+	}
+
+	schedule, err := scheduleService.GetScheduleByDate(ctx, date)
+	if err != nil {
+		t.Fatalf("番組表の取得に失敗: %v", err)
+	}
+	if len(schedule.Programs) != 1 {
+		t.Errorf("検証エラー時に番組表が変更されています: %d件", len(schedule.Programs))
+	}
+}
+
+// unavailableAssetRepository はアセットの取得に失敗するリポジトリです
+type unavailableAssetRepository struct {
+	*repository.InMemoryAssetRepository
+}
+
+func (r *unavailableAssetRepository) GetAsset(ctx context.Context, id string) (*domain.Asset, error) {
+	return nil, errors.New("アセットの取得に失敗しました")
+}
+
+func TestScheduleService_AssetLookupFailureAbortsSave(t *testing.T) {
+	ctx := context.Background()
+	assetRepo := &unavailableAssetRepository{InMemoryAssetRepository: repository.NewInMemoryAssetRepository()}
+	scheduleService := service.NewScheduleService(repository.NewInMemoryScheduleRepository(), assetRepo, nil, time.UTC, domain.SystemClock{})
+	date := "2025-09-15"
+
+	_, _, err := scheduleService.AddProgramToSchedule(ctx, domain.RequestProgramItem{
+		StartTime:   "2025-09-15T19:00:00+09:00",
+		DurationSec: 1800,
+		Type:        "video",
+		Title:       "ニュース",
+		AssetID:     "asset-1",
+	}, date, domain.AnyVersion, nil)
+	if err == nil {
+		t.Fatal("アセットの存在確認の失敗がエラーになりません")
+	}
+	var validationErr *domain.ValidationError
+	if errors.As(err, &validationErr) {
+		t.Errorf("リポジトリのエラーが検証エラーとして返されました: %v", err)
+	}
+	if _, err := scheduleService.GetScheduleByDate(ctx, date); !errors.Is(err, domain.ErrScheduleNotFound) {
+		t.Errorf("存在確認に失敗した番組が保存されています: %v", err)
+	}
+}
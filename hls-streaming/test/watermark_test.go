@@ -0,0 +1,81 @@
+package test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/genki0524/hls_striming_go/internal/domain"
+)
+
+func watermarkedPlaylist(count int) *domain.M3U8Playlist {
+	playlist := domain.NewM3U8Playlist()
+	playlist.TargetDuration = 2
+	for i := 0; i < count; i++ {
+		playlist.Segments = append(playlist.Segments, domain.M3U8Segment{Duration: 2, Filename: fmt.Sprintf("a/video%03d.ts", i)})
+	}
+	return playlist
+}
+
+func TestWatermark_ApplyFollowsSessionPattern(t *testing.T) {
+	watermark := domain.Watermark{Secret: "secret"}
+	playlist := watermarkedPlaylist(300)
+	watermark.Apply(playlist, "session1")
+
+	pattern := watermark.Pattern("session1")
+	counts := map[string]int{}
+	for i, segment := range playlist.Segments {
+		variant, _, _ := strings.Cut(segment.Filename, "/")
+		counts[variant]++
+		if expected := watermark.Variant("session1", i); variant != expected || (variant == domain.WatermarkVariantB) != pattern[i%domain.WatermarkPatternBits] {
+			t.Fatalf("%d番目: 期待 %s, 実際 %s", i, expected, segment.Filename)
+		}
+	}
+	if counts[domain.WatermarkVariantA] == 0 || counts[domain.WatermarkVariantB] == 0 {
+		t.Errorf("AとBの両方が選ばれる想定です: %+v", counts)
+	}
+
+	// 同じセッションは常に同じ並びになり、別のセッションや秘密鍵では異なる並びになる
+	if fmt.Sprint(pattern) != fmt.Sprint(watermark.Pattern("session1")) {
+		t.Error("同じセッションのビット列が一致しません")
+	}
+	if fmt.Sprint(pattern) == fmt.Sprint(watermark.Pattern("session2")) || fmt.Sprint(pattern) == fmt.Sprint(domain.Watermark{Secret: "other"}.Pattern("session1")) {
+		t.Error("セッションまたは秘密鍵が違う場合はビット列が異なる想定です")
+	}
+}
+
+func TestWatermark_ApplySkipsUnmarkedOrWithoutSession(t *testing.T) {
+	unmarked := tenSegmentPlaylist()
+	domain.Watermark{Secret: "secret"}.Apply(unmarked, "session1")
+	if unmarked.Segments[0].Filename != "segment0.ts" {
+		t.Errorf("透かしのない素材は変更しない想定です: %s", unmarked.Segments[0].Filename)
+	}
+
+	playlist := watermarkedPlaylist(20)
+	domain.Watermark{Secret: "secret"}.Apply(playlist, "")
+	domain.Watermark{}.Apply(playlist, "session1")
+	for _, segment := range playlist.Segments {
+		if !strings.HasPrefix(segment.Filename, "a/") {
+			t.Fatalf("セッションがない場合や透かしが無効な場合はAを配信する想定です: %s", segment.Filename)
+		}
+	}
+}
+
+func TestWatermark_MatchFindsLeakedSession(t *testing.T) {
+	watermark := domain.Watermark{Secret: "secret"}
+	candidates := []string{"session1", "session2", "session3", "session4"}
+
+	// session3の録画から40セグメント分（100番目から）の透かしを読み取った想定
+	observed := make(map[int]string)
+	for i := 100; i < 140; i++ {
+		observed[i] = watermark.Variant("session3", i)
+	}
+
+	matches := watermark.Match(candidates, observed)
+	if matches[0].SessionID != "session3" || matches[0].Matched != 40 || matches[0].Compared != 40 {
+		t.Errorf("流出元のセッションを特定できません: %+v", matches)
+	}
+	if matches[1].Matched == 40 {
+		t.Errorf("他のセッションが完全に一致しています: %+v", matches)
+	}
+}